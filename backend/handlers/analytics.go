@@ -0,0 +1,429 @@
+package handlers
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"wacatalogue/backend/models"
+)
+
+// User agent fragments of crawlers, link previewers and scripted clients
+var botUserAgents = []string{
+	"bot", "crawl", "spider", "slurp", "facebookexternalhit", "whatsapp/",
+	"headless", "lighthouse", "preview", "curl", "wget", "python", "go-http-client",
+	"java/", "okhttp", "axios", "node-fetch", "httpclient",
+}
+
+// Longest date range the analytics endpoints accept
+const maxAnalyticsDays = 366
+
+// Counter fields that top-products can rank by, keyed by their JSON name
+var analyticsMetrics = map[string]string{
+	"productViews":   models.EventProductView,
+	"addToCart":      models.EventAddToCart,
+	"whatsappClicks": models.EventWhatsAppClick,
+}
+
+// RecordStorefrontEvent counts a storefront event in the daily rollups. Bots,
+// prefetches and repeats from the same visitor within the deduplication window
+// are accepted but not counted, so the response never reveals the filtering.
+func RecordStorefrontEvent(db *models.Database) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// Get store ID from URL
+		vars := mux.Vars(r)
+		storeID, err := primitive.ObjectIDFromHex(vars["storeId"])
+		if err != nil {
+			RespondWithError(w, r, ErrInvalidStoreID)
+			return
+		}
+
+		var req models.AnalyticsEventRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			RespondWithError(w, r, ErrInvalidPayload.WithDetails(err.Error()))
+			return
+		}
+		if errs := req.Validate(); len(errs) > 0 {
+			RespondWithError(w, r, ErrValidationFailed.WithDetails(errs))
+			return
+		}
+
+		var productID primitive.ObjectID
+		if req.ProductID != "" {
+			productID, err = primitive.ObjectIDFromHex(req.ProductID)
+			if err != nil {
+				RespondWithError(w, r, ErrInvalidProductID)
+				return
+			}
+		}
+
+		if isBot(r) {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+
+		// Create database context
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		// Only count events for public stores and their public products
+		err = db.GetCollection(models.StoreCollection).FindOne(ctx, notDeleted(bson.M{"_id": storeID, "active": true})).Err()
+		if err == nil && !productID.IsZero() {
+			err = db.GetCollection(models.ProductCollection).FindOne(ctx, notDeleted(bson.M{"_id": productID, "store_id": storeID, "active": true})).Err()
+			if err == mongo.ErrNoDocuments {
+				RespondWithError(w, r, ErrProductNotFound)
+				return
+			}
+		}
+		if err != nil {
+			if err == mongo.ErrNoDocuments {
+				RespondWithError(w, r, ErrStoreNotFound)
+			} else {
+				RespondWithError(w, r, ErrInternal.WithMessage("Failed to find store"))
+			}
+			return
+		}
+
+		now := time.Now().UTC()
+		first, err := firstOccurrence(ctx, db, r, storeID, productID, req.Type, now)
+		if err != nil {
+			RespondWithError(w, r, ErrInternal.WithMessage("Failed to record event"))
+			return
+		}
+		if !first {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+
+		// Add the event to the day's rollup
+		_, err = db.GetCollection(models.AnalyticsDailyCollection).UpdateOne(
+			ctx,
+			bson.M{"store_id": storeID, "product_id": productID, "date": utcDay(now)},
+			bson.M{"$inc": bson.M{"counts." + req.Type: 1}},
+			options.Update().SetUpsert(true),
+		)
+		if err != nil {
+			RespondWithError(w, r, ErrInternal.WithMessage("Failed to record event"))
+			return
+		}
+
+		// Send response
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// isBot reports whether a request comes from a crawler, a link previewer, a
+// scripted client or a browser prefetch rather than a visitor
+func isBot(r *http.Request) bool {
+	if r.Header.Get("Sec-Purpose") != "" || r.Header.Get("Purpose") == "prefetch" {
+		return true
+	}
+
+	userAgent := strings.ToLower(r.UserAgent())
+	if userAgent == "" {
+		return true
+	}
+	for _, fragment := range botUserAgents {
+		if strings.Contains(userAgent, fragment) {
+			return true
+		}
+	}
+	return false
+}
+
+// firstOccurrence reports whether this is the visitor's first such event
+// within the deduplication window, remembering it if so. Visitors are told
+// apart by IP address only, which is not stored as is: the user agent is
+// up to the client, so keying on it would let one client count many times.
+// Forwarded addresses are only used from trusted proxies, see clientIP.
+func firstOccurrence(ctx context.Context, db *models.Database, r *http.Request, storeID, productID primitive.ObjectID, eventType string, now time.Time) (bool, error) {
+	sum := sha256.Sum256([]byte(strings.Join([]string{clientIP(r), storeID.Hex(), productID.Hex(), eventType}, "|")))
+	key := hex.EncodeToString(sum[:])
+
+	dedupeColl := db.GetCollection(models.AnalyticsDedupeCollection)
+	_, err := dedupeColl.InsertOne(ctx, bson.M{"_id": key, "created_at": now})
+	if err == nil {
+		return true, nil
+	}
+	if !mongo.IsDuplicateKeyError(err) {
+		return false, err
+	}
+
+	// The TTL monitor only runs once a minute, so an expired marker may still
+	// be present. Take it over if it is older than the window.
+	window := time.Duration(models.AnalyticsDedupeSeconds) * time.Second
+	result, err := dedupeColl.UpdateOne(
+		ctx,
+		bson.M{"_id": key, "created_at": bson.M{"$lt": now.Add(-window)}},
+		bson.M{"$set": bson.M{"created_at": now}},
+	)
+	if err != nil {
+		return false, err
+	}
+	return result.ModifiedCount == 1, nil
+}
+
+// GetStoreAnalytics returns daily event totals for one of the user's stores,
+// optionally narrowed to a single product
+func GetStoreAnalytics(db *models.Database) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// Get user ID from context
+		userID, err := getUserIDFromContext(r)
+		if err != nil {
+			RespondWithError(w, r, ErrUnauthenticated)
+			return
+		}
+
+		// Get store ID from URL
+		vars := mux.Vars(r)
+		storeID, err := primitive.ObjectIDFromHex(vars["id"])
+		if err != nil {
+			RespondWithError(w, r, ErrInvalidStoreID)
+			return
+		}
+
+		from, to, err := parseAnalyticsRange(r)
+		if err != nil {
+			RespondWithError(w, r, err)
+			return
+		}
+
+		filter := bson.M{"store_id": storeID, "date": bson.M{"$gte": from, "$lte": to}}
+		response := models.AnalyticsTimeSeriesResponse{
+			From: from.Format("2006-01-02"),
+			To:   to.Format("2006-01-02"),
+			Days: []models.AnalyticsDay{},
+		}
+		if value := r.URL.Query().Get("productId"); value != "" {
+			productID, err := primitive.ObjectIDFromHex(value)
+			if err != nil {
+				RespondWithError(w, r, ErrInvalidProductID)
+				return
+			}
+			filter["product_id"] = productID
+			response.ProductID = value
+		}
+
+		// Create database context
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		// Check if store exists and the user's role allows this
+		if _, err := loadStoreFor(ctx, db, storeID, userID, models.PermissionReports); err != nil {
+			RespondWithError(w, r, err)
+			return
+		}
+
+		pipeline := mongo.Pipeline{
+			{{Key: "$match", Value: filter}},
+			{{Key: "$group", Value: sumCounts("$date")}},
+		}
+		cursor, err := db.GetCollection(models.AnalyticsDailyCollection).Aggregate(ctx, pipeline)
+		if err != nil {
+			RespondWithError(w, r, ErrInternal.WithMessage("Failed to aggregate analytics"))
+			return
+		}
+		var rows []struct {
+			Date   time.Time              `bson:"_id"`
+			Counts models.AnalyticsCounts `bson:",inline"`
+		}
+		if err = cursor.All(ctx, &rows); err != nil {
+			RespondWithError(w, r, ErrInternal.WithMessage("Failed to decode analytics"))
+			return
+		}
+
+		byDay := make(map[time.Time]models.AnalyticsCounts, len(rows))
+		for _, row := range rows {
+			byDay[row.Date.UTC()] = row.Counts
+		}
+
+		// Report every day of the range, including days without events
+		for day := from; !day.After(to); day = day.AddDate(0, 0, 1) {
+			counts := byDay[day]
+			response.Days = append(response.Days, models.AnalyticsDay{Date: day.Format("2006-01-02"), Counts: counts})
+			response.Totals.StoreViews += counts.StoreViews
+			response.Totals.ProductViews += counts.ProductViews
+			response.Totals.AddToCart += counts.AddToCart
+			response.Totals.WhatsAppClicks += counts.WhatsAppClicks
+		}
+
+		// Send response
+		RespondWithJSON(w, http.StatusOK, response)
+	}
+}
+
+// GetTopProducts ranks a store's products by one of their counters
+func GetTopProducts(db *models.Database) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// Get user ID from context
+		userID, err := getUserIDFromContext(r)
+		if err != nil {
+			RespondWithError(w, r, ErrUnauthenticated)
+			return
+		}
+
+		// Get store ID from URL
+		vars := mux.Vars(r)
+		storeID, err := primitive.ObjectIDFromHex(vars["id"])
+		if err != nil {
+			RespondWithError(w, r, ErrInvalidStoreID)
+			return
+		}
+
+		from, to, err := parseAnalyticsRange(r)
+		if err != nil {
+			RespondWithError(w, r, err)
+			return
+		}
+
+		metric := r.URL.Query().Get("metric")
+		if metric == "" {
+			metric = "productViews"
+		}
+		field, ok := analyticsMetrics[metric]
+		if !ok {
+			RespondWithError(w, r, ErrValidationFailed.WithDetails([]models.FieldError{{Field: "metric", Message: "must be one of productViews, addToCart, whatsappClicks"}}))
+			return
+		}
+
+		limit, _ := strconv.ParseInt(r.URL.Query().Get("limit"), 10, 64)
+		if limit < 1 {
+			limit = 10
+		}
+		if limit > 100 {
+			limit = 100
+		}
+
+		// Create database context
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		// Check if store exists and the user's role allows this
+		if _, err := loadStoreFor(ctx, db, storeID, userID, models.PermissionReports); err != nil {
+			RespondWithError(w, r, err)
+			return
+		}
+
+		pipeline := mongo.Pipeline{
+			{{Key: "$match", Value: bson.M{
+				"store_id":   storeID,
+				"product_id": bson.M{"$ne": primitive.NilObjectID},
+				"date":       bson.M{"$gte": from, "$lte": to},
+			}}},
+			{{Key: "$group", Value: sumCounts("$product_id")}},
+			{{Key: "$match", Value: bson.M{field: bson.M{"$gt": 0}}}},
+			{{Key: "$sort", Value: bson.D{{Key: field, Value: -1}, {Key: "_id", Value: 1}}}},
+			{{Key: "$limit", Value: limit}},
+		}
+		cursor, err := db.GetCollection(models.AnalyticsDailyCollection).Aggregate(ctx, pipeline)
+		if err != nil {
+			RespondWithError(w, r, ErrInternal.WithMessage("Failed to aggregate analytics"))
+			return
+		}
+		var rows []struct {
+			ProductID primitive.ObjectID     `bson:"_id"`
+			Counts    models.AnalyticsCounts `bson:",inline"`
+		}
+		if err = cursor.All(ctx, &rows); err != nil {
+			RespondWithError(w, r, ErrInternal.WithMessage("Failed to decode analytics"))
+			return
+		}
+
+		// Look up product names, including products that have since been trashed
+		productIDs := make([]primitive.ObjectID, 0, len(rows))
+		for _, row := range rows {
+			productIDs = append(productIDs, row.ProductID)
+		}
+		names := map[primitive.ObjectID]string{}
+		if len(productIDs) > 0 {
+			cursor, err = db.GetCollection(models.ProductCollection).Find(ctx, bson.M{"_id": bson.M{"$in": productIDs}}, options.Find().SetProjection(bson.M{"name": 1}))
+			if err != nil {
+				RespondWithError(w, r, ErrInternal.WithMessage("Failed to find products"))
+				return
+			}
+			var products []models.Product
+			if err = cursor.All(ctx, &products); err != nil {
+				RespondWithError(w, r, ErrInternal.WithMessage("Failed to decode products"))
+				return
+			}
+			for _, product := range products {
+				names[product.ID] = product.Name
+			}
+		}
+
+		response := models.AnalyticsTopProductsResponse{
+			From:     from.Format("2006-01-02"),
+			To:       to.Format("2006-01-02"),
+			Metric:   metric,
+			Products: []models.AnalyticsTopProduct{},
+		}
+		for _, row := range rows {
+			response.Products = append(response.Products, models.AnalyticsTopProduct{
+				ProductID: row.ProductID,
+				Name:      names[row.ProductID],
+				Value:     row.Counts.Get(field),
+				Counts:    row.Counts,
+			})
+		}
+
+		// Send response
+		RespondWithJSON(w, http.StatusOK, response)
+	}
+}
+
+// sumCounts builds a $group stage totalling every counter by the given key
+func sumCounts(key string) bson.M {
+	group := bson.M{"_id": key}
+	for _, eventType := range []string{models.EventStoreView, models.EventProductView, models.EventAddToCart, models.EventWhatsAppClick} {
+		group[eventType] = bson.M{"$sum": "$counts." + eventType}
+	}
+	return group
+}
+
+// parseAnalyticsRange reads the from and to query parameters (YYYY-MM-DD,
+// inclusive). The default range is the last 30 days.
+func parseAnalyticsRange(r *http.Request) (time.Time, time.Time, error) {
+	query := r.URL.Query()
+	to := utcDay(time.Now())
+	if value := query.Get("to"); value != "" {
+		parsed, err := time.Parse("2006-01-02", value)
+		if err != nil {
+			return time.Time{}, time.Time{}, ErrValidationFailed.WithDetails([]models.FieldError{{Field: "to", Message: "must be a date in YYYY-MM-DD format"}})
+		}
+		to = parsed
+	}
+
+	from := to.AddDate(0, 0, -29)
+	if value := query.Get("from"); value != "" {
+		parsed, err := time.Parse("2006-01-02", value)
+		if err != nil {
+			return time.Time{}, time.Time{}, ErrValidationFailed.WithDetails([]models.FieldError{{Field: "from", Message: "must be a date in YYYY-MM-DD format"}})
+		}
+		from = parsed
+	}
+
+	if from.After(to) {
+		return time.Time{}, time.Time{}, ErrValidationFailed.WithDetails([]models.FieldError{{Field: "from", Message: "must not be after to"}})
+	}
+	if to.Sub(from) >= maxAnalyticsDays*24*time.Hour {
+		return time.Time{}, time.Time{}, ErrValidationFailed.WithDetails([]models.FieldError{{Field: "from", Message: "range must not exceed " + strconv.Itoa(maxAnalyticsDays) + " days"}})
+	}
+	return from, to, nil
+}
+
+// utcDay truncates a time to midnight UTC, the key of daily rollups
+func utcDay(t time.Time) time.Time {
+	year, month, day := t.UTC().Date()
+	return time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+}
@@ -0,0 +1,236 @@
+package handlers
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"reflect"
+	"time"
+
+	"github.com/gorilla/mux"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"wacatalogue/backend/models"
+)
+
+// Fields that change on every write and would only add noise to diffs
+var auditIgnoredFields = map[string]bool{"updated_at": true}
+
+// recordAudit appends an entry to the audit log. before and after are the
+// target document before and after the mutation; either may be nil for
+// creates and deletes. Failures are logged and never fail the request.
+func recordAudit(ctx context.Context, db *models.Database, r *http.Request, action, targetType string, targetID, storeID primitive.ObjectID, before, after interface{}) {
+	entry := newAuditEntry(r, action, targetType, targetID, storeID)
+	entry.Changes = diffDocuments(before, after)
+	insertAudit(ctx, db, entry)
+}
+
+// newAuditEntry captures the actor and request details of a mutation. Work
+// that outlives the request builds the entry up front and inserts it later.
+func newAuditEntry(r *http.Request, action, targetType string, targetID, storeID primitive.ObjectID) models.AuditEntry {
+	actorID, _ := getUserIDFromContext(r)
+	actorUsername, _ := getUsernameFromContext(r)
+
+	return models.AuditEntry{
+		ID:            primitive.NewObjectID(),
+		ActorID:       actorID,
+		ActorUsername: actorUsername,
+		Action:        action,
+		TargetType:    targetType,
+		TargetID:      targetID,
+		StoreID:       storeID,
+		Changes:       map[string]models.FieldChange{},
+		IP:            clientIP(r),
+		UserAgent:     r.UserAgent(),
+		RequestID:     getRequestIDFromContext(r),
+		CreatedAt:     time.Now(),
+	}
+}
+
+// insertAudit writes an entry to the audit log, logging failures
+func insertAudit(ctx context.Context, db *models.Database, entry models.AuditEntry) {
+	if _, err := db.GetCollection(models.AuditLogCollection).InsertOne(ctx, entry); err != nil {
+		log.Printf("Failed to write audit entry %s for %s %s: %v", entry.Action, entry.TargetType, entry.TargetID.Hex(), err)
+	}
+}
+
+// diffDocuments returns the fields whose BSON values differ between two documents
+func diffDocuments(before, after interface{}) map[string]models.FieldChange {
+	beforeDoc := toBSONMap(before)
+	afterDoc := toBSONMap(after)
+
+	changes := map[string]models.FieldChange{}
+	for key, value := range beforeDoc {
+		if auditIgnoredFields[key] {
+			continue
+		}
+		if newValue, ok := afterDoc[key]; !ok || !reflect.DeepEqual(value, newValue) {
+			changes[key] = models.FieldChange{Before: value, After: afterDoc[key]}
+		}
+	}
+	for key, value := range afterDoc {
+		if auditIgnoredFields[key] {
+			continue
+		}
+		if _, ok := beforeDoc[key]; !ok {
+			changes[key] = models.FieldChange{Before: nil, After: value}
+		}
+	}
+	return changes
+}
+
+// toBSONMap converts a document to a map using its BSON field names
+func toBSONMap(doc interface{}) bson.M {
+	result := bson.M{}
+	if doc == nil {
+		return result
+	}
+	if v := reflect.ValueOf(doc); v.Kind() == reflect.Ptr && v.IsNil() {
+		return result
+	}
+	data, err := bson.Marshal(doc)
+	if err != nil {
+		return result
+	}
+	if err := bson.Unmarshal(data, &result); err != nil {
+		return bson.M{}
+	}
+	return result
+}
+
+// findAuditEntries runs a filtered, paginated audit log query
+func findAuditEntries(ctx context.Context, db *models.Database, filter bson.M, page, limit int64) (models.AuditLogResponse, error) {
+	auditColl := db.GetCollection(models.AuditLogCollection)
+
+	total, err := auditColl.CountDocuments(ctx, filter)
+	if err != nil {
+		return models.AuditLogResponse{}, ErrInternal.WithMessage("Failed to count audit entries")
+	}
+
+	findOptions := options.Find()
+	findOptions.SetSort(bson.D{{Key: "created_at", Value: -1}})
+	findOptions.SetSkip((page - 1) * limit)
+	findOptions.SetLimit(limit)
+
+	cursor, err := auditColl.Find(ctx, filter, findOptions)
+	if err != nil {
+		return models.AuditLogResponse{}, ErrInternal.WithMessage("Failed to find audit entries")
+	}
+	defer cursor.Close(ctx)
+
+	entries := []models.AuditEntry{}
+	if err = cursor.All(ctx, &entries); err != nil {
+		return models.AuditLogResponse{}, ErrInternal.WithMessage("Failed to decode audit entries")
+	}
+
+	return models.AuditLogResponse{Entries: entries, Total: total, Page: page, Limit: limit}, nil
+}
+
+// GetStoreAuditLog returns the mutation history of one of the user's stores
+func GetStoreAuditLog(db *models.Database) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// Get user ID from context
+		userID, err := getUserIDFromContext(r)
+		if err != nil {
+			RespondWithError(w, r, ErrUnauthenticated)
+			return
+		}
+
+		// Get store ID from URL
+		vars := mux.Vars(r)
+		storeID, err := primitive.ObjectIDFromHex(vars["id"])
+		if err != nil {
+			RespondWithError(w, r, ErrInvalidStoreID)
+			return
+		}
+
+		// Create database context
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		// Check if store exists and the user's role allows this
+		if _, err := loadStoreFor(ctx, db, storeID, userID, models.PermissionReports); err != nil {
+			RespondWithError(w, r, err)
+			return
+		}
+
+		filter := bson.M{"store_id": storeID}
+		if action := r.URL.Query().Get("action"); action != "" {
+			filter["action"] = action
+		}
+
+		page, limit := parsePagination(r, 50, 200)
+		response, err := findAuditEntries(ctx, db, filter, page, limit)
+		if err != nil {
+			RespondWithError(w, r, err)
+			return
+		}
+
+		// Send response
+		RespondWithJSON(w, http.StatusOK, response)
+	}
+}
+
+// GetAuditLog returns audit entries across all stores for admins.
+// Supported filters: actorId, storeId, targetType, targetId, action, from, to (RFC 3339).
+func GetAuditLog(db *models.Database) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		filter := bson.M{}
+
+		// ID filters
+		for param, field := range map[string]string{"actorId": "actor_id", "storeId": "store_id", "targetId": "target_id"} {
+			value := query.Get(param)
+			if value == "" {
+				continue
+			}
+			id, err := primitive.ObjectIDFromHex(value)
+			if err != nil {
+				RespondWithError(w, r, ErrValidationFailed.WithDetails([]models.FieldError{{Field: param, Message: "must be a valid ID"}}))
+				return
+			}
+			filter[field] = id
+		}
+
+		if targetType := query.Get("targetType"); targetType != "" {
+			filter["target_type"] = targetType
+		}
+		if action := query.Get("action"); action != "" {
+			filter["action"] = action
+		}
+
+		// Date range filters
+		createdAt := bson.M{}
+		for param, operator := range map[string]string{"from": "$gte", "to": "$lte"} {
+			value := query.Get(param)
+			if value == "" {
+				continue
+			}
+			t, err := time.Parse(time.RFC3339, value)
+			if err != nil {
+				RespondWithError(w, r, ErrValidationFailed.WithDetails([]models.FieldError{{Field: param, Message: "must be an RFC 3339 timestamp"}}))
+				return
+			}
+			createdAt[operator] = t
+		}
+		if len(createdAt) > 0 {
+			filter["created_at"] = createdAt
+		}
+
+		// Create database context
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		page, limit := parsePagination(r, 50, 200)
+		response, err := findAuditEntries(ctx, db, filter, page, limit)
+		if err != nil {
+			RespondWithError(w, r, err)
+			return
+		}
+
+		// Send response
+		RespondWithJSON(w, http.StatusOK, response)
+	}
+}
@@ -0,0 +1,508 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"wacatalogue/backend/models"
+)
+
+// GetStoreCoupons lists the coupons of one of the user's stores
+func GetStoreCoupons(db *models.Database) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// Get user ID from context
+		userID, err := getUserIDFromContext(r)
+		if err != nil {
+			RespondWithError(w, r, ErrUnauthenticated)
+			return
+		}
+
+		// Get store ID from URL
+		vars := mux.Vars(r)
+		storeID, err := primitive.ObjectIDFromHex(vars["id"])
+		if err != nil {
+			RespondWithError(w, r, ErrInvalidStoreID)
+			return
+		}
+
+		// Create database context
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		// Check if store exists and the user's role allows this
+		store, err := loadStoreFor(ctx, db, storeID, userID, models.PermissionSettings)
+		if err != nil {
+			RespondWithError(w, r, err)
+			return
+		}
+
+		findOptions := options.Find().SetSort(bson.D{{Key: "created_at", Value: -1}})
+		cursor, err := db.GetCollection(models.CouponCollection).Find(ctx, bson.M{"store_id": storeID}, findOptions)
+		if err != nil {
+			RespondWithError(w, r, ErrInternal.WithMessage("Failed to find coupons"))
+			return
+		}
+		coupons := []models.Coupon{}
+		if err = cursor.All(ctx, &coupons); err != nil {
+			RespondWithError(w, r, ErrInternal.WithMessage("Failed to decode coupons"))
+			return
+		}
+		currency := models.CurrencyOf(store.Currency)
+		for i := range coupons {
+			coupons[i].ApplyCurrency(currency)
+		}
+
+		// Send response
+		RespondWithJSON(w, http.StatusOK, coupons)
+	}
+}
+
+// CreateCoupon adds a coupon to one of the user's stores
+func CreateCoupon(db *models.Database) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// Get user ID from context
+		userID, err := getUserIDFromContext(r)
+		if err != nil {
+			RespondWithError(w, r, ErrUnauthenticated)
+			return
+		}
+
+		// Get store ID from URL
+		vars := mux.Vars(r)
+		storeID, err := primitive.ObjectIDFromHex(vars["id"])
+		if err != nil {
+			RespondWithError(w, r, ErrInvalidStoreID)
+			return
+		}
+
+		var req models.CouponRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			RespondWithError(w, r, ErrInvalidPayload.WithDetails(err.Error()))
+			return
+		}
+
+		// Create database context
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		// Check if store exists and the user's role allows this
+		store, err := loadStoreFor(ctx, db, storeID, userID, models.PermissionSettings)
+		if err != nil {
+			RespondWithError(w, r, err)
+			return
+		}
+
+		// Amounts are in the store's currency
+		currency := models.CurrencyOf(store.Currency)
+		req.ResolveAmounts(currency)
+		if errs := req.Validate(); len(errs) > 0 {
+			RespondWithError(w, r, ErrValidationFailed.WithDetails(errs))
+			return
+		}
+
+		now := time.Now()
+		coupon := models.Coupon{
+			ID:        primitive.NewObjectID(),
+			StoreID:   storeID,
+			Active:    true,
+			CreatedAt: now,
+			UpdatedAt: now,
+		}
+		applyCouponRequest(&coupon, req)
+
+		// Insert coupon
+		if _, err = db.GetCollection(models.CouponCollection).InsertOne(ctx, coupon); err != nil {
+			if mongo.IsDuplicateKeyError(err) {
+				RespondWithError(w, r, ErrCouponExists)
+			} else {
+				RespondWithError(w, r, ErrInternal.WithMessage("Failed to create coupon"))
+			}
+			return
+		}
+
+		// Record mutation
+		recordAudit(ctx, db, r, models.AuditCouponCreate, "coupon", coupon.ID, storeID, nil, &coupon)
+
+		// Send response
+		coupon.ApplyCurrency(currency)
+		RespondWithJSON(w, http.StatusCreated, coupon)
+	}
+}
+
+// UpdateCoupon replaces the settings of one of the user's coupons. The usage
+// count is kept.
+func UpdateCoupon(db *models.Database) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// Get user ID from context
+		userID, err := getUserIDFromContext(r)
+		if err != nil {
+			RespondWithError(w, r, ErrUnauthenticated)
+			return
+		}
+
+		// Get coupon ID from URL
+		vars := mux.Vars(r)
+		couponID, err := primitive.ObjectIDFromHex(vars["id"])
+		if err != nil {
+			RespondWithError(w, r, ErrInvalidCouponID)
+			return
+		}
+
+		var req models.CouponRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			RespondWithError(w, r, ErrInvalidPayload.WithDetails(err.Error()))
+			return
+		}
+
+		// Create database context
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		coupon, store, err := loadCouponFor(ctx, db, couponID, userID)
+		if err != nil {
+			RespondWithError(w, r, err)
+			return
+		}
+
+		// Amounts are in the store's currency
+		currency := models.CurrencyOf(store.Currency)
+		req.ResolveAmounts(currency)
+		if errs := req.Validate(); len(errs) > 0 {
+			RespondWithError(w, r, ErrValidationFailed.WithDetails(errs))
+			return
+		}
+
+		updated := coupon
+		applyCouponRequest(&updated, req)
+		updated.UpdatedAt = time.Now()
+
+		// Update everything but the usage count, which checkouts change concurrently
+		couponsColl := db.GetCollection(models.CouponCollection)
+		_, err = couponsColl.UpdateOne(
+			ctx,
+			bson.M{"_id": couponID},
+			bson.M{"$set": bson.M{
+				"code":               updated.Code,
+				"description":        updated.Description,
+				"type":               updated.Type,
+				"percent":            updated.Percent,
+				"amount_minor":       updated.AmountMinor,
+				"max_discount_minor": updated.MaxDiscountMinor,
+				"min_order_minor":    updated.MinOrderMinor,
+				"starts_at":          updated.StartsAt,
+				"ends_at":            updated.EndsAt,
+				"usage_limit":        updated.UsageLimit,
+				"per_customer_limit": updated.PerCustomerLimit,
+				"product_ids":        updated.ProductIDs,
+				"categories":         updated.Categories,
+				"active":             updated.Active,
+				"updated_at":         updated.UpdatedAt,
+			}},
+		)
+		if err != nil {
+			if mongo.IsDuplicateKeyError(err) {
+				RespondWithError(w, r, ErrCouponExists)
+			} else {
+				RespondWithError(w, r, ErrInternal.WithMessage("Failed to update coupon"))
+			}
+			return
+		}
+
+		// Get updated coupon
+		var updatedCoupon models.Coupon
+		if err = couponsColl.FindOne(ctx, bson.M{"_id": couponID}).Decode(&updatedCoupon); err != nil {
+			RespondWithError(w, r, ErrInternal.WithMessage("Failed to retrieve updated coupon"))
+			return
+		}
+
+		// Record mutation
+		recordAudit(ctx, db, r, models.AuditCouponUpdate, "coupon", couponID, coupon.StoreID, &coupon, &updatedCoupon)
+
+		// Send response
+		updatedCoupon.ApplyCurrency(currency)
+		RespondWithJSON(w, http.StatusOK, updatedCoupon)
+	}
+}
+
+// DeleteCoupon removes one of the user's coupons. Orders keep the code they used.
+func DeleteCoupon(db *models.Database) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// Get user ID from context
+		userID, err := getUserIDFromContext(r)
+		if err != nil {
+			RespondWithError(w, r, ErrUnauthenticated)
+			return
+		}
+
+		// Get coupon ID from URL
+		vars := mux.Vars(r)
+		couponID, err := primitive.ObjectIDFromHex(vars["id"])
+		if err != nil {
+			RespondWithError(w, r, ErrInvalidCouponID)
+			return
+		}
+
+		// Create database context
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		coupon, _, err := loadCouponFor(ctx, db, couponID, userID)
+		if err != nil {
+			RespondWithError(w, r, err)
+			return
+		}
+
+		// Delete coupon and its per-customer counters
+		if _, err = db.GetCollection(models.CouponCollection).DeleteOne(ctx, bson.M{"_id": couponID}); err != nil {
+			RespondWithError(w, r, ErrInternal.WithMessage("Failed to delete coupon"))
+			return
+		}
+		if _, err = db.GetCollection(models.CouponRedemptionCollection).DeleteMany(ctx, bson.M{"coupon_id": couponID}); err != nil {
+			log.Printf("Failed to delete redemptions of coupon %s: %v", couponID.Hex(), err)
+		}
+
+		// Record mutation
+		recordAudit(ctx, db, r, models.AuditCouponDelete, "coupon", couponID, coupon.StoreID, &coupon, nil)
+
+		// Send response
+		RespondWithJSON(w, http.StatusOK, map[string]string{"message": "Coupon deleted successfully"})
+	}
+}
+
+// applyCouponRequest copies the settings of a request onto a coupon
+func applyCouponRequest(coupon *models.Coupon, req models.CouponRequest) {
+	coupon.Code = strings.ToUpper(req.Code)
+	coupon.Description = req.Description
+	coupon.Type = req.Type
+	coupon.Percent = 0
+	coupon.AmountMinor = 0
+	switch req.Type {
+	case models.CouponPercentage:
+		coupon.Percent = req.Percent
+	case models.CouponFixed:
+		coupon.AmountMinor = req.AmountMinor
+	}
+	coupon.MaxDiscountMinor = req.MaxDiscountMinor
+	coupon.MinOrderMinor = req.MinOrderMinor
+	coupon.StartsAt = req.StartsAt
+	coupon.EndsAt = req.EndsAt
+	coupon.UsageLimit = req.UsageLimit
+	coupon.PerCustomerLimit = req.PerCustomerLimit
+	coupon.Categories = req.Categories
+	coupon.ProductIDs = nil
+	for _, id := range req.ProductIDs {
+		productID, _ := primitive.ObjectIDFromHex(id) // Checked by Validate
+		coupon.ProductIDs = append(coupon.ProductIDs, productID)
+	}
+	if req.Active != nil {
+		coupon.Active = *req.Active
+	}
+}
+
+// findCouponByCode finds an active coupon of a store by its code
+func findCouponByCode(ctx context.Context, db *models.Database, storeID primitive.ObjectID, code string) (models.Coupon, error) {
+	var coupon models.Coupon
+	err := db.GetCollection(models.CouponCollection).FindOne(ctx, bson.M{
+		"store_id": storeID,
+		"code":     strings.ToUpper(strings.TrimSpace(code)),
+		"active":   true,
+	}).Decode(&coupon)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return coupon, ErrCouponInvalid.WithMessage("This coupon code is not valid")
+		}
+		return coupon, ErrInternal.WithMessage("Failed to find coupon")
+	}
+	return coupon, nil
+}
+
+// couponDiscount checks that a coupon applies to an order and returns the
+// amount it takes off. Scoped coupons only discount the matching items.
+func couponDiscount(coupon models.Coupon, order models.Order, now time.Time) (int64, error) {
+	if coupon.StartsAt != nil && now.Before(*coupon.StartsAt) {
+		return 0, ErrCouponInvalid.WithMessage("This coupon is not valid yet")
+	}
+	if coupon.EndsAt != nil && !now.Before(*coupon.EndsAt) {
+		return 0, ErrCouponInvalid.WithMessage("This coupon has expired")
+	}
+	if coupon.UsageLimit > 0 && coupon.UsedCount >= coupon.UsageLimit {
+		return 0, ErrCouponInvalid.WithMessage("This coupon has been fully redeemed")
+	}
+	if order.SubtotalMinor < coupon.MinOrderMinor {
+		currency := models.CurrencyOf(order.Currency)
+		return 0, ErrCouponInvalid.WithMessage("This coupon requires a minimum order of " + currency.Format(coupon.MinOrderMinor))
+	}
+
+	scoped := len(coupon.ProductIDs) > 0 || len(coupon.Categories) > 0
+	var eligible int64
+	for _, item := range order.Items {
+		if !scoped || couponCovers(coupon, item) {
+			eligible += item.SubtotalMinor
+		}
+	}
+	if eligible == 0 {
+		return 0, ErrCouponInvalid.WithMessage("This coupon does not apply to any product in the order")
+	}
+
+	var discount int64
+	switch coupon.Type {
+	case models.CouponPercentage:
+		discount = models.PercentOf(eligible, coupon.Percent)
+		if coupon.MaxDiscountMinor > 0 && discount > coupon.MaxDiscountMinor {
+			discount = coupon.MaxDiscountMinor
+		}
+	case models.CouponFixed:
+		discount = coupon.AmountMinor
+	}
+	if discount > eligible {
+		discount = eligible
+	}
+	return discount, nil
+}
+
+// couponCovers reports whether a scoped coupon applies to an order item
+func couponCovers(coupon models.Coupon, item models.OrderItem) bool {
+	for _, id := range coupon.ProductIDs {
+		if id == item.ProductID {
+			return true
+		}
+	}
+	for _, category := range coupon.Categories {
+		if item.Category != "" && strings.EqualFold(category, item.Category) {
+			return true
+		}
+	}
+	return false
+}
+
+// redeemCoupon atomically counts a use of a coupon, enforcing the per-customer
+// and overall limits against concurrent checkouts
+func redeemCoupon(ctx context.Context, db *models.Database, coupon models.Coupon, phone string) error {
+	redemptionsColl := db.GetCollection(models.CouponRedemptionCollection)
+
+	if coupon.PerCustomerLimit > 0 {
+		if phone == "" {
+			return ErrCouponInvalid.WithMessage("Enter your WhatsApp number to use this coupon")
+		}
+
+		// When the customer is at the limit the filter doesn't match, and the
+		// upsert collides with their existing counter on the unique index
+		_, err := redemptionsColl.UpdateOne(
+			ctx,
+			bson.M{"coupon_id": coupon.ID, "phone": phone, "count": bson.M{"$lt": coupon.PerCustomerLimit}},
+			bson.M{"$inc": bson.M{"count": 1}},
+			options.Update().SetUpsert(true),
+		)
+		if mongo.IsDuplicateKeyError(err) {
+			return ErrCouponInvalid.WithMessage("You have already used this coupon the maximum number of times")
+		} else if err != nil {
+			return ErrInternal.WithMessage("Failed to redeem coupon")
+		}
+	}
+
+	// The limit is read from the stored coupon so concurrent edits are respected
+	result, err := db.GetCollection(models.CouponCollection).UpdateOne(
+		ctx,
+		bson.M{"_id": coupon.ID, "active": true, "$expr": bson.M{"$or": bson.A{
+			bson.M{"$eq": bson.A{"$usage_limit", 0}},
+			bson.M{"$lt": bson.A{"$used_count", "$usage_limit"}},
+		}}},
+		bson.M{"$inc": bson.M{"used_count": 1}},
+	)
+	if err != nil || result.MatchedCount == 0 {
+		if coupon.PerCustomerLimit > 0 {
+			releaseCustomerRedemption(ctx, db, coupon.ID, phone)
+		}
+		if err != nil {
+			return ErrInternal.WithMessage("Failed to redeem coupon")
+		}
+		return ErrCouponInvalid.WithMessage("This coupon has been fully redeemed")
+	}
+	return nil
+}
+
+// releaseCoupon undoes a redemption when the order could not be placed or was
+// cancelled. The phone is the one the per-customer limit was counted under,
+// empty when it wasn't.
+func releaseCoupon(ctx context.Context, db *models.Database, couponID primitive.ObjectID, phone string) {
+	_, err := db.GetCollection(models.CouponCollection).UpdateOne(ctx, bson.M{"_id": couponID}, bson.M{"$inc": bson.M{"used_count": -1}})
+	if err != nil {
+		log.Printf("Failed to release coupon %s: %v", couponID.Hex(), err)
+	}
+	releaseCustomerRedemption(ctx, db, couponID, phone)
+}
+
+// releaseCustomerRedemption undoes the per-customer part of a redemption
+func releaseCustomerRedemption(ctx context.Context, db *models.Database, couponID primitive.ObjectID, phone string) {
+	if phone == "" {
+		return
+	}
+	_, err := db.GetCollection(models.CouponRedemptionCollection).UpdateOne(
+		ctx,
+		bson.M{"coupon_id": couponID, "phone": phone},
+		bson.M{"$inc": bson.M{"count": -1}},
+	)
+	if err != nil {
+		log.Printf("Failed to release redemption of coupon %s: %v", couponID.Hex(), err)
+	}
+}
+
+// releaseOrderCoupon gives back the coupon use of a cancelled order, once.
+// Marking the order first keeps concurrent cancellations from both releasing.
+func releaseOrderCoupon(ctx context.Context, db *models.Database, order models.Order) error {
+	if order.CouponID.IsZero() {
+		return nil
+	}
+	result, err := db.GetCollection(models.OrderCollection).UpdateOne(
+		ctx,
+		bson.M{"_id": order.ID, "coupon_voided": bson.M{"$ne": true}},
+		bson.M{"$set": bson.M{"coupon_voided": true}},
+	)
+	if err != nil {
+		return err
+	}
+	if result.ModifiedCount > 0 {
+		releaseCoupon(ctx, db, order.CouponID, order.CouponPhone)
+	}
+	return nil
+}
+
+// recountOrderCoupon counts the coupon use of a cancelled order again when it
+// is reopened. The limits were met when the order was placed, so they are not
+// checked again.
+func recountOrderCoupon(ctx context.Context, db *models.Database, order models.Order) error {
+	if order.CouponID.IsZero() {
+		return nil
+	}
+	result, err := db.GetCollection(models.OrderCollection).UpdateOne(
+		ctx,
+		bson.M{"_id": order.ID, "coupon_voided": true},
+		bson.M{"$unset": bson.M{"coupon_voided": ""}},
+	)
+	if err != nil || result.ModifiedCount == 0 {
+		return err
+	}
+
+	if _, err := db.GetCollection(models.CouponCollection).UpdateOne(ctx, bson.M{"_id": order.CouponID}, bson.M{"$inc": bson.M{"used_count": 1}}); err != nil {
+		return err
+	}
+	if order.CouponPhone != "" {
+		_, err = db.GetCollection(models.CouponRedemptionCollection).UpdateOne(
+			ctx,
+			bson.M{"coupon_id": order.CouponID, "phone": order.CouponPhone},
+			bson.M{"$inc": bson.M{"count": 1}},
+			options.Update().SetUpsert(true),
+		)
+	}
+	return err
+}
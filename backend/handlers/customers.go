@@ -0,0 +1,384 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"wacatalogue/backend/models"
+)
+
+// Customer order history shows at most this many orders
+const customerOrderLimit = 50
+
+// GetStoreCustomers lists the customers of one of the user's stores, most
+// recent first. The q parameter searches names and tags, or phone numbers
+// when it is a number.
+func GetStoreCustomers(db *models.Database) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// Get user ID from context
+		userID, err := getUserIDFromContext(r)
+		if err != nil {
+			RespondWithError(w, r, ErrUnauthenticated)
+			return
+		}
+
+		// Get store ID from URL
+		vars := mux.Vars(r)
+		storeID, err := primitive.ObjectIDFromHex(vars["id"])
+		if err != nil {
+			RespondWithError(w, r, ErrInvalidStoreID)
+			return
+		}
+
+		filter := bson.M{"store_id": storeID}
+		if q := strings.TrimSpace(r.URL.Query().Get("q")); q != "" {
+			filter["$or"] = customerSearch(q)
+		}
+
+		// Create database context
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		// Check if store exists and the user's role allows this
+		store, err := loadStoreFor(ctx, db, storeID, userID, models.PermissionOrders)
+		if err != nil {
+			RespondWithError(w, r, err)
+			return
+		}
+
+		customersColl := db.GetCollection(models.CustomerCollection)
+		total, err := customersColl.CountDocuments(ctx, filter)
+		if err != nil {
+			RespondWithError(w, r, ErrInternal.WithMessage("Failed to count customers"))
+			return
+		}
+
+		page, limit := parsePagination(r, 20, 100)
+		findOptions := options.Find()
+		findOptions.SetSort(bson.D{{Key: "last_order_at", Value: -1}})
+		findOptions.SetSkip((page - 1) * limit)
+		findOptions.SetLimit(limit)
+
+		cursor, err := customersColl.Find(ctx, filter, findOptions)
+		if err != nil {
+			RespondWithError(w, r, ErrInternal.WithMessage("Failed to find customers"))
+			return
+		}
+		customers := []models.Customer{}
+		if err = cursor.All(ctx, &customers); err != nil {
+			RespondWithError(w, r, ErrInternal.WithMessage("Failed to decode customers"))
+			return
+		}
+		if err := applyLifetimeValues(ctx, db, customers, models.CurrencyOf(store.Currency)); err != nil {
+			RespondWithError(w, r, err)
+			return
+		}
+
+		// Send response
+		RespondWithJSON(w, http.StatusOK, models.CustomerListResponse{Customers: customers, Total: total, Page: page, Limit: limit})
+	}
+}
+
+// GetCustomer returns one of the user's customers with their order history
+func GetCustomer(db *models.Database) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// Get user ID from context
+		userID, err := getUserIDFromContext(r)
+		if err != nil {
+			RespondWithError(w, r, ErrUnauthenticated)
+			return
+		}
+
+		// Get customer ID from URL
+		vars := mux.Vars(r)
+		customerID, err := primitive.ObjectIDFromHex(vars["id"])
+		if err != nil {
+			RespondWithError(w, r, ErrInvalidCustomerID)
+			return
+		}
+
+		// Create database context
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		customer, store, err := loadCustomerFor(ctx, db, customerID, userID)
+		if err != nil {
+			RespondWithError(w, r, err)
+			return
+		}
+
+		findOptions := options.Find().SetSort(bson.D{{Key: "created_at", Value: -1}}).SetLimit(customerOrderLimit)
+		cursor, err := db.GetCollection(models.OrderCollection).Find(ctx, bson.M{"customer_id": customerID}, findOptions)
+		if err != nil {
+			RespondWithError(w, r, ErrInternal.WithMessage("Failed to find orders"))
+			return
+		}
+		orders := []models.Order{}
+		if err = cursor.All(ctx, &orders); err != nil {
+			RespondWithError(w, r, ErrInternal.WithMessage("Failed to decode orders"))
+			return
+		}
+
+		customers := []models.Customer{customer}
+		if err := applyLifetimeValues(ctx, db, customers, models.CurrencyOf(store.Currency)); err != nil {
+			RespondWithError(w, r, err)
+			return
+		}
+
+		// Send response
+		models.ApplyCurrency(orders)
+		RespondWithJSON(w, http.StatusOK, models.CustomerDetail{Customer: customers[0], Orders: orders})
+	}
+}
+
+// UpdateCustomer sets the owner's name, notes and tags for one of their customers
+func UpdateCustomer(db *models.Database) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// Get user ID from context
+		userID, err := getUserIDFromContext(r)
+		if err != nil {
+			RespondWithError(w, r, ErrUnauthenticated)
+			return
+		}
+
+		// Get customer ID from URL
+		vars := mux.Vars(r)
+		customerID, err := primitive.ObjectIDFromHex(vars["id"])
+		if err != nil {
+			RespondWithError(w, r, ErrInvalidCustomerID)
+			return
+		}
+
+		var req models.UpdateCustomerRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			RespondWithError(w, r, ErrInvalidPayload.WithDetails(err.Error()))
+			return
+		}
+		if errs := req.Validate(); len(errs) > 0 {
+			RespondWithError(w, r, ErrValidationFailed.WithDetails(errs))
+			return
+		}
+
+		// Create database context
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		customer, store, err := loadCustomerFor(ctx, db, customerID, userID)
+		if err != nil {
+			RespondWithError(w, r, err)
+			return
+		}
+
+		// Build update document
+		update := bson.M{"updated_at": time.Now()}
+		if req.Name != nil {
+			update["name"] = strings.TrimSpace(*req.Name)
+		}
+		if req.Notes != nil {
+			update["notes"] = strings.TrimSpace(*req.Notes)
+		}
+		if req.Tags != nil {
+			tags := make([]string, len(*req.Tags))
+			for i, tag := range *req.Tags {
+				tags[i] = strings.TrimSpace(tag)
+			}
+			update["tags"] = tags
+		}
+
+		// Update customer
+		customersColl := db.GetCollection(models.CustomerCollection)
+		if _, err = customersColl.UpdateOne(ctx, bson.M{"_id": customerID}, bson.M{"$set": update}); err != nil {
+			RespondWithError(w, r, ErrInternal.WithMessage("Failed to update customer"))
+			return
+		}
+
+		// Get updated customer
+		var updatedCustomer models.Customer
+		if err = customersColl.FindOne(ctx, bson.M{"_id": customerID}).Decode(&updatedCustomer); err != nil {
+			RespondWithError(w, r, ErrInternal.WithMessage("Failed to retrieve updated customer"))
+			return
+		}
+
+		// Record mutation
+		recordAudit(ctx, db, r, models.AuditCustomerUpdate, "customer", customerID, customer.StoreID, &customer, &updatedCustomer)
+
+		customers := []models.Customer{updatedCustomer}
+		if err := applyLifetimeValues(ctx, db, customers, models.CurrencyOf(store.Currency)); err != nil {
+			RespondWithError(w, r, err)
+			return
+		}
+
+		// Send response
+		RespondWithJSON(w, http.StatusOK, customers[0])
+	}
+}
+
+// customerSearch builds the conditions matching a search term: a phone
+// number fragment, or part of a name or tag
+func customerSearch(q string) []bson.M {
+	digits := phoneDigits(q)
+	if digits != "" && strings.Trim(q, "0123456789+-() ") == "" {
+		// Local numbers start with 0 where the stored number has a country code
+		digits = strings.TrimLeft(digits, "0")
+		return []bson.M{{"phone": bson.M{"$regex": regexp.QuoteMeta(digits)}}}
+	}
+	pattern := primitive.Regex{Pattern: regexp.QuoteMeta(q), Options: "i"}
+	return []bson.M{{"name": pattern}, {"tags": pattern}}
+}
+
+// applyLifetimeValues fills in what each customer has spent on confirmed and
+// completed orders
+func applyLifetimeValues(ctx context.Context, db *models.Database, customers []models.Customer, currency models.Currency) error {
+	if len(customers) == 0 {
+		return nil
+	}
+	ids := make([]primitive.ObjectID, len(customers))
+	for i, customer := range customers {
+		ids[i] = customer.ID
+	}
+
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{"customer_id": bson.M{"$in": ids}, "status": bson.M{"$in": models.RevenueStatuses}}}},
+		{{Key: "$group", Value: bson.M{"_id": "$customer_id", "total": bson.M{"$sum": "$total_minor"}}}},
+	}
+	cursor, err := db.GetCollection(models.OrderCollection).Aggregate(ctx, pipeline)
+	if err != nil {
+		return ErrInternal.WithMessage("Failed to total customer orders")
+	}
+	var totals []struct {
+		ID    primitive.ObjectID `bson:"_id"`
+		Total int64              `bson:"total"`
+	}
+	if err := cursor.All(ctx, &totals); err != nil {
+		return ErrInternal.WithMessage("Failed to decode customer totals")
+	}
+
+	values := make(map[primitive.ObjectID]int64, len(totals))
+	for _, total := range totals {
+		values[total.ID] = total.Total
+	}
+	for i := range customers {
+		customers[i].LifetimeValueMinor = values[customers[i].ID]
+		customers[i].ApplyCurrency(currency)
+	}
+	return nil
+}
+
+// recordCustomer creates or updates the customer of a placed order, keyed by
+// their phone number in international form, and links the order to them.
+// Orders without a phone number have no customer. The name given at checkout
+// only names new customers, so it never replaces the one the owner set.
+func recordCustomer(ctx context.Context, db *models.Database, store models.Store, order *models.Order) error {
+	phone := internationalPhone(order.CustomerPhone, store.WhatsappNumber)
+	if phone == "" {
+		return nil
+	}
+
+	setOnInsert := bson.M{"_id": primitive.NewObjectID(), "created_at": order.CreatedAt}
+	if order.CustomerName != "" {
+		setOnInsert["name"] = order.CustomerName
+	}
+	update := bson.M{
+		"$set":         bson.M{"last_order_at": order.CreatedAt, "updated_at": order.CreatedAt},
+		"$inc":         bson.M{"order_count": 1},
+		"$setOnInsert": setOnInsert,
+	}
+	filter := bson.M{"store_id": order.StoreID, "phone": phone}
+	findOptions := options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After)
+
+	customersColl := db.GetCollection(models.CustomerCollection)
+	var customer models.Customer
+	err := customersColl.FindOneAndUpdate(ctx, filter, update, findOptions).Decode(&customer)
+	if mongo.IsDuplicateKeyError(err) {
+		// Another checkout created the customer first
+		err = customersColl.FindOneAndUpdate(ctx, filter, update, findOptions).Decode(&customer)
+	}
+	if err != nil {
+		return err
+	}
+	order.CustomerID = customer.ID
+	if _, err := db.GetCollection(models.OrderCollection).UpdateOne(ctx, bson.M{"_id": order.ID}, bson.M{"$set": bson.M{"customer_id": customer.ID}}); err != nil {
+		return err
+	}
+
+	// Save the address the order went to, most recently used first
+	var address models.CustomerAddress
+	switch {
+	case order.Delivery != nil:
+		location := order.Delivery.Location
+		address = models.CustomerAddress{Address: order.Delivery.Address, Location: &location}
+	case order.Shipping != nil:
+		address = models.CustomerAddress{Address: order.Shipping.Address, Destination: order.Shipping.Destination}
+	default:
+		return nil
+	}
+	if address.Address == "" && address.Destination == "" {
+		return nil
+	}
+	address.LastUsedAt = order.CreatedAt
+	addresses := []models.CustomerAddress{address}
+	for _, saved := range customer.Addresses {
+		if !saved.SameAs(address) && len(addresses) < models.MaxCustomerAddresses {
+			addresses = append(addresses, saved)
+		}
+	}
+	_, err = customersColl.UpdateOne(ctx, bson.M{"_id": customer.ID}, bson.M{"$set": bson.M{"addresses": addresses}})
+	return err
+}
+
+// internationalPhone returns a phone number as digits with the country
+// calling code, so 0812-3456-789 and +62 812 3456 789 are the same customer.
+// Numbers written with the national trunk prefix 0 take the country code of
+// the store's own WhatsApp number; other numbers are taken as international.
+func internationalPhone(number, storeNumber string) string {
+	number = strings.TrimSpace(number)
+	digits := phoneDigits(number)
+	switch {
+	case digits == "":
+		return ""
+	case strings.HasPrefix(number, "+"):
+		return digits
+	case strings.HasPrefix(digits, "00"):
+		return digits[2:]
+	case strings.HasPrefix(digits, "0"):
+		if code := callingCode(phoneDigits(storeNumber)); code != "" {
+			return code + strings.TrimLeft(digits, "0")
+		}
+	}
+	return digits
+}
+
+// Two digit country calling codes; zones 1 and 7 use one digit and every
+// other code has three
+var twoDigitCallingCodes = map[string]bool{
+	"20": true, "27": true, "30": true, "31": true, "32": true, "33": true, "34": true, "36": true, "39": true,
+	"40": true, "41": true, "43": true, "44": true, "45": true, "46": true, "47": true, "48": true, "49": true,
+	"51": true, "52": true, "53": true, "54": true, "55": true, "56": true, "57": true, "58": true,
+	"60": true, "61": true, "62": true, "63": true, "64": true, "65": true, "66": true,
+	"81": true, "82": true, "84": true, "86": true,
+	"90": true, "91": true, "92": true, "93": true, "94": true, "95": true, "98": true,
+}
+
+// callingCode returns the country calling code an international number in
+// digits starts with, or "" when the number is not international
+func callingCode(digits string) string {
+	switch {
+	case len(digits) < 4 || digits[0] == '0':
+		return ""
+	case digits[0] == '1' || digits[0] == '7':
+		return digits[:1]
+	case twoDigitCallingCodes[digits[:2]]:
+		return digits[:2]
+	}
+	return digits[:3]
+}
@@ -0,0 +1,29 @@
+package handlers
+
+import "testing"
+
+// TestInternationalPhone checks that the ways customers type the same number
+// all give one customer key
+func TestInternationalPhone(t *testing.T) {
+	tests := []struct {
+		number string
+		store  string
+		want   string
+	}{
+		{number: "0812-3456-789", store: "+62 811 000 111", want: "628123456789"},
+		{number: "+62 812 3456 789", store: "+62 811 000 111", want: "628123456789"},
+		{number: "0062 812 3456 789", store: "+62 811 000 111", want: "628123456789"},
+		{number: "628123456789", store: "+62 811 000 111", want: "628123456789"},
+		{number: "0805 987 6543", store: "+234 803 123 4567", want: "2348059876543"},
+		{number: "07700 900123", store: "+44 7700 900000", want: "447700900123"},
+		{number: "(415) 555-0100", store: "+1 415 555 0199", want: "4155550100"},
+		{number: "0812-3456-789", store: "0811 000 111", want: "08123456789"},
+		{number: "n/a", store: "+62 811 000 111", want: ""},
+	}
+
+	for _, tt := range tests {
+		if got := internationalPhone(tt.number, tt.store); got != tt.want {
+			t.Errorf("internationalPhone(%q, %q) = %q, want %q", tt.number, tt.store, got, tt.want)
+		}
+	}
+}
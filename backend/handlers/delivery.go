@@ -0,0 +1,428 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"wacatalogue/backend/models"
+)
+
+// GetStoreDeliveryZones lists the delivery zones of one of the user's stores
+func GetStoreDeliveryZones(db *models.Database) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// Get user ID from context
+		userID, err := getUserIDFromContext(r)
+		if err != nil {
+			RespondWithError(w, r, ErrUnauthenticated)
+			return
+		}
+
+		// Get store ID from URL
+		vars := mux.Vars(r)
+		storeID, err := primitive.ObjectIDFromHex(vars["id"])
+		if err != nil {
+			RespondWithError(w, r, ErrInvalidStoreID)
+			return
+		}
+
+		// Create database context
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		// Check if store exists and the user's role allows this
+		store, err := loadStoreFor(ctx, db, storeID, userID, models.PermissionSettings)
+		if err != nil {
+			RespondWithError(w, r, err)
+			return
+		}
+
+		findOptions := options.Find().SetSort(bson.D{{Key: "fee_minor", Value: 1}})
+		cursor, err := db.GetCollection(models.DeliveryZoneCollection).Find(ctx, bson.M{"store_id": storeID}, findOptions)
+		if err != nil {
+			RespondWithError(w, r, ErrInternal.WithMessage("Failed to find delivery zones"))
+			return
+		}
+		zones := []models.DeliveryZone{}
+		if err = cursor.All(ctx, &zones); err != nil {
+			RespondWithError(w, r, ErrInternal.WithMessage("Failed to decode delivery zones"))
+			return
+		}
+		currency := models.CurrencyOf(store.Currency)
+		for i := range zones {
+			zones[i].ApplyCurrency(currency)
+		}
+
+		// Send response
+		RespondWithJSON(w, http.StatusOK, zones)
+	}
+}
+
+// CreateDeliveryZone adds a delivery zone to one of the user's stores
+func CreateDeliveryZone(db *models.Database) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// Get user ID from context
+		userID, err := getUserIDFromContext(r)
+		if err != nil {
+			RespondWithError(w, r, ErrUnauthenticated)
+			return
+		}
+
+		// Get store ID from URL
+		vars := mux.Vars(r)
+		storeID, err := primitive.ObjectIDFromHex(vars["id"])
+		if err != nil {
+			RespondWithError(w, r, ErrInvalidStoreID)
+			return
+		}
+
+		var req models.DeliveryZoneRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			RespondWithError(w, r, ErrInvalidPayload.WithDetails(err.Error()))
+			return
+		}
+		if errs := req.Validate(); len(errs) > 0 {
+			RespondWithError(w, r, ErrValidationFailed.WithDetails(errs))
+			return
+		}
+
+		// Create database context
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		// Check if store exists and the user's role allows this
+		store, err := loadStoreFor(ctx, db, storeID, userID, models.PermissionSettings)
+		if err != nil {
+			RespondWithError(w, r, err)
+			return
+		}
+		if req.Type == models.ZoneRadius && store.Coordinates == nil {
+			RespondWithError(w, r, ErrValidationFailed.WithDetails([]models.FieldError{{Field: "type", Message: "radius zones need the store's coordinates to be set"}}))
+			return
+		}
+
+		now := time.Now()
+		zone := models.DeliveryZone{
+			ID:        primitive.NewObjectID(),
+			StoreID:   storeID,
+			Active:    true,
+			CreatedAt: now,
+			UpdatedAt: now,
+		}
+		applyZoneRequest(&zone, req)
+
+		// Insert zone; MongoDB rejects polygons it cannot index
+		if _, err = db.GetCollection(models.DeliveryZoneCollection).InsertOne(ctx, zone); err != nil {
+			if isGeoError(err) {
+				RespondWithError(w, r, ErrValidationFailed.WithDetails([]models.FieldError{{Field: "area", Message: "must be a valid polygon without self-intersections"}}))
+			} else {
+				RespondWithError(w, r, ErrInternal.WithMessage("Failed to create delivery zone"))
+			}
+			return
+		}
+
+		// Record mutation
+		recordAudit(ctx, db, r, models.AuditZoneCreate, "delivery_zone", zone.ID, storeID, nil, &zone)
+
+		// Send response
+		zone.ApplyCurrency(models.CurrencyOf(store.Currency))
+		RespondWithJSON(w, http.StatusCreated, zone)
+	}
+}
+
+// UpdateDeliveryZone replaces the settings of one of the user's delivery zones
+func UpdateDeliveryZone(db *models.Database) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// Get user ID from context
+		userID, err := getUserIDFromContext(r)
+		if err != nil {
+			RespondWithError(w, r, ErrUnauthenticated)
+			return
+		}
+
+		// Get zone ID from URL
+		vars := mux.Vars(r)
+		zoneID, err := primitive.ObjectIDFromHex(vars["id"])
+		if err != nil {
+			RespondWithError(w, r, ErrInvalidZoneID)
+			return
+		}
+
+		var req models.DeliveryZoneRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			RespondWithError(w, r, ErrInvalidPayload.WithDetails(err.Error()))
+			return
+		}
+		if errs := req.Validate(); len(errs) > 0 {
+			RespondWithError(w, r, ErrValidationFailed.WithDetails(errs))
+			return
+		}
+
+		// Create database context
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		zone, store, err := loadZoneFor(ctx, db, zoneID, userID)
+		if err != nil {
+			RespondWithError(w, r, err)
+			return
+		}
+		if req.Type == models.ZoneRadius && store.Coordinates == nil {
+			RespondWithError(w, r, ErrValidationFailed.WithDetails([]models.FieldError{{Field: "type", Message: "radius zones need the store's coordinates to be set"}}))
+			return
+		}
+
+		updated := zone
+		applyZoneRequest(&updated, req)
+		updated.UpdatedAt = time.Now()
+
+		// Replace zone
+		if _, err = db.GetCollection(models.DeliveryZoneCollection).ReplaceOne(ctx, bson.M{"_id": zoneID}, updated); err != nil {
+			if isGeoError(err) {
+				RespondWithError(w, r, ErrValidationFailed.WithDetails([]models.FieldError{{Field: "area", Message: "must be a valid polygon without self-intersections"}}))
+			} else {
+				RespondWithError(w, r, ErrInternal.WithMessage("Failed to update delivery zone"))
+			}
+			return
+		}
+
+		// Record mutation
+		recordAudit(ctx, db, r, models.AuditZoneUpdate, "delivery_zone", zoneID, zone.StoreID, &zone, &updated)
+
+		// Send response
+		updated.ApplyCurrency(models.CurrencyOf(store.Currency))
+		RespondWithJSON(w, http.StatusOK, updated)
+	}
+}
+
+// DeleteDeliveryZone removes one of the user's delivery zones. Orders keep
+// the zone name and fee they were charged.
+func DeleteDeliveryZone(db *models.Database) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// Get user ID from context
+		userID, err := getUserIDFromContext(r)
+		if err != nil {
+			RespondWithError(w, r, ErrUnauthenticated)
+			return
+		}
+
+		// Get zone ID from URL
+		vars := mux.Vars(r)
+		zoneID, err := primitive.ObjectIDFromHex(vars["id"])
+		if err != nil {
+			RespondWithError(w, r, ErrInvalidZoneID)
+			return
+		}
+
+		// Create database context
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		zone, _, err := loadZoneFor(ctx, db, zoneID, userID)
+		if err != nil {
+			RespondWithError(w, r, err)
+			return
+		}
+
+		// Delete zone
+		if _, err = db.GetCollection(models.DeliveryZoneCollection).DeleteOne(ctx, bson.M{"_id": zoneID}); err != nil {
+			RespondWithError(w, r, ErrInternal.WithMessage("Failed to delete delivery zone"))
+			return
+		}
+
+		// Record mutation
+		recordAudit(ctx, db, r, models.AuditZoneDelete, "delivery_zone", zoneID, zone.StoreID, &zone, nil)
+
+		// Send response
+		RespondWithJSON(w, http.StatusOK, map[string]string{"message": "Delivery zone deleted successfully"})
+	}
+}
+
+// QuoteDelivery tells a customer whether a store delivers to their location
+// and what it costs
+func QuoteDelivery(db *models.Database) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// Get store ID from URL
+		vars := mux.Vars(r)
+		storeID, err := primitive.ObjectIDFromHex(vars["storeId"])
+		if err != nil {
+			RespondWithError(w, r, ErrInvalidStoreID)
+			return
+		}
+
+		var req models.DeliveryQuoteRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			RespondWithError(w, r, ErrInvalidPayload.WithDetails(err.Error()))
+			return
+		}
+		if errs := req.Validate(); len(errs) > 0 {
+			RespondWithError(w, r, ErrValidationFailed.WithDetails(errs))
+			return
+		}
+
+		// Create database context
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		// Find active store
+		var store models.Store
+		err = db.GetCollection(models.StoreCollection).FindOne(ctx, notDeleted(bson.M{"_id": storeID, "active": true})).Decode(&store)
+		if err != nil {
+			if err == mongo.ErrNoDocuments {
+				RespondWithError(w, r, ErrStoreNotFound)
+			} else {
+				RespondWithError(w, r, ErrInternal.WithMessage("Failed to find store"))
+			}
+			return
+		}
+
+		quote, err := quoteDelivery(ctx, db, store, models.NewGeoPoint(req.Latitude, req.Longitude), req.SubtotalMinor)
+		if err != nil {
+			RespondWithError(w, r, err)
+			return
+		}
+
+		// Send response
+		RespondWithJSON(w, http.StatusOK, quote)
+	}
+}
+
+// quoteDelivery finds the cheapest active zone of a store that covers a
+// location. Polygon zones are matched with $geoIntersects; radius bands by
+// the $geoNear distance from the store. When subtotal is given, zones whose
+// minimum order it does not reach are passed over.
+func quoteDelivery(ctx context.Context, db *models.Database, store models.Store, location models.GeoPoint, subtotal *int64) (models.DeliveryQuote, error) {
+	currency := models.CurrencyOf(store.Currency)
+	quote := models.DeliveryQuote{Currency: currency.Code}
+
+	covers := bson.A{bson.M{"type": models.ZonePolygon, "area": bson.M{"$geoIntersects": bson.M{"$geometry": location}}}}
+	if store.Coordinates != nil {
+		distance, err := storeDistance(ctx, db, store.ID, location)
+		if err != nil {
+			return quote, err
+		}
+		quote.DistanceMeters = distance
+		covers = append(covers, bson.M{
+			"type":           models.ZoneRadius,
+			"min_distance_m": bson.M{"$lte": distance},
+			"max_distance_m": bson.M{"$gt": distance},
+		})
+	}
+
+	findOptions := options.Find().SetSort(bson.D{{Key: "fee_minor", Value: 1}})
+	cursor, err := db.GetCollection(models.DeliveryZoneCollection).Find(ctx, bson.M{"store_id": store.ID, "active": true, "$or": covers}, findOptions)
+	if err != nil {
+		return quote, ErrInternal.WithMessage("Failed to find delivery zones")
+	}
+	var zones []models.DeliveryZone
+	if err = cursor.All(ctx, &zones); err != nil {
+		return quote, ErrInternal.WithMessage("Failed to decode delivery zones")
+	}
+	if len(zones) == 0 {
+		quote.Reason = "The store does not deliver to this location"
+		return quote, nil
+	}
+
+	// Zones are sorted by fee, so the first one the order qualifies for is the cheapest
+	zone := zones[0]
+	if subtotal != nil {
+		qualified := false
+		for _, candidate := range zones {
+			if *subtotal >= candidate.MinOrderMinor {
+				zone, qualified = candidate, true
+				break
+			}
+		}
+		if !qualified {
+			for _, candidate := range zones[1:] {
+				if candidate.MinOrderMinor < zone.MinOrderMinor {
+					zone = candidate
+				}
+			}
+			quote.MinOrderMinor = zone.MinOrderMinor
+			quote.FormattedMinOrder = currency.Format(zone.MinOrderMinor)
+			quote.Reason = "Delivery needs an order of at least " + quote.FormattedMinOrder
+			return quote, nil
+		}
+	}
+
+	quote.Available = true
+	quote.ZoneID = zone.ID
+	quote.ZoneName = zone.Name
+	quote.FeeMinor = zone.FeeMinor
+	quote.FormattedFee = currency.Format(zone.FeeMinor)
+	quote.MinOrderMinor = zone.MinOrderMinor
+	if zone.MinOrderMinor > 0 {
+		quote.FormattedMinOrder = currency.Format(zone.MinOrderMinor)
+	}
+	return quote, nil
+}
+
+// storeDistance measures the distance in meters from a store to a location
+// with $geoNear, which uses the stores' 2dsphere index
+func storeDistance(ctx context.Context, db *models.Database, storeID primitive.ObjectID, location models.GeoPoint) (float64, error) {
+	pipeline := mongo.Pipeline{
+		{{Key: "$geoNear", Value: bson.M{
+			"near":          location,
+			"key":           "coordinates",
+			"query":         bson.M{"_id": storeID},
+			"distanceField": "distance",
+			"spherical":     true,
+		}}},
+		{{Key: "$project", Value: bson.M{"distance": 1}}},
+	}
+	cursor, err := db.GetCollection(models.StoreCollection).Aggregate(ctx, pipeline)
+	if err != nil {
+		return 0, ErrInternal.WithMessage("Failed to measure delivery distance")
+	}
+	var results []struct {
+		Distance float64 `bson:"distance"`
+	}
+	if err = cursor.All(ctx, &results); err != nil || len(results) == 0 {
+		return 0, ErrInternal.WithMessage("Failed to measure delivery distance")
+	}
+	return results[0].Distance, nil
+}
+
+// applyZoneRequest copies the settings of a request onto a delivery zone
+func applyZoneRequest(zone *models.DeliveryZone, req models.DeliveryZoneRequest) {
+	zone.Name = req.Name
+	zone.Type = req.Type
+	zone.MinDistanceMeters = 0
+	zone.MaxDistanceMeters = 0
+	zone.Area = nil
+	switch req.Type {
+	case models.ZoneRadius:
+		zone.MinDistanceMeters = req.MinDistanceMeters
+		zone.MaxDistanceMeters = req.MaxDistanceMeters
+	case models.ZonePolygon:
+		zone.Area = req.Area
+	}
+	zone.FeeMinor = req.FeeMinor
+	zone.MinOrderMinor = req.MinOrderMinor
+	if req.Active != nil {
+		zone.Active = *req.Active
+	}
+}
+
+// isGeoError reports whether MongoDB refused a document because it could not
+// index its geometry
+func isGeoError(err error) bool {
+	var writeErr mongo.WriteException
+	if errors.As(err, &writeErr) {
+		for _, e := range writeErr.WriteErrors {
+			if e.Code == 16755 {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,114 @@
+package handlers
+
+import (
+	"net/http"
+)
+
+// APIError is an error with an HTTP status and a stable, machine-readable code.
+// Clients should branch on Code; Message is human-readable and may change.
+type APIError struct {
+	Status  int
+	Code    string
+	Message string
+	Details interface{}
+}
+
+// Error implements the error interface
+func (e *APIError) Error() string {
+	return e.Code + ": " + e.Message
+}
+
+// WithMessage returns a copy of the error with a different message
+func (e *APIError) WithMessage(message string) *APIError {
+	copied := *e
+	copied.Message = message
+	return &copied
+}
+
+// WithDetails returns a copy of the error carrying extra details
+func (e *APIError) WithDetails(details interface{}) *APIError {
+	copied := *e
+	copied.Details = details
+	return &copied
+}
+
+// Error codes returned by the API
+var (
+	// 400
+	ErrInvalidPayload    = &APIError{Status: http.StatusBadRequest, Code: "INVALID_PAYLOAD", Message: "Invalid request payload"}
+	ErrValidationFailed  = &APIError{Status: http.StatusBadRequest, Code: "VALIDATION_FAILED", Message: "Request validation failed"}
+	ErrInvalidStoreID    = &APIError{Status: http.StatusBadRequest, Code: "INVALID_STORE_ID", Message: "Invalid store ID"}
+	ErrInvalidProductID  = &APIError{Status: http.StatusBadRequest, Code: "INVALID_PRODUCT_ID", Message: "Invalid product ID"}
+	ErrInvalidOrderID    = &APIError{Status: http.StatusBadRequest, Code: "INVALID_ORDER_ID", Message: "Invalid order ID"}
+	ErrInvalidCouponID   = &APIError{Status: http.StatusBadRequest, Code: "INVALID_COUPON_ID", Message: "Invalid coupon ID"}
+	ErrCouponInvalid     = &APIError{Status: http.StatusBadRequest, Code: "COUPON_NOT_APPLICABLE", Message: "This coupon cannot be applied to the order"}
+	ErrInvalidZoneID     = &APIError{Status: http.StatusBadRequest, Code: "INVALID_DELIVERY_ZONE_ID", Message: "Invalid delivery zone ID"}
+	ErrNoDelivery        = &APIError{Status: http.StatusBadRequest, Code: "DELIVERY_UNAVAILABLE", Message: "The store does not deliver to this location"}
+	ErrInvalidCustomerID = &APIError{Status: http.StatusBadRequest, Code: "INVALID_CUSTOMER_ID", Message: "Invalid customer ID"}
+	ErrInvalidMemberID   = &APIError{Status: http.StatusBadRequest, Code: "INVALID_MEMBER_ID", Message: "Invalid member ID"}
+	ErrInvalidInviteID   = &APIError{Status: http.StatusBadRequest, Code: "INVALID_INVITE_ID", Message: "Invalid invitation ID"}
+	ErrInvalidReviewID   = &APIError{Status: http.StatusBadRequest, Code: "INVALID_REVIEW_ID", Message: "Invalid review ID"}
+	ErrNoShipping        = &APIError{Status: http.StatusBadRequest, Code: "SHIPPING_UNAVAILABLE", Message: "The courier service does not ship to this destination"}
+
+	// 401
+	ErrUnauthenticated    = &APIError{Status: http.StatusUnauthorized, Code: "UNAUTHENTICATED", Message: "User not authenticated"}
+	ErrInvalidToken       = &APIError{Status: http.StatusUnauthorized, Code: "INVALID_TOKEN", Message: "Invalid or expired token"}
+	ErrInvalidCredentials = &APIError{Status: http.StatusUnauthorized, Code: "INVALID_CREDENTIALS", Message: "Invalid username or password"}
+	ErrInvalidSignature   = &APIError{Status: http.StatusUnauthorized, Code: "INVALID_SIGNATURE", Message: "Webhook signature is missing or invalid"}
+
+	// 403
+	ErrForbidden           = &APIError{Status: http.StatusForbidden, Code: "FORBIDDEN", Message: "Not authorized to perform this action"}
+	ErrNotOwner            = &APIError{Status: http.StatusForbidden, Code: "NOT_OWNER", Message: "Store not owned by user"}
+	ErrNotVerifiedBuyer    = &APIError{Status: http.StatusForbidden, Code: "REVIEW_NOT_VERIFIED", Message: "No completed order with this product matches the reference and phone number"}
+	ErrNoPermission        = &APIError{Status: http.StatusForbidden, Code: "PERMISSION_DENIED", Message: "Your role in this store does not allow this"}
+	ErrVerifyTokenMismatch = &APIError{Status: http.StatusForbidden, Code: "VERIFY_TOKEN_MISMATCH", Message: "Webhook verify token does not match"}
+
+	// 404
+	ErrRouteNotFound     = &APIError{Status: http.StatusNotFound, Code: "ROUTE_NOT_FOUND", Message: "Route not found"}
+	ErrStoreNotFound     = &APIError{Status: http.StatusNotFound, Code: "STORE_NOT_FOUND", Message: "Store not found"}
+	ErrNoStore           = &APIError{Status: http.StatusNotFound, Code: "NO_STORE", Message: "No store found for this user"}
+	ErrProductNotFound   = &APIError{Status: http.StatusNotFound, Code: "PRODUCT_NOT_FOUND", Message: "Product not found"}
+	ErrImportJobNotFound = &APIError{Status: http.StatusNotFound, Code: "IMPORT_JOB_NOT_FOUND", Message: "Import job not found"}
+	ErrSitemapNotFound   = &APIError{Status: http.StatusNotFound, Code: "SITEMAP_NOT_FOUND", Message: "Sitemap not found"}
+	ErrOrderNotFound     = &APIError{Status: http.StatusNotFound, Code: "ORDER_NOT_FOUND", Message: "Order not found"}
+	ErrCouponNotFound    = &APIError{Status: http.StatusNotFound, Code: "COUPON_NOT_FOUND", Message: "Coupon not found"}
+	ErrZoneNotFound      = &APIError{Status: http.StatusNotFound, Code: "DELIVERY_ZONE_NOT_FOUND", Message: "Delivery zone not found"}
+	ErrCustomerNotFound  = &APIError{Status: http.StatusNotFound, Code: "CUSTOMER_NOT_FOUND", Message: "Customer not found"}
+	ErrMemberNotFound    = &APIError{Status: http.StatusNotFound, Code: "MEMBER_NOT_FOUND", Message: "Store member not found"}
+	ErrInviteNotFound    = &APIError{Status: http.StatusNotFound, Code: "INVITE_NOT_FOUND", Message: "Invitation not found"}
+	ErrReviewNotFound    = &APIError{Status: http.StatusNotFound, Code: "REVIEW_NOT_FOUND", Message: "Review not found"}
+	ErrRatesNotFound     = &APIError{Status: http.StatusNotFound, Code: "COURIER_RATES_NOT_FOUND", Message: "Courier rate table not found"}
+
+	// 405
+	ErrMethodNotAllowed = &APIError{Status: http.StatusMethodNotAllowed, Code: "METHOD_NOT_ALLOWED", Message: "Method not allowed"}
+
+	// 409
+	ErrUsernameTaken       = &APIError{Status: http.StatusConflict, Code: "USERNAME_TAKEN", Message: "Username already exists"}
+	ErrStoreInTrash        = &APIError{Status: http.StatusConflict, Code: "STORE_IN_TRASH", Message: "Restore the store before restoring its products"}
+	ErrCouponExists        = &APIError{Status: http.StatusConflict, Code: "COUPON_ALREADY_EXISTS", Message: "The store already has a coupon with this code"}
+	ErrSKUExists           = &APIError{Status: http.StatusConflict, Code: "SKU_ALREADY_EXISTS", Message: "The store already has a product with this SKU"}
+	ErrProductsUnavailable = &APIError{Status: http.StatusConflict, Code: "PRODUCTS_UNAVAILABLE", Message: "Some products are unavailable or out of stock"}
+	ErrAlreadyMember       = &APIError{Status: http.StatusConflict, Code: "ALREADY_MEMBER", Message: "The user already has a role in this store"}
+	ErrReviewExists        = &APIError{Status: http.StatusConflict, Code: "REVIEW_ALREADY_EXISTS", Message: "This product has already been reviewed for this order"}
+	ErrWeightMissing       = &APIError{Status: http.StatusConflict, Code: "WEIGHT_MISSING", Message: "Some products have no shipping weight"}
+
+	// 410
+	ErrInviteExpired = &APIError{Status: http.StatusGone, Code: "INVITE_EXPIRED", Message: "This invitation has expired or was already used"}
+
+	// 500
+	ErrInternal = &APIError{Status: http.StatusInternalServerError, Code: "INTERNAL_ERROR", Message: "Internal server error"}
+)
+
+// NotFoundHandler responds to unknown routes with the error envelope
+func NotFoundHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		RespondWithError(w, r, ErrRouteNotFound)
+	})
+}
+
+// MethodNotAllowedHandler responds to known routes called with the wrong method
+func MethodNotAllowedHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		RespondWithError(w, r, ErrMethodNotAllowed)
+	})
+}
@@ -0,0 +1,323 @@
+package handlers
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/xml"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"wacatalogue/backend/models"
+)
+
+// Feeds are fetched on a schedule, so let caches hold them for a while
+const feedCacheControl = "public, max-age=3600"
+
+// catalogItem is a product in the shape shared by commerce catalog feeds
+type catalogItem struct {
+	ID           string
+	Title        string
+	Description  string
+	Availability string // "in stock" or "out of stock"
+	Condition    string
+	Price        string // "15000.00 IDR", in the store's currency
+	SalePrice    string // Set while a sale is running
+	SaleDates    string // ISO 8601 interval of the sale, when bounded
+	Link         string
+	ImageLink    string
+	Brand        string
+	ProductType  string
+	Quantity     int
+}
+
+// rssFeed is an RSS 2.0 document using the g: product namespace understood by
+// both Meta commerce catalogs and Google Merchant Center
+type rssFeed struct {
+	XMLName xml.Name   `xml:"rss"`
+	Version string     `xml:"version,attr"`
+	XMLNSG  string     `xml:"xmlns:g,attr"`
+	Channel rssChannel `xml:"channel"`
+}
+
+type rssChannel struct {
+	Title       string    `xml:"title"`
+	Link        string    `xml:"link"`
+	Description string    `xml:"description"`
+	Items       []rssItem `xml:"item"`
+}
+
+type rssItem struct {
+	ID           string `xml:"g:id"`
+	Title        string `xml:"g:title"`
+	Description  string `xml:"g:description"`
+	Availability string `xml:"g:availability"`
+	Condition    string `xml:"g:condition"`
+	Price        string `xml:"g:price"`
+	SalePrice    string `xml:"g:sale_price,omitempty"`
+	SaleDates    string `xml:"g:sale_price_effective_date,omitempty"`
+	Link         string `xml:"g:link"`
+	ImageLink    string `xml:"g:image_link,omitempty"`
+	Brand        string `xml:"g:brand,omitempty"`
+	ProductType  string `xml:"g:product_type,omitempty"`
+	Quantity     *int   `xml:"g:quantity_to_sell_on_facebook,omitempty"`
+}
+
+// loadPublicCatalog returns an active store with its active products
+func loadPublicCatalog(ctx context.Context, db *models.Database, storeID primitive.ObjectID) (models.Store, []models.Product, error) {
+	var store models.Store
+	err := db.GetCollection(models.StoreCollection).FindOne(ctx, notDeleted(bson.M{"_id": storeID, "active": true})).Decode(&store)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return store, nil, ErrStoreNotFound
+		}
+		return store, nil, ErrInternal.WithMessage("Failed to find store")
+	}
+
+	findOptions := options.Find().SetSort(bson.D{{Key: "created_at", Value: -1}})
+	cursor, err := db.GetCollection(models.ProductCollection).Find(ctx, notDeleted(bson.M{"store_id": storeID, "active": true}), findOptions)
+	if err != nil {
+		return store, nil, ErrInternal.WithMessage("Failed to find products")
+	}
+	defer cursor.Close(ctx)
+
+	products := []models.Product{}
+	if err = cursor.All(ctx, &products); err != nil {
+		return store, nil, ErrInternal.WithMessage("Failed to decode products")
+	}
+	models.ApplyPricing(products, time.Now(), models.CurrencyOf(store.Currency))
+	return store, products, nil
+}
+
+// newCatalogItem maps a product to its feed representation
+func newCatalogItem(base string, store models.Store, product models.Product) catalogItem {
+	availability := "in stock"
+	if !product.InStock() {
+		availability = "out of stock"
+	}
+
+	// Catalogs reject items without a description
+	description := product.Description
+	if description == "" {
+		description = product.Name
+	}
+
+	currency := models.CurrencyOf(store.Currency)
+	item := catalogItem{
+		ID:           product.ID.Hex(),
+		Title:        product.Name,
+		Description:  description,
+		Availability: availability,
+		Condition:    "new",
+		Price:        feedPrice(product.PriceMinor, currency),
+		Link:         productURL(base, store.ID, product.ID),
+		ImageLink:    absoluteURL(base, product.Image),
+		Brand:        store.Name,
+		ProductType:  product.Category,
+		Quantity:     product.Stock,
+	}
+	if product.OnSale {
+		item.SalePrice = feedPrice(product.SalePriceMinor, currency)
+		item.SaleDates = saleDates(product)
+	}
+	return item
+}
+
+// feedPrice formats an amount the way catalog feeds expect, e.g. "15000.00 IDR"
+func feedPrice(minor int64, currency models.Currency) string {
+	return currency.DecimalString(minor) + " " + currency.Code
+}
+
+// saleDates returns the ISO 8601 interval of a sale, with an open start
+// closed off at the Unix epoch and an open end ten years after the start
+func saleDates(product models.Product) string {
+	if product.SaleStartsAt == nil && product.SaleEndsAt == nil {
+		return ""
+	}
+	start := time.Unix(0, 0)
+	if product.SaleStartsAt != nil {
+		start = *product.SaleStartsAt
+	}
+	// Deriving the end from the start keeps the feed the same between requests
+	end := start.AddDate(10, 0, 0)
+	if product.SaleEndsAt != nil {
+		end = *product.SaleEndsAt
+	}
+	return start.UTC().Format(time.RFC3339) + "/" + end.UTC().Format(time.RFC3339)
+}
+
+// lastModified returns the newest update time across a store and all of its
+// products, including those deactivated or moved to the trash since, so
+// removing a product from the feed also changes it
+func lastModified(ctx context.Context, db *models.Database, store models.Store) (time.Time, error) {
+	latest := store.UpdatedAt
+	findOptions := options.FindOne().SetSort(bson.D{{Key: "updated_at", Value: -1}}).SetProjection(bson.M{"updated_at": 1})
+	var product models.Product
+	err := db.GetCollection(models.ProductCollection).FindOne(ctx, bson.M{"store_id": store.ID}, findOptions).Decode(&product)
+	if err != nil && err != mongo.ErrNoDocuments {
+		return latest, err
+	}
+	if product.UpdatedAt.After(latest) {
+		latest = product.UpdatedAt
+	}
+	return latest, nil
+}
+
+// metaCatalogItems maps products to Meta catalog items, leaving out those
+// without a publicly reachable image, which Meta requires
+func metaCatalogItems(base string, store models.Store, products []models.Product) []catalogItem {
+	items := []catalogItem{}
+	for _, product := range products {
+		item := newCatalogItem(base, store, product)
+		if item.ImageLink == "" {
+			continue
+		}
+		items = append(items, item)
+	}
+	return items
+}
+
+// feedCatalog loads the catalog for a feed request and writes caching
+// headers, returning the catalog's modification time. It returns false when
+// the response has already been written.
+func feedCatalog(db *models.Database, w http.ResponseWriter, r *http.Request) (models.Store, []models.Product, time.Time, bool) {
+	// Get store ID from URL
+	vars := mux.Vars(r)
+	storeID, err := primitive.ObjectIDFromHex(vars["storeId"])
+	if err != nil {
+		RespondWithError(w, r, ErrInvalidStoreID)
+		return models.Store{}, nil, time.Time{}, false
+	}
+
+	// Create database context
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	store, products, err := loadPublicCatalog(ctx, db, storeID)
+	if err != nil {
+		RespondWithError(w, r, err)
+		return store, nil, time.Time{}, false
+	}
+
+	modified, err := lastModified(ctx, db, store)
+	if err != nil {
+		RespondWithError(w, r, ErrInternal.WithMessage("Failed to find products"))
+		return store, nil, time.Time{}, false
+	}
+	modified = modified.UTC().Truncate(time.Second)
+	if since, err := http.ParseTime(r.Header.Get("If-Modified-Since")); err == nil && !modified.After(since) {
+		w.WriteHeader(http.StatusNotModified)
+		return store, nil, modified, false
+	}
+	w.Header().Set("Last-Modified", modified.Format(http.TimeFormat))
+	w.Header().Set("Cache-Control", feedCacheControl)
+	return store, products, modified, true
+}
+
+// GetMetaCatalogCSV serves a store's products as a Meta commerce catalog CSV feed
+func GetMetaCatalogCSV(db *models.Database) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		store, products, _, ok := feedCatalog(db, w, r)
+		if !ok {
+			return
+		}
+
+		base := publicBaseURL(r)
+		w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+		w.WriteHeader(http.StatusOK)
+
+		writer := csv.NewWriter(w)
+		writer.Write([]string{"id", "title", "description", "availability", "condition", "price", "sale_price", "sale_price_effective_date", "link", "image_link", "brand", "product_type", "quantity_to_sell_on_facebook"})
+		for _, item := range metaCatalogItems(base, store, products) {
+			writer.Write([]string{
+				item.ID,
+				item.Title,
+				item.Description,
+				item.Availability,
+				item.Condition,
+				item.Price,
+				item.SalePrice,
+				item.SaleDates,
+				item.Link,
+				item.ImageLink,
+				item.Brand,
+				item.ProductType,
+				strconv.Itoa(item.Quantity),
+			})
+		}
+		writer.Flush()
+	}
+}
+
+// GetMetaCatalogXML serves a store's products as a Meta commerce catalog RSS feed
+func GetMetaCatalogXML(db *models.Database) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		store, products, _, ok := feedCatalog(db, w, r)
+		if !ok {
+			return
+		}
+
+		base := publicBaseURL(r)
+		feed := newRSSFeed(base, store)
+		for _, item := range metaCatalogItems(base, store, products) {
+			rss := item.rssItem()
+			quantity := item.Quantity
+			rss.Quantity = &quantity
+			feed.Channel.Items = append(feed.Channel.Items, rss)
+		}
+
+		writeXML(w, feed)
+	}
+}
+
+// newRSSFeed creates an empty product feed for a store
+func newRSSFeed(base string, store models.Store) rssFeed {
+	return rssFeed{
+		Version: "2.0",
+		XMLNSG:  "http://base.google.com/ns/1.0",
+		Channel: rssChannel{
+			Title:       store.Name,
+			Link:        storeURL(base, store.ID),
+			Description: store.Description,
+			Items:       []rssItem{},
+		},
+	}
+}
+
+// rssItem converts a catalog item to its RSS representation
+func (item catalogItem) rssItem() rssItem {
+	return rssItem{
+		ID:           item.ID,
+		Title:        item.Title,
+		Description:  item.Description,
+		Availability: item.Availability,
+		Condition:    item.Condition,
+		Price:        item.Price,
+		SalePrice:    item.SalePrice,
+		SaleDates:    item.SaleDates,
+		Link:         item.Link,
+		ImageLink:    item.ImageLink,
+		Brand:        item.Brand,
+		ProductType:  item.ProductType,
+	}
+}
+
+// writeXML sends an XML response
+func writeXML(w http.ResponseWriter, payload interface{}) {
+	response, err := xml.MarshalIndent(payload, "", "  ")
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/xml; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte(xml.Header))
+	w.Write(response)
+}
@@ -0,0 +1,215 @@
+package handlers
+
+import (
+	"context"
+	"encoding/xml"
+	"net/http"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/gorilla/mux"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"wacatalogue/backend/models"
+)
+
+// Google Merchant Center attribute limits
+const (
+	googleMaxTitleLength       = 150
+	googleMaxDescriptionLength = 5000
+)
+
+// googleItem extends the shared RSS item with Google-specific attributes
+type googleItem struct {
+	rssItem
+	IdentifierExists string `xml:"g:identifier_exists"`
+}
+
+// googleRSSFeed is a Google Merchant Center RSS 2.0 feed
+type googleRSSFeed struct {
+	XMLName xml.Name         `xml:"rss"`
+	Version string           `xml:"version,attr"`
+	XMLNSG  string           `xml:"xmlns:g,attr"`
+	Channel googleRSSChannel `xml:"channel"`
+}
+
+type googleRSSChannel struct {
+	Title       string       `xml:"title"`
+	Link        string       `xml:"link"`
+	Description string       `xml:"description"`
+	Items       []googleItem `xml:"item"`
+}
+
+// googleAtomFeed is a Google Merchant Center Atom 1.0 feed
+type googleAtomFeed struct {
+	XMLName xml.Name     `xml:"feed"`
+	XMLNS   string       `xml:"xmlns,attr"`
+	XMLNSG  string       `xml:"xmlns:g,attr"`
+	Title   string       `xml:"title"`
+	Link    atomLink     `xml:"link"`
+	Updated string       `xml:"updated"`
+	ID      string       `xml:"id"`
+	Entries []googleItem `xml:"entry"`
+}
+
+type atomLink struct {
+	Rel  string `xml:"rel,attr"`
+	Href string `xml:"href,attr"`
+}
+
+// googleFeedIssues lists the reasons a product can't be listed on Google
+func googleFeedIssues(base string, product models.Product) []models.FeedIssue {
+	var issues []models.FeedIssue
+	add := func(field, message string) {
+		issues = append(issues, models.FeedIssue{
+			ProductID:   product.ID,
+			ProductName: product.Name,
+			Field:       field,
+			Message:     message,
+		})
+	}
+
+	title := strings.TrimSpace(product.Name)
+	switch {
+	case title == "":
+		add("title", "is required")
+	case utf8.RuneCountInString(title) > googleMaxTitleLength:
+		add("title", "must be at most 150 characters")
+	}
+
+	description := strings.TrimSpace(product.Description)
+	switch {
+	case description == "":
+		add("description", "is required")
+	case utf8.RuneCountInString(description) > googleMaxDescriptionLength:
+		add("description", "must be at most 5000 characters")
+	}
+
+	if product.PriceMinor <= 0 {
+		add("price", "must be greater than zero")
+	}
+
+	switch {
+	case strings.TrimSpace(product.Image) == "":
+		add("image_link", "is required")
+	case absoluteURL(base, product.Image) == "":
+		add("image_link", "must be a publicly reachable URL, not an embedded image")
+	}
+
+	return issues
+}
+
+// googleCatalog splits a store's catalog into listable items and issues
+func googleCatalog(base string, store models.Store, products []models.Product) ([]googleItem, models.FeedValidationReport) {
+	report := models.FeedValidationReport{
+		Feed:          "google",
+		TotalProducts: len(products),
+		Issues:        []models.FeedIssue{},
+	}
+
+	items := []googleItem{}
+	for _, product := range products {
+		if issues := googleFeedIssues(base, product); len(issues) > 0 {
+			report.Issues = append(report.Issues, issues...)
+			continue
+		}
+
+		item := newCatalogItem(base, store, product).rssItem()
+		item.Availability = strings.ReplaceAll(item.Availability, " ", "_") // Google uses in_stock / out_of_stock
+		items = append(items, googleItem{rssItem: item, IdentifierExists: "no"})
+	}
+	report.ValidProducts = len(items)
+	return items, report
+}
+
+// GetGoogleMerchantRSS serves a store's valid products as a Google Merchant RSS 2.0 feed
+func GetGoogleMerchantRSS(db *models.Database) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		store, products, _, ok := feedCatalog(db, w, r)
+		if !ok {
+			return
+		}
+
+		base := publicBaseURL(r)
+		items, _ := googleCatalog(base, store, products)
+		writeXML(w, googleRSSFeed{
+			Version: "2.0",
+			XMLNSG:  "http://base.google.com/ns/1.0",
+			Channel: googleRSSChannel{
+				Title:       store.Name,
+				Link:        storeURL(base, store.ID),
+				Description: store.Description,
+				Items:       items,
+			},
+		})
+	}
+}
+
+// GetGoogleMerchantAtom serves a store's valid products as a Google Merchant Atom 1.0 feed
+func GetGoogleMerchantAtom(db *models.Database) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		store, products, modified, ok := feedCatalog(db, w, r)
+		if !ok {
+			return
+		}
+
+		base := publicBaseURL(r)
+		items, _ := googleCatalog(base, store, products)
+		writeXML(w, googleAtomFeed{
+			XMLNS:   "http://www.w3.org/2005/Atom",
+			XMLNSG:  "http://base.google.com/ns/1.0",
+			Title:   store.Name,
+			Link:    atomLink{Rel: "self", Href: base + r.URL.Path},
+			Updated: modified.Format(time.RFC3339),
+			ID:      storeURL(base, store.ID),
+			Entries: items,
+		})
+	}
+}
+
+// GetGoogleFeedIssues reports which of the owner's products are left out of
+// the Google Merchant feed and why
+func GetGoogleFeedIssues(db *models.Database) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// Get user ID from context
+		userID, err := getUserIDFromContext(r)
+		if err != nil {
+			RespondWithError(w, r, ErrUnauthenticated)
+			return
+		}
+
+		// Get store ID from URL
+		vars := mux.Vars(r)
+		storeID, err := primitive.ObjectIDFromHex(vars["storeId"])
+		if err != nil {
+			RespondWithError(w, r, ErrInvalidStoreID)
+			return
+		}
+
+		// Create database context
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+
+		// Check if store exists and the user's role allows this
+		if _, err := loadStoreFor(ctx, db, storeID, userID, models.PermissionCatalog); err != nil {
+			RespondWithError(w, r, err)
+			return
+		}
+
+		// Validate the catalog exactly as the public feed sees it
+		store, products, err := loadPublicCatalog(ctx, db, storeID)
+		if err == ErrStoreNotFound {
+			RespondWithError(w, r, ErrStoreNotFound.WithMessage("Store is inactive, so its feed is empty"))
+			return
+		} else if err != nil {
+			RespondWithError(w, r, err)
+			return
+		}
+
+		_, report := googleCatalog(publicBaseURL(r), store, products)
+
+		// Send response
+		RespondWithJSON(w, http.StatusOK, report)
+	}
+}
@@ -3,6 +3,7 @@ package handlers
 import (
 	"context"
 	"encoding/json"
+	"log"
 	"net/http"
 	"time"
 
@@ -22,7 +23,11 @@ func Register(db *models.Database) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		var req models.RegisterRequest
 		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-			RespondWithError(w, http.StatusBadRequest, "Invalid request payload")
+			RespondWithError(w, r, ErrInvalidPayload.WithDetails(err.Error()))
+			return
+		}
+		if errs := req.Validate(); len(errs) > 0 {
+			RespondWithError(w, r, ErrValidationFailed.WithDetails(errs))
 			return
 		}
 
@@ -35,17 +40,17 @@ func Register(db *models.Database) http.HandlerFunc {
 		var existingUser models.User
 		err := usersColl.FindOne(ctx, bson.M{"username": req.Username}).Decode(&existingUser)
 		if err == nil {
-			RespondWithError(w, http.StatusConflict, "Username already exists")
+			RespondWithError(w, r, ErrUsernameTaken)
 			return
 		} else if err != mongo.ErrNoDocuments {
-			RespondWithError(w, http.StatusInternalServerError, "Failed to check username")
+			RespondWithError(w, r, ErrInternal.WithMessage("Failed to check username"))
 			return
 		}
 
 		// Hash password
 		hashedPassword, err := HashPassword(req.Password)
 		if err != nil {
-			RespondWithError(w, http.StatusInternalServerError, "Failed to hash password")
+			RespondWithError(w, r, ErrInternal.WithMessage("Failed to hash password"))
 			return
 		}
 
@@ -64,14 +69,14 @@ func Register(db *models.Database) http.HandlerFunc {
 		// Insert user into database
 		_, err = usersColl.InsertOne(ctx, newUser)
 		if err != nil {
-			RespondWithError(w, http.StatusInternalServerError, "Failed to create user")
+			RespondWithError(w, r, ErrInternal.WithMessage("Failed to create user"))
 			return
 		}
 
 		// Generate JWT
 		token, err := GenerateJWT(newUser.ID, newUser.Username)
 		if err != nil {
-			RespondWithError(w, http.StatusInternalServerError, "Failed to generate token")
+			RespondWithError(w, r, ErrInternal.WithMessage("Failed to generate token"))
 			return
 		}
 
@@ -91,7 +96,7 @@ func Login(db *models.Database) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		var req models.LoginRequest
 		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-			RespondWithError(w, http.StatusBadRequest, "Invalid request payload")
+			RespondWithError(w, r, ErrInvalidPayload.WithDetails(err.Error()))
 			return
 		}
 
@@ -105,23 +110,23 @@ func Login(db *models.Database) http.HandlerFunc {
 		err := usersColl.FindOne(ctx, bson.M{"username": req.Username}).Decode(&user)
 		if err != nil {
 			if err == mongo.ErrNoDocuments {
-				RespondWithError(w, http.StatusUnauthorized, "Invalid username or password")
+				RespondWithError(w, r, ErrInvalidCredentials)
 			} else {
-				RespondWithError(w, http.StatusInternalServerError, "Failed to find user")
+				RespondWithError(w, r, ErrInternal.WithMessage("Failed to find user"))
 			}
 			return
 		}
 
 		// Check password
 		if !CheckPasswordHash(req.Password, user.PasswordHash) {
-			RespondWithError(w, http.StatusUnauthorized, "Invalid username or password")
+			RespondWithError(w, r, ErrInvalidCredentials)
 			return
 		}
 
 		// Generate JWT
 		token, err := GenerateJWT(user.ID, user.Username)
 		if err != nil {
-			RespondWithError(w, http.StatusInternalServerError, "Failed to generate token")
+			RespondWithError(w, r, ErrInternal.WithMessage("Failed to generate token"))
 			return
 		}
 
@@ -153,12 +158,12 @@ func GetAllStores(db *models.Database) http.HandlerFunc {
 		findOptions.SetSort(bson.D{{Key: "created_at", Value: -1}})
 
 		// Only include active stores for public viewing
-		filter := bson.M{"active": true}
+		filter := notDeleted(bson.M{"active": true})
 
 		// Find all stores
 		cursor, err := storesColl.Find(ctx, filter, findOptions)
 		if err != nil {
-			RespondWithError(w, http.StatusInternalServerError, "Failed to find stores")
+			RespondWithError(w, r, ErrInternal.WithMessage("Failed to find stores"))
 			return
 		}
 		defer cursor.Close(ctx)
@@ -166,10 +171,14 @@ func GetAllStores(db *models.Database) http.HandlerFunc {
 		// Decode stores
 		var stores []models.Store
 		if err = cursor.All(ctx, &stores); err != nil {
-			RespondWithError(w, http.StatusInternalServerError, "Failed to decode stores")
+			RespondWithError(w, r, ErrInternal.WithMessage("Failed to decode stores"))
 			return
 		}
 
+		for i := range stores {
+			stores[i] = stores[i].Public()
+		}
+
 		// Send response
 		RespondWithJSON(w, http.StatusOK, stores)
 	}
@@ -182,7 +191,7 @@ func GetStore(db *models.Database) http.HandlerFunc {
 		vars := mux.Vars(r)
 		id, err := primitive.ObjectIDFromHex(vars["id"])
 		if err != nil {
-			RespondWithError(w, http.StatusBadRequest, "Invalid store ID")
+			RespondWithError(w, r, ErrInvalidStoreID)
 			return
 		}
 
@@ -195,28 +204,29 @@ func GetStore(db *models.Database) http.HandlerFunc {
 
 		// Find store by ID
 		var store models.Store
-		err = storesColl.FindOne(ctx, bson.M{"_id": id}).Decode(&store)
+		err = storesColl.FindOne(ctx, notDeleted(bson.M{"_id": id})).Decode(&store)
 		if err != nil {
 			if err == mongo.ErrNoDocuments {
-				RespondWithError(w, http.StatusNotFound, "Store not found")
+				RespondWithError(w, r, ErrStoreNotFound)
 			} else {
-				RespondWithError(w, http.StatusInternalServerError, "Failed to find store")
+				RespondWithError(w, r, ErrInternal.WithMessage("Failed to find store"))
 			}
 			return
 		}
 
 		// Send response
-		RespondWithJSON(w, http.StatusOK, store)
+		RespondWithJSON(w, http.StatusOK, store.Public())
 	}
 }
 
-// GetMyStore returns the current user's store
+// GetMyStore returns the current user's selected store, or the one named by
+// the storeId query parameter
 func GetMyStore(db *models.Database) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		// Get user ID from context
 		userID, err := getUserIDFromContext(r)
 		if err != nil {
-			RespondWithError(w, http.StatusUnauthorized, "User not authenticated")
+			RespondWithError(w, r, ErrUnauthenticated)
 			return
 		}
 
@@ -224,23 +234,132 @@ func GetMyStore(db *models.Database) http.HandlerFunc {
 		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 		defer cancel()
 
-		// Get stores collection
-		storesColl := db.GetCollection(models.StoreCollection)
+		store, err := loadMyStore(ctx, db, r, userID)
+		if err != nil {
+			RespondWithError(w, r, err)
+			return
+		}
+		role, err := storeRole(ctx, db, store, userID)
+		if err != nil {
+			RespondWithError(w, r, err)
+			return
+		}
 
-		// Find store by owner ID
-		var store models.Store
-		err = storesColl.FindOne(ctx, bson.M{"owner_id": userID}).Decode(&store)
+		// Send response
+		RespondWithJSON(w, http.StatusOK, storeAsSeenBy(store, role))
+	}
+}
+
+// GetMyStores lists the stores the current user owns or helps run, with their
+// role in each, oldest first
+func GetMyStores(db *models.Database) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// Get user ID from context
+		userID, err := getUserIDFromContext(r)
 		if err != nil {
-			if err == mongo.ErrNoDocuments {
-				RespondWithError(w, http.StatusNotFound, "No store found for this user")
-			} else {
-				RespondWithError(w, http.StatusInternalServerError, "Failed to find store")
+			RespondWithError(w, r, ErrUnauthenticated)
+			return
+		}
+
+		// Create database context
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		members, err := storeMemberships(ctx, db, userID)
+		if err != nil {
+			RespondWithError(w, r, err)
+			return
+		}
+		roles := make(map[primitive.ObjectID]string, len(members))
+		storeIDs := make([]primitive.ObjectID, len(members))
+		for i, member := range members {
+			roles[member.StoreID] = member.Role
+			storeIDs[i] = member.StoreID
+		}
+
+		filter := notDeleted(bson.M{"$or": []bson.M{{"owner_id": userID}, {"_id": bson.M{"$in": storeIDs}}}})
+		findOptions := options.Find().SetSort(bson.D{{Key: "created_at", Value: 1}})
+		cursor, err := db.GetCollection(models.StoreCollection).Find(ctx, filter, findOptions)
+		if err != nil {
+			RespondWithError(w, r, ErrInternal.WithMessage("Failed to find stores"))
+			return
+		}
+		response := models.MyStoresResponse{Stores: []models.Store{}, Roles: map[string]string{}}
+		if err = cursor.All(ctx, &response.Stores); err != nil {
+			RespondWithError(w, r, ErrInternal.WithMessage("Failed to decode stores"))
+			return
+		}
+		for i, store := range response.Stores {
+			role := roles[store.ID]
+			if store.OwnerID == userID {
+				role = models.RoleOwner
 			}
+			response.Roles[store.ID.Hex()] = role
+			response.Stores[i] = storeAsSeenBy(store, role)
+		}
+
+		// The selected store is the one the dashboard opens without a storeId
+		if len(response.Stores) > 0 {
+			selected, err := loadMyStore(ctx, db, r, userID)
+			if err != nil {
+				RespondWithError(w, r, err)
+				return
+			}
+			response.SelectedStoreID = selected.ID
+		}
+
+		// Send response
+		RespondWithJSON(w, http.StatusOK, response)
+	}
+}
+
+// SelectMyStore makes one of the user's stores, owned or not, the one the dashboard opens by default
+func SelectMyStore(db *models.Database) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// Get user ID from context
+		userID, err := getUserIDFromContext(r)
+		if err != nil {
+			RespondWithError(w, r, ErrUnauthenticated)
+			return
+		}
+
+		// Get store ID from URL
+		vars := mux.Vars(r)
+		storeID, err := primitive.ObjectIDFromHex(vars["id"])
+		if err != nil {
+			RespondWithError(w, r, ErrInvalidStoreID)
+			return
+		}
+
+		// Create database context
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		// Check if store exists and the user's role allows this
+		store, err := loadStoreFor(ctx, db, storeID, userID, models.PermissionView)
+		if err != nil {
+			RespondWithError(w, r, err)
+			return
+		}
+
+		// Update user
+		_, err = db.GetCollection(models.UserCollection).UpdateOne(
+			ctx,
+			bson.M{"_id": userID},
+			bson.M{"$set": bson.M{"store_id": storeID, "updated_at": time.Now()}},
+		)
+		if err != nil {
+			RespondWithError(w, r, ErrInternal.WithMessage("Failed to select store"))
+			return
+		}
+		role, err := storeRole(ctx, db, store, userID)
+		if err != nil {
+			RespondWithError(w, r, err)
 			return
 		}
 
 		// Send response
-		RespondWithJSON(w, http.StatusOK, store)
+		RespondWithJSON(w, http.StatusOK, storeAsSeenBy(store, role))
 	}
 }
 
@@ -250,13 +369,17 @@ func CreateStore(db *models.Database) http.HandlerFunc {
 		// Get user ID from context
 		userID, err := getUserIDFromContext(r)
 		if err != nil {
-			RespondWithError(w, http.StatusUnauthorized, "User not authenticated")
+			RespondWithError(w, r, ErrUnauthenticated)
 			return
 		}
 
 		var req models.CreateStoreRequest
 		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-			RespondWithError(w, http.StatusBadRequest, "Invalid request payload")
+			RespondWithError(w, r, ErrInvalidPayload.WithDetails(err.Error()))
+			return
+		}
+		if errs := req.Validate(); len(errs) > 0 {
+			RespondWithError(w, r, ErrValidationFailed.WithDetails(errs))
 			return
 		}
 
@@ -268,17 +391,6 @@ func CreateStore(db *models.Database) http.HandlerFunc {
 		storesColl := db.GetCollection(models.StoreCollection)
 		usersColl := db.GetCollection(models.UserCollection)
 
-		// Check if user already has a store
-		var existingStore models.Store
-		err = storesColl.FindOne(ctx, bson.M{"owner_id": userID}).Decode(&existingStore)
-		if err == nil {
-			RespondWithError(w, http.StatusConflict, "User already has a store")
-			return
-		} else if err != mongo.ErrNoDocuments {
-			RespondWithError(w, http.StatusInternalServerError, "Failed to check store existence")
-			return
-		}
-
 		// Create new store
 		now := time.Now()
 		newStore := models.Store{
@@ -289,20 +401,25 @@ func CreateStore(db *models.Database) http.HandlerFunc {
 			Logo:           req.Logo,
 			Location:       req.Location,
 			WhatsappNumber: req.WhatsappNumber,
+			WhatsappDigits: storeNumberDigits(req.WhatsappNumber),
 			BusinessHours:  req.BusinessHours,
+			Currency:       models.DefaultCurrency,
 			Active:         true,
 			CreatedAt:      now,
 			UpdatedAt:      now,
 		}
+		if req.Currency != "" {
+			newStore.Currency = models.CurrencyOf(req.Currency).Code
+		}
 
 		// Insert store into database
 		_, err = storesColl.InsertOne(ctx, newStore)
 		if err != nil {
-			RespondWithError(w, http.StatusInternalServerError, "Failed to create store")
+			RespondWithError(w, r, ErrInternal.WithMessage("Failed to create store"))
 			return
 		}
 
-		// Update user with store ID
+		// Select the new store for the dashboard
 		_, err = usersColl.UpdateOne(
 			ctx,
 			bson.M{"_id": userID},
@@ -373,6 +490,9 @@ func CreateStore(db *models.Database) http.HandlerFunc {
 			// But for this demo, it's acceptable
 		}
 
+		// Record mutation
+		recordAudit(ctx, db, r, models.AuditStoreCreate, "store", newStore.ID, newStore.ID, nil, &newStore)
+
 		// Send response
 		RespondWithJSON(w, http.StatusCreated, newStore)
 	}
@@ -384,7 +504,7 @@ func UpdateStore(db *models.Database) http.HandlerFunc {
 		// Get user ID from context
 		userID, err := getUserIDFromContext(r)
 		if err != nil {
-			RespondWithError(w, http.StatusUnauthorized, "User not authenticated")
+			RespondWithError(w, r, ErrUnauthenticated)
 			return
 		}
 
@@ -392,13 +512,17 @@ func UpdateStore(db *models.Database) http.HandlerFunc {
 		vars := mux.Vars(r)
 		storeID, err := primitive.ObjectIDFromHex(vars["id"])
 		if err != nil {
-			RespondWithError(w, http.StatusBadRequest, "Invalid store ID")
+			RespondWithError(w, r, ErrInvalidStoreID)
 			return
 		}
 
 		var req models.UpdateStoreRequest
 		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-			RespondWithError(w, http.StatusBadRequest, "Invalid request payload")
+			RespondWithError(w, r, ErrInvalidPayload.WithDetails(err.Error()))
+			return
+		}
+		if errs := req.Validate(); len(errs) > 0 {
+			RespondWithError(w, r, ErrValidationFailed.WithDetails(errs))
 			return
 		}
 
@@ -409,15 +533,10 @@ func UpdateStore(db *models.Database) http.HandlerFunc {
 		// Get stores collection
 		storesColl := db.GetCollection(models.StoreCollection)
 
-		// Check if store exists and belongs to user
-		var store models.Store
-		err = storesColl.FindOne(ctx, bson.M{"_id": storeID, "owner_id": userID}).Decode(&store)
+		// Check if store exists and the user's role allows this
+		store, err := loadStoreFor(ctx, db, storeID, userID, models.PermissionSettings)
 		if err != nil {
-			if err == mongo.ErrNoDocuments {
-				RespondWithError(w, http.StatusNotFound, "Store not found or not owned by user")
-			} else {
-				RespondWithError(w, http.StatusInternalServerError, "Failed to find store")
-			}
+			RespondWithError(w, r, err)
 			return
 		}
 
@@ -437,6 +556,7 @@ func UpdateStore(db *models.Database) http.HandlerFunc {
 		}
 		if req.WhatsappNumber != "" {
 			update["whatsapp_number"] = req.WhatsappNumber
+			update["whatsapp_digits"] = storeNumberDigits(req.WhatsappNumber)
 		}
 		if req.BusinessHours != "" {
 			update["business_hours"] = req.BusinessHours
@@ -447,20 +567,58 @@ func UpdateStore(db *models.Database) http.HandlerFunc {
 		if req.Active != nil {
 			update["active"] = *req.Active
 		}
+		if next := models.CurrencyOf(req.Currency); req.Currency != "" && next.Code != models.CurrencyOf(store.Currency).Code {
+			// Stored amounts are not converted, so the currency is fixed once
+			// anything has a price in it
+			priced, err := storeHasPrices(ctx, db, storeID)
+			if err != nil {
+				RespondWithError(w, r, err)
+				return
+			}
+			if priced {
+				RespondWithError(w, r, ErrValidationFailed.WithDetails([]models.FieldError{{Field: "currency", Message: "can't be changed once the store has products, coupons, delivery zones or shipping rates"}}))
+				return
+			}
+			update["currency"] = next.Code
+		}
+		if req.Coordinates != nil {
+			update["coordinates"] = req.Coordinates
+		}
+		if req.ShippingOrigin != "" {
+			update["shipping_origin"] = req.ShippingOrigin
+		}
+		if req.Fulfilment != nil {
+			// Pickup locations keep their IDs so customers can choose between them
+			for i := range req.Fulfilment.Pickup.Locations {
+				if req.Fulfilment.Pickup.Locations[i].ID == "" {
+					req.Fulfilment.Pickup.Locations[i].ID = primitive.NewObjectID().Hex()
+				}
+			}
+			update["fulfilment"] = req.Fulfilment
+		}
+		if req.Notifications != nil {
+			update["notifications"] = req.Notifications
+		}
+		if req.Tax != nil {
+			update["tax"] = req.Tax
+		}
+		if req.ServiceCharge != nil {
+			update["service_charge"] = req.ServiceCharge
+		}
 
 		// Update store
 		result, err := storesColl.UpdateOne(
 			ctx,
-			bson.M{"_id": storeID, "owner_id": userID},
+			bson.M{"_id": storeID},
 			bson.M{"$set": update},
 		)
 		if err != nil {
-			RespondWithError(w, http.StatusInternalServerError, "Failed to update store")
+			RespondWithError(w, r, ErrInternal.WithMessage("Failed to update store"))
 			return
 		}
 
 		if result.ModifiedCount == 0 {
-			RespondWithError(w, http.StatusNotFound, "Store not found or not modified")
+			RespondWithError(w, r, ErrStoreNotFound.WithMessage("Store not found or not modified"))
 			return
 		}
 
@@ -469,22 +627,40 @@ func UpdateStore(db *models.Database) http.HandlerFunc {
 		err = storesColl.FindOne(ctx, bson.M{"_id": storeID}).Decode(&updatedStore)
 		if err != nil {
 			// This should not happen, but just in case
-			RespondWithError(w, http.StatusInternalServerError, "Failed to retrieve updated store")
+			RespondWithError(w, r, ErrInternal.WithMessage("Failed to retrieve updated store"))
 			return
 		}
 
+		// Record mutation
+		recordAudit(ctx, db, r, models.AuditStoreUpdate, "store", storeID, storeID, &store, &updatedStore)
+
 		// Send response
 		RespondWithJSON(w, http.StatusOK, updatedStore)
 	}
 }
 
-// DeleteStore deletes a store
+// storeHasPrices reports whether anything of the store, trashed or not, holds
+// amounts in the store's currency
+func storeHasPrices(ctx context.Context, db *models.Database, storeID primitive.ObjectID) (bool, error) {
+	for _, collection := range []string{models.ProductCollection, models.CouponCollection, models.DeliveryZoneCollection, models.CourierRateCollection} {
+		count, err := db.GetCollection(collection).CountDocuments(ctx, bson.M{"store_id": storeID}, options.Count().SetLimit(1))
+		if err != nil {
+			return false, ErrInternal.WithMessage("Failed to check store prices")
+		}
+		if count > 0 {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// DeleteStore moves a store and its products to the trash
 func DeleteStore(db *models.Database) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		// Get user ID from context
 		userID, err := getUserIDFromContext(r)
 		if err != nil {
-			RespondWithError(w, http.StatusUnauthorized, "User not authenticated")
+			RespondWithError(w, r, ErrUnauthenticated)
 			return
 		}
 
@@ -492,7 +668,7 @@ func DeleteStore(db *models.Database) http.HandlerFunc {
 		vars := mux.Vars(r)
 		storeID, err := primitive.ObjectIDFromHex(vars["id"])
 		if err != nil {
-			RespondWithError(w, http.StatusBadRequest, "Invalid store ID")
+			RespondWithError(w, r, ErrInvalidStoreID)
 			return
 		}
 
@@ -503,41 +679,50 @@ func DeleteStore(db *models.Database) http.HandlerFunc {
 		// Get stores collection
 		storesColl := db.GetCollection(models.StoreCollection)
 
-		// Check if store exists and belongs to user
-		var store models.Store
-		err = storesColl.FindOne(ctx, bson.M{"_id": storeID, "owner_id": userID}).Decode(&store)
+		// Check if store exists and the user's role allows this
+		store, err := loadStoreFor(ctx, db, storeID, userID, models.PermissionDelete)
 		if err != nil {
-			if err == mongo.ErrNoDocuments {
-				RespondWithError(w, http.StatusNotFound, "Store not found or not owned by user")
-			} else {
-				RespondWithError(w, http.StatusInternalServerError, "Failed to find store")
-			}
+			RespondWithError(w, r, err)
 			return
 		}
 
-		// Delete store
-		_, err = storesColl.DeleteOne(ctx, bson.M{"_id": storeID, "owner_id": userID})
+		// Move store to trash. A concurrent delete may have trashed it since
+		// it was loaded; its timestamp must stay the one its products got.
+		now := time.Now()
+		result, err := storesColl.UpdateOne(
+			ctx,
+			notDeleted(bson.M{"_id": storeID}),
+			bson.M{"$set": bson.M{"deleted_at": now, "updated_at": now}},
+		)
 		if err != nil {
-			RespondWithError(w, http.StatusInternalServerError, "Failed to delete store")
+			RespondWithError(w, r, ErrInternal.WithMessage("Failed to delete store"))
+			return
+		}
+		if result.MatchedCount == 0 {
+			RespondWithError(w, r, ErrStoreNotFound)
 			return
 		}
 
-		// Get products collection to delete all products for this store
+		// Trash the store's products with the same timestamp so a restore
+		// brings back exactly the products that were deleted with the store
 		productsColl := db.GetCollection(models.ProductCollection)
-		_, err = productsColl.DeleteMany(ctx, bson.M{"store_id": storeID})
+		_, err = productsColl.UpdateMany(
+			ctx,
+			notDeleted(bson.M{"store_id": storeID}),
+			bson.M{"$set": bson.M{"deleted_at": now, "updated_at": now}},
+		)
 		if err != nil {
 			// Log error but don't fail the request as the store is already deleted
-			// This is not a critical operation for this demo
-			// In a production system, this should be transactional
-			// to ensure data consistency
+			// The products stay hidden because public queries check the store first
+			log.Printf("Failed to trash products of store %s: %v", storeID.Hex(), err)
 		}
 
-		// Get users collection to update user
+		// Unselect the store; the dashboard falls back to the user's other stores
 		usersColl := db.GetCollection(models.UserCollection)
 		_, err = usersColl.UpdateOne(
 			ctx,
-			bson.M{"_id": userID},
-			bson.M{"$unset": bson.M{"store_id": ""}, "$set": bson.M{"updated_at": time.Now()}},
+			bson.M{"_id": userID, "store_id": storeID},
+			bson.M{"$unset": bson.M{"store_id": ""}, "$set": bson.M{"updated_at": now}},
 		)
 		if err != nil {
 			// Log error but don't fail the request as the store is already deleted
@@ -546,8 +731,11 @@ func DeleteStore(db *models.Database) http.HandlerFunc {
 			// to ensure data consistency
 		}
 
+		// Record mutation
+		recordAudit(ctx, db, r, models.AuditStoreDelete, "store", storeID, storeID, &store, nil)
+
 		// Send response
-		RespondWithJSON(w, http.StatusOK, map[string]string{"message": "Store deleted successfully"})
+		RespondWithJSON(w, http.StatusOK, map[string]string{"message": "Store moved to trash"})
 	}
 }
 
@@ -560,7 +748,7 @@ func GetStoreProducts(db *models.Database) http.HandlerFunc {
 		vars := mux.Vars(r)
 		storeID, err := primitive.ObjectIDFromHex(vars["storeId"])
 		if err != nil {
-			RespondWithError(w, http.StatusBadRequest, "Invalid store ID")
+			RespondWithError(w, r, ErrInvalidStoreID)
 			return
 		}
 
@@ -571,12 +759,12 @@ func GetStoreProducts(db *models.Database) http.HandlerFunc {
 		// Check if store exists
 		storesColl := db.GetCollection(models.StoreCollection)
 		var store models.Store
-		err = storesColl.FindOne(ctx, bson.M{"_id": storeID}).Decode(&store)
+		err = storesColl.FindOne(ctx, notDeleted(bson.M{"_id": storeID})).Decode(&store)
 		if err != nil {
 			if err == mongo.ErrNoDocuments {
-				RespondWithError(w, http.StatusNotFound, "Store not found")
+				RespondWithError(w, r, ErrStoreNotFound)
 			} else {
-				RespondWithError(w, http.StatusInternalServerError, "Failed to find store")
+				RespondWithError(w, r, ErrInternal.WithMessage("Failed to find store"))
 			}
 			return
 		}
@@ -590,7 +778,7 @@ func GetStoreProducts(db *models.Database) http.HandlerFunc {
 
 		// Only include active products for public viewing
 		// unless an admin token is provided
-		filter := bson.M{"store_id": storeID}
+		filter := notDeleted(bson.M{"store_id": storeID})
 
 		// Extract the userID from the token, if present
 		userID, _ := getUserIDFromContext(r)
@@ -602,7 +790,7 @@ func GetStoreProducts(db *models.Database) http.HandlerFunc {
 		// Find all products for store
 		cursor, err := productsColl.Find(ctx, filter, findOptions)
 		if err != nil {
-			RespondWithError(w, http.StatusInternalServerError, "Failed to find products")
+			RespondWithError(w, r, ErrInternal.WithMessage("Failed to find products"))
 			return
 		}
 		defer cursor.Close(ctx)
@@ -610,9 +798,10 @@ func GetStoreProducts(db *models.Database) http.HandlerFunc {
 		// Decode products
 		var products []models.Product
 		if err = cursor.All(ctx, &products); err != nil {
-			RespondWithError(w, http.StatusInternalServerError, "Failed to decode products")
+			RespondWithError(w, r, ErrInternal.WithMessage("Failed to decode products"))
 			return
 		}
+		models.ApplyPricing(products, time.Now(), models.CurrencyOf(store.Currency))
 
 		// Send response
 		RespondWithJSON(w, http.StatusOK, products)
@@ -626,7 +815,7 @@ func GetProduct(db *models.Database) http.HandlerFunc {
 		vars := mux.Vars(r)
 		id, err := primitive.ObjectIDFromHex(vars["id"])
 		if err != nil {
-			RespondWithError(w, http.StatusBadRequest, "Invalid product ID")
+			RespondWithError(w, r, ErrInvalidProductID)
 			return
 		}
 
@@ -639,17 +828,26 @@ func GetProduct(db *models.Database) http.HandlerFunc {
 
 		// Find product by ID
 		var product models.Product
-		err = productsColl.FindOne(ctx, bson.M{"_id": id}).Decode(&product)
+		err = productsColl.FindOne(ctx, notDeleted(bson.M{"_id": id})).Decode(&product)
 		if err != nil {
 			if err == mongo.ErrNoDocuments {
-				RespondWithError(w, http.StatusNotFound, "Product not found")
+				RespondWithError(w, r, ErrProductNotFound)
 			} else {
-				RespondWithError(w, http.StatusInternalServerError, "Failed to find product")
+				RespondWithError(w, r, ErrInternal.WithMessage("Failed to find product"))
 			}
 			return
 		}
 
+		// Prices are in the store's currency
+		var store models.Store
+		err = db.GetCollection(models.StoreCollection).FindOne(ctx, bson.M{"_id": product.StoreID}, options.FindOne().SetProjection(bson.M{"currency": 1})).Decode(&store)
+		if err != nil && err != mongo.ErrNoDocuments {
+			RespondWithError(w, r, ErrInternal.WithMessage("Failed to find store"))
+			return
+		}
+
 		// Send response
+		product.ApplyPricing(time.Now(), models.CurrencyOf(store.Currency))
 		RespondWithJSON(w, http.StatusOK, product)
 	}
 }
@@ -660,7 +858,7 @@ func CreateProduct(db *models.Database) http.HandlerFunc {
 		// Get user ID from context
 		userID, err := getUserIDFromContext(r)
 		if err != nil {
-			RespondWithError(w, http.StatusUnauthorized, "User not authenticated")
+			RespondWithError(w, r, ErrUnauthenticated)
 			return
 		}
 
@@ -668,13 +866,13 @@ func CreateProduct(db *models.Database) http.HandlerFunc {
 		vars := mux.Vars(r)
 		storeID, err := primitive.ObjectIDFromHex(vars["storeId"])
 		if err != nil {
-			RespondWithError(w, http.StatusBadRequest, "Invalid store ID")
+			RespondWithError(w, r, ErrInvalidStoreID)
 			return
 		}
 
 		var req models.CreateProductRequest
 		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-			RespondWithError(w, http.StatusBadRequest, "Invalid request payload")
+			RespondWithError(w, r, ErrInvalidPayload.WithDetails(err.Error()))
 			return
 		}
 
@@ -682,16 +880,18 @@ func CreateProduct(db *models.Database) http.HandlerFunc {
 		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 		defer cancel()
 
-		// Check if store exists and belongs to user
-		storesColl := db.GetCollection(models.StoreCollection)
-		var store models.Store
-		err = storesColl.FindOne(ctx, bson.M{"_id": storeID, "owner_id": userID}).Decode(&store)
+		// Check if store exists and the user's role allows this
+		store, err := loadStoreFor(ctx, db, storeID, userID, models.PermissionCatalog)
 		if err != nil {
-			if err == mongo.ErrNoDocuments {
-				RespondWithError(w, http.StatusNotFound, "Store not found or not owned by user")
-			} else {
-				RespondWithError(w, http.StatusInternalServerError, "Failed to find store")
-			}
+			RespondWithError(w, r, err)
+			return
+		}
+
+		// Amounts are in the store's currency
+		currency := models.CurrencyOf(store.Currency)
+		req.ResolveAmounts(currency)
+		if errs := req.Validate(); len(errs) > 0 {
+			RespondWithError(w, r, ErrValidationFailed.WithDetails(errs))
 			return
 		}
 
@@ -706,16 +906,28 @@ func CreateProduct(db *models.Database) http.HandlerFunc {
 		newProduct := models.Product{
 			ID:          primitive.NewObjectID(),
 			StoreID:     storeID,
+			SKU:         req.SKU,
 			Name:        req.Name,
 			Description: req.Description,
-			Price:       req.Price,
+			PriceMinor:  req.PriceMinor,
 			Image:       req.Image,
 			Category:    req.Category,
 			Stock:       req.Stock,
+			TrackStock:  req.TrackStock,
 			Featured:    req.Featured,
 			Active:      active,
 			CreatedAt:   now,
 			UpdatedAt:   now,
+
+			SalePriceMinor:      req.SalePriceMinor,
+			SaleStartsAt:        req.SaleStartsAt,
+			SaleEndsAt:          req.SaleEndsAt,
+			CompareAtPriceMinor: req.CompareAtPriceMinor,
+
+			WeightGrams: req.WeightGrams,
+			LengthCm:    req.LengthCm,
+			WidthCm:     req.WidthCm,
+			HeightCm:    req.HeightCm,
 		}
 
 		// Get products collection
@@ -724,11 +936,19 @@ func CreateProduct(db *models.Database) http.HandlerFunc {
 		// Insert product into database
 		_, err = productsColl.InsertOne(ctx, newProduct)
 		if err != nil {
-			RespondWithError(w, http.StatusInternalServerError, "Failed to create product")
+			if mongo.IsDuplicateKeyError(err) {
+				RespondWithError(w, r, ErrSKUExists)
+			} else {
+				RespondWithError(w, r, ErrInternal.WithMessage("Failed to create product"))
+			}
 			return
 		}
 
+		// Record mutation
+		recordAudit(ctx, db, r, models.AuditProductCreate, "product", newProduct.ID, storeID, nil, &newProduct)
+
 		// Send response
+		newProduct.ApplyPricing(now, currency)
 		RespondWithJSON(w, http.StatusCreated, newProduct)
 	}
 }
@@ -739,7 +959,7 @@ func UpdateProduct(db *models.Database) http.HandlerFunc {
 		// Get user ID from context
 		userID, err := getUserIDFromContext(r)
 		if err != nil {
-			RespondWithError(w, http.StatusUnauthorized, "User not authenticated")
+			RespondWithError(w, r, ErrUnauthenticated)
 			return
 		}
 
@@ -747,13 +967,13 @@ func UpdateProduct(db *models.Database) http.HandlerFunc {
 		vars := mux.Vars(r)
 		productID, err := primitive.ObjectIDFromHex(vars["id"])
 		if err != nil {
-			RespondWithError(w, http.StatusBadRequest, "Invalid product ID")
+			RespondWithError(w, r, ErrInvalidProductID)
 			return
 		}
 
 		var req models.UpdateProductRequest
 		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-			RespondWithError(w, http.StatusBadRequest, "Invalid request payload")
+			RespondWithError(w, r, ErrInvalidPayload.WithDetails(err.Error()))
 			return
 		}
 
@@ -764,41 +984,34 @@ func UpdateProduct(db *models.Database) http.HandlerFunc {
 		// Get products collection
 		productsColl := db.GetCollection(models.ProductCollection)
 
-		// Find product to get store ID
-		var product models.Product
-		err = productsColl.FindOne(ctx, bson.M{"_id": productID}).Decode(&product)
+		// Find product and check that the user owns its store
+		product, store, err := loadProductFor(ctx, db, productID, userID)
 		if err != nil {
-			if err == mongo.ErrNoDocuments {
-				RespondWithError(w, http.StatusNotFound, "Product not found")
-			} else {
-				RespondWithError(w, http.StatusInternalServerError, "Failed to find product")
-			}
+			RespondWithError(w, r, err)
 			return
 		}
 
-		// Check if user owns the store
-		storesColl := db.GetCollection(models.StoreCollection)
-		var store models.Store
-		err = storesColl.FindOne(ctx, bson.M{"_id": product.StoreID, "owner_id": userID}).Decode(&store)
-		if err != nil {
-			if err == mongo.ErrNoDocuments {
-				RespondWithError(w, http.StatusForbidden, "Not authorized to update this product")
-			} else {
-				RespondWithError(w, http.StatusInternalServerError, "Failed to verify ownership")
-			}
+		// Amounts are in the store's currency
+		currency := models.CurrencyOf(store.Currency)
+		req.ResolveAmounts(currency)
+		if errs := req.Validate(); len(errs) > 0 {
+			RespondWithError(w, r, ErrValidationFailed.WithDetails(errs))
 			return
 		}
 
 		// Build update document
 		update := bson.M{"updated_at": time.Now()}
+		if req.SKU != "" {
+			update["sku"] = req.SKU
+		}
 		if req.Name != "" {
 			update["name"] = req.Name
 		}
 		if req.Description != "" {
 			update["description"] = req.Description
 		}
-		if req.Price != nil {
-			update["price"] = *req.Price
+		if req.PriceMinor != nil {
+			update["price_minor"] = *req.PriceMinor
 		}
 		if req.Image != "" {
 			update["image"] = req.Image
@@ -809,26 +1022,101 @@ func UpdateProduct(db *models.Database) http.HandlerFunc {
 		if req.Stock != nil {
 			update["stock"] = *req.Stock
 		}
+		if req.TrackStock != nil {
+			update["track_stock"] = *req.TrackStock
+		}
 		if req.Featured != nil {
 			update["featured"] = *req.Featured
 		}
 		if req.Active != nil {
 			update["active"] = *req.Active
 		}
+		if req.WeightGrams != nil {
+			update["weight_g"] = *req.WeightGrams
+		}
+		if req.LengthCm != nil {
+			update["length_cm"] = *req.LengthCm
+		}
+		if req.WidthCm != nil {
+			update["width_cm"] = *req.WidthCm
+		}
+		if req.HeightCm != nil {
+			update["height_cm"] = *req.HeightCm
+		}
+
+		// Sale pricing; a zero sale price ends the sale
+		unset := bson.M{}
+		if req.SaleStartsAt != nil {
+			update["sale_starts_at"] = *req.SaleStartsAt
+		}
+		if req.SaleEndsAt != nil {
+			update["sale_ends_at"] = *req.SaleEndsAt
+		}
+		if req.SalePriceMinor != nil {
+			if *req.SalePriceMinor > 0 {
+				update["sale_price_minor"] = *req.SalePriceMinor
+			} else {
+				unset["sale_price_minor"] = ""
+				unset["sale_starts_at"] = ""
+				unset["sale_ends_at"] = ""
+				delete(update, "sale_starts_at")
+				delete(update, "sale_ends_at")
+			}
+		}
+		if req.CompareAtPriceMinor != nil {
+			if *req.CompareAtPriceMinor > 0 {
+				update["compare_at_price_minor"] = *req.CompareAtPriceMinor
+			} else {
+				unset["compare_at_price_minor"] = ""
+			}
+		}
+		if req.Featured != nil {
+			// The owner has taken over the badge from the sale scheduler
+			unset["sale_featured"] = ""
+		}
+
+		// Check the sale against the resulting price and window
+		merged := product
+		if req.PriceMinor != nil {
+			merged.PriceMinor = *req.PriceMinor
+		}
+		if req.SalePriceMinor != nil {
+			merged.SalePriceMinor = *req.SalePriceMinor
+		}
+		if req.SaleStartsAt != nil {
+			merged.SaleStartsAt = req.SaleStartsAt
+		}
+		if req.SaleEndsAt != nil {
+			merged.SaleEndsAt = req.SaleEndsAt
+		}
+		if merged.SalePriceMinor > 0 {
+			if errs := models.ValidateSale(merged); len(errs) > 0 {
+				RespondWithError(w, r, ErrValidationFailed.WithDetails(errs))
+				return
+			}
+		}
 
 		// Update product
+		changes := bson.M{"$set": update}
+		if len(unset) > 0 {
+			changes["$unset"] = unset
+		}
 		result, err := productsColl.UpdateOne(
 			ctx,
 			bson.M{"_id": productID},
-			bson.M{"$set": update},
+			changes,
 		)
 		if err != nil {
-			RespondWithError(w, http.StatusInternalServerError, "Failed to update product")
+			if mongo.IsDuplicateKeyError(err) {
+				RespondWithError(w, r, ErrSKUExists)
+			} else {
+				RespondWithError(w, r, ErrInternal.WithMessage("Failed to update product"))
+			}
 			return
 		}
 
 		if result.ModifiedCount == 0 {
-			RespondWithError(w, http.StatusNotFound, "Product not found or not modified")
+			RespondWithError(w, r, ErrProductNotFound.WithMessage("Product not found or not modified"))
 			return
 		}
 
@@ -837,22 +1125,26 @@ func UpdateProduct(db *models.Database) http.HandlerFunc {
 		err = productsColl.FindOne(ctx, bson.M{"_id": productID}).Decode(&updatedProduct)
 		if err != nil {
 			// This should not happen, but just in case
-			RespondWithError(w, http.StatusInternalServerError, "Failed to retrieve updated product")
+			RespondWithError(w, r, ErrInternal.WithMessage("Failed to retrieve updated product"))
 			return
 		}
 
+		// Record mutation
+		recordAudit(ctx, db, r, models.AuditProductUpdate, "product", productID, product.StoreID, &product, &updatedProduct)
+
 		// Send response
+		updatedProduct.ApplyPricing(time.Now(), currency)
 		RespondWithJSON(w, http.StatusOK, updatedProduct)
 	}
 }
 
-// DeleteProduct deletes a product
+// DeleteProduct moves a product to the trash
 func DeleteProduct(db *models.Database) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		// Get user ID from context
 		userID, err := getUserIDFromContext(r)
 		if err != nil {
-			RespondWithError(w, http.StatusUnauthorized, "User not authenticated")
+			RespondWithError(w, r, ErrUnauthenticated)
 			return
 		}
 
@@ -860,7 +1152,7 @@ func DeleteProduct(db *models.Database) http.HandlerFunc {
 		vars := mux.Vars(r)
 		productID, err := primitive.ObjectIDFromHex(vars["id"])
 		if err != nil {
-			RespondWithError(w, http.StatusBadRequest, "Invalid product ID")
+			RespondWithError(w, r, ErrInvalidProductID)
 			return
 		}
 
@@ -871,44 +1163,32 @@ func DeleteProduct(db *models.Database) http.HandlerFunc {
 		// Get products collection
 		productsColl := db.GetCollection(models.ProductCollection)
 
-		// Find product to get store ID
-		var product models.Product
-		err = productsColl.FindOne(ctx, bson.M{"_id": productID}).Decode(&product)
+		// Find product and check that the user owns its store
+		product, store, err := loadProductFor(ctx, db, productID, userID)
 		if err != nil {
-			if err == mongo.ErrNoDocuments {
-				RespondWithError(w, http.StatusNotFound, "Product not found")
-			} else {
-				RespondWithError(w, http.StatusInternalServerError, "Failed to find product")
-			}
+			RespondWithError(w, r, err)
 			return
 		}
 
-		// Check if user owns the store
-		storesColl := db.GetCollection(models.StoreCollection)
-		var store models.Store
-		err = storesColl.FindOne(ctx, bson.M{"_id": product.StoreID, "owner_id": userID}).Decode(&store)
-		if err != nil {
-			if err == mongo.ErrNoDocuments {
-				RespondWithError(w, http.StatusForbidden, "Not authorized to delete this product")
-			} else {
-				RespondWithError(w, http.StatusInternalServerError, "Failed to verify ownership")
-			}
-			return
-		}
-
-		// Delete product
-		_, err = productsColl.DeleteOne(ctx, bson.M{"_id": productID})
+		// Move product to trash
+		now := time.Now()
+		_, err = productsColl.UpdateOne(
+			ctx,
+			bson.M{"_id": productID},
+			bson.M{"$set": bson.M{"deleted_at": now, "updated_at": now}},
+		)
 		if err != nil {
-			RespondWithError(w, http.StatusInternalServerError, "Failed to delete product")
+			RespondWithError(w, r, ErrInternal.WithMessage("Failed to delete product"))
 			return
 		}
 
 		// If the product was featured in the store, update store to remove featured product
 		if store.FeaturedProduct == productID {
+			storesColl := db.GetCollection(models.StoreCollection)
 			_, err = storesColl.UpdateOne(
 				ctx,
 				bson.M{"_id": store.ID},
-				bson.M{"$unset": bson.M{"featured_product": ""}, "$set": bson.M{"updated_at": time.Now()}},
+				bson.M{"$unset": bson.M{"featured_product": ""}, "$set": bson.M{"updated_at": now}},
 			)
 			if err != nil {
 				// Log error but don't fail the request as the product is already deleted
@@ -916,7 +1196,10 @@ func DeleteProduct(db *models.Database) http.HandlerFunc {
 			}
 		}
 
+		// Record mutation
+		recordAudit(ctx, db, r, models.AuditProductDelete, "product", productID, product.StoreID, &product, nil)
+
 		// Send response
-		RespondWithJSON(w, http.StatusOK, map[string]string{"message": "Product deleted successfully"})
+		RespondWithJSON(w, http.StatusOK, map[string]string{"message": "Product moved to trash"})
 	}
-}
\ No newline at end of file
+}
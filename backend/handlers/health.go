@@ -0,0 +1,83 @@
+package handlers
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"time"
+
+	"wacatalogue/backend/models"
+)
+
+// Readiness checks use a short timeout so a hung database fails the probe quickly
+const readinessTimeout = 2 * time.Second
+
+// ComponentStatus reports the health of a single dependency
+type ComponentStatus struct {
+	Status    string   `json:"status"` // "up", "down" or "degraded"
+	LatencyMs int64    `json:"latencyMs,omitempty"`
+	Missing   []string `json:"missing,omitempty"`
+	Error     string   `json:"error,omitempty"`
+}
+
+// HealthResponse represents the response body for the health endpoints
+type HealthResponse struct {
+	Status     string                     `json:"status"` // "ok", "degraded" or "unavailable"
+	Components map[string]ComponentStatus `json:"components,omitempty"`
+}
+
+// Livez reports that the process is running and able to serve requests
+func Livez() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		RespondWithJSON(w, http.StatusOK, HealthResponse{Status: "ok"})
+	}
+}
+
+// Readyz reports whether the instance's dependencies are usable. Missing
+// indexes only degrade it: an index that failed to build, e.g. on duplicate
+// data, needs an operator and would otherwise keep the instance out of
+// service until then. The failure is logged when indexes are built.
+// Database errors are logged rather than shown to unauthenticated callers.
+func Readyz(db *models.Database) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), readinessTimeout)
+		defer cancel()
+
+		response := HealthResponse{
+			Status:     "ok",
+			Components: map[string]ComponentStatus{},
+		}
+
+		// Ping MongoDB
+		start := time.Now()
+		if err := db.Ping(ctx); err != nil {
+			log.Printf("Readiness: MongoDB ping failed: %v", err)
+			response.Components["mongodb"] = ComponentStatus{Status: "down", Error: "ping failed"}
+			response.Components["indexes"] = ComponentStatus{Status: "down", Error: "database unreachable"}
+			response.Status = "unavailable"
+			RespondWithJSON(w, http.StatusServiceUnavailable, response)
+			return
+		}
+		response.Components["mongodb"] = ComponentStatus{
+			Status:    "up",
+			LatencyMs: time.Since(start).Milliseconds(),
+		}
+
+		// Check required indexes
+		missing, err := db.MissingIndexes(ctx)
+		switch {
+		case err != nil:
+			log.Printf("Readiness: failed to list indexes: %v", err)
+			response.Components["indexes"] = ComponentStatus{Status: "degraded", Error: "indexes could not be listed"}
+		case len(missing) > 0:
+			response.Components["indexes"] = ComponentStatus{Status: "degraded", Missing: missing}
+		default:
+			response.Components["indexes"] = ComponentStatus{Status: "up"}
+		}
+		if response.Components["indexes"].Status == "degraded" {
+			response.Status = "degraded"
+		}
+
+		RespondWithJSON(w, http.StatusOK, response)
+	}
+}
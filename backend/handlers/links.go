@@ -0,0 +1,69 @@
+package handlers
+
+import (
+	"net/http"
+	"os"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// publicBaseURL returns the externally visible origin of the storefront, from
+// PUBLIC_BASE_URL when set and otherwise from the incoming request. Forwarded
+// host and scheme headers are only honoured from TRUSTED_PROXIES, as the
+// result ends up in cached feeds and sitemaps.
+func publicBaseURL(r *http.Request) string {
+	if base := os.Getenv("PUBLIC_BASE_URL"); base != "" {
+		return strings.TrimRight(base, "/")
+	}
+
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	host := r.Host
+	if !trustedProxy(remoteIP(r)) {
+		return scheme + "://" + host
+	}
+	if forwarded := r.Header.Get("X-Forwarded-Proto"); forwarded != "" {
+		scheme = strings.TrimSpace(strings.Split(forwarded, ",")[0])
+	}
+	if forwarded := r.Header.Get("X-Forwarded-Host"); forwarded != "" {
+		host = strings.TrimSpace(strings.Split(forwarded, ",")[0])
+	}
+	return scheme + "://" + host
+}
+
+// storeURL returns the server-rendered page of a store
+func storeURL(base string, storeID primitive.ObjectID) string {
+	return base + "/store/" + storeID.Hex()
+}
+
+// productURL returns the server-rendered page of a product
+func productURL(base string, storeID, productID primitive.ObjectID) string {
+	return storeURL(base, storeID) + "/products/" + productID.Hex()
+}
+
+// absoluteURL resolves an image or asset reference against the public base.
+// References that can't be fetched by a crawler, such as data URIs, return "".
+func absoluteURL(base, ref string) string {
+	ref = strings.TrimSpace(ref)
+	switch {
+	case ref == "":
+		return ""
+	case strings.HasPrefix(ref, "http://"), strings.HasPrefix(ref, "https://"):
+		return ref
+	case strings.HasPrefix(ref, "//"):
+		return "https:" + ref
+	case strings.HasPrefix(ref, "/"):
+		return base + ref
+	case strings.Contains(ref, ":"):
+		return "" // data:, blob: and other non-fetchable schemes
+	}
+	return base + "/" + ref
+}
+
+// inviteURL returns the dashboard page that accepts a store invitation
+func inviteURL(base, token string) string {
+	return base + "/#/admin/invite/" + token
+}
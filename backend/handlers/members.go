@@ -0,0 +1,484 @@
+package handlers
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"wacatalogue/backend/models"
+)
+
+// GetStoreTeam lists the members and pending invitations of one of the user's stores
+func GetStoreTeam(db *models.Database) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// Get user ID from context
+		userID, err := getUserIDFromContext(r)
+		if err != nil {
+			RespondWithError(w, r, ErrUnauthenticated)
+			return
+		}
+
+		// Get store ID from URL
+		vars := mux.Vars(r)
+		storeID, err := primitive.ObjectIDFromHex(vars["id"])
+		if err != nil {
+			RespondWithError(w, r, ErrInvalidStoreID)
+			return
+		}
+
+		// Create database context
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		// Check if store exists and the user's role allows this
+		store, err := loadStoreFor(ctx, db, storeID, userID, models.PermissionMembers)
+		if err != nil {
+			RespondWithError(w, r, err)
+			return
+		}
+
+		response := models.StoreTeamResponse{OwnerID: store.OwnerID, Members: []models.StoreMember{}, Invites: []models.StoreInvite{}}
+		findOptions := options.Find().SetSort(bson.D{{Key: "created_at", Value: 1}})
+		cursor, err := db.GetCollection(models.StoreMemberCollection).Find(ctx, bson.M{"store_id": storeID}, findOptions)
+		if err != nil {
+			RespondWithError(w, r, ErrInternal.WithMessage("Failed to find store members"))
+			return
+		}
+		if err = cursor.All(ctx, &response.Members); err != nil {
+			RespondWithError(w, r, ErrInternal.WithMessage("Failed to decode store members"))
+			return
+		}
+
+		pending := bson.M{"store_id": storeID, "accepted_at": bson.M{"$exists": false}, "expires_at": bson.M{"$gt": time.Now()}}
+		cursor, err = db.GetCollection(models.StoreInviteCollection).Find(ctx, pending, findOptions)
+		if err != nil {
+			RespondWithError(w, r, ErrInternal.WithMessage("Failed to find invitations"))
+			return
+		}
+		if err = cursor.All(ctx, &response.Invites); err != nil {
+			RespondWithError(w, r, ErrInternal.WithMessage("Failed to decode invitations"))
+			return
+		}
+
+		// Send response
+		RespondWithJSON(w, http.StatusOK, response)
+	}
+}
+
+// CreateStoreInvite invites someone to help run one of the user's stores. The
+// link in the response is the only copy of the invitation token; invitations
+// for an email address are also emailed the link through mailer, when one is
+// configured. Otherwise the owner shares the link themselves.
+func CreateStoreInvite(db *models.Database, mailer Notifier) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// Get user ID from context
+		userID, err := getUserIDFromContext(r)
+		if err != nil {
+			RespondWithError(w, r, ErrUnauthenticated)
+			return
+		}
+
+		// Get store ID from URL
+		vars := mux.Vars(r)
+		storeID, err := primitive.ObjectIDFromHex(vars["id"])
+		if err != nil {
+			RespondWithError(w, r, ErrInvalidStoreID)
+			return
+		}
+
+		var req models.CreateInviteRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			RespondWithError(w, r, ErrInvalidPayload.WithDetails(err.Error()))
+			return
+		}
+		req.Email = strings.TrimSpace(req.Email)
+		if errs := req.Validate(); len(errs) > 0 {
+			RespondWithError(w, r, ErrValidationFailed.WithDetails(errs))
+			return
+		}
+
+		// Create database context
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		// Check if store exists and the user's role allows this
+		store, err := loadStoreFor(ctx, db, storeID, userID, models.PermissionMembers)
+		if err != nil {
+			RespondWithError(w, r, err)
+			return
+		}
+
+		token, err := newInviteToken()
+		if err != nil {
+			RespondWithError(w, r, ErrInternal.WithMessage("Failed to create invitation token"))
+			return
+		}
+		now := time.Now()
+		invite := models.StoreInvite{
+			ID:        primitive.NewObjectID(),
+			StoreID:   storeID,
+			StoreName: store.Name,
+			Role:      req.Role,
+			Email:     strings.ToLower(req.Email),
+			TokenHash: inviteTokenHash(token),
+			InvitedBy: userID,
+			ExpiresAt: now.Add(models.InviteTTL),
+			CreatedAt: now,
+		}
+
+		// Insert invitation
+		if _, err = db.GetCollection(models.StoreInviteCollection).InsertOne(ctx, invite); err != nil {
+			RespondWithError(w, r, ErrInternal.WithMessage("Failed to create invitation"))
+			return
+		}
+
+		// Record mutation
+		recordAudit(ctx, db, r, models.AuditInviteCreate, "invite", invite.ID, storeID, nil, &invite)
+
+		response := models.CreateInviteResponse{
+			Invite: invite,
+			Token:  token,
+			URL:    inviteURL(publicBaseURL(r), token),
+		}
+		if invite.Email != "" && mailer != nil {
+			if err := mailer.Notify(ctx, inviteNotification(invite, response.URL)); err != nil {
+				log.Printf("Failed to email invitation %s: %v", invite.ID.Hex(), err)
+			} else {
+				response.Emailed = true
+			}
+		}
+
+		// Send response
+		RespondWithJSON(w, http.StatusCreated, response)
+	}
+}
+
+// inviteNotification formats the email inviting someone to a store
+func inviteNotification(invite models.StoreInvite, url string) models.Notification {
+	subject := "You're invited to help run " + invite.StoreName
+	body := "You have been invited to join " + invite.StoreName + " as " + invite.Role + ".\n\n" +
+		"Sign in or create an account with this email address, then open this link to accept:\n" + url + "\n\n" +
+		"The link expires on " + invite.ExpiresAt.UTC().Format("2 January 2006") + ". If you weren't expecting it, you can ignore this email."
+	return models.Notification{
+		ID:        primitive.NewObjectID(),
+		StoreID:   invite.StoreID,
+		Event:     models.NotificationStoreInvite,
+		Channel:   models.NotifyEmail,
+		Recipient: invite.Email,
+		Subject:   subject,
+		Body:      body,
+		CreatedAt: invite.CreatedAt,
+		UpdatedAt: invite.CreatedAt,
+	}
+}
+
+// RevokeStoreInvite deletes a pending invitation
+func RevokeStoreInvite(db *models.Database) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// Get user ID from context
+		userID, err := getUserIDFromContext(r)
+		if err != nil {
+			RespondWithError(w, r, ErrUnauthenticated)
+			return
+		}
+
+		// Get invitation ID from URL
+		vars := mux.Vars(r)
+		inviteID, err := primitive.ObjectIDFromHex(vars["id"])
+		if err != nil {
+			RespondWithError(w, r, ErrInvalidInviteID)
+			return
+		}
+
+		// Create database context
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		invitesColl := db.GetCollection(models.StoreInviteCollection)
+		var invite models.StoreInvite
+		if err := invitesColl.FindOne(ctx, bson.M{"_id": inviteID, "accepted_at": bson.M{"$exists": false}}).Decode(&invite); err != nil {
+			if err == mongo.ErrNoDocuments {
+				RespondWithError(w, r, ErrInviteNotFound)
+			} else {
+				RespondWithError(w, r, ErrInternal.WithMessage("Failed to find invitation"))
+			}
+			return
+		}
+		if _, err := loadStoreFor(ctx, db, invite.StoreID, userID, models.PermissionMembers); err != nil {
+			if err == ErrStoreNotFound {
+				err = ErrInviteNotFound
+			}
+			RespondWithError(w, r, err)
+			return
+		}
+
+		// Delete invitation
+		if _, err := invitesColl.DeleteOne(ctx, bson.M{"_id": inviteID}); err != nil {
+			RespondWithError(w, r, ErrInternal.WithMessage("Failed to revoke invitation"))
+			return
+		}
+
+		// Record mutation
+		recordAudit(ctx, db, r, models.AuditInviteRevoke, "invite", inviteID, invite.StoreID, &invite, nil)
+
+		// Send response
+		RespondWithJSON(w, http.StatusOK, map[string]string{"message": "Invitation revoked"})
+	}
+}
+
+// AcceptStoreInvite gives the current user the role of an invitation
+func AcceptStoreInvite(db *models.Database) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// Get user ID from context
+		userID, err := getUserIDFromContext(r)
+		if err != nil {
+			RespondWithError(w, r, ErrUnauthenticated)
+			return
+		}
+
+		var req models.AcceptInviteRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			RespondWithError(w, r, ErrInvalidPayload.WithDetails(err.Error()))
+			return
+		}
+		if req.Token == "" {
+			RespondWithError(w, r, ErrValidationFailed.WithDetails([]models.FieldError{{Field: "token", Message: "is required"}}))
+			return
+		}
+
+		// Create database context
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		// Find invitation
+		invitesColl := db.GetCollection(models.StoreInviteCollection)
+		var invite models.StoreInvite
+		if err := invitesColl.FindOne(ctx, bson.M{"token_hash": inviteTokenHash(req.Token)}).Decode(&invite); err != nil {
+			if err == mongo.ErrNoDocuments {
+				RespondWithError(w, r, ErrInviteNotFound)
+			} else {
+				RespondWithError(w, r, ErrInternal.WithMessage("Failed to find invitation"))
+			}
+			return
+		}
+		now := time.Now()
+		if invite.AcceptedAt != nil || !invite.ExpiresAt.After(now) {
+			RespondWithError(w, r, ErrInviteExpired)
+			return
+		}
+
+		// Email invitations are for one person
+		var user models.User
+		if err := db.GetCollection(models.UserCollection).FindOne(ctx, bson.M{"_id": userID}).Decode(&user); err != nil {
+			RespondWithError(w, r, ErrInternal.WithMessage("Failed to find user"))
+			return
+		}
+		if invite.Email != "" && !strings.EqualFold(invite.Email, user.Email) {
+			RespondWithError(w, r, ErrForbidden.WithMessage("This invitation is for another email address"))
+			return
+		}
+
+		var store models.Store
+		if err := db.GetCollection(models.StoreCollection).FindOne(ctx, notDeleted(bson.M{"_id": invite.StoreID})).Decode(&store); err != nil {
+			if err == mongo.ErrNoDocuments {
+				RespondWithError(w, r, ErrStoreNotFound)
+			} else {
+				RespondWithError(w, r, ErrInternal.WithMessage("Failed to find store"))
+			}
+			return
+		}
+		if store.OwnerID == userID {
+			RespondWithError(w, r, ErrAlreadyMember)
+			return
+		}
+
+		// Insert member
+		member := models.StoreMember{
+			ID:        primitive.NewObjectID(),
+			StoreID:   store.ID,
+			UserID:    userID,
+			Username:  user.Username,
+			Role:      invite.Role,
+			InvitedBy: invite.InvitedBy,
+			CreatedAt: now,
+			UpdatedAt: now,
+		}
+		membersColl := db.GetCollection(models.StoreMemberCollection)
+		if _, err := membersColl.InsertOne(ctx, member); err != nil {
+			if mongo.IsDuplicateKeyError(err) {
+				RespondWithError(w, r, ErrAlreadyMember)
+			} else {
+				RespondWithError(w, r, ErrInternal.WithMessage("Failed to add store member"))
+			}
+			return
+		}
+
+		// Use up the invitation; if another user got there first, undo the membership
+		result, err := invitesColl.UpdateOne(
+			ctx,
+			bson.M{"_id": invite.ID, "accepted_at": bson.M{"$exists": false}},
+			bson.M{"$set": bson.M{"accepted_at": now, "accepted_by": userID}},
+		)
+		if err != nil || result.MatchedCount == 0 {
+			membersColl.DeleteOne(ctx, bson.M{"_id": member.ID})
+			if err != nil {
+				RespondWithError(w, r, ErrInternal.WithMessage("Failed to accept invitation"))
+			} else {
+				RespondWithError(w, r, ErrInviteExpired)
+			}
+			return
+		}
+
+		// Record mutation
+		recordAudit(ctx, db, r, models.AuditMemberJoin, "member", member.ID, store.ID, nil, &member)
+
+		// Send response
+		RespondWithJSON(w, http.StatusCreated, member)
+	}
+}
+
+// UpdateStoreMember changes the role of a store member
+func UpdateStoreMember(db *models.Database) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// Get user ID from context
+		userID, err := getUserIDFromContext(r)
+		if err != nil {
+			RespondWithError(w, r, ErrUnauthenticated)
+			return
+		}
+
+		// Get member ID from URL
+		vars := mux.Vars(r)
+		memberID, err := primitive.ObjectIDFromHex(vars["id"])
+		if err != nil {
+			RespondWithError(w, r, ErrInvalidMemberID)
+			return
+		}
+
+		var req models.UpdateMemberRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			RespondWithError(w, r, ErrInvalidPayload.WithDetails(err.Error()))
+			return
+		}
+		if errs := req.Validate(); len(errs) > 0 {
+			RespondWithError(w, r, ErrValidationFailed.WithDetails(errs))
+			return
+		}
+
+		// Create database context
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		member, err := loadMemberFor(ctx, db, memberID, userID)
+		if err != nil {
+			RespondWithError(w, r, err)
+			return
+		}
+
+		// Update member
+		membersColl := db.GetCollection(models.StoreMemberCollection)
+		_, err = membersColl.UpdateOne(
+			ctx,
+			bson.M{"_id": memberID},
+			bson.M{"$set": bson.M{"role": req.Role, "updated_at": time.Now()}},
+		)
+		if err != nil {
+			RespondWithError(w, r, ErrInternal.WithMessage("Failed to update store member"))
+			return
+		}
+
+		// Get updated member
+		var updatedMember models.StoreMember
+		if err = membersColl.FindOne(ctx, bson.M{"_id": memberID}).Decode(&updatedMember); err != nil {
+			RespondWithError(w, r, ErrInternal.WithMessage("Failed to retrieve updated store member"))
+			return
+		}
+
+		// Record mutation
+		recordAudit(ctx, db, r, models.AuditMemberUpdate, "member", memberID, member.StoreID, &member, &updatedMember)
+
+		// Send response
+		RespondWithJSON(w, http.StatusOK, updatedMember)
+	}
+}
+
+// RemoveStoreMember takes a member's role away. Members may also remove
+// themselves to leave a store.
+func RemoveStoreMember(db *models.Database) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// Get user ID from context
+		userID, err := getUserIDFromContext(r)
+		if err != nil {
+			RespondWithError(w, r, ErrUnauthenticated)
+			return
+		}
+
+		// Get member ID from URL
+		vars := mux.Vars(r)
+		memberID, err := primitive.ObjectIDFromHex(vars["id"])
+		if err != nil {
+			RespondWithError(w, r, ErrInvalidMemberID)
+			return
+		}
+
+		// Create database context
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		membersColl := db.GetCollection(models.StoreMemberCollection)
+		var member models.StoreMember
+		err = membersColl.FindOne(ctx, bson.M{"_id": memberID, "user_id": userID}).Decode(&member)
+		if err == mongo.ErrNoDocuments {
+			// Not leaving, so the user must be allowed to manage members
+			member, err = loadMemberFor(ctx, db, memberID, userID)
+		}
+		if err != nil {
+			if _, ok := err.(*APIError); !ok {
+				err = ErrInternal.WithMessage("Failed to find store member")
+			}
+			RespondWithError(w, r, err)
+			return
+		}
+
+		// Delete member
+		if _, err := membersColl.DeleteOne(ctx, bson.M{"_id": memberID}); err != nil {
+			RespondWithError(w, r, ErrInternal.WithMessage("Failed to remove store member"))
+			return
+		}
+
+		// Record mutation
+		recordAudit(ctx, db, r, models.AuditMemberRemove, "member", memberID, member.StoreID, &member, nil)
+
+		// Send response
+		RespondWithJSON(w, http.StatusOK, map[string]string{"message": "Store member removed"})
+	}
+}
+
+// newInviteToken returns a random 256-bit token for an invitation link
+func newInviteToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// inviteTokenHash is what is stored of an invitation token, so a leaked
+// database can't be used to join stores
+func inviteTokenHash(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
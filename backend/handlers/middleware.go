@@ -2,11 +2,19 @@ package handlers
 
 import (
 	"context"
+	"crypto/rand"
+	"encoding/hex"
 	"net/http"
+	"regexp"
 	"strings"
+	"time"
 
 	"github.com/dgrijalva/jwt-go"
+	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+
+	"wacatalogue/backend/models"
 )
 
 // AuthMiddleware checks for a valid JWT token
@@ -15,14 +23,14 @@ func AuthMiddleware(next http.Handler) http.Handler {
 		// Extract token from Authorization header
 		authHeader := r.Header.Get("Authorization")
 		if authHeader == "" {
-			RespondWithError(w, http.StatusUnauthorized, "Authorization header required")
+			RespondWithError(w, r, ErrUnauthenticated.WithMessage("Authorization header required"))
 			return
 		}
 
 		// Expected format: "Bearer {token}"
 		tokenParts := strings.Split(authHeader, " ")
 		if len(tokenParts) != 2 || tokenParts[0] != "Bearer" {
-			RespondWithError(w, http.StatusUnauthorized, "Invalid authorization format, expected 'Bearer {token}'")
+			RespondWithError(w, r, ErrInvalidToken.WithMessage("Invalid authorization format, expected 'Bearer {token}'"))
 			return
 		}
 
@@ -38,7 +46,7 @@ func AuthMiddleware(next http.Handler) http.Handler {
 		})
 
 		if err != nil {
-			RespondWithError(w, http.StatusUnauthorized, "Invalid or expired token")
+			RespondWithError(w, r, ErrInvalidToken)
 			return
 		}
 
@@ -47,7 +55,7 @@ func AuthMiddleware(next http.Handler) http.Handler {
 			// Add user ID and username to request context
 			userID, err := primitive.ObjectIDFromHex(claims["userId"].(string))
 			if err != nil {
-				RespondWithError(w, http.StatusUnauthorized, "Invalid user ID in token")
+				RespondWithError(w, r, ErrInvalidToken.WithMessage("Invalid user ID in token"))
 				return
 			}
 
@@ -60,12 +68,72 @@ func AuthMiddleware(next http.Handler) http.Handler {
 			// Call next handler with updated context
 			next.ServeHTTP(w, r.WithContext(ctx))
 		} else {
-			RespondWithError(w, http.StatusUnauthorized, "Invalid token claims")
+			RespondWithError(w, r, ErrInvalidToken.WithMessage("Invalid token claims"))
 			return
 		}
 	})
 }
 
+// AdminMiddleware only lets users with the admin role through. It must run after AuthMiddleware.
+func AdminMiddleware(db *models.Database) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			userID, err := getUserIDFromContext(r)
+			if err != nil {
+				RespondWithError(w, r, ErrUnauthenticated)
+				return
+			}
+
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			defer cancel()
+
+			var user models.User
+			err = db.GetCollection(models.UserCollection).FindOne(ctx, bson.M{"_id": userID}).Decode(&user)
+			if err != nil {
+				if err == mongo.ErrNoDocuments {
+					RespondWithError(w, r, ErrUnauthenticated)
+				} else {
+					RespondWithError(w, r, ErrInternal.WithMessage("Failed to find user"))
+				}
+				return
+			}
+
+			if user.Role != "admin" {
+				RespondWithError(w, r, ErrForbidden.WithMessage("Admin role required"))
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// RequestIDMiddleware tags every request with an ID, reusing a well-formed
+// incoming X-Request-ID header so IDs can be correlated across services
+func RequestIDMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestID := r.Header.Get("X-Request-ID")
+		if !requestIDPattern.MatchString(requestID) {
+			requestID = newRequestID()
+		}
+
+		w.Header().Set("X-Request-ID", requestID)
+		ctx := context.WithValue(r.Context(), "requestID", requestID)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+var requestIDPattern = regexp.MustCompile(`^[A-Za-z0-9._-]{1,64}$`)
+
+// newRequestID returns a random 128-bit hex ID
+func newRequestID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return primitive.NewObjectID().Hex()
+	}
+	return hex.EncodeToString(b)
+}
+
 // CORSMiddleware adds CORS headers to responses
 func CORSMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -101,4 +169,10 @@ func getUsernameFromContext(r *http.Request) (string, error) {
 		return "", jwt.NewValidationError("Username not found in context", jwt.ValidationErrorMalformed)
 	}
 	return username, nil
-}
\ No newline at end of file
+}
+
+// Helper function to get the request ID from request context
+func getRequestIDFromContext(r *http.Request) string {
+	requestID, _ := r.Context().Value("requestID").(string)
+	return requestID
+}
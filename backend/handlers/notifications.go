@@ -0,0 +1,291 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"wacatalogue/backend/models"
+)
+
+// A notification being sent is not picked up again for this long, so one
+// left behind by a crashed server is retried once the lease runs out
+const notificationLease = 2 * time.Minute
+
+// Each delivery pass sends at most this many notifications
+const notificationBatch = 50
+
+// notificationWake starts a delivery pass early when notifications are queued
+var notificationWake = make(chan struct{}, 1)
+
+// StartNotificationDelivery periodically sends pending notifications through
+// the notifier of their channel, retrying failures with backoff. Checkout
+// wakes it up, so new orders are usually sent within moments.
+func StartNotificationDelivery(ctx context.Context, db *models.Database, notifiers map[string]Notifier, interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			deliverNotifications(ctx, db, notifiers)
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			case <-notificationWake:
+			}
+		}
+	}()
+}
+
+// GetStoreNotifications lists the notifications sent about one of the
+// user's stores, newest first, optionally only those with a status
+func GetStoreNotifications(db *models.Database) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// Get user ID from context
+		userID, err := getUserIDFromContext(r)
+		if err != nil {
+			RespondWithError(w, r, ErrUnauthenticated)
+			return
+		}
+
+		// Get store ID from URL
+		vars := mux.Vars(r)
+		storeID, err := primitive.ObjectIDFromHex(vars["id"])
+		if err != nil {
+			RespondWithError(w, r, ErrInvalidStoreID)
+			return
+		}
+
+		filter := bson.M{"store_id": storeID}
+		switch status := r.URL.Query().Get("status"); status {
+		case "":
+		case models.NotificationPending, models.NotificationSent, models.NotificationFailed:
+			filter["status"] = status
+		default:
+			RespondWithError(w, r, ErrValidationFailed.WithDetails([]models.FieldError{{Field: "status", Message: "must be one of pending, sent, failed"}}))
+			return
+		}
+
+		// Create database context
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		// Check if store exists and the user's role allows this
+		if _, err := loadStoreFor(ctx, db, storeID, userID, models.PermissionSettings); err != nil {
+			RespondWithError(w, r, err)
+			return
+		}
+
+		notificationsColl := db.GetCollection(models.NotificationCollection)
+		total, err := notificationsColl.CountDocuments(ctx, filter)
+		if err != nil {
+			RespondWithError(w, r, ErrInternal.WithMessage("Failed to count notifications"))
+			return
+		}
+
+		page, limit := parsePagination(r, 20, 100)
+		findOptions := options.Find()
+		findOptions.SetSort(bson.D{{Key: "created_at", Value: -1}})
+		findOptions.SetSkip((page - 1) * limit)
+		findOptions.SetLimit(limit)
+
+		cursor, err := notificationsColl.Find(ctx, filter, findOptions)
+		if err != nil {
+			RespondWithError(w, r, ErrInternal.WithMessage("Failed to find notifications"))
+			return
+		}
+		notifications := []models.Notification{}
+		if err = cursor.All(ctx, &notifications); err != nil {
+			RespondWithError(w, r, ErrInternal.WithMessage("Failed to decode notifications"))
+			return
+		}
+
+		// Send response
+		RespondWithJSON(w, http.StatusOK, models.NotificationListResponse{Notifications: notifications, Total: total, Page: page, Limit: limit})
+	}
+}
+
+// queueOrderNotifications queues the new order notifications the store's
+// owner asked for and wakes the delivery worker. Nothing is sent here, so
+// checkout does not wait on the providers.
+func queueOrderNotifications(ctx context.Context, db *models.Database, store models.Store, order models.Order) error {
+	settings := store.NotificationSettings()
+	if !settings.NewOrders {
+		return nil
+	}
+
+	subject, body := orderNotification(store, order)
+	params := orderTemplateParams(store, order)
+	recipients := map[string]string{
+		models.NotifyWhatsApp: settings.WhatsApp,
+		models.NotifyEmail:    settings.Email,
+	}
+	var notifications []interface{}
+	for _, channel := range []string{models.NotifyWhatsApp, models.NotifyEmail} {
+		if recipients[channel] == "" {
+			continue
+		}
+		notifications = append(notifications, models.Notification{
+			ID:             primitive.NewObjectID(),
+			StoreID:        store.ID,
+			OrderID:        order.ID,
+			Event:          models.NotificationOrderCreated,
+			Channel:        channel,
+			Recipient:      recipients[channel],
+			Subject:        subject,
+			Body:           body,
+			TemplateParams: params,
+			Status:         models.NotificationPending,
+			NextAttemptAt:  order.CreatedAt,
+			CreatedAt:      order.CreatedAt,
+			UpdatedAt:      order.CreatedAt,
+		})
+	}
+	if len(notifications) == 0 {
+		return nil
+	}
+
+	if _, err := db.GetCollection(models.NotificationCollection).InsertMany(ctx, notifications); err != nil {
+		return err
+	}
+	select {
+	case notificationWake <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// orderNotification formats the owner's notification of a new order
+func orderNotification(store models.Store, order models.Order) (string, string) {
+	subject := "New order #" + order.Reference + " at " + store.Name
+	currency := models.CurrencyOf(order.Currency)
+
+	body := subject + "\n"
+	for _, item := range order.Items {
+		body += "\n" + strconv.Itoa(item.Quantity) + " x " + item.Name + " = " + currency.Format(item.SubtotalMinor)
+	}
+	body += "\n\nTotal: " + currency.Format(order.TotalMinor)
+	if order.CustomerName != "" || order.CustomerPhone != "" {
+		body += "\nCustomer: " + order.CustomerName
+		if order.CustomerPhone != "" {
+			body += " (" + order.CustomerPhone + ")"
+		}
+	}
+	if order.Fulfilment != nil {
+		body += "\n" + fulfilmentLine(*order.Fulfilment, order.Shipping)
+	}
+	if order.Note != "" {
+		body += "\nNote: " + order.Note
+	}
+	return subject, body
+}
+
+// orderTemplateParams are the parameters of the WhatsApp new order template:
+// reference, store name, total and customer. Template parameters may not span
+// lines, so the customer is always a single line.
+func orderTemplateParams(store models.Store, order models.Order) []string {
+	customer := strings.Join(strings.Fields(order.CustomerName+" "+order.CustomerPhone), " ")
+	if customer == "" {
+		customer = "-"
+	}
+	return []string{order.Reference, store.Name, models.CurrencyOf(order.Currency).Format(order.TotalMinor), customer}
+}
+
+// deliverNotifications runs a single delivery pass
+func deliverNotifications(ctx context.Context, db *models.Database, notifiers map[string]Notifier) {
+	notificationsColl := db.GetCollection(models.NotificationCollection)
+
+	var sent, failed int
+	for i := 0; i < notificationBatch; i++ {
+		if ctx.Err() != nil {
+			return
+		}
+
+		// Claim the next due notification by pushing its next attempt back
+		now := time.Now()
+		var notification models.Notification
+		err := notificationsColl.FindOneAndUpdate(
+			ctx,
+			bson.M{"status": models.NotificationPending, "next_attempt_at": bson.M{"$lte": now}},
+			bson.M{
+				"$set": bson.M{"next_attempt_at": now.Add(notificationLease), "updated_at": now},
+				"$inc": bson.M{"attempts": 1},
+			},
+			options.FindOneAndUpdate().SetSort(bson.D{{Key: "next_attempt_at", Value: 1}}).SetReturnDocument(options.After),
+		).Decode(&notification)
+		if err == mongo.ErrNoDocuments {
+			break
+		}
+		if err != nil {
+			log.Printf("Notification delivery: failed to claim notification: %v", err)
+			return
+		}
+
+		if sendNotification(ctx, db, notifiers, notification) {
+			sent++
+		} else {
+			failed++
+		}
+	}
+	if sent > 0 || failed > 0 {
+		log.Printf("Notification delivery: %d sent, %d failed", sent, failed)
+	}
+}
+
+// sendNotification makes one delivery attempt and records its outcome
+func sendNotification(ctx context.Context, db *models.Database, notifiers map[string]Notifier, notification models.Notification) bool {
+	var err error = fmt.Errorf("no notifier is configured for channel %q", notification.Channel)
+	notifier, configured := notifiers[notification.Channel]
+	if configured {
+		sendCtx, cancel := context.WithTimeout(ctx, 30*time.Second)
+		err = notifier.Notify(sendCtx, notification)
+		cancel()
+	}
+
+	now := time.Now()
+	var update bson.M
+	switch {
+	case err == nil:
+		update = bson.M{
+			"$set":   bson.M{"status": models.NotificationSent, "sent_at": now, "updated_at": now},
+			"$unset": bson.M{"last_error": ""},
+		}
+	case !configured, notification.Attempts >= models.NotificationMaxAttempts:
+		// Retrying can't help without a notifier
+		update = bson.M{"$set": bson.M{"status": models.NotificationFailed, "last_error": err.Error(), "updated_at": now}}
+	default:
+		update = bson.M{"$set": bson.M{"next_attempt_at": now.Add(notificationBackoff(notification.Attempts)), "last_error": err.Error(), "updated_at": now}}
+	}
+	if _, updateErr := db.GetCollection(models.NotificationCollection).UpdateOne(ctx, bson.M{"_id": notification.ID}, update); updateErr != nil {
+		log.Printf("Notification delivery: failed to update notification %s: %v", notification.ID.Hex(), updateErr)
+	}
+	if err != nil {
+		log.Printf("Notification delivery: attempt %d of notification %s failed: %v", notification.Attempts, notification.ID.Hex(), err)
+	}
+	return err == nil
+}
+
+// notificationBackoff is the wait before the next attempt: 30 seconds after
+// the first failure, doubling up to an hour
+func notificationBackoff(attempts int) time.Duration {
+	wait := 30 * time.Second
+	for i := 1; i < attempts && wait < time.Hour; i++ {
+		wait *= 2
+	}
+	if wait > time.Hour {
+		wait = time.Hour
+	}
+	return wait
+}
@@ -0,0 +1,263 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net"
+	"net/http"
+	"net/smtp"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"wacatalogue/backend/models"
+)
+
+// Notifier delivers owner notifications over one channel. An error means the
+// notification was not delivered and will be retried.
+type Notifier interface {
+	Notify(ctx context.Context, notification models.Notification) error
+}
+
+// NotifiersFromEnv returns a notifier for each channel whose provider is
+// configured. Notifications of other channels fail, unless NOTIFY_SINK=log
+// writes every channel to the log sink instead, which is meant for
+// development setups only:
+//
+//	WHATSAPP_ACCESS_TOKEN, WHATSAPP_PHONE_NUMBER_ID  WhatsApp Cloud API
+//	WHATSAPP_NOTIFY_TEMPLATE, WHATSAPP_NOTIFY_LANGUAGE  approved template to send
+//	SMTP_HOST, SMTP_PORT, SMTP_USERNAME, SMTP_PASSWORD, SMTP_FROM  email
+//	NOTIFY_SINK=log, NOTIFY_LOG_FILE  log sink, and the file it appends to
+func NotifiersFromEnv() map[string]Notifier {
+	if os.Getenv("NOTIFY_SINK") == "log" {
+		log.Print("Notifications are written to the log sink and not sent")
+		sink := &LogNotifier{Path: os.Getenv("NOTIFY_LOG_FILE")}
+		return map[string]Notifier{
+			models.NotifyWhatsApp: sink,
+			models.NotifyEmail:    sink,
+		}
+	}
+
+	notifiers := map[string]Notifier{}
+	if token, phoneNumberID := os.Getenv("WHATSAPP_ACCESS_TOKEN"), os.Getenv("WHATSAPP_PHONE_NUMBER_ID"); token != "" && phoneNumberID != "" {
+		notifiers[models.NotifyWhatsApp] = &WhatsAppNotifier{
+			AccessToken:   token,
+			PhoneNumberID: phoneNumberID,
+			Template:      os.Getenv("WHATSAPP_NOTIFY_TEMPLATE"),
+			Language:      os.Getenv("WHATSAPP_NOTIFY_LANGUAGE"),
+		}
+	}
+	if host, from := os.Getenv("SMTP_HOST"), os.Getenv("SMTP_FROM"); host != "" && from != "" {
+		port := os.Getenv("SMTP_PORT")
+		if port == "" {
+			port = "587"
+		}
+		notifiers[models.NotifyEmail] = &EmailNotifier{
+			Addr:     net.JoinHostPort(host, port),
+			Username: os.Getenv("SMTP_USERNAME"),
+			Password: os.Getenv("SMTP_PASSWORD"),
+			From:     from,
+		}
+	}
+	for _, channel := range []string{models.NotifyWhatsApp, models.NotifyEmail} {
+		if notifiers[channel] == nil {
+			log.Printf("No %s notifier is configured; %s notifications will fail", channel, channel)
+		}
+	}
+	return notifiers
+}
+
+// WhatsAppNotifier sends notifications as template messages through the
+// WhatsApp Cloud API, as free-form text is only delivered to numbers that
+// messaged the business in the last 24 hours. The template must be approved
+// for the sending number and take the notification's parameters in order;
+// for new orders: reference, store name, total and customer.
+type WhatsAppNotifier struct {
+	AccessToken   string
+	PhoneNumberID string       // Sending number registered with the Cloud API
+	Template      string       // Defaults to new_order
+	Language      string       // Template language code, defaults to en
+	BaseURL       string       // Defaults to https://graph.facebook.com/v20.0
+	Client        *http.Client // Defaults to a client with a 10 second timeout
+}
+
+// Notify implements Notifier
+func (n *WhatsAppNotifier) Notify(ctx context.Context, notification models.Notification) error {
+	base := n.BaseURL
+	if base == "" {
+		base = "https://graph.facebook.com/v20.0"
+	}
+	client := n.Client
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Second}
+	}
+
+	template := n.Template
+	if template == "" {
+		template = "new_order"
+	}
+	language := n.Language
+	if language == "" {
+		language = "en"
+	}
+
+	payload, err := json.Marshal(whatsAppTemplateMessage(phoneDigits(notification.Recipient), template, language, notification.TemplateParams))
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, "POST", base+"/"+n.PhoneNumberID+"/messages", bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+n.AccessToken)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("whatsapp api: %s: %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+	return nil
+}
+
+// whatsAppTemplateMessage builds the Cloud API payload of a template message
+// with text parameters for its body
+func whatsAppTemplateMessage(to, template, language string, params []string) map[string]interface{} {
+	tmpl := map[string]interface{}{
+		"name":     template,
+		"language": map[string]string{"code": language},
+	}
+	if len(params) > 0 {
+		parameters := make([]map[string]string, len(params))
+		for i, param := range params {
+			parameters[i] = map[string]string{"type": "text", "text": param}
+		}
+		tmpl["components"] = []map[string]interface{}{{"type": "body", "parameters": parameters}}
+	}
+	return map[string]interface{}{
+		"messaging_product": "whatsapp",
+		"to":                to,
+		"type":              "template",
+		"template":          tmpl,
+	}
+}
+
+// EmailNotifier sends notifications as plain text email over SMTP, using
+// STARTTLS when the server offers it
+type EmailNotifier struct {
+	Addr     string // host:port
+	Username string // Empty sends without authentication
+	Password string
+	From     string
+	Timeout  time.Duration // Limits the whole exchange, defaults to 30 seconds
+}
+
+// Notify implements Notifier. The exchange is cut off by the timeout or when
+// ctx is done, whichever comes first.
+func (n *EmailNotifier) Notify(ctx context.Context, notification models.Notification) error {
+	timeout := n.Timeout
+	if timeout == 0 {
+		timeout = 30 * time.Second
+	}
+	deadline := time.Now().Add(timeout)
+	if ctxDeadline, ok := ctx.Deadline(); ok && ctxDeadline.Before(deadline) {
+		deadline = ctxDeadline
+	}
+
+	dialer := net.Dialer{Deadline: deadline}
+	conn, err := dialer.DialContext(ctx, "tcp", n.Addr)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	if err := conn.SetDeadline(deadline); err != nil {
+		return err
+	}
+	// Unblock the exchange as soon as ctx is cancelled
+	stop := context.AfterFunc(ctx, func() { conn.SetDeadline(time.Now()) })
+	defer stop()
+
+	host, _, _ := net.SplitHostPort(n.Addr)
+	client, err := smtp.NewClient(conn, host)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+	if ok, _ := client.Extension("STARTTLS"); ok {
+		if err := client.StartTLS(&tls.Config{ServerName: host}); err != nil {
+			return err
+		}
+	}
+	if n.Username != "" {
+		if err := client.Auth(smtp.PlainAuth("", n.Username, n.Password, host)); err != nil {
+			return err
+		}
+	}
+	if err := client.Mail(n.From); err != nil {
+		return err
+	}
+	if err := client.Rcpt(notification.Recipient); err != nil {
+		return err
+	}
+
+	var message bytes.Buffer
+	message.WriteString("From: " + n.From + "\r\n")
+	message.WriteString("To: " + notification.Recipient + "\r\n")
+	message.WriteString("Subject: " + mime.QEncoding.Encode("utf-8", notification.Subject) + "\r\n")
+	message.WriteString("MIME-Version: 1.0\r\n")
+	message.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	message.WriteString("\r\n")
+	message.WriteString(strings.ReplaceAll(notification.Body, "\n", "\r\n"))
+
+	data, err := client.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := data.Write(message.Bytes()); err != nil {
+		return err
+	}
+	if err := data.Close(); err != nil {
+		return err
+	}
+	return client.Quit()
+}
+
+// LogNotifier writes notifications to a file, or to the server log when Path
+// is empty, so development setups can see them without a provider
+type LogNotifier struct {
+	Path string
+
+	mu sync.Mutex
+}
+
+// Notify implements Notifier
+func (n *LogNotifier) Notify(ctx context.Context, notification models.Notification) error {
+	entry := fmt.Sprintf("[%s] %s to %s: %s\n%s\n", notification.Channel, notification.Event, notification.Recipient, notification.Subject, notification.Body)
+	if n.Path == "" {
+		log.Print("Notification " + entry)
+		return nil
+	}
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	file, err := os.OpenFile(n.Path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+	if _, err := file.WriteString(time.Now().UTC().Format(time.RFC3339) + " " + entry + "\n"); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
@@ -0,0 +1,90 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"wacatalogue/backend/models"
+)
+
+// TestWhatsAppNotifierSendsTemplate checks that notifications go out as
+// template messages, which are delivered outside the 24 hour window
+func TestWhatsAppNotifierSendsTemplate(t *testing.T) {
+	var payload struct {
+		To       string `json:"to"`
+		Type     string `json:"type"`
+		Template struct {
+			Name     string `json:"name"`
+			Language struct {
+				Code string `json:"code"`
+			} `json:"language"`
+			Components []struct {
+				Type       string `json:"type"`
+				Parameters []struct {
+					Text string `json:"text"`
+				} `json:"parameters"`
+			} `json:"components"`
+		} `json:"template"`
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/1234/messages" || r.Header.Get("Authorization") != "Bearer token" {
+			t.Errorf("got request %s with %q", r.URL.Path, r.Header.Get("Authorization"))
+		}
+		json.NewDecoder(r.Body).Decode(&payload)
+		w.Write([]byte(`{"messages":[{"id":"wamid.1"}]}`))
+	}))
+	defer server.Close()
+
+	notifier := &WhatsAppNotifier{AccessToken: "token", PhoneNumberID: "1234", Language: "id", BaseURL: server.URL}
+	notification := models.Notification{
+		Channel:        models.NotifyWhatsApp,
+		Recipient:      "+62 811 000 111",
+		Body:           "New order #5F3A9C21",
+		TemplateParams: []string{"5F3A9C21", "Toko Ada", "Rp 150.000", "Ada +62812"},
+	}
+	if err := notifier.Notify(context.Background(), notification); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if payload.To != "62811000111" || payload.Type != "template" || payload.Template.Name != "new_order" || payload.Template.Language.Code != "id" {
+		t.Errorf("got message to %q of type %q with template %q (%q)", payload.To, payload.Type, payload.Template.Name, payload.Template.Language.Code)
+	}
+	var params []string
+	for _, component := range payload.Template.Components {
+		for _, param := range component.Parameters {
+			params = append(params, param.Text)
+		}
+	}
+	if !reflect.DeepEqual(params, notification.TemplateParams) {
+		t.Errorf("got parameters %v, want %v", params, notification.TemplateParams)
+	}
+}
+
+// TestNotifiersFromEnv checks that unconfigured channels have no notifier
+// unless the log sink is asked for
+func TestNotifiersFromEnv(t *testing.T) {
+	for _, key := range []string{"NOTIFY_SINK", "WHATSAPP_ACCESS_TOKEN", "WHATSAPP_PHONE_NUMBER_ID", "SMTP_HOST", "SMTP_FROM"} {
+		t.Setenv(key, "")
+	}
+	if notifiers := NotifiersFromEnv(); len(notifiers) != 0 {
+		t.Errorf("got notifiers %v without any configuration, want none", notifiers)
+	}
+
+	t.Setenv("SMTP_HOST", "smtp.example.com")
+	t.Setenv("SMTP_FROM", "orders@example.com")
+	notifiers := NotifiersFromEnv()
+	if _, ok := notifiers[models.NotifyEmail].(*EmailNotifier); !ok || notifiers[models.NotifyWhatsApp] != nil {
+		t.Errorf("got notifiers %v with only SMTP configured", notifiers)
+	}
+
+	t.Setenv("NOTIFY_SINK", "log")
+	for channel, notifier := range NotifiersFromEnv() {
+		if _, ok := notifier.(*LogNotifier); !ok {
+			t.Errorf("got %T for %s with NOTIFY_SINK=log", notifier, channel)
+		}
+	}
+}
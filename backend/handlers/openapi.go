@@ -0,0 +1,471 @@
+package handlers
+
+import (
+	_ "embed"
+	"net/http"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"wacatalogue/backend/models"
+)
+
+//go:embed docs.html
+var apiDocsHTML []byte
+
+// apiOperation describes a single route for the OpenAPI document
+type apiOperation struct {
+	Method   string
+	Path     string
+	Tag      string
+	Summary  string
+	Auth     bool
+	Query    []string    // Optional query parameters
+	Request  interface{} // Request body type, nil if none
+	Response interface{} // Success response body type
+	Status   int         // Success status code, defaults to 200
+
+	// Media types for non-JSON bodies, which are documented as plain strings
+	RequestMedia  string
+	ResponseMedia string
+}
+
+// apiOperations lists every route registered in routes.go
+var apiOperations = []apiOperation{
+	// Health
+	{Method: "GET", Path: "/livez", Tag: "Health", Summary: "Liveness probe", Response: HealthResponse{}},
+	{Method: "GET", Path: "/readyz", Tag: "Health", Summary: "Readiness probe checking MongoDB and indexes", Response: HealthResponse{}},
+	{Method: "GET", Path: "/api/health", Tag: "Health", Summary: "Liveness probe (alias of /livez)", Response: HealthResponse{}},
+
+	// Pages
+	{Method: "GET", Path: "/store/{storeId}", Tag: "Pages", Summary: "Server-rendered store page with Open Graph tags and JSON-LD", ResponseMedia: "text/html"},
+	{Method: "GET", Path: "/store/{storeId}/products/{id}", Tag: "Pages", Summary: "Server-rendered product page with Open Graph tags and JSON-LD", ResponseMedia: "text/html"},
+	{Method: "GET", Path: "/robots.txt", Tag: "Pages", Summary: "Crawler rules and sitemap locations", ResponseMedia: "text/plain"},
+	{Method: "GET", Path: "/sitemap.xml", Tag: "Pages", Summary: "Sitemap index of every store sitemap", ResponseMedia: "application/xml"},
+	{Method: "GET", Path: "/sitemaps/index-{page}.xml", Tag: "Pages", Summary: "Further sitemap index pages, listed in robots.txt when needed", ResponseMedia: "application/xml"},
+	{Method: "GET", Path: "/sitemaps/{storeId}/{part}.xml", Tag: "Pages", Summary: "One part of a store's sitemap", ResponseMedia: "application/xml"},
+
+	// Documentation
+	{Method: "GET", Path: "/api/openapi.json", Tag: "Documentation", Summary: "This OpenAPI document", Response: map[string]interface{}{}},
+	{Method: "GET", Path: "/api/docs", Tag: "Documentation", Summary: "HTML viewer for the OpenAPI document", ResponseMedia: "text/html"},
+
+	// Auth
+	{Method: "POST", Path: "/api/auth/register", Tag: "Auth", Summary: "Register a new owner account", Request: models.RegisterRequest{}, Response: models.AuthResponse{}, Status: http.StatusCreated},
+	{Method: "POST", Path: "/api/auth/login", Tag: "Auth", Summary: "Log in and obtain a JWT", Request: models.LoginRequest{}, Response: models.AuthResponse{}},
+
+	// Stores
+	{Method: "GET", Path: "/api/stores", Tag: "Stores", Summary: "List active stores", Response: []models.Store{}},
+	{Method: "GET", Path: "/api/stores/{id}", Tag: "Stores", Summary: "Get a store", Response: models.Store{}},
+	{Method: "GET", Path: "/api/my-store", Tag: "Stores", Summary: "Get the current user's selected store, or the one named by storeId", Auth: true, Query: []string{"storeId"}, Response: models.Store{}},
+	{Method: "GET", Path: "/api/my-store/summary", Tag: "Stores", Summary: "Dashboard overview: product counts, low stock, recent orders, revenue and best sellers", Auth: true, Query: []string{"storeId", "lowStock"}, Response: models.DashboardSummary{}},
+	{Method: "GET", Path: "/api/my-stores", Tag: "Stores", Summary: "List the current user's stores and the one selected for the dashboard", Auth: true, Response: models.MyStoresResponse{}},
+	{Method: "POST", Path: "/api/my-stores/{id}/select", Tag: "Stores", Summary: "Select the store the dashboard opens by default", Auth: true, Response: models.Store{}},
+	{Method: "POST", Path: "/api/stores", Tag: "Stores", Summary: "Create a store", Auth: true, Request: models.CreateStoreRequest{}, Response: models.Store{}, Status: http.StatusCreated},
+	{Method: "PUT", Path: "/api/stores/{id}", Tag: "Stores", Summary: "Update a store", Auth: true, Request: models.UpdateStoreRequest{}, Response: models.Store{}},
+	{Method: "DELETE", Path: "/api/stores/{id}", Tag: "Stores", Summary: "Move a store and its products to the trash", Auth: true, Response: map[string]string{}},
+	{Method: "POST", Path: "/api/stores/{id}/restore", Tag: "Stores", Summary: "Restore a store and the products trashed with it", Auth: true, Response: models.Store{}},
+	{Method: "GET", Path: "/api/stores/{id}/audit-log", Tag: "Audit", Summary: "Mutation history of one of the user's stores", Auth: true, Query: []string{"action", "page", "limit"}, Response: models.AuditLogResponse{}},
+
+	// Products
+	{Method: "GET", Path: "/api/stores/{storeId}/products", Tag: "Products", Summary: "List a store's products", Response: []models.Product{}},
+	{Method: "GET", Path: "/api/products/{id}", Tag: "Products", Summary: "Get a product", Response: models.Product{}},
+	{Method: "POST", Path: "/api/stores/{storeId}/products", Tag: "Products", Summary: "Create a product", Auth: true, Request: models.CreateProductRequest{}, Response: models.Product{}, Status: http.StatusCreated},
+	{Method: "GET", Path: "/api/stores/{storeId}/products/export", Tag: "Products", Summary: "Export a store's products as CSV", Auth: true, ResponseMedia: "text/csv"},
+	{Method: "POST", Path: "/api/stores/{storeId}/products/import", Tag: "Products", Summary: "Validate (dryRun=true, default) or upsert products from CSV; large imports return 202 with a job", Auth: true, Query: []string{"dryRun"}, RequestMedia: "text/csv", Response: models.ImportReport{}},
+	{Method: "GET", Path: "/api/import-jobs/{id}", Tag: "Products", Summary: "Progress of a background product import", Auth: true, Response: models.ImportJob{}},
+	{Method: "PUT", Path: "/api/products/{id}", Tag: "Products", Summary: "Update a product", Auth: true, Request: models.UpdateProductRequest{}, Response: models.Product{}},
+	{Method: "DELETE", Path: "/api/products/{id}", Tag: "Products", Summary: "Move a product to the trash", Auth: true, Response: map[string]string{}},
+	{Method: "POST", Path: "/api/products/{id}/restore", Tag: "Products", Summary: "Restore a product from the trash", Auth: true, Response: models.Product{}},
+
+	// Feeds
+	{Method: "GET", Path: "/api/stores/{storeId}/feeds/meta.csv", Tag: "Feeds", Summary: "Meta commerce catalog feed (CSV)", ResponseMedia: "text/csv"},
+	{Method: "GET", Path: "/api/stores/{storeId}/feeds/meta.xml", Tag: "Feeds", Summary: "Meta commerce catalog feed (RSS 2.0)", ResponseMedia: "application/xml"},
+	{Method: "GET", Path: "/api/stores/{storeId}/feeds/google.xml", Tag: "Feeds", Summary: "Google Merchant Center feed (RSS 2.0)", ResponseMedia: "application/xml"},
+	{Method: "GET", Path: "/api/stores/{storeId}/feeds/google.atom", Tag: "Feeds", Summary: "Google Merchant Center feed (Atom 1.0)", ResponseMedia: "application/xml"},
+	{Method: "GET", Path: "/api/stores/{storeId}/feeds/google/issues", Tag: "Feeds", Summary: "Products left out of the Google feed and why", Auth: true, Response: models.FeedValidationReport{}},
+
+	// Orders
+	{Method: "POST", Path: "/api/stores/{storeId}/quote", Tag: "Orders", Summary: "Price a cart with coupon, fulfilment and charges, without placing an order", Request: models.CheckoutRequest{}, Response: models.Order{}},
+	{Method: "POST", Path: "/api/stores/{storeId}/checkout", Tag: "Orders", Summary: "Place an order and get the WhatsApp link that sends it to the store", Request: models.CheckoutRequest{}, Response: models.CheckoutResponse{}, Status: http.StatusCreated},
+
+	// Webhooks
+	{Method: "GET", Path: "/api/webhooks/whatsapp", Tag: "Webhooks", Summary: "WhatsApp Cloud API verification: echoes hub.challenge when hub.verify_token matches", Query: []string{"hub.mode", "hub.verify_token", "hub.challenge"}, ResponseMedia: "text/plain"},
+	{Method: "POST", Path: "/api/webhooks/whatsapp", Tag: "Webhooks", Summary: "WhatsApp Cloud API messages, signed with X-Hub-Signature-256; attaches them to the orders they quote", Request: models.WhatsAppWebhook{}, Response: models.WebhookResponse{}},
+	{Method: "GET", Path: "/api/stores/{id}/orders", Tag: "Orders", Summary: "List the orders of one of the user's stores", Auth: true, Query: []string{"status", "page", "limit"}, Response: models.OrderListResponse{}},
+	{Method: "GET", Path: "/api/orders/{id}", Tag: "Orders", Summary: "Get an order", Auth: true, Response: models.Order{}},
+	{Method: "PUT", Path: "/api/orders/{id}/status", Tag: "Orders", Summary: "Confirm, complete or cancel an order; confirming takes tracked stock", Auth: true, Request: models.UpdateOrderStatusRequest{}, Response: models.Order{}},
+	{Method: "GET", Path: "/api/orders/{id}/conversation", Tag: "Orders", Summary: "WhatsApp messages the customer sent about an order", Auth: true, Response: []models.ConversationMessage{}},
+
+	// Team
+	{Method: "GET", Path: "/api/stores/{id}/members", Tag: "Team", Summary: "List the members and pending invitations of one of the user's stores", Auth: true, Response: models.StoreTeamResponse{}},
+	{Method: "POST", Path: "/api/stores/{id}/invites", Tag: "Team", Summary: "Invite someone to help run a store, by email or with a link", Auth: true, Request: models.CreateInviteRequest{}, Response: models.CreateInviteResponse{}, Status: http.StatusCreated},
+	{Method: "POST", Path: "/api/invites/accept", Tag: "Team", Summary: "Accept an invitation and join its store", Auth: true, Request: models.AcceptInviteRequest{}, Response: models.StoreMember{}, Status: http.StatusCreated},
+	{Method: "DELETE", Path: "/api/invites/{id}", Tag: "Team", Summary: "Revoke a pending invitation", Auth: true, Response: map[string]string{}},
+	{Method: "PUT", Path: "/api/members/{id}", Tag: "Team", Summary: "Change a member's role", Auth: true, Request: models.UpdateMemberRequest{}, Response: models.StoreMember{}},
+	{Method: "DELETE", Path: "/api/members/{id}", Tag: "Team", Summary: "Remove a member, or leave a store", Auth: true, Response: map[string]string{}},
+
+	// Customers
+	{Method: "GET", Path: "/api/stores/{id}/customers", Tag: "Customers", Summary: "List or search the customers of one of the user's stores, with lifetime value", Auth: true, Query: []string{"q", "page", "limit"}, Response: models.CustomerListResponse{}},
+	{Method: "GET", Path: "/api/customers/{id}", Tag: "Customers", Summary: "Get a customer with their saved addresses and order history", Auth: true, Response: models.CustomerDetail{}},
+	{Method: "PUT", Path: "/api/customers/{id}", Tag: "Customers", Summary: "Set a customer's name, notes and tags", Auth: true, Request: models.UpdateCustomerRequest{}, Response: models.Customer{}},
+
+	// Reviews
+	{Method: "GET", Path: "/api/products/{id}/reviews", Tag: "Reviews", Summary: "Published reviews of a product with its average rating", Query: []string{"page", "limit"}, Response: models.ReviewListResponse{}},
+	{Method: "POST", Path: "/api/products/{id}/reviews", Tag: "Reviews", Summary: "Review a product bought in a completed order, verified by order reference and phone", Request: models.CreateReviewRequest{}, Response: models.Review{}, Status: http.StatusCreated},
+	{Method: "GET", Path: "/api/stores/{id}/reviews", Tag: "Reviews", Summary: "List the reviews of one of the user's stores", Auth: true, Query: []string{"status", "productId", "page", "limit"}, Response: models.ReviewListResponse{}},
+	{Method: "PUT", Path: "/api/reviews/{id}", Tag: "Reviews", Summary: "Reply to, hide or unhide a review", Auth: true, Request: models.ModerateReviewRequest{}, Response: models.Review{}},
+
+	// Coupons
+	{Method: "GET", Path: "/api/stores/{id}/coupons", Tag: "Coupons", Summary: "List the coupons of one of the user's stores", Auth: true, Response: []models.Coupon{}},
+	{Method: "POST", Path: "/api/stores/{id}/coupons", Tag: "Coupons", Summary: "Create a coupon", Auth: true, Request: models.CouponRequest{}, Response: models.Coupon{}, Status: http.StatusCreated},
+	{Method: "PUT", Path: "/api/coupons/{id}", Tag: "Coupons", Summary: "Replace a coupon's settings, keeping its usage count", Auth: true, Request: models.CouponRequest{}, Response: models.Coupon{}},
+	{Method: "DELETE", Path: "/api/coupons/{id}", Tag: "Coupons", Summary: "Delete a coupon", Auth: true, Response: map[string]string{}},
+
+	// Delivery
+	{Method: "POST", Path: "/api/stores/{storeId}/delivery-quote", Tag: "Delivery", Summary: "Check whether the store delivers to a location and what it costs", Request: models.DeliveryQuoteRequest{}, Response: models.DeliveryQuote{}},
+	{Method: "GET", Path: "/api/stores/{id}/delivery-zones", Tag: "Delivery", Summary: "List the delivery zones of one of the user's stores", Auth: true, Response: []models.DeliveryZone{}},
+	{Method: "POST", Path: "/api/stores/{id}/delivery-zones", Tag: "Delivery", Summary: "Create a radius or polygon delivery zone", Auth: true, Request: models.DeliveryZoneRequest{}, Response: models.DeliveryZone{}, Status: http.StatusCreated},
+	{Method: "PUT", Path: "/api/delivery-zones/{id}", Tag: "Delivery", Summary: "Replace a delivery zone's settings", Auth: true, Request: models.DeliveryZoneRequest{}, Response: models.DeliveryZone{}},
+	{Method: "DELETE", Path: "/api/delivery-zones/{id}", Tag: "Delivery", Summary: "Delete a delivery zone", Auth: true, Response: map[string]string{}},
+
+	// Shipping
+	{Method: "POST", Path: "/api/stores/{storeId}/shipping-quote", Tag: "Shipping", Summary: "List courier options and prices to ship a cart to a region", Request: models.ShippingQuoteRequest{}, Response: models.ShippingQuote{}},
+	{Method: "GET", Path: "/api/stores/{id}/courier-rates", Tag: "Shipping", Summary: "List the courier rate tables of one of the user's stores", Auth: true, Response: []models.CourierRateTable{}},
+	{Method: "PUT", Path: "/api/stores/{id}/courier-rates/{courier}/{service}", Tag: "Shipping", Summary: "Replace a courier service's rate table from CSV (origin, destination, price_per_kg, eta)", Auth: true, RequestMedia: "text/csv", Response: models.CourierRateTable{}},
+	{Method: "DELETE", Path: "/api/stores/{id}/courier-rates/{courier}/{service}", Tag: "Shipping", Summary: "Delete a courier service's rate table", Auth: true, Response: map[string]string{}},
+
+	// Analytics
+	{Method: "POST", Path: "/api/stores/{storeId}/events", Tag: "Analytics", Summary: "Record a storefront event; bots and repeat events are accepted but not counted", Request: models.AnalyticsEventRequest{}, Status: http.StatusNoContent},
+	{Method: "GET", Path: "/api/stores/{id}/analytics", Tag: "Analytics", Summary: "Daily event totals of one of the user's stores", Auth: true, Query: []string{"from", "to", "productId"}, Response: models.AnalyticsTimeSeriesResponse{}},
+	{Method: "GET", Path: "/api/stores/{id}/analytics/top-products", Tag: "Analytics", Summary: "A store's products ranked by views, add-to-cart or WhatsApp clicks", Auth: true, Query: []string{"from", "to", "metric", "limit"}, Response: models.AnalyticsTopProductsResponse{}},
+
+	// Notifications
+	{Method: "GET", Path: "/api/stores/{id}/notifications", Tag: "Notifications", Summary: "Owner notifications of one of the user's stores with their delivery status", Auth: true, Query: []string{"status", "page", "limit"}, Response: models.NotificationListResponse{}},
+
+	// Trash
+	{Method: "GET", Path: "/api/trash", Tag: "Trash", Summary: "List the user's trashed stores and products", Auth: true, Response: models.TrashResponse{}},
+
+	// Admin
+	{Method: "GET", Path: "/api/admin/audit-log", Tag: "Admin", Summary: "Search the audit log across all stores", Auth: true, Query: []string{"actorId", "storeId", "targetType", "targetId", "action", "from", "to", "page", "limit"}, Response: models.AuditLogResponse{}},
+	{Method: "POST", Path: "/api/admin/reviews/{id}/remove", Tag: "Admin", Summary: "Remove an abusive review and its text, recording the reason", Auth: true, Request: models.RemoveReviewRequest{}, Response: map[string]string{}},
+}
+
+var pathParamPattern = regexp.MustCompile(`\{([^}:]+)(:[^}]*)?\}`)
+
+// OpenAPISpec builds the OpenAPI 3 document for the API
+func OpenAPISpec() map[string]interface{} {
+	schemas := map[string]interface{}{}
+	schemaFor(reflect.TypeOf(ErrorResponse{}), schemas)
+
+	paths := map[string]interface{}{}
+	for _, op := range apiOperations {
+		item, ok := paths[op.Path].(map[string]interface{})
+		if !ok {
+			item = map[string]interface{}{}
+			paths[op.Path] = item
+		}
+
+		operation := map[string]interface{}{
+			"summary": op.Summary,
+			"tags":    []string{op.Tag},
+		}
+
+		// Path parameters
+		var params []interface{}
+		for _, match := range pathParamPattern.FindAllStringSubmatch(op.Path, -1) {
+			params = append(params, map[string]interface{}{
+				"name":     match[1],
+				"in":       "path",
+				"required": true,
+				"schema":   map[string]interface{}{"type": "string"},
+			})
+		}
+		for _, name := range op.Query {
+			params = append(params, map[string]interface{}{
+				"name":   name,
+				"in":     "query",
+				"schema": map[string]interface{}{"type": "string"},
+			})
+		}
+		if len(params) > 0 {
+			operation["parameters"] = params
+		}
+
+		if op.Auth {
+			operation["security"] = []interface{}{map[string]interface{}{"bearerAuth": []string{}}}
+		}
+
+		if op.RequestMedia != "" {
+			operation["requestBody"] = map[string]interface{}{
+				"required": true,
+				"content": map[string]interface{}{
+					op.RequestMedia: map[string]interface{}{
+						"schema": map[string]interface{}{"type": "string"},
+					},
+				},
+			}
+		} else if op.Request != nil {
+			operation["requestBody"] = map[string]interface{}{
+				"required": true,
+				"content": map[string]interface{}{
+					"application/json": map[string]interface{}{
+						"schema": schemaFor(reflect.TypeOf(op.Request), schemas),
+					},
+				},
+			}
+		}
+
+		status := op.Status
+		if status == 0 {
+			status = http.StatusOK
+		}
+		success := map[string]interface{}{"description": http.StatusText(status)}
+		if op.ResponseMedia != "" {
+			success["content"] = map[string]interface{}{
+				op.ResponseMedia: map[string]interface{}{
+					"schema": map[string]interface{}{"type": "string"},
+				},
+			}
+		} else if op.Response != nil {
+			success["content"] = map[string]interface{}{
+				"application/json": map[string]interface{}{
+					"schema": schemaFor(reflect.TypeOf(op.Response), schemas),
+				},
+			}
+		}
+		operation["responses"] = map[string]interface{}{
+			strconv.Itoa(status): success,
+			"default": map[string]interface{}{
+				"description": "Error",
+				"content": map[string]interface{}{
+					"application/json": map[string]interface{}{
+						"schema": map[string]interface{}{"$ref": "#/components/schemas/ErrorResponse"},
+					},
+				},
+			},
+		}
+
+		item[strings.ToLower(op.Method)] = operation
+	}
+
+	return map[string]interface{}{
+		"openapi": "3.0.3",
+		"info": map[string]interface{}{
+			"title":   "WA Catalogue API",
+			"version": "1.0.0",
+		},
+		"servers": []interface{}{map[string]interface{}{"url": "/"}},
+		"paths":   paths,
+		"components": map[string]interface{}{
+			"schemas": schemas,
+			"securitySchemes": map[string]interface{}{
+				"bearerAuth": map[string]interface{}{
+					"type":         "http",
+					"scheme":       "bearer",
+					"bearerFormat": "JWT",
+				},
+			},
+		},
+	}
+}
+
+// schemaFor returns the schema for a Go type, registering named structs in schemas
+func schemaFor(t reflect.Type, schemas map[string]interface{}) map[string]interface{} {
+	switch t {
+	case reflect.TypeOf(primitive.ObjectID{}):
+		return map[string]interface{}{"type": "string", "pattern": "^[0-9a-f]{24}$"}
+	case reflect.TypeOf(time.Time{}):
+		return map[string]interface{}{"type": "string", "format": "date-time"}
+	}
+
+	switch t.Kind() {
+	case reflect.Ptr:
+		schema := schemaFor(t.Elem(), schemas)
+		if _, isRef := schema["$ref"]; !isRef {
+			schema["nullable"] = true
+		}
+		return schema
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int8, reflect.Int16, reflect.Int32:
+		return map[string]interface{}{"type": "integer", "format": "int32"}
+	case reflect.Int, reflect.Int64, reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]interface{}{"type": "integer", "format": "int64"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case reflect.Slice, reflect.Array:
+		return map[string]interface{}{"type": "array", "items": schemaFor(t.Elem(), schemas)}
+	case reflect.Map:
+		return map[string]interface{}{"type": "object", "additionalProperties": schemaFor(t.Elem(), schemas)}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return structSchema(t, schemas)
+		}
+		if _, ok := schemas[t.Name()]; !ok {
+			// Reserve the name first so recursive types terminate
+			schemas[t.Name()] = map[string]interface{}{}
+			schemas[t.Name()] = structSchema(t, schemas)
+		}
+		return map[string]interface{}{"$ref": "#/components/schemas/" + t.Name()}
+	}
+
+	// interface{} and anything else accepts any value
+	return map[string]interface{}{}
+}
+
+// structSchema builds an object schema from a struct's json tags
+func structSchema(t reflect.Type, schemas map[string]interface{}) map[string]interface{} {
+	properties := map[string]interface{}{}
+	var required []string
+
+	for _, field := range jsonFields(t) {
+		property := schemaFor(field.Type, schemas)
+		if field.Tag.Get("deprecated") == "true" {
+			property["deprecated"] = true
+		}
+		properties[field.Name] = property
+		if !field.OmitEmpty && !field.Optional && field.Type.Kind() != reflect.Ptr {
+			required = append(required, field.Name)
+		}
+	}
+
+	schema := map[string]interface{}{
+		"type":       "object",
+		"properties": properties,
+	}
+	if len(required) > 0 {
+		schema["required"] = required
+	}
+	return schema
+}
+
+// jsonField is a field of a struct as encoding/json sees it
+type jsonField struct {
+	Name      string
+	Type      reflect.Type
+	Tag       reflect.StructTag
+	OmitEmpty bool
+	Tagged    bool // The name comes from the json tag
+	Depth     int  // How deeply the field is embedded
+	Optional  bool // Promoted through an embedded pointer, so left out when it is nil
+}
+
+// jsonFields lists the fields encoding/json encodes for a struct, in order,
+// with the fields of embedded structs without a json name promoted. Like
+// encoding/json, a name used at several depths goes to the shallowest field,
+// preferring a tagged one, and is dropped when that is still ambiguous.
+func jsonFields(t reflect.Type) []jsonField {
+	var fields []jsonField
+	var collect func(t reflect.Type, depth int, optional bool, visited map[reflect.Type]bool)
+	collect = func(t reflect.Type, depth int, optional bool, visited map[reflect.Type]bool) {
+		if visited[t] {
+			return
+		}
+		visited[t] = true
+		defer delete(visited, t)
+
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			tag, hasTag := field.Tag.Lookup("json")
+			parts := strings.Split(tag, ",")
+			if parts[0] == "-" && len(parts) == 1 {
+				continue
+			}
+
+			fieldType := field.Type
+			if field.Anonymous {
+				if fieldType.Kind() == reflect.Ptr {
+					fieldType = fieldType.Elem()
+				}
+				if !field.IsExported() && fieldType.Kind() != reflect.Struct {
+					continue // unexported
+				}
+				if parts[0] == "" && fieldType.Kind() == reflect.Struct {
+					collect(fieldType, depth+1, optional || field.Type.Kind() == reflect.Ptr, visited)
+					continue
+				}
+			} else if !field.IsExported() {
+				continue // unexported
+			}
+
+			f := jsonField{Name: field.Name, Type: field.Type, Tag: field.Tag, Depth: depth, Optional: optional}
+			if hasTag && parts[0] != "" {
+				f.Name, f.Tagged = parts[0], true
+			}
+			for _, opt := range parts[1:] {
+				if opt == "omitempty" {
+					f.OmitEmpty = true
+				}
+			}
+			fields = append(fields, f)
+		}
+	}
+	collect(t, 0, false, map[reflect.Type]bool{})
+
+	// Keep the dominant field of each name
+	byName := map[string][]jsonField{}
+	for _, f := range fields {
+		byName[f.Name] = append(byName[f.Name], f)
+	}
+	var result []jsonField
+	for _, f := range fields {
+		candidates := byName[f.Name]
+		if candidates == nil {
+			continue // already decided
+		}
+		delete(byName, f.Name)
+		if dominant, ok := dominantField(candidates); ok {
+			result = append(result, dominant)
+		}
+	}
+	return result
+}
+
+// dominantField picks the field that wins a name, following encoding/json
+func dominantField(fields []jsonField) (jsonField, bool) {
+	depth := fields[0].Depth
+	for _, f := range fields {
+		if f.Depth < depth {
+			depth = f.Depth
+		}
+	}
+	var shallowest, tagged []jsonField
+	for _, f := range fields {
+		if f.Depth == depth {
+			shallowest = append(shallowest, f)
+			if f.Tagged {
+				tagged = append(tagged, f)
+			}
+		}
+	}
+	switch {
+	case len(tagged) == 1:
+		return tagged[0], true
+	case len(tagged) == 0 && len(shallowest) == 1:
+		return shallowest[0], true
+	}
+	return jsonField{}, false
+}
+
+// GetOpenAPISpec serves the OpenAPI document
+func GetOpenAPISpec() http.HandlerFunc {
+	spec := OpenAPISpec()
+	return func(w http.ResponseWriter, r *http.Request) {
+		RespondWithJSON(w, http.StatusOK, spec)
+	}
+}
+
+// GetAPIDocs serves the embedded API documentation viewer
+func GetAPIDocs() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.WriteHeader(http.StatusOK)
+		w.Write(apiDocsHTML)
+	}
+}
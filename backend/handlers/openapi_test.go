@@ -0,0 +1,63 @@
+package handlers
+
+import (
+	"encoding/json"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+type schemaBase struct {
+	ID      string `json:"id"`
+	Name    string `json:"name"`
+	Private string `json:"-"`
+}
+
+type SchemaAudit struct {
+	CreatedAt string `json:"createdAt"`
+	Note      string `json:"note,omitempty"`
+}
+
+type schemaPage struct {
+	Total int `json:"total"`
+}
+
+type schemaProduct struct {
+	schemaBase
+	*SchemaAudit
+	Page  schemaPage `json:"page"`
+	Name  string     `json:"name"` // Shadows the embedded name
+	Price int64      `json:"priceMinor"`
+	Note  string     `json:"-"`
+}
+
+// TestStructSchemaFlattensEmbedded checks that schemas list the same fields
+// encoding/json writes, with embedded structs flattened
+func TestStructSchemaFlattensEmbedded(t *testing.T) {
+	schema := structSchema(reflect.TypeOf(schemaProduct{}), map[string]interface{}{})
+
+	var properties []string
+	for name := range schema["properties"].(map[string]interface{}) {
+		properties = append(properties, name)
+	}
+	sort.Strings(properties)
+
+	data, err := json.Marshal(schemaProduct{schemaBase: schemaBase{ID: "1"}, SchemaAudit: &SchemaAudit{Note: "n"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var encoded map[string]interface{}
+	json.Unmarshal(data, &encoded)
+	var want []string
+	for name := range encoded {
+		want = append(want, name)
+	}
+	sort.Strings(want)
+
+	if !reflect.DeepEqual(properties, want) {
+		t.Errorf("got properties %v, want %v as encoded", properties, want)
+	}
+	if required := schema["required"]; !reflect.DeepEqual(required, []string{"id", "name", "page", "priceMinor"}) {
+		t.Errorf("got required %v", required)
+	}
+}
@@ -0,0 +1,738 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"wacatalogue/backend/models"
+)
+
+// Checkout prices a cart against the catalog, records it as a pending order
+// and returns the WhatsApp link that sends the order to the store
+func Checkout(db *models.Database) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// Get store ID from URL
+		vars := mux.Vars(r)
+		storeID, err := primitive.ObjectIDFromHex(vars["storeId"])
+		if err != nil {
+			RespondWithError(w, r, ErrInvalidStoreID)
+			return
+		}
+
+		var req models.CheckoutRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			RespondWithError(w, r, ErrInvalidPayload.WithDetails(err.Error()))
+			return
+		}
+		errs := req.Validate()
+		if req.Fulfilment.Method == "" {
+			errs = append(errs, models.FieldError{Field: "fulfilment.method", Message: "is required"})
+		}
+		if len(errs) > 0 {
+			RespondWithError(w, r, ErrValidationFailed.WithDetails(errs))
+			return
+		}
+
+		// Create database context
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		now := time.Now()
+		store, order, err := priceCart(ctx, db, storeID, req, now)
+		if err != nil {
+			RespondWithError(w, r, err)
+			return
+		}
+
+		coupon, err := applyAdjustments(ctx, db, store, &order, req, now)
+		if err != nil {
+			RespondWithError(w, r, err)
+			return
+		}
+
+		// Count the coupon redemption before the order is stored
+		customerPhone := internationalPhone(order.CustomerPhone, store.WhatsappNumber)
+		if coupon != nil {
+			if err := redeemCoupon(ctx, db, *coupon, customerPhone); err != nil {
+				RespondWithError(w, r, err)
+				return
+			}
+			if coupon.PerCustomerLimit > 0 {
+				order.CouponPhone = customerPhone
+			}
+		}
+
+		// Insert order
+		if _, err = db.GetCollection(models.OrderCollection).InsertOne(ctx, order); err != nil {
+			if coupon != nil {
+				releaseCoupon(ctx, db, coupon.ID, order.CouponPhone)
+			}
+			RespondWithError(w, r, ErrInternal.WithMessage("Failed to create order"))
+			return
+		}
+
+		// Link the order to the customer's record; the order stands without it
+		if err := recordCustomer(ctx, db, store, &order); err != nil {
+			log.Printf("Failed to record customer of order %s: %v", order.ID.Hex(), err)
+		}
+
+		// Let the owner know; notifications are sent in the background
+		if err := queueOrderNotifications(ctx, db, store, order); err != nil {
+			log.Printf("Failed to queue notifications of order %s: %v", order.ID.Hex(), err)
+		}
+
+		message := orderMessage(store, order)
+
+		// Send response
+		order.ApplyCurrency()
+		RespondWithJSON(w, http.StatusCreated, models.CheckoutResponse{
+			Order:       order,
+			Message:     message,
+			WhatsAppURL: whatsAppURL(store.WhatsappNumber, message),
+		})
+	}
+}
+
+// QuoteCart prices a cart the way Checkout would, with coupon, delivery and
+// charges, without recording an order or redeeming the coupon
+func QuoteCart(db *models.Database) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// Get store ID from URL
+		vars := mux.Vars(r)
+		storeID, err := primitive.ObjectIDFromHex(vars["storeId"])
+		if err != nil {
+			RespondWithError(w, r, ErrInvalidStoreID)
+			return
+		}
+
+		var req models.CheckoutRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			RespondWithError(w, r, ErrInvalidPayload.WithDetails(err.Error()))
+			return
+		}
+		if errs := req.Validate(); len(errs) > 0 {
+			RespondWithError(w, r, ErrValidationFailed.WithDetails(errs))
+			return
+		}
+
+		// Create database context
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		now := time.Now()
+		store, order, err := priceCart(ctx, db, storeID, req, now)
+		if err != nil {
+			RespondWithError(w, r, err)
+			return
+		}
+
+		if _, err := applyAdjustments(ctx, db, store, &order, req, now); err != nil {
+			RespondWithError(w, r, err)
+			return
+		}
+
+		// Send response
+		order.ApplyCurrency()
+		RespondWithJSON(w, http.StatusOK, order)
+	}
+}
+
+// priceCart prices a cart against the catalog of an active store, never
+// trusting prices from the client. The returned order is not stored.
+func priceCart(ctx context.Context, db *models.Database, storeID primitive.ObjectID, req models.CheckoutRequest, now time.Time) (models.Store, models.Order, error) {
+	var store models.Store
+	var order models.Order
+
+	productIDs, quantities, err := parseCartItems(req.Items)
+	if err != nil {
+		return store, order, err
+	}
+
+	// Find active store
+	err = db.GetCollection(models.StoreCollection).FindOne(ctx, notDeleted(bson.M{"_id": storeID, "active": true})).Decode(&store)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return store, order, ErrStoreNotFound
+		}
+		return store, order, ErrInternal.WithMessage("Failed to find store")
+	}
+	currency := models.CurrencyOf(store.Currency)
+
+	// Price the cart from the catalog
+	cursor, err := db.GetCollection(models.ProductCollection).Find(ctx, notDeleted(bson.M{"_id": bson.M{"$in": productIDs}, "store_id": storeID, "active": true}))
+	if err != nil {
+		return store, order, ErrInternal.WithMessage("Failed to find products")
+	}
+	var products []models.Product
+	if err = cursor.All(ctx, &products); err != nil {
+		return store, order, ErrInternal.WithMessage("Failed to decode products")
+	}
+	models.ApplyPricing(products, now, currency)
+	byID := make(map[primitive.ObjectID]models.Product, len(products))
+	for _, product := range products {
+		byID[product.ID] = product
+	}
+
+	order = models.Order{
+		ID:            primitive.NewObjectID(),
+		StoreID:       storeID,
+		Items:         []models.OrderItem{},
+		Currency:      currency.Code,
+		CustomerName:  strings.TrimSpace(req.CustomerName),
+		CustomerPhone: strings.TrimSpace(req.CustomerPhone),
+		Note:          strings.TrimSpace(req.Note),
+		Status:        models.OrderPending,
+		CreatedAt:     now,
+		UpdatedAt:     now,
+	}
+	order.Reference = models.OrderReference(order.ID)
+
+	var unavailable []models.FieldError
+	for _, productID := range productIDs {
+		product, ok := byID[productID]
+		quantity := quantities[productID]
+		switch {
+		case !ok:
+			unavailable = append(unavailable, models.FieldError{Field: productID.Hex(), Message: "is not available"})
+			continue
+		case product.TrackStock && product.Stock < quantity:
+			unavailable = append(unavailable, models.FieldError{Field: productID.Hex(), Message: "only " + strconv.Itoa(product.Stock) + " left in stock"})
+			continue
+		}
+
+		item := models.OrderItem{
+			ProductID:     product.ID,
+			SKU:           product.SKU,
+			Name:          product.Name,
+			Category:      product.Category,
+			PriceMinor:    product.EffectivePriceMinor,
+			Quantity:      quantity,
+			SubtotalMinor: product.EffectivePriceMinor * int64(quantity),
+			TrackStock:    product.TrackStock,
+		}
+		order.Items = append(order.Items, item)
+		order.SubtotalMinor += item.SubtotalMinor
+	}
+	if len(unavailable) > 0 {
+		return store, order, ErrProductsUnavailable.WithDetails(unavailable)
+	}
+	order.TotalMinor = order.SubtotalMinor
+	return store, order, nil
+}
+
+// applyAdjustments applies the coupon, fulfilment and store charges of a
+// checkout request to a priced order. The coupon is returned so the caller
+// can redeem it; it is not counted here.
+func applyAdjustments(ctx context.Context, db *models.Database, store models.Store, order *models.Order, req models.CheckoutRequest, now time.Time) (*models.Coupon, error) {
+	var coupon *models.Coupon
+	if code := strings.TrimSpace(req.CouponCode); code != "" {
+		found, err := findCouponByCode(ctx, db, store.ID, code)
+		if err != nil {
+			return nil, err
+		}
+		discount, err := couponDiscount(found, *order, now)
+		if err != nil {
+			return nil, err
+		}
+		coupon = &found
+		order.CouponID = found.ID
+		order.CouponCode = found.Code
+		order.DiscountMinor = discount
+	}
+
+	if err := applyFulfilment(ctx, db, store, order, req, now); err != nil {
+		return nil, err
+	}
+
+	order.ApplyCharges(store)
+	return coupon, nil
+}
+
+// applyFulfilment checks the customer's fulfilment choice against the
+// store's settings and records it on the order, pricing delivery and
+// shipping. Quotes may leave the method out; a delivery location alone then
+// prices delivery.
+func applyFulfilment(ctx context.Context, db *models.Database, store models.Store, order *models.Order, req models.CheckoutRequest, now time.Time) error {
+	choice := req.Fulfilment
+	if choice.Method == "" {
+		if req.Delivery == nil {
+			return nil
+		}
+		choice.Method = models.FulfilmentDelivery
+	}
+
+	settings := store.FulfilmentSettings()
+	if !settings.Allows(choice.Method) {
+		return ErrValidationFailed.WithDetails([]models.FieldError{{Field: "fulfilment.method", Message: "must be one of " + strings.Join(settings.Methods(), ", ") + " for this store"}})
+	}
+
+	fulfilment := models.OrderFulfilment{Method: choice.Method}
+	switch choice.Method {
+	case models.FulfilmentPickup:
+		pickup, errs := settings.Pickup.ResolvePickup(choice, store, now)
+		if len(errs) > 0 {
+			return ErrValidationFailed.WithDetails(errs)
+		}
+		fulfilment = pickup
+
+	case models.FulfilmentDelivery:
+		location := models.NewGeoPoint(req.Delivery.Latitude, req.Delivery.Longitude)
+		net := order.SubtotalMinor - order.DiscountMinor
+		quote, err := quoteDelivery(ctx, db, store, location, &net)
+		if err != nil {
+			return err
+		}
+		if !quote.Available {
+			return ErrNoDelivery.WithMessage(quote.Reason)
+		}
+		order.Delivery = &models.OrderDelivery{
+			ZoneID:         quote.ZoneID,
+			ZoneName:       quote.ZoneName,
+			Location:       location,
+			Address:        strings.TrimSpace(req.Delivery.Address),
+			DistanceMeters: quote.DistanceMeters,
+			FeeMinor:       quote.FeeMinor,
+		}
+
+	case models.FulfilmentShipping:
+		productIDs := make([]primitive.ObjectID, len(order.Items))
+		quantities := make(map[primitive.ObjectID]int, len(order.Items))
+		for i, item := range order.Items {
+			productIDs[i] = item.ProductID
+			quantities[item.ProductID] = item.Quantity
+		}
+		quote, err := quoteShipping(ctx, db, store, productIDs, quantities, req.Shipping.Destination)
+		if err != nil {
+			return err
+		}
+		for _, option := range quote.Options {
+			if strings.EqualFold(option.Courier, req.Shipping.Courier) && strings.EqualFold(option.Service, req.Shipping.Service) {
+				order.Shipping = &models.OrderShipping{
+					Courier:     option.Courier,
+					Service:     option.Service,
+					Destination: quote.Destination,
+					Address:     strings.TrimSpace(req.Shipping.Address),
+					BilledKg:    quote.BilledKg,
+					FeeMinor:    option.PriceMinor,
+					MinDays:     option.MinDays,
+					MaxDays:     option.MaxDays,
+				}
+			}
+		}
+		if order.Shipping == nil {
+			return ErrNoShipping
+		}
+
+	case models.FulfilmentDineIn:
+		fulfilment.TableNumber = strings.TrimSpace(choice.TableNumber)
+	}
+
+	order.Fulfilment = &fulfilment
+	return nil
+}
+
+// parseCartItems parses the product IDs of a cart, in cart order, merging
+// repeated products
+func parseCartItems(items []models.CheckoutItem) ([]primitive.ObjectID, map[primitive.ObjectID]int, error) {
+	quantities := map[primitive.ObjectID]int{}
+	var productIDs []primitive.ObjectID
+	for i, item := range items {
+		productID, err := primitive.ObjectIDFromHex(item.ProductID)
+		if err != nil {
+			return nil, nil, ErrValidationFailed.WithDetails([]models.FieldError{{Field: "items[" + strconv.Itoa(i) + "].productId", Message: "must be a valid ID"}})
+		}
+		if _, ok := quantities[productID]; !ok {
+			productIDs = append(productIDs, productID)
+		}
+		quantities[productID] += item.Quantity
+	}
+	return productIDs, quantities, nil
+}
+
+// orderMessage formats an order the way customers send it over WhatsApp
+func orderMessage(store models.Store, order models.Order) string {
+	var message strings.Builder
+	message.WriteString("*Order from " + store.Name + "*\n")
+	message.WriteString("Order #" + order.Reference + "\n\n")
+
+	currency := models.CurrencyOf(order.Currency)
+	for i, item := range order.Items {
+		if i > 0 {
+			message.WriteString("\n\n")
+		}
+		message.WriteString("*" + item.Name + "*\n")
+		message.WriteString(currency.Format(item.PriceMinor) + " x " + strconv.Itoa(item.Quantity) + " = " + currency.Format(item.SubtotalMinor))
+	}
+
+	if order.DiscountMinor > 0 || len(order.Charges) > 0 || order.Delivery != nil || order.Shipping != nil {
+		message.WriteString("\n\nSubtotal: " + currency.Format(order.SubtotalMinor))
+		if order.DiscountMinor > 0 {
+			message.WriteString("\nDiscount (" + order.CouponCode + "): -" + currency.Format(order.DiscountMinor))
+		}
+		for _, charge := range order.Charges {
+			message.WriteString("\n" + charge.Label() + ": " + currency.Format(charge.AmountMinor))
+		}
+		if order.Delivery != nil {
+			message.WriteString("\nDelivery (" + order.Delivery.ZoneName + "): " + currency.Format(order.Delivery.FeeMinor))
+		}
+		if order.Shipping != nil {
+			message.WriteString("\nShipping (" + order.Shipping.Courier + " " + order.Shipping.Service + ", " + strconv.Itoa(order.Shipping.BilledKg) + " kg): " + currency.Format(order.Shipping.FeeMinor))
+		}
+		message.WriteString("\n*Total: " + currency.Format(order.TotalMinor) + "*")
+	} else {
+		message.WriteString("\n\n*Total: " + currency.Format(order.TotalMinor) + "*")
+	}
+	message.WriteString("\n")
+	if order.CustomerName != "" {
+		message.WriteString("\nName: " + order.CustomerName)
+	}
+	if order.Fulfilment != nil {
+		message.WriteString("\n" + fulfilmentLine(*order.Fulfilment, order.Shipping))
+	}
+	if order.Delivery != nil {
+		if order.Delivery.Address != "" {
+			message.WriteString("\nAddress: " + order.Delivery.Address)
+		}
+		message.WriteString("\nLocation: " + order.Delivery.MapsURL())
+	}
+	if order.Shipping != nil && order.Shipping.Address != "" {
+		message.WriteString("\nAddress: " + order.Shipping.Address)
+	}
+	if order.Note != "" {
+		message.WriteString("\nNote: " + order.Note)
+	}
+	message.WriteString("\n\nThank you!")
+	return message.String()
+}
+
+// fulfilmentLine describes how an order is handed over, for the WhatsApp message
+func fulfilmentLine(fulfilment models.OrderFulfilment, shipping *models.OrderShipping) string {
+	switch fulfilment.Method {
+	case models.FulfilmentPickup:
+		line := "Pickup"
+		if location := fulfilment.PickupLocation; location != nil {
+			line += " at " + location.Name
+			if location.Address != "" {
+				line += " (" + location.Address + ")"
+			}
+		}
+		if fulfilment.PickupSlot != "" {
+			line += ", " + fulfilment.PickupDate + " " + fulfilment.PickupSlot
+		}
+		return line
+	case models.FulfilmentShipping:
+		line := "Shipping"
+		if shipping != nil {
+			line += " via " + shipping.Courier + " " + shipping.Service + " to " + shipping.Destination
+			if shipping.MaxDays > 0 {
+				line += " (" + strconv.Itoa(shipping.MinDays) + "-" + strconv.Itoa(shipping.MaxDays) + " days)"
+			}
+		}
+		return line
+	case models.FulfilmentDineIn:
+		return "Dine-in, table " + fulfilment.TableNumber
+	}
+	return "Delivery"
+}
+
+// GetStoreOrders lists the orders of one of the user's stores, newest first
+func GetStoreOrders(db *models.Database) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// Get user ID from context
+		userID, err := getUserIDFromContext(r)
+		if err != nil {
+			RespondWithError(w, r, ErrUnauthenticated)
+			return
+		}
+
+		// Get store ID from URL
+		vars := mux.Vars(r)
+		storeID, err := primitive.ObjectIDFromHex(vars["id"])
+		if err != nil {
+			RespondWithError(w, r, ErrInvalidStoreID)
+			return
+		}
+
+		filter := bson.M{"store_id": storeID}
+		if status := r.URL.Query().Get("status"); status != "" {
+			if errs := (models.UpdateOrderStatusRequest{Status: status}).Validate(); len(errs) > 0 {
+				RespondWithError(w, r, ErrValidationFailed.WithDetails(errs))
+				return
+			}
+			filter["status"] = status
+		}
+
+		// Create database context
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		// Check if store exists and the user's role allows this
+		if _, err := loadStoreFor(ctx, db, storeID, userID, models.PermissionOrders); err != nil {
+			RespondWithError(w, r, err)
+			return
+		}
+
+		ordersColl := db.GetCollection(models.OrderCollection)
+		total, err := ordersColl.CountDocuments(ctx, filter)
+		if err != nil {
+			RespondWithError(w, r, ErrInternal.WithMessage("Failed to count orders"))
+			return
+		}
+
+		page, limit := parsePagination(r, 20, 100)
+		findOptions := options.Find()
+		findOptions.SetSort(bson.D{{Key: "created_at", Value: -1}})
+		findOptions.SetSkip((page - 1) * limit)
+		findOptions.SetLimit(limit)
+
+		cursor, err := ordersColl.Find(ctx, filter, findOptions)
+		if err != nil {
+			RespondWithError(w, r, ErrInternal.WithMessage("Failed to find orders"))
+			return
+		}
+		orders := []models.Order{}
+		if err = cursor.All(ctx, &orders); err != nil {
+			RespondWithError(w, r, ErrInternal.WithMessage("Failed to decode orders"))
+			return
+		}
+
+		// Send response
+		models.ApplyCurrency(orders)
+		RespondWithJSON(w, http.StatusOK, models.OrderListResponse{Orders: orders, Total: total, Page: page, Limit: limit})
+	}
+}
+
+// GetOrder returns one of the user's orders
+func GetOrder(db *models.Database) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// Get user ID from context
+		userID, err := getUserIDFromContext(r)
+		if err != nil {
+			RespondWithError(w, r, ErrUnauthenticated)
+			return
+		}
+
+		// Get order ID from URL
+		vars := mux.Vars(r)
+		orderID, err := primitive.ObjectIDFromHex(vars["id"])
+		if err != nil {
+			RespondWithError(w, r, ErrInvalidOrderID)
+			return
+		}
+
+		// Create database context
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		order, _, err := loadOrderFor(ctx, db, orderID, userID)
+		if err != nil {
+			RespondWithError(w, r, err)
+			return
+		}
+
+		// Send response
+		order.ApplyCurrency()
+		RespondWithJSON(w, http.StatusOK, order)
+	}
+}
+
+// UpdateOrderStatus moves one of the user's orders to another status
+func UpdateOrderStatus(db *models.Database) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// Get user ID from context
+		userID, err := getUserIDFromContext(r)
+		if err != nil {
+			RespondWithError(w, r, ErrUnauthenticated)
+			return
+		}
+
+		// Get order ID from URL
+		vars := mux.Vars(r)
+		orderID, err := primitive.ObjectIDFromHex(vars["id"])
+		if err != nil {
+			RespondWithError(w, r, ErrInvalidOrderID)
+			return
+		}
+
+		var req models.UpdateOrderStatusRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			RespondWithError(w, r, ErrInvalidPayload.WithDetails(err.Error()))
+			return
+		}
+		if errs := req.Validate(); len(errs) > 0 {
+			RespondWithError(w, r, ErrValidationFailed.WithDetails(errs))
+			return
+		}
+
+		// Create database context
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		order, _, err := loadOrderFor(ctx, db, orderID, userID)
+		if err != nil {
+			RespondWithError(w, r, err)
+			return
+		}
+
+		// Confirmed and completed orders hold their tracked stock. An order
+		// taken back from confirmed returns it; a completed order's goods are
+		// gone, so its stock stays taken.
+		holdsStock := req.Status == models.OrderConfirmed || req.Status == models.OrderCompleted
+		if holdsStock {
+			if err := reserveOrderStock(ctx, db, order); err != nil {
+				RespondWithError(w, r, err)
+				return
+			}
+		}
+
+		// Update order
+		ordersColl := db.GetCollection(models.OrderCollection)
+		_, err = ordersColl.UpdateOne(
+			ctx,
+			bson.M{"_id": orderID},
+			bson.M{"$set": bson.M{"status": req.Status, "updated_at": time.Now()}},
+		)
+		if err != nil {
+			RespondWithError(w, r, ErrInternal.WithMessage("Failed to update order"))
+			return
+		}
+
+		if !holdsStock && order.Status != models.OrderCompleted {
+			if err := releaseOrderStock(ctx, db, order); err != nil {
+				log.Printf("Failed to release stock of order %s: %v", orderID.Hex(), err)
+			}
+		}
+
+		// Cancelled orders don't count towards coupon limits
+		if req.Status == models.OrderCancelled {
+			if err := releaseOrderCoupon(ctx, db, order); err != nil {
+				log.Printf("Failed to release coupon of order %s: %v", orderID.Hex(), err)
+			}
+		} else if order.Status == models.OrderCancelled {
+			if err := recountOrderCoupon(ctx, db, order); err != nil {
+				log.Printf("Failed to count coupon of order %s: %v", orderID.Hex(), err)
+			}
+		}
+
+		// Get updated order
+		var updatedOrder models.Order
+		if err = ordersColl.FindOne(ctx, bson.M{"_id": orderID}).Decode(&updatedOrder); err != nil {
+			RespondWithError(w, r, ErrInternal.WithMessage("Failed to retrieve updated order"))
+			return
+		}
+
+		// Record mutation
+		recordAudit(ctx, db, r, models.AuditOrderStatus, "order", orderID, order.StoreID, &order, &updatedOrder)
+
+		// Send response
+		updatedOrder.ApplyCurrency()
+		RespondWithJSON(w, http.StatusOK, updatedOrder)
+	}
+}
+
+// reserveOrderStock takes the stock of an order's tracked items that it does
+// not hold yet, once. Marking the items first keeps concurrent confirmations
+// from both taking it.
+func reserveOrderStock(ctx context.Context, db *models.Database, order models.Order) error {
+	var items []models.OrderItem
+	var productIDs []primitive.ObjectID
+	for _, item := range order.Items {
+		if item.TrackStock && !item.StockReserved {
+			items = append(items, item)
+			productIDs = append(productIDs, item.ProductID)
+		}
+	}
+	if len(items) == 0 {
+		return nil
+	}
+
+	ordersColl := db.GetCollection(models.OrderCollection)
+	marked := options.Update().SetArrayFilters(options.ArrayFilters{
+		Filters: []interface{}{bson.M{"item.product_id": bson.M{"$in": productIDs}}},
+	})
+	result, err := ordersColl.UpdateOne(
+		ctx,
+		bson.M{"_id": order.ID, "items": bson.M{"$elemMatch": bson.M{"product_id": bson.M{"$in": productIDs}, "stock_reserved": bson.M{"$ne": true}}}},
+		bson.M{"$set": bson.M{"items.$[item].stock_reserved": true}},
+		marked,
+	)
+	if err != nil {
+		return ErrInternal.WithMessage("Failed to reserve stock")
+	}
+	if result.ModifiedCount == 0 {
+		return nil
+	}
+
+	if err := reserveStock(ctx, db, items); err != nil {
+		if _, unmarkErr := ordersColl.UpdateOne(ctx, bson.M{"_id": order.ID}, bson.M{"$unset": bson.M{"items.$[item].stock_reserved": ""}}, marked); unmarkErr != nil {
+			log.Printf("Failed to unmark stock of order %s: %v", order.ID.Hex(), unmarkErr)
+		}
+		return err
+	}
+	return nil
+}
+
+// reserveStock takes the quantities of the given items. It fails without
+// taking anything when a product no longer has enough.
+func reserveStock(ctx context.Context, db *models.Database, items []models.OrderItem) error {
+	productsColl := db.GetCollection(models.ProductCollection)
+	for i, item := range items {
+		result, err := productsColl.UpdateOne(
+			ctx,
+			bson.M{"_id": item.ProductID, "stock": bson.M{"$gte": item.Quantity}},
+			bson.M{"$inc": bson.M{"stock": -item.Quantity}},
+		)
+		if err != nil || result.MatchedCount == 0 {
+			releaseStock(ctx, db, items[:i])
+			if err != nil {
+				return ErrInternal.WithMessage("Failed to reserve stock")
+			}
+			return ErrProductsUnavailable.WithDetails([]models.FieldError{{Field: item.ProductID.Hex(), Message: "is out of stock"}})
+		}
+	}
+	return nil
+}
+
+// releaseStock puts the quantities of the given items back. Failures are logged.
+func releaseStock(ctx context.Context, db *models.Database, items []models.OrderItem) {
+	productsColl := db.GetCollection(models.ProductCollection)
+	for _, item := range items {
+		if _, err := productsColl.UpdateOne(ctx, bson.M{"_id": item.ProductID}, bson.M{"$inc": bson.M{"stock": item.Quantity}}); err != nil {
+			log.Printf("Failed to release %d of product %s: %v", item.Quantity, item.ProductID.Hex(), err)
+		}
+	}
+}
+
+// releaseOrderStock returns the stock an order reserved, once. Clearing the
+// reservation marks first keeps concurrent cancellations from both releasing.
+func releaseOrderStock(ctx context.Context, db *models.Database, order models.Order) error {
+	result, err := db.GetCollection(models.OrderCollection).UpdateOne(
+		ctx,
+		bson.M{"_id": order.ID, "items.stock_reserved": true},
+		bson.M{"$unset": bson.M{"items.$[].stock_reserved": ""}},
+	)
+	if err != nil {
+		return err
+	}
+	if result.ModifiedCount > 0 {
+		var items []models.OrderItem
+		for _, item := range order.Items {
+			if item.StockReserved {
+				items = append(items, item)
+			}
+		}
+		releaseStock(ctx, db, items)
+	}
+	return nil
+}
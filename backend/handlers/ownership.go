@@ -0,0 +1,255 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"wacatalogue/backend/models"
+)
+
+// loadStoreFor finds a store and checks that the user's role in it grants a
+// permission. Users without a role get ErrNotOwner.
+func loadStoreFor(ctx context.Context, db *models.Database, storeID, userID primitive.ObjectID, permission string) (models.Store, error) {
+	var store models.Store
+	err := db.GetCollection(models.StoreCollection).FindOne(ctx, notDeleted(bson.M{"_id": storeID})).Decode(&store)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return store, ErrStoreNotFound
+		}
+		return store, ErrInternal.WithMessage("Failed to find store")
+	}
+
+	role, err := storeRole(ctx, db, store, userID)
+	if err != nil {
+		return store, err
+	}
+	if role == "" {
+		return store, ErrNotOwner
+	}
+	if !models.RoleAllows(role, permission) {
+		return store, ErrNoPermission
+	}
+	return store, nil
+}
+
+// storeRole returns the user's role in a store, or "" when they have none
+func storeRole(ctx context.Context, db *models.Database, store models.Store, userID primitive.ObjectID) (string, error) {
+	if store.OwnerID == userID {
+		return models.RoleOwner, nil
+	}
+	var member models.StoreMember
+	err := db.GetCollection(models.StoreMemberCollection).FindOne(ctx, bson.M{"store_id": store.ID, "user_id": userID}).Decode(&member)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return "", nil
+		}
+		return "", ErrInternal.WithMessage("Failed to find store member")
+	}
+	return member.Role, nil
+}
+
+// storeAsSeenBy hides a store's notification settings from roles that may
+// not change its settings
+func storeAsSeenBy(store models.Store, role string) models.Store {
+	if models.RoleAllows(role, models.PermissionSettings) {
+		return store
+	}
+	return store.Public()
+}
+
+// loadMyStore finds the store a dashboard request is about: the one named by
+// the storeId query parameter, else the user's selected store, else their
+// oldest store, else the first store they are a member of
+func loadMyStore(ctx context.Context, db *models.Database, r *http.Request, userID primitive.ObjectID) (models.Store, error) {
+	if value := r.URL.Query().Get("storeId"); value != "" {
+		storeID, err := primitive.ObjectIDFromHex(value)
+		if err != nil {
+			return models.Store{}, ErrInvalidStoreID
+		}
+		return loadStoreFor(ctx, db, storeID, userID, models.PermissionView)
+	}
+
+	var user models.User
+	if err := db.GetCollection(models.UserCollection).FindOne(ctx, bson.M{"_id": userID}).Decode(&user); err != nil && err != mongo.ErrNoDocuments {
+		return models.Store{}, ErrInternal.WithMessage("Failed to find user")
+	}
+	if !user.StoreID.IsZero() {
+		if store, err := loadStoreFor(ctx, db, user.StoreID, userID, models.PermissionView); err == nil {
+			return store, nil
+		}
+	}
+
+	// Owned stores come before the ones the user helps run
+	members, err := storeMemberships(ctx, db, userID)
+	if err != nil {
+		return models.Store{}, err
+	}
+	storeIDs := make([]primitive.ObjectID, len(members))
+	for i, member := range members {
+		storeIDs[i] = member.StoreID
+	}
+	storesColl := db.GetCollection(models.StoreCollection)
+	findOptions := options.FindOne().SetSort(bson.D{{Key: "created_at", Value: 1}})
+	var store models.Store
+	for _, filter := range []bson.M{{"owner_id": userID}, {"_id": bson.M{"$in": storeIDs}}} {
+		err = storesColl.FindOne(ctx, notDeleted(filter), findOptions).Decode(&store)
+		if err == nil {
+			return store, nil
+		}
+		if err != mongo.ErrNoDocuments {
+			return store, ErrInternal.WithMessage("Failed to find store")
+		}
+	}
+	return store, ErrNoStore
+}
+
+// storeMemberships lists the user's roles in stores they do not own
+func storeMemberships(ctx context.Context, db *models.Database, userID primitive.ObjectID) ([]models.StoreMember, error) {
+	cursor, err := db.GetCollection(models.StoreMemberCollection).Find(ctx, bson.M{"user_id": userID})
+	if err != nil {
+		return nil, ErrInternal.WithMessage("Failed to find store memberships")
+	}
+	members := []models.StoreMember{}
+	if err := cursor.All(ctx, &members); err != nil {
+		return nil, ErrInternal.WithMessage("Failed to decode store memberships")
+	}
+	return members, nil
+}
+
+// loadProductFor finds a product and checks that the user may manage the catalog of its store
+func loadProductFor(ctx context.Context, db *models.Database, productID, userID primitive.ObjectID) (models.Product, models.Store, error) {
+	var product models.Product
+	err := db.GetCollection(models.ProductCollection).FindOne(ctx, notDeleted(bson.M{"_id": productID})).Decode(&product)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return product, models.Store{}, ErrProductNotFound
+		}
+		return product, models.Store{}, ErrInternal.WithMessage("Failed to find product")
+	}
+
+	store, err := loadStoreFor(ctx, db, product.StoreID, userID, models.PermissionCatalog)
+	if err == ErrStoreNotFound {
+		// A product whose store is gone can't be managed by anyone
+		return product, store, ErrProductNotFound
+	}
+	return product, store, err
+}
+
+// notDeleted adds a condition excluding documents that are in the trash
+func notDeleted(filter bson.M) bson.M {
+	filter["deleted_at"] = bson.M{"$exists": false}
+	return filter
+}
+
+// loadOrderFor finds an order and checks that the user may manage the orders of its store
+func loadOrderFor(ctx context.Context, db *models.Database, orderID, userID primitive.ObjectID) (models.Order, models.Store, error) {
+	var order models.Order
+	err := db.GetCollection(models.OrderCollection).FindOne(ctx, bson.M{"_id": orderID}).Decode(&order)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return order, models.Store{}, ErrOrderNotFound
+		}
+		return order, models.Store{}, ErrInternal.WithMessage("Failed to find order")
+	}
+
+	store, err := loadStoreFor(ctx, db, order.StoreID, userID, models.PermissionOrders)
+	if err == ErrStoreNotFound {
+		return order, store, ErrOrderNotFound
+	}
+	return order, store, err
+}
+
+// loadCouponFor finds a coupon and checks that the user may change the settings of its store
+func loadCouponFor(ctx context.Context, db *models.Database, couponID, userID primitive.ObjectID) (models.Coupon, models.Store, error) {
+	var coupon models.Coupon
+	err := db.GetCollection(models.CouponCollection).FindOne(ctx, bson.M{"_id": couponID}).Decode(&coupon)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return coupon, models.Store{}, ErrCouponNotFound
+		}
+		return coupon, models.Store{}, ErrInternal.WithMessage("Failed to find coupon")
+	}
+
+	store, err := loadStoreFor(ctx, db, coupon.StoreID, userID, models.PermissionSettings)
+	if err == ErrStoreNotFound {
+		return coupon, store, ErrCouponNotFound
+	}
+	return coupon, store, err
+}
+
+// loadZoneFor finds a delivery zone and checks that the user may change the settings of its store
+func loadZoneFor(ctx context.Context, db *models.Database, zoneID, userID primitive.ObjectID) (models.DeliveryZone, models.Store, error) {
+	var zone models.DeliveryZone
+	err := db.GetCollection(models.DeliveryZoneCollection).FindOne(ctx, bson.M{"_id": zoneID}).Decode(&zone)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return zone, models.Store{}, ErrZoneNotFound
+		}
+		return zone, models.Store{}, ErrInternal.WithMessage("Failed to find delivery zone")
+	}
+
+	store, err := loadStoreFor(ctx, db, zone.StoreID, userID, models.PermissionSettings)
+	if err == ErrStoreNotFound {
+		return zone, store, ErrZoneNotFound
+	}
+	return zone, store, err
+}
+
+// loadCustomerFor finds a customer and checks that the user may manage the orders of their store
+func loadCustomerFor(ctx context.Context, db *models.Database, customerID, userID primitive.ObjectID) (models.Customer, models.Store, error) {
+	var customer models.Customer
+	err := db.GetCollection(models.CustomerCollection).FindOne(ctx, bson.M{"_id": customerID}).Decode(&customer)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return customer, models.Store{}, ErrCustomerNotFound
+		}
+		return customer, models.Store{}, ErrInternal.WithMessage("Failed to find customer")
+	}
+
+	store, err := loadStoreFor(ctx, db, customer.StoreID, userID, models.PermissionOrders)
+	if err == ErrStoreNotFound {
+		return customer, store, ErrCustomerNotFound
+	}
+	return customer, store, err
+}
+
+// loadMemberFor finds a store member and checks that the user may manage the members of their store
+func loadMemberFor(ctx context.Context, db *models.Database, memberID, userID primitive.ObjectID) (models.StoreMember, error) {
+	var member models.StoreMember
+	err := db.GetCollection(models.StoreMemberCollection).FindOne(ctx, bson.M{"_id": memberID}).Decode(&member)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return member, ErrMemberNotFound
+		}
+		return member, ErrInternal.WithMessage("Failed to find store member")
+	}
+
+	_, err = loadStoreFor(ctx, db, member.StoreID, userID, models.PermissionMembers)
+	if err == ErrStoreNotFound {
+		return member, ErrMemberNotFound
+	}
+	return member, err
+}
+
+// loadReviewFor finds a review and checks that the user may manage the catalog of its store
+func loadReviewFor(ctx context.Context, db *models.Database, reviewID, userID primitive.ObjectID) (models.Review, error) {
+	var review models.Review
+	err := db.GetCollection(models.ReviewCollection).FindOne(ctx, bson.M{"_id": reviewID}).Decode(&review)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return review, ErrReviewNotFound
+		}
+		return review, ErrInternal.WithMessage("Failed to find review")
+	}
+
+	_, err = loadStoreFor(ctx, db, review.StoreID, userID, models.PermissionCatalog)
+	if err == ErrStoreNotFound {
+		return review, ErrReviewNotFound
+	}
+	return review, err
+}
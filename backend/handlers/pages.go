@@ -0,0 +1,354 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"embed"
+	"encoding/json"
+	"html/template"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+
+	"wacatalogue/backend/models"
+)
+
+//go:embed templates/*.html
+var templateFS embed.FS
+
+var pageTemplates = template.Must(template.ParseFS(templateFS, "templates/*.html"))
+
+// Public pages change rarely and are shared widely, so let caches keep them briefly
+const pageCacheControl = "public, max-age=300"
+
+// pageProduct is a product as displayed on a server-rendered page
+type pageProduct struct {
+	Name        string
+	Description string
+	Image       string
+	URL         string
+	Price       string
+	InStock     bool
+}
+
+// pageData is the template data for server-rendered storefront pages
+type pageData struct {
+	// Head
+	Title         string
+	Description   string
+	URL           string
+	Image         string
+	OGType        string
+	PriceAmount   string
+	PriceCurrency string
+	JSONLD        template.JS
+
+	// Hydration
+	InitialState template.JS
+	AppScript    string
+
+	// Body
+	Store       models.Store
+	StoreURL    string
+	Product     pageProduct
+	Products    []pageProduct
+	WhatsAppURL string
+}
+
+// StorePage server-renders a store with Open Graph tags, JSON-LD and a
+// crawlable product list, then hands over to the Svelte app
+func StorePage(db *models.Database) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// Get store ID from URL
+		vars := mux.Vars(r)
+		storeID, err := primitive.ObjectIDFromHex(vars["storeId"])
+		if err != nil {
+			renderNotFound(w, "This store does not exist.")
+			return
+		}
+
+		// Create database context
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		store, products, err := loadPublicCatalog(ctx, db, storeID)
+		if err != nil {
+			if err == ErrStoreNotFound {
+				renderNotFound(w, "This store does not exist or is no longer available.")
+			} else {
+				log.Printf("Failed to render store page %s: %v", storeID.Hex(), err)
+				http.Error(w, "Internal server error", http.StatusInternalServerError)
+			}
+			return
+		}
+
+		base := publicBaseURL(r)
+		data := pageData{
+			Title:       store.Name,
+			Description: pageDescription(store.Description, "Browse products from "+store.Name+" and order via WhatsApp"),
+			URL:         storeURL(base, store.ID),
+			Image:       absoluteURL(base, store.Logo),
+			OGType:      "website",
+			AppScript:   appScript(),
+			Store:       store,
+			StoreURL:    storeURL(base, store.ID),
+			WhatsAppURL: whatsAppURL(store.WhatsappNumber, "Hi "+store.Name+", I'd like to order"),
+		}
+
+		currency := models.CurrencyOf(store.Currency)
+		offers := []interface{}{}
+		for _, product := range products {
+			data.Products = append(data.Products, newPageProduct(base, store, product))
+			offers = append(offers, map[string]interface{}{
+				"@type":         "Offer",
+				"price":         currency.DecimalString(product.EffectivePriceMinor),
+				"priceCurrency": currency.Code,
+				"availability":  schemaAvailability(product),
+				"itemOffered": map[string]interface{}{
+					"@type": "Product",
+					"name":  product.Name,
+					"url":   productURL(base, store.ID, product.ID),
+				},
+			})
+		}
+
+		business := map[string]interface{}{
+			"@context": "https://schema.org",
+			"@type":    "Store",
+			"name":     store.Name,
+			"url":      data.URL,
+			"hasOfferCatalog": map[string]interface{}{
+				"@type":           "OfferCatalog",
+				"name":            store.Name,
+				"itemListElement": offers,
+			},
+		}
+		setIfPresent(business, "description", store.Description)
+		setIfPresent(business, "image", data.Image)
+		setIfPresent(business, "address", store.Location)
+		setIfPresent(business, "openingHours", store.BusinessHours)
+		if digits := phoneDigits(store.WhatsappNumber); digits != "" {
+			business["telephone"] = "+" + digits
+		}
+
+		data.JSONLD = marshalScript(business)
+		data.InitialState = marshalScript(map[string]interface{}{
+			"route":    "/store/" + store.ID.Hex(),
+			"store":    store.Public(),
+			"products": products,
+		})
+
+		renderPage(w, "store", data)
+	}
+}
+
+// ProductPage server-renders a single product with Open Graph tags and
+// schema.org Product JSON-LD
+func ProductPage(db *models.Database) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// Get store and product IDs from URL
+		vars := mux.Vars(r)
+		storeID, err := primitive.ObjectIDFromHex(vars["storeId"])
+		if err != nil {
+			renderNotFound(w, "This product does not exist.")
+			return
+		}
+		productID, err := primitive.ObjectIDFromHex(vars["id"])
+		if err != nil {
+			renderNotFound(w, "This product does not exist.")
+			return
+		}
+
+		// Create database context
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		// Find the active store and the active product within it
+		var store models.Store
+		err = db.GetCollection(models.StoreCollection).FindOne(ctx, notDeleted(bson.M{"_id": storeID, "active": true})).Decode(&store)
+		if err == nil {
+			var product models.Product
+			err = db.GetCollection(models.ProductCollection).FindOne(ctx, notDeleted(bson.M{"_id": productID, "store_id": storeID, "active": true})).Decode(&product)
+			if err == nil {
+				product.ApplyPricing(time.Now(), models.CurrencyOf(store.Currency))
+				renderProductPage(w, r, store, product)
+				return
+			}
+		}
+
+		if err == mongo.ErrNoDocuments {
+			renderNotFound(w, "This product does not exist or is no longer available.")
+		} else {
+			log.Printf("Failed to render product page %s: %v", productID.Hex(), err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+		}
+	}
+}
+
+// renderProductPage builds and writes the product page
+func renderProductPage(w http.ResponseWriter, r *http.Request, store models.Store, product models.Product) {
+	base := publicBaseURL(r)
+	item := newPageProduct(base, store, product)
+	currency := models.CurrencyOf(store.Currency)
+
+	data := pageData{
+		Title:         product.Name + " - " + store.Name,
+		Description:   pageDescription(product.Description, product.Name+" from "+store.Name+", "+item.Price),
+		URL:           item.URL,
+		Image:         item.Image,
+		OGType:        "product",
+		PriceAmount:   currency.DecimalString(product.EffectivePriceMinor),
+		PriceCurrency: currency.Code,
+		AppScript:     appScript(),
+		Store:         store,
+		StoreURL:      storeURL(base, store.ID),
+		Product:       item,
+		WhatsAppURL:   whatsAppURL(store.WhatsappNumber, "Hi "+store.Name+", I'd like to order "+product.Name+" ("+item.URL+")"),
+	}
+
+	offer := map[string]interface{}{
+		"@type":         "Offer",
+		"price":         data.PriceAmount,
+		"priceCurrency": data.PriceCurrency,
+		"availability":  schemaAvailability(product),
+		"url":           item.URL,
+		"seller": map[string]interface{}{
+			"@type": "Organization",
+			"name":  store.Name,
+		},
+	}
+	jsonLD := map[string]interface{}{
+		"@context": "https://schema.org",
+		"@type":    "Product",
+		"name":     product.Name,
+		"url":      item.URL,
+		"brand": map[string]interface{}{
+			"@type": "Brand",
+			"name":  store.Name,
+		},
+		"offers": offer,
+	}
+	setIfPresent(jsonLD, "description", product.Description)
+	setIfPresent(jsonLD, "image", item.Image)
+	setIfPresent(jsonLD, "sku", product.SKU)
+	setIfPresent(jsonLD, "category", product.Category)
+
+	data.JSONLD = marshalScript(jsonLD)
+	data.InitialState = marshalScript(map[string]interface{}{
+		"route":     "/store/" + store.ID.Hex(),
+		"store":     store.Public(),
+		"product":   product,
+		"productId": product.ID.Hex(),
+	})
+
+	renderPage(w, "product", data)
+}
+
+// newPageProduct prepares a product for display
+func newPageProduct(base string, store models.Store, product models.Product) pageProduct {
+	return pageProduct{
+		Name:        product.Name,
+		Description: product.Description,
+		Image:       absoluteURL(base, product.Image),
+		URL:         productURL(base, store.ID, product.ID),
+		Price:       product.FormattedPrice,
+		InStock:     product.InStock(),
+	}
+}
+
+// renderPage executes a page template into a buffer so template errors
+// never produce a half-written page
+func renderPage(w http.ResponseWriter, name string, data pageData) {
+	var buf bytes.Buffer
+	if err := pageTemplates.ExecuteTemplate(&buf, name, data); err != nil {
+		log.Printf("Failed to render %s page: %v", name, err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", pageCacheControl)
+	w.WriteHeader(http.StatusOK)
+	w.Write(buf.Bytes())
+}
+
+// renderNotFound writes the HTML 404 page
+func renderNotFound(w http.ResponseWriter, message string) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(http.StatusNotFound)
+	pageTemplates.ExecuteTemplate(w, "not-found", message)
+}
+
+// appScript is the module that boots the Svelte app. Development uses Vite's
+// source entry; production builds set SPA_ENTRY to the hashed bundle.
+func appScript() string {
+	if entry := os.Getenv("SPA_ENTRY"); entry != "" {
+		return entry
+	}
+	return "/src/main.js"
+}
+
+// marshalScript encodes a value for embedding in a <script> element. The JSON
+// encoder escapes <, > and &, so the output can't close the element early.
+func marshalScript(v interface{}) template.JS {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return template.JS("null")
+	}
+	return template.JS(data)
+}
+
+// pageDescription trims a description for meta tags, falling back when empty
+func pageDescription(description, fallback string) string {
+	description = strings.Join(strings.Fields(description), " ")
+	if description == "" {
+		return fallback
+	}
+	if runes := []rune(description); len(runes) > 200 {
+		return string(runes[:197]) + "..."
+	}
+	return description
+}
+
+// schemaAvailability maps stock to a schema.org ItemAvailability URL
+func schemaAvailability(product models.Product) string {
+	if product.InStock() {
+		return "https://schema.org/InStock"
+	}
+	return "https://schema.org/OutOfStock"
+}
+
+// setIfPresent adds a JSON-LD property only when it has a value
+func setIfPresent(doc map[string]interface{}, key, value string) {
+	if value != "" {
+		doc[key] = value
+	}
+}
+
+// phoneDigits strips a WhatsApp number down to the digits wa.me expects
+func phoneDigits(number string) string {
+	var digits strings.Builder
+	for _, c := range number {
+		if c >= '0' && c <= '9' {
+			digits.WriteRune(c)
+		}
+	}
+	return digits.String()
+}
+
+// whatsAppURL returns a wa.me link with a pre-filled message
+func whatsAppURL(number, message string) string {
+	digits := phoneDigits(number)
+	if digits == "" {
+		return ""
+	}
+	return "https://wa.me/" + digits + "?text=" + strings.ReplaceAll(url.QueryEscape(message), "+", "%20")
+}
@@ -0,0 +1,45 @@
+package handlers
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"wacatalogue/backend/models"
+)
+
+// TestRenderProductPageHidesNotifications checks that the owner's private
+// notification settings stay out of the public page source
+func TestRenderProductPageHidesNotifications(t *testing.T) {
+	store := models.Store{
+		ID:             primitive.NewObjectID(),
+		Name:           "Toko Kopi",
+		WhatsappNumber: "6281234567890",
+		Currency:       "IDR",
+		Notifications: &models.NotificationSettings{
+			NewOrders: true,
+			WhatsApp:  "6289999999999",
+			Email:     "owner@example.com",
+		},
+	}
+	product := models.Product{ID: primitive.NewObjectID(), StoreID: store.ID, Name: "Kopi Susu"}
+
+	req := httptest.NewRequest("GET", "/store/"+store.ID.Hex()+"/product/"+product.ID.Hex(), nil)
+	rec := httptest.NewRecorder()
+	renderProductPage(rec, req, store, product)
+
+	if rec.Code != 200 {
+		t.Fatalf("status = %d, want 200", rec.Code)
+	}
+	page := rec.Body.String()
+	if !strings.Contains(page, "Kopi Susu") {
+		t.Fatalf("page does not contain the product:\n%s", page)
+	}
+	for _, secret := range []string{"owner@example.com", "6289999999999"} {
+		if strings.Contains(page, secret) {
+			t.Errorf("page contains notification setting %q", secret)
+		}
+	}
+}
@@ -0,0 +1,683 @@
+package handlers
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"wacatalogue/backend/models"
+)
+
+// Columns of the product CSV format, in export order
+var productCSVColumns = []string{"sku", "name", "description", "price", "image", "category", "stock", "track_stock", "featured", "active", "weight_g", "length_cm", "width_cm", "height_cm"}
+
+const (
+	// Imports with more rows than this run as a background job
+	importAsyncThreshold = 200
+	// Rows written per bulk operation, and how often job progress is saved
+	importBatchSize = 100
+	// Maximum accepted upload size
+	importMaxBytes = 10 << 20
+)
+
+// importRow is a parsed CSV row. Present records which columns had a value,
+// so that updates only touch the fields the file actually provides.
+type importRow struct {
+	Line    int
+	Req     models.CreateProductRequest
+	Present map[string]bool
+}
+
+// ExportProductsCSV returns all of a store's products as CSV
+func ExportProductsCSV(db *models.Database) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// Get user ID from context
+		userID, err := getUserIDFromContext(r)
+		if err != nil {
+			RespondWithError(w, r, ErrUnauthenticated)
+			return
+		}
+
+		// Get store ID from URL
+		vars := mux.Vars(r)
+		storeID, err := primitive.ObjectIDFromHex(vars["storeId"])
+		if err != nil {
+			RespondWithError(w, r, ErrInvalidStoreID)
+			return
+		}
+
+		// Create database context
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+
+		// Check if store exists and the user's role allows this
+		store, err := loadStoreFor(ctx, db, storeID, userID, models.PermissionCatalog)
+		if err != nil {
+			RespondWithError(w, r, err)
+			return
+		}
+		currency := models.CurrencyOf(store.Currency)
+
+		// Find all products for store, inactive ones included
+		productsColl := db.GetCollection(models.ProductCollection)
+		findOptions := options.Find().SetSort(bson.D{{Key: "created_at", Value: 1}})
+		cursor, err := productsColl.Find(ctx, notDeleted(bson.M{"store_id": storeID}), findOptions)
+		if err != nil {
+			RespondWithError(w, r, ErrInternal.WithMessage("Failed to find products"))
+			return
+		}
+		defer cursor.Close(ctx)
+
+		w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+		w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="products-%s.csv"`, storeID.Hex()))
+		w.WriteHeader(http.StatusOK)
+
+		writer := csv.NewWriter(w)
+		writer.Write(productCSVColumns)
+		for cursor.Next(ctx) {
+			var product models.Product
+			if err := cursor.Decode(&product); err != nil {
+				log.Printf("Failed to decode product during CSV export of store %s: %v", storeID.Hex(), err)
+				continue
+			}
+			writer.Write([]string{
+				product.SKU,
+				product.Name,
+				product.Description,
+				currency.DecimalString(product.PriceMinor),
+				product.Image,
+				product.Category,
+				strconv.Itoa(product.Stock),
+				strconv.FormatBool(product.TrackStock),
+				strconv.FormatBool(product.Featured),
+				strconv.FormatBool(product.Active),
+				formatParcelInt(product.WeightGrams),
+				formatParcelFloat(product.LengthCm),
+				formatParcelFloat(product.WidthCm),
+				formatParcelFloat(product.HeightCm),
+			})
+		}
+		writer.Flush()
+	}
+}
+
+// ImportProductsCSV validates a product CSV. Requests default to a dry run;
+// with dryRun=false the rows are upserted by SKU or, for rows without one,
+// by name. Large imports run in the background and return a job to poll.
+func ImportProductsCSV(db *models.Database) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// Get user ID from context
+		userID, err := getUserIDFromContext(r)
+		if err != nil {
+			RespondWithError(w, r, ErrUnauthenticated)
+			return
+		}
+
+		// Get store ID from URL
+		vars := mux.Vars(r)
+		storeID, err := primitive.ObjectIDFromHex(vars["storeId"])
+		if err != nil {
+			RespondWithError(w, r, ErrInvalidStoreID)
+			return
+		}
+
+		dryRun := r.URL.Query().Get("dryRun") != "false"
+
+		// Check if store exists and the user's role allows this; prices are in its currency
+		lookupCtx, lookupCancel := context.WithTimeout(context.Background(), 10*time.Second)
+		store, err := loadStoreFor(lookupCtx, db, storeID, userID, models.PermissionCatalog)
+		lookupCancel()
+		if err != nil {
+			RespondWithError(w, r, err)
+			return
+		}
+
+		// Read and validate the file before touching the products
+		body, err := uploadedCSV(w, r)
+		if err != nil {
+			RespondWithError(w, r, ErrInvalidPayload.WithDetails(err.Error()))
+			return
+		}
+		defer body.Close()
+
+		rows, rowErrors, err := parseProductCSV(body, models.CurrencyOf(store.Currency))
+		if err != nil {
+			RespondWithError(w, r, ErrInvalidPayload.WithDetails(err.Error()))
+			return
+		}
+
+		report := models.ImportReport{
+			DryRun:    dryRun,
+			TotalRows: len(rows) + countRows(rowErrors),
+			ValidRows: len(rows),
+			Errors:    rowErrors,
+		}
+
+		// Create database context
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		if dryRun {
+			RespondWithJSON(w, http.StatusOK, report)
+			return
+		}
+
+		// Only import files that are valid as a whole
+		if len(rowErrors) > 0 {
+			RespondWithError(w, r, ErrValidationFailed.WithMessage("CSV contains invalid rows").WithDetails(report))
+			return
+		}
+
+		audit := newAuditEntry(r, models.AuditProductImport, "store", storeID, storeID)
+
+		// Small imports run inline
+		if len(rows) <= importAsyncThreshold {
+			importCtx, importCancel := context.WithTimeout(context.Background(), time.Minute)
+			defer importCancel()
+
+			report.Created, report.Updated, err = applyProductImport(importCtx, db, storeID, rows, audit, nil)
+			if err != nil {
+				log.Printf("Product import for store %s failed: %v", storeID.Hex(), err)
+				RespondWithError(w, r, ErrInternal.WithMessage("Failed to import products"))
+				return
+			}
+
+			recordImportAudit(importCtx, db, audit, report)
+			RespondWithJSON(w, http.StatusOK, report)
+			return
+		}
+
+		// Large imports run as a background job
+		now := time.Now()
+		job := models.ImportJob{
+			ID:        primitive.NewObjectID(),
+			StoreID:   storeID,
+			UserID:    userID,
+			Status:    models.ImportJobPending,
+			TotalRows: len(rows),
+			CreatedAt: now,
+			UpdatedAt: now,
+		}
+		if _, err := db.GetCollection(models.ImportJobCollection).InsertOne(ctx, job); err != nil {
+			RespondWithError(w, r, ErrInternal.WithMessage("Failed to create import job"))
+			return
+		}
+
+		go runImportJob(db, job, rows, report, audit)
+
+		// Send response
+		RespondWithJSON(w, http.StatusAccepted, job)
+	}
+}
+
+// GetImportJob reports the progress of a background import
+func GetImportJob(db *models.Database) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// Get user ID from context
+		userID, err := getUserIDFromContext(r)
+		if err != nil {
+			RespondWithError(w, r, ErrUnauthenticated)
+			return
+		}
+
+		// Get job ID from URL
+		vars := mux.Vars(r)
+		jobID, err := primitive.ObjectIDFromHex(vars["id"])
+		if err != nil {
+			RespondWithError(w, r, ErrImportJobNotFound)
+			return
+		}
+
+		// Create database context
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		var job models.ImportJob
+		err = db.GetCollection(models.ImportJobCollection).FindOne(ctx, bson.M{"_id": jobID, "user_id": userID}).Decode(&job)
+		if err != nil {
+			if err == mongo.ErrNoDocuments {
+				RespondWithError(w, r, ErrImportJobNotFound)
+			} else {
+				RespondWithError(w, r, ErrInternal.WithMessage("Failed to find import job"))
+			}
+			return
+		}
+
+		// Send response
+		RespondWithJSON(w, http.StatusOK, job)
+	}
+}
+
+// runImportJob applies an import in the background, saving progress after every batch
+func runImportJob(db *models.Database, job models.ImportJob, rows []importRow, report models.ImportReport, audit models.AuditEntry) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Minute)
+	defer cancel()
+
+	jobsColl := db.GetCollection(models.ImportJobCollection)
+	setJob := func(fields bson.M) {
+		fields["updated_at"] = time.Now()
+		if _, err := jobsColl.UpdateOne(ctx, bson.M{"_id": job.ID}, bson.M{"$set": fields}); err != nil {
+			log.Printf("Failed to update import job %s: %v", job.ID.Hex(), err)
+		}
+	}
+
+	setJob(bson.M{"status": models.ImportJobRunning})
+
+	created, updated, err := applyProductImport(ctx, db, job.StoreID, rows, audit, func(processed int) {
+		setJob(bson.M{"processed_rows": processed})
+	})
+
+	finishedAt := time.Now()
+	if err != nil {
+		log.Printf("Import job %s failed: %v", job.ID.Hex(), err)
+		setJob(bson.M{"status": models.ImportJobFailed, "error": err.Error(), "finished_at": finishedAt})
+		return
+	}
+
+	report.Created = created
+	report.Updated = updated
+	setJob(bson.M{
+		"status":         models.ImportJobCompleted,
+		"processed_rows": len(rows),
+		"report":         report,
+		"finished_at":    finishedAt,
+	})
+	recordImportAudit(ctx, db, audit, report)
+}
+
+// FailInterruptedImports marks import jobs that stopped making progress, such
+// as those running when the server last stopped, as failed so they don't
+// look like they are still running. Jobs save progress after every batch, so
+// jobs of other servers that are still running are left alone.
+func FailInterruptedImports(ctx context.Context, db *models.Database, staleAfter time.Duration) error {
+	now := time.Now()
+	result, err := db.GetCollection(models.ImportJobCollection).UpdateMany(ctx,
+		bson.M{
+			"status":     bson.M{"$in": []string{models.ImportJobPending, models.ImportJobRunning}},
+			"updated_at": bson.M{"$lt": now.Add(-staleAfter)},
+		},
+		bson.M{"$set": bson.M{"status": models.ImportJobFailed, "error": "import was interrupted; run it again", "updated_at": now, "finished_at": now}},
+	)
+	if err != nil {
+		return err
+	}
+	if result.ModifiedCount > 0 {
+		log.Printf("Marked %d interrupted import jobs as failed", result.ModifiedCount)
+	}
+	return nil
+}
+
+// recordImportAudit logs the outcome of an import as a summary entry, next
+// to the entries of the products it changed
+func recordImportAudit(ctx context.Context, db *models.Database, entry models.AuditEntry, report models.ImportReport) {
+	entry.Changes = map[string]models.FieldChange{
+		"created": {Before: nil, After: report.Created},
+		"updated": {Before: nil, After: report.Updated},
+	}
+	entry.CreatedAt = time.Now()
+	insertAudit(ctx, db, entry)
+}
+
+// applyProductImport upserts rows into a store's catalog. Rows match existing
+// products by SKU, or by case-insensitive name when the row has no SKU. Each
+// product created or changed gets its own audit entry, based on audit.
+func applyProductImport(ctx context.Context, db *models.Database, storeID primitive.ObjectID, rows []importRow, audit models.AuditEntry, progress func(processed int)) (created, updated int, err error) {
+	productsColl := db.GetCollection(models.ProductCollection)
+
+	// Index the existing catalog
+	cursor, err := productsColl.Find(ctx, notDeleted(bson.M{"store_id": storeID}))
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to load products: %v", err)
+	}
+	var existing []models.Product
+	if err = cursor.All(ctx, &existing); err != nil {
+		return 0, 0, fmt.Errorf("failed to decode products: %v", err)
+	}
+
+	bySKU := map[string]primitive.ObjectID{}
+	byName := map[string]primitive.ObjectID{}
+	current := map[primitive.ObjectID]bson.M{} // Products as the import has left them so far, for audit diffs
+	for _, product := range existing {
+		if product.SKU != "" {
+			bySKU[product.SKU] = product.ID
+		}
+		byName[normalizeName(product.Name)] = product.ID
+		current[product.ID] = toBSONMap(product)
+	}
+	auditEntry := func(action string, productID primitive.ObjectID, changes map[string]models.FieldChange, now time.Time) models.AuditEntry {
+		entry := audit
+		entry.ID = primitive.NewObjectID()
+		entry.Action = action
+		entry.TargetType = "product"
+		entry.TargetID = productID
+		entry.Changes = changes
+		entry.CreatedAt = now
+		return entry
+	}
+
+	for start := 0; start < len(rows); start += importBatchSize {
+		end := start + importBatchSize
+		if end > len(rows) {
+			end = len(rows)
+		}
+
+		now := time.Now()
+		var writes []mongo.WriteModel
+		var entries []interface{}
+		for _, row := range rows[start:end] {
+			req := row.Req
+
+			id, found := primitive.NilObjectID, false
+			if req.SKU != "" {
+				id, found = bySKU[req.SKU]
+			} else {
+				id, found = byName[normalizeName(req.Name)]
+			}
+
+			if found {
+				update := importUpdate(row, now)
+				writes = append(writes, mongo.NewUpdateOneModel().
+					SetFilter(bson.M{"_id": id}).
+					SetUpdate(bson.M{"$set": update}))
+				updated++
+
+				before := current[id]
+				after := bson.M{}
+				for field, value := range before {
+					after[field] = value
+				}
+				for field, value := range update {
+					after[field] = value
+				}
+				after = toBSONMap(after)
+				current[id] = after
+				if changes := diffDocuments(before, after); len(changes) > 0 {
+					entries = append(entries, auditEntry(models.AuditProductUpdate, id, changes, now))
+				}
+				continue
+			}
+
+			active := true
+			if req.Active != nil {
+				active = *req.Active
+			}
+			product := models.Product{
+				ID:          primitive.NewObjectID(),
+				StoreID:     storeID,
+				SKU:         req.SKU,
+				Name:        req.Name,
+				Description: req.Description,
+				PriceMinor:  req.PriceMinor,
+				Image:       req.Image,
+				Category:    req.Category,
+				Stock:       req.Stock,
+				TrackStock:  req.TrackStock,
+				Featured:    req.Featured,
+				Active:      active,
+				CreatedAt:   now,
+				UpdatedAt:   now,
+				WeightGrams: req.WeightGrams,
+				LengthCm:    req.LengthCm,
+				WidthCm:     req.WidthCm,
+				HeightCm:    req.HeightCm,
+			}
+			writes = append(writes, mongo.NewInsertOneModel().SetDocument(product))
+			created++
+			current[product.ID] = toBSONMap(product)
+			entries = append(entries, auditEntry(models.AuditProductCreate, product.ID, diffDocuments(nil, &product), now))
+
+			// Later rows for the same product update this one
+			if product.SKU != "" {
+				bySKU[product.SKU] = product.ID
+			}
+			byName[normalizeName(product.Name)] = product.ID
+		}
+
+		if _, err := productsColl.BulkWrite(ctx, writes); err != nil {
+			return created, updated, fmt.Errorf("failed to write rows %d-%d: %v", rows[start].Line, rows[end-1].Line, err)
+		}
+		if len(entries) > 0 {
+			if _, err := db.GetCollection(models.AuditLogCollection).InsertMany(ctx, entries); err != nil {
+				log.Printf("Failed to write audit entries of imported rows %d-%d: %v", rows[start].Line, rows[end-1].Line, err)
+			}
+		}
+		if progress != nil {
+			progress(end)
+		}
+	}
+
+	return created, updated, nil
+}
+
+// importUpdate builds the $set document for a row that matches an existing product
+func importUpdate(row importRow, now time.Time) bson.M {
+	req := row.Req
+	update := bson.M{"updated_at": now}
+	values := map[string]interface{}{
+		"sku":         req.SKU,
+		"name":        req.Name,
+		"description": req.Description,
+		"image":       req.Image,
+		"category":    req.Category,
+		"stock":       req.Stock,
+		"track_stock": req.TrackStock,
+		"featured":    req.Featured,
+		"weight_g":    req.WeightGrams,
+		"length_cm":   req.LengthCm,
+		"width_cm":    req.WidthCm,
+		"height_cm":   req.HeightCm,
+	}
+	for column, value := range values {
+		if row.Present[column] {
+			update[column] = value
+		}
+	}
+	if row.Present["price"] {
+		update["price_minor"] = req.PriceMinor
+	}
+	if req.Active != nil {
+		update["active"] = *req.Active
+	}
+	return update
+}
+
+// uploadedCSV returns the CSV from a multipart "file" field or the raw request body
+func uploadedCSV(w http.ResponseWriter, r *http.Request) (io.ReadCloser, error) {
+	r.Body = http.MaxBytesReader(w, r.Body, importMaxBytes)
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/form-data") {
+		file, _, err := r.FormFile("file")
+		if err != nil {
+			return nil, fmt.Errorf("missing CSV file in form field \"file\": %v", err)
+		}
+		return file, nil
+	}
+	return r.Body, nil
+}
+
+// parseProductCSV parses and validates product rows. Columns are matched by
+// header name, case-insensitively; only "name" is required. Prices are
+// decimals in major units of the store's currency.
+func parseProductCSV(body io.Reader, currency models.Currency) ([]importRow, []models.ImportRowError, error) {
+	reader := csv.NewReader(body)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read CSV header: %v", err)
+	}
+
+	columns := map[string]int{}
+	for i, name := range header {
+		name = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))
+		columns[name] = i
+	}
+	if _, ok := columns["name"]; !ok {
+		return nil, nil, fmt.Errorf("CSV header must include a \"name\" column")
+	}
+
+	rows := []importRow{}
+	rowErrors := []models.ImportRowError{}
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		// Quoted fields may span lines, so rows are numbered by the line they start on
+		var parseErr *csv.ParseError
+		if errors.As(err, &parseErr) {
+			rowErrors = append(rowErrors, models.ImportRowError{Row: parseErr.StartLine, Message: parseErr.Err.Error()})
+			continue
+		} else if err != nil {
+			return nil, nil, fmt.Errorf("failed to read CSV: %v", err)
+		}
+		line, _ := reader.FieldPos(0)
+
+		row, errs := parseProductRow(line, record, columns, currency)
+		if len(errs) > 0 {
+			rowErrors = append(rowErrors, errs...)
+			continue
+		}
+		rows = append(rows, row)
+	}
+
+	return rows, rowErrors, nil
+}
+
+// parseProductRow converts a CSV record into a validated product request
+func parseProductRow(line int, record []string, columns map[string]int, currency models.Currency) (importRow, []models.ImportRowError) {
+	row := importRow{Line: line, Present: map[string]bool{}}
+	var errs []models.ImportRowError
+
+	value := func(column string) string {
+		i, ok := columns[column]
+		if !ok || i >= len(record) {
+			return ""
+		}
+		v := strings.TrimSpace(record[i])
+		if v != "" {
+			row.Present[column] = true
+		}
+		return v
+	}
+	fail := func(field, message string) {
+		errs = append(errs, models.ImportRowError{Row: line, Field: field, Message: message})
+	}
+
+	row.Req.SKU = value("sku")
+	row.Req.Name = value("name")
+	row.Req.Description = value("description")
+	row.Req.Image = value("image")
+	row.Req.Category = value("category")
+
+	if v := value("price"); v != "" {
+		price, err := currency.ParseAmount(v)
+		if err != nil {
+			fail("price", err.Error())
+		}
+		row.Req.PriceMinor = price
+	}
+	if v := value("stock"); v != "" {
+		stock, err := strconv.Atoi(v)
+		if err != nil {
+			fail("stock", "must be a whole number")
+		}
+		row.Req.Stock = stock
+	}
+	if v := value("track_stock"); v != "" {
+		track, err := parseCSVBool(v)
+		if err != nil {
+			fail("track_stock", err.Error())
+		}
+		row.Req.TrackStock = track
+	}
+	if v := value("featured"); v != "" {
+		featured, err := parseCSVBool(v)
+		if err != nil {
+			fail("featured", err.Error())
+		}
+		row.Req.Featured = featured
+	}
+	if v := value("active"); v != "" {
+		active, err := parseCSVBool(v)
+		if err != nil {
+			fail("active", err.Error())
+		}
+		row.Req.Active = &active
+	}
+	if v := value("weight_g"); v != "" {
+		weight, err := strconv.Atoi(v)
+		if err != nil {
+			fail("weight_g", "must be a whole number of grams")
+		}
+		row.Req.WeightGrams = weight
+	}
+	for column, dimension := range map[string]*float64{"length_cm": &row.Req.LengthCm, "width_cm": &row.Req.WidthCm, "height_cm": &row.Req.HeightCm} {
+		if v := value(column); v != "" {
+			cm, err := strconv.ParseFloat(v, 64)
+			if err != nil {
+				fail(column, "must be a number of centimeters")
+			}
+			*dimension = cm
+		}
+	}
+
+	for _, fieldErr := range row.Req.Validate() {
+		fail(fieldErr.Field, fieldErr.Message)
+	}
+	return row, errs
+}
+
+// formatParcelInt leaves unset weights empty in exports
+func formatParcelInt(v int) string {
+	if v == 0 {
+		return ""
+	}
+	return strconv.Itoa(v)
+}
+
+// formatParcelFloat leaves unset dimensions empty in exports
+func formatParcelFloat(v float64) string {
+	if v == 0 {
+		return ""
+	}
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
+
+// parseCSVBool accepts true/false, yes/no and 1/0
+func parseCSVBool(v string) (bool, error) {
+	switch strings.ToLower(v) {
+	case "true", "yes", "y", "1":
+		return true, nil
+	case "false", "no", "n", "0":
+		return false, nil
+	}
+	return false, fmt.Errorf("must be true or false")
+}
+
+// countRows returns the number of distinct rows with errors
+func countRows(rowErrors []models.ImportRowError) int {
+	rows := map[int]bool{}
+	for _, rowErr := range rowErrors {
+		rows[rowErr.Row] = true
+	}
+	return len(rows)
+}
+
+// normalizeName is used to match products by name
+func normalizeName(name string) string {
+	return strings.ToLower(strings.TrimSpace(name))
+}
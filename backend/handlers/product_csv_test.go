@@ -0,0 +1,85 @@
+package handlers
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"wacatalogue/backend/models"
+)
+
+// TestParseProductCSV checks the rows and row errors of a product CSV
+func TestParseProductCSV(t *testing.T) {
+	body := "\ufeffSKU, Name ,Price,Stock,Track_Stock,Active,Description\n" +
+		"KP-1,Kopi Susu,15000,12,yes,true,Iced\n" +
+		"KP-2,\"Kopi\nHitam\",12000.5,,no,,\"Two\nlines\"\n" +
+		",Teh,abc,1.5,maybe,,\n" +
+		"KP-4,,1000,,,,\n" +
+		"KP-5,Roti,5000\n"
+
+	rows, rowErrors, err := parseProductCSV(strings.NewReader(body), models.CurrencyOf("IDR"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(rows) != 3 {
+		t.Fatalf("got %d rows, want 3", len(rows))
+	}
+	first := rows[0]
+	if first.Line != 2 || first.Req.SKU != "KP-1" || first.Req.Name != "Kopi Susu" || first.Req.PriceMinor != 1500000 || first.Req.Stock != 12 || !first.Req.TrackStock || first.Req.Active == nil || !*first.Req.Active {
+		t.Errorf("got first row %+v", first)
+	}
+	second := rows[1]
+	if second.Line != 3 || second.Req.Name != "Kopi\nHitam" || second.Req.PriceMinor != 1200050 || second.Req.Active != nil {
+		t.Errorf("got second row %+v", second)
+	}
+	if second.Present["stock"] || !second.Present["track_stock"] || !second.Present["price"] {
+		t.Errorf("got present columns %v of the second row", second.Present)
+	}
+	// The second row spans three lines, so the short row starts on line 8
+	if last := rows[2]; last.Line != 8 || last.Req.Name != "Roti" || last.Present["description"] {
+		t.Errorf("got last row %+v", last)
+	}
+
+	got := map[int][]string{}
+	for _, rowErr := range rowErrors {
+		got[rowErr.Row] = append(got[rowErr.Row], rowErr.Field)
+	}
+	want := map[int][]string{
+		6: {"price", "stock", "track_stock"},
+		7: {"name"},
+	}
+	for row, fields := range want {
+		if !reflect.DeepEqual(got[row], fields) {
+			t.Errorf("row %d: got errors on %v, want %v", row, got[row], fields)
+		}
+	}
+	if len(got) != len(want) {
+		t.Errorf("got errors on rows %v, want rows 6 and 7", got)
+	}
+	if n := countRows(rowErrors); n != 2 {
+		t.Errorf("got %d rows with errors, want 2", n)
+	}
+}
+
+// TestParseProductCSVMalformed checks malformed records and headers
+func TestParseProductCSVMalformed(t *testing.T) {
+	body := "name,price\nKopi,1000\n\"Teh,2000\nRoti,3000\n"
+	rows, rowErrors, err := parseProductCSV(strings.NewReader(body), models.CurrencyOf("USD"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(rows) != 1 || rows[0].Req.PriceMinor != 100000 {
+		t.Errorf("got rows %+v, want only Kopi", rows)
+	}
+	if len(rowErrors) != 1 || rowErrors[0].Row != 3 {
+		t.Errorf("got errors %+v, want one on row 3", rowErrors)
+	}
+
+	if _, _, err := parseProductCSV(strings.NewReader("sku,price\nKP-1,1000\n"), models.CurrencyOf("USD")); err == nil {
+		t.Error("header without a name column was accepted")
+	}
+	if _, _, err := parseProductCSV(strings.NewReader(""), models.CurrencyOf("USD")); err == nil {
+		t.Error("empty file was accepted")
+	}
+}
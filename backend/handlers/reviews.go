@@ -0,0 +1,442 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"wacatalogue/backend/models"
+)
+
+// CreateReview records a customer's review of a product. The customer proves
+// they bought it with the reference and phone number of a completed order.
+func CreateReview(db *models.Database) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// Get product ID from URL
+		vars := mux.Vars(r)
+		productID, err := primitive.ObjectIDFromHex(vars["id"])
+		if err != nil {
+			RespondWithError(w, r, ErrInvalidProductID)
+			return
+		}
+
+		var req models.CreateReviewRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			RespondWithError(w, r, ErrInvalidPayload.WithDetails(err.Error()))
+			return
+		}
+		if errs := req.Validate(); len(errs) > 0 {
+			RespondWithError(w, r, ErrValidationFailed.WithDetails(errs))
+			return
+		}
+
+		// Create database context
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		// Find product
+		var product models.Product
+		err = db.GetCollection(models.ProductCollection).FindOne(ctx, notDeleted(bson.M{"_id": productID, "active": true})).Decode(&product)
+		if err != nil {
+			if err == mongo.ErrNoDocuments {
+				RespondWithError(w, r, ErrProductNotFound)
+			} else {
+				RespondWithError(w, r, ErrInternal.WithMessage("Failed to find product"))
+			}
+			return
+		}
+
+		// Find the product's store, whose number places local phone numbers
+		var store models.Store
+		err = db.GetCollection(models.StoreCollection).FindOne(ctx, notDeleted(bson.M{"_id": product.StoreID})).Decode(&store)
+		if err != nil {
+			if err == mongo.ErrNoDocuments {
+				RespondWithError(w, r, ErrProductNotFound)
+			} else {
+				RespondWithError(w, r, ErrInternal.WithMessage("Failed to find store"))
+			}
+			return
+		}
+
+		// Find the completed order that contained the product
+		var order models.Order
+		filter := bson.M{
+			"store_id":         product.StoreID,
+			"reference":        strings.ToUpper(strings.TrimSpace(strings.TrimPrefix(req.OrderReference, "#"))),
+			"items.product_id": productID,
+			"status":           models.OrderCompleted,
+		}
+		err = db.GetCollection(models.OrderCollection).FindOne(ctx, filter).Decode(&order)
+		if err != nil && err != mongo.ErrNoDocuments {
+			RespondWithError(w, r, ErrInternal.WithMessage("Failed to find order"))
+			return
+		}
+		orderPhone := internationalPhone(order.CustomerPhone, store.WhatsappNumber)
+		if err == mongo.ErrNoDocuments || orderPhone == "" || orderPhone != internationalPhone(req.Phone, store.WhatsappNumber) {
+			RespondWithError(w, r, ErrNotVerifiedBuyer)
+			return
+		}
+
+		now := time.Now()
+		review := models.Review{
+			ID:         primitive.NewObjectID(),
+			StoreID:    product.StoreID,
+			ProductID:  productID,
+			OrderID:    order.ID,
+			CustomerID: order.CustomerID,
+			AuthorName: strings.TrimSpace(req.Name),
+			Rating:     req.Rating,
+			Title:      strings.TrimSpace(req.Title),
+			Body:       strings.TrimSpace(req.Body),
+			Status:     models.ReviewPublished,
+			CreatedAt:  now,
+			UpdatedAt:  now,
+		}
+		if review.AuthorName == "" {
+			review.AuthorName = order.CustomerName
+		}
+
+		// Insert review
+		if _, err = db.GetCollection(models.ReviewCollection).InsertOne(ctx, review); err != nil {
+			if mongo.IsDuplicateKeyError(err) {
+				RespondWithError(w, r, ErrReviewExists)
+			} else {
+				RespondWithError(w, r, ErrInternal.WithMessage("Failed to create review"))
+			}
+			return
+		}
+		refreshRatings(ctx, db, review.StoreID, review.ProductID)
+
+		// Send response
+		RespondWithJSON(w, http.StatusCreated, review)
+	}
+}
+
+// GetProductReviews lists the published reviews of a product, newest first
+func GetProductReviews(db *models.Database) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// Get product ID from URL
+		vars := mux.Vars(r)
+		productID, err := primitive.ObjectIDFromHex(vars["id"])
+		if err != nil {
+			RespondWithError(w, r, ErrInvalidProductID)
+			return
+		}
+
+		// Create database context
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		// Find product
+		var product models.Product
+		err = db.GetCollection(models.ProductCollection).FindOne(ctx, notDeleted(bson.M{"_id": productID})).Decode(&product)
+		if err != nil {
+			if err == mongo.ErrNoDocuments {
+				RespondWithError(w, r, ErrProductNotFound)
+			} else {
+				RespondWithError(w, r, ErrInternal.WithMessage("Failed to find product"))
+			}
+			return
+		}
+
+		page, limit := parsePagination(r, 20, 100)
+		response, err := findReviews(ctx, db, bson.M{"product_id": productID, "status": models.ReviewPublished}, page, limit)
+		if err != nil {
+			RespondWithError(w, r, err)
+			return
+		}
+		response.Rating = product.Rating
+
+		// Send response
+		RespondWithJSON(w, http.StatusOK, response)
+	}
+}
+
+// GetStoreReviews lists the reviews of one of the user's stores, newest
+// first, optionally only those with a status
+func GetStoreReviews(db *models.Database) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// Get user ID from context
+		userID, err := getUserIDFromContext(r)
+		if err != nil {
+			RespondWithError(w, r, ErrUnauthenticated)
+			return
+		}
+
+		// Get store ID from URL
+		vars := mux.Vars(r)
+		storeID, err := primitive.ObjectIDFromHex(vars["id"])
+		if err != nil {
+			RespondWithError(w, r, ErrInvalidStoreID)
+			return
+		}
+
+		filter := bson.M{"store_id": storeID}
+		switch status := r.URL.Query().Get("status"); status {
+		case "":
+		case models.ReviewPublished, models.ReviewHidden, models.ReviewRemoved:
+			filter["status"] = status
+		default:
+			RespondWithError(w, r, ErrValidationFailed.WithDetails([]models.FieldError{{Field: "status", Message: "must be one of published, hidden, removed"}}))
+			return
+		}
+		if value := r.URL.Query().Get("productId"); value != "" {
+			productID, err := primitive.ObjectIDFromHex(value)
+			if err != nil {
+				RespondWithError(w, r, ErrInvalidProductID)
+				return
+			}
+			filter["product_id"] = productID
+		}
+
+		// Create database context
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		// Check if store exists and the user's role allows this
+		store, err := loadStoreFor(ctx, db, storeID, userID, models.PermissionCatalog)
+		if err != nil {
+			RespondWithError(w, r, err)
+			return
+		}
+
+		page, limit := parsePagination(r, 20, 100)
+		response, err := findReviews(ctx, db, filter, page, limit)
+		if err != nil {
+			RespondWithError(w, r, err)
+			return
+		}
+		response.Rating = store.Rating
+
+		// Send response
+		RespondWithJSON(w, http.StatusOK, response)
+	}
+}
+
+// ModerateReview replies to or hides a review of one of the user's products
+func ModerateReview(db *models.Database) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// Get user ID from context
+		userID, err := getUserIDFromContext(r)
+		if err != nil {
+			RespondWithError(w, r, ErrUnauthenticated)
+			return
+		}
+
+		// Get review ID from URL
+		vars := mux.Vars(r)
+		reviewID, err := primitive.ObjectIDFromHex(vars["id"])
+		if err != nil {
+			RespondWithError(w, r, ErrInvalidReviewID)
+			return
+		}
+
+		var req models.ModerateReviewRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			RespondWithError(w, r, ErrInvalidPayload.WithDetails(err.Error()))
+			return
+		}
+		if errs := req.Validate(); len(errs) > 0 {
+			RespondWithError(w, r, ErrValidationFailed.WithDetails(errs))
+			return
+		}
+
+		// Create database context
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		review, err := loadReviewFor(ctx, db, reviewID, userID)
+		if err != nil {
+			RespondWithError(w, r, err)
+			return
+		}
+		if review.Status == models.ReviewRemoved {
+			RespondWithError(w, r, ErrReviewNotFound.WithMessage("Review was removed"))
+			return
+		}
+
+		// Build update document
+		now := time.Now()
+		set := bson.M{"updated_at": now}
+		update := bson.M{"$set": set}
+		if req.Reply != nil {
+			if reply := strings.TrimSpace(*req.Reply); reply != "" {
+				set["reply"] = models.ReviewReply{Body: reply, AuthorID: userID, CreatedAt: now}
+			} else {
+				update["$unset"] = bson.M{"reply": ""}
+			}
+		}
+		if req.Hidden != nil {
+			set["status"] = models.ReviewPublished
+			if *req.Hidden {
+				set["status"] = models.ReviewHidden
+			}
+		}
+
+		// Update review
+		reviewsColl := db.GetCollection(models.ReviewCollection)
+		if _, err = reviewsColl.UpdateOne(ctx, bson.M{"_id": reviewID}, update); err != nil {
+			RespondWithError(w, r, ErrInternal.WithMessage("Failed to update review"))
+			return
+		}
+
+		// Get updated review
+		var updatedReview models.Review
+		if err = reviewsColl.FindOne(ctx, bson.M{"_id": reviewID}).Decode(&updatedReview); err != nil {
+			RespondWithError(w, r, ErrInternal.WithMessage("Failed to retrieve updated review"))
+			return
+		}
+		if updatedReview.Status != review.Status {
+			refreshRatings(ctx, db, review.StoreID, review.ProductID)
+		}
+
+		// Record mutation
+		recordAudit(ctx, db, r, models.AuditReviewModerate, "review", reviewID, review.StoreID, &review, &updatedReview)
+
+		// Send response
+		RespondWithJSON(w, http.StatusOK, updatedReview)
+	}
+}
+
+// RemoveReview takes down an abusive review for good, keeping a record of
+// who removed it and why but not its text
+func RemoveReview(db *models.Database) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// Get user ID from context
+		userID, err := getUserIDFromContext(r)
+		if err != nil {
+			RespondWithError(w, r, ErrUnauthenticated)
+			return
+		}
+
+		// Get review ID from URL
+		vars := mux.Vars(r)
+		reviewID, err := primitive.ObjectIDFromHex(vars["id"])
+		if err != nil {
+			RespondWithError(w, r, ErrInvalidReviewID)
+			return
+		}
+
+		var req models.RemoveReviewRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			RespondWithError(w, r, ErrInvalidPayload.WithDetails(err.Error()))
+			return
+		}
+		if errs := req.Validate(); len(errs) > 0 {
+			RespondWithError(w, r, ErrValidationFailed.WithDetails(errs))
+			return
+		}
+
+		// Create database context
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		reviewsColl := db.GetCollection(models.ReviewCollection)
+		var review models.Review
+		if err := reviewsColl.FindOne(ctx, bson.M{"_id": reviewID}).Decode(&review); err != nil {
+			if err == mongo.ErrNoDocuments {
+				RespondWithError(w, r, ErrReviewNotFound)
+			} else {
+				RespondWithError(w, r, ErrInternal.WithMessage("Failed to find review"))
+			}
+			return
+		}
+
+		// Remove review
+		now := time.Now()
+		removal := models.ReviewRemoval{Reason: strings.TrimSpace(req.Reason), RemovedBy: userID, RemovedAt: now}
+		_, err = reviewsColl.UpdateOne(
+			ctx,
+			bson.M{"_id": reviewID},
+			bson.M{
+				"$set":   bson.M{"status": models.ReviewRemoved, "removal": removal, "updated_at": now},
+				"$unset": bson.M{"title": "", "body": "", "reply": ""},
+			},
+		)
+		if err != nil {
+			RespondWithError(w, r, ErrInternal.WithMessage("Failed to remove review"))
+			return
+		}
+		refreshRatings(ctx, db, review.StoreID, review.ProductID)
+
+		// Record mutation. The removed text is not copied into the audit log.
+		recordAudit(ctx, db, r, models.AuditReviewRemove, "review", reviewID, review.StoreID, nil, &removal)
+
+		// Send response
+		RespondWithJSON(w, http.StatusOK, map[string]string{"message": "Review removed"})
+	}
+}
+
+// findReviews returns a page of the reviews matching a filter, newest first
+func findReviews(ctx context.Context, db *models.Database, filter bson.M, page, limit int64) (models.ReviewListResponse, error) {
+	reviewsColl := db.GetCollection(models.ReviewCollection)
+	response := models.ReviewListResponse{Reviews: []models.Review{}, Page: page, Limit: limit}
+
+	total, err := reviewsColl.CountDocuments(ctx, filter)
+	if err != nil {
+		return response, ErrInternal.WithMessage("Failed to count reviews")
+	}
+	response.Total = total
+
+	findOptions := options.Find()
+	findOptions.SetSort(bson.D{{Key: "created_at", Value: -1}})
+	findOptions.SetSkip((page - 1) * limit)
+	findOptions.SetLimit(limit)
+	cursor, err := reviewsColl.Find(ctx, filter, findOptions)
+	if err != nil {
+		return response, ErrInternal.WithMessage("Failed to find reviews")
+	}
+	if err = cursor.All(ctx, &response.Reviews); err != nil {
+		return response, ErrInternal.WithMessage("Failed to decode reviews")
+	}
+	return response, nil
+}
+
+// refreshRatings recomputes the rating summaries of a product and its store
+// from their published reviews. Failures are logged; the next review
+// change recomputes them again.
+func refreshRatings(ctx context.Context, db *models.Database, storeID, productID primitive.ObjectID) {
+	targets := []struct {
+		collection string
+		id         primitive.ObjectID
+		match      bson.M
+	}{
+		{models.ProductCollection, productID, bson.M{"product_id": productID, "status": models.ReviewPublished}},
+		{models.StoreCollection, storeID, bson.M{"store_id": storeID, "status": models.ReviewPublished}},
+	}
+	for _, target := range targets {
+		pipeline := mongo.Pipeline{
+			{{Key: "$match", Value: target.match}},
+			{{Key: "$group", Value: bson.M{"_id": nil, "average": bson.M{"$avg": "$rating"}, "count": bson.M{"$sum": 1}}}},
+		}
+		cursor, err := db.GetCollection(models.ReviewCollection).Aggregate(ctx, pipeline)
+		if err != nil {
+			log.Printf("Failed to aggregate ratings of %s %s: %v", target.collection, target.id.Hex(), err)
+			continue
+		}
+		var results []models.RatingSummary
+		if err := cursor.All(ctx, &results); err != nil {
+			log.Printf("Failed to decode ratings of %s %s: %v", target.collection, target.id.Hex(), err)
+			continue
+		}
+
+		update := bson.M{"$unset": bson.M{"rating": ""}}
+		if len(results) > 0 {
+			summary := results[0]
+			summary.Average = models.RoundRating(summary.Average)
+			update = bson.M{"$set": bson.M{"rating": summary}}
+		}
+		if _, err := db.GetCollection(target.collection).UpdateOne(ctx, bson.M{"_id": target.id}, update); err != nil {
+			log.Printf("Failed to update ratings of %s %s: %v", target.collection, target.id.Hex(), err)
+		}
+	}
+}
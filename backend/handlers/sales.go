@@ -0,0 +1,101 @@
+package handlers
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+
+	"wacatalogue/backend/models"
+)
+
+// StartSaleScheduler periodically features products whose sale has started
+// and unfeatures them again once it ends. Products the owner had already
+// featured keep their badge after the sale.
+func StartSaleScheduler(ctx context.Context, db *models.Database, interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			updateSales(ctx, db)
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// updateSales runs a single scheduler pass
+func updateSales(ctx context.Context, db *models.Database) {
+	ctx, cancel := context.WithTimeout(ctx, time.Minute)
+	defer cancel()
+
+	now := time.Now()
+	productsColl := db.GetCollection(models.ProductCollection)
+
+	// Products with a sale that may have started or ended since the last pass.
+	// Sales that are yet to start or already over are left out, so products
+	// whose sale has long ended are not loaded again on every pass.
+	cursor, err := productsColl.Find(ctx, notDeleted(bson.M{
+		"$or": []bson.M{
+			{
+				"sale_price_minor": bson.M{"$gt": 0},
+				"sale_started":     bson.M{"$ne": true},
+				"$and": []bson.M{
+					{"$or": []bson.M{{"sale_starts_at": nil}, {"sale_starts_at": bson.M{"$lte": now}}}},
+					{"$or": []bson.M{{"sale_ends_at": nil}, {"sale_ends_at": bson.M{"$gt": now}}}},
+				},
+			},
+			{"sale_started": true},
+		},
+	}))
+	if err != nil {
+		log.Printf("Sale scheduler: failed to find products: %v", err)
+		return
+	}
+	var products []models.Product
+	if err = cursor.All(ctx, &products); err != nil {
+		log.Printf("Sale scheduler: failed to decode products: %v", err)
+		return
+	}
+
+	var started, ended int
+	for _, product := range products {
+		active := product.SaleActive(now)
+		var changes bson.M
+		switch {
+		case active && !product.SaleStarted:
+			set := bson.M{"sale_started": true, "updated_at": now}
+			if !product.Featured {
+				set["featured"] = true
+				set["sale_featured"] = true
+			}
+			changes = bson.M{"$set": set}
+			started++
+		case !active && product.SaleStarted:
+			changes = bson.M{
+				"$set":   bson.M{"updated_at": now},
+				"$unset": bson.M{"sale_started": "", "sale_featured": ""},
+			}
+			if product.SaleFeatured {
+				changes["$set"] = bson.M{"featured": false, "updated_at": now}
+			}
+			ended++
+		default:
+			continue
+		}
+
+		if _, err := productsColl.UpdateOne(ctx, bson.M{"_id": product.ID}, changes); err != nil {
+			log.Printf("Sale scheduler: failed to update product %s: %v", product.ID.Hex(), err)
+		}
+	}
+
+	if started > 0 || ended > 0 {
+		log.Printf("Sale scheduler: %d sales started, %d ended", started, ended)
+	}
+}
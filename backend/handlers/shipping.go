@@ -0,0 +1,494 @@
+package handlers
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+
+	"wacatalogue/backend/models"
+)
+
+// Maximum rows of a courier rate table
+const courierRatesMaxRows = 20000
+
+// GetCourierRates lists the courier rate tables of one of the user's stores
+func GetCourierRates(db *models.Database) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// Get user ID from context
+		userID, err := getUserIDFromContext(r)
+		if err != nil {
+			RespondWithError(w, r, ErrUnauthenticated)
+			return
+		}
+
+		// Get store ID from URL
+		vars := mux.Vars(r)
+		storeID, err := primitive.ObjectIDFromHex(vars["id"])
+		if err != nil {
+			RespondWithError(w, r, ErrInvalidStoreID)
+			return
+		}
+
+		// Create database context
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		// Check if store exists and the user's role allows this
+		if _, err := loadStoreFor(ctx, db, storeID, userID, models.PermissionSettings); err != nil {
+			RespondWithError(w, r, err)
+			return
+		}
+
+		tables, err := courierRateTables(ctx, db, bson.M{"store_id": storeID})
+		if err != nil {
+			RespondWithError(w, r, err)
+			return
+		}
+
+		// Send response
+		RespondWithJSON(w, http.StatusOK, tables)
+	}
+}
+
+// UploadCourierRates replaces the rate table of one courier service with an
+// uploaded CSV with origin, destination, price_per_kg and eta columns. Prices
+// are decimals in major units of the store's currency; eta is a number of
+// days or a range such as "2-3". Files with invalid rows are rejected whole.
+func UploadCourierRates(db *models.Database) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// Get user ID from context
+		userID, err := getUserIDFromContext(r)
+		if err != nil {
+			RespondWithError(w, r, ErrUnauthenticated)
+			return
+		}
+
+		// Get store ID and courier service from URL
+		vars := mux.Vars(r)
+		storeID, err := primitive.ObjectIDFromHex(vars["id"])
+		if err != nil {
+			RespondWithError(w, r, ErrInvalidStoreID)
+			return
+		}
+		courier := strings.TrimSpace(vars["courier"])
+		service := strings.TrimSpace(vars["service"])
+
+		// Check if store exists and the user's role allows this; prices are in its currency
+		lookupCtx, lookupCancel := context.WithTimeout(context.Background(), 10*time.Second)
+		store, err := loadStoreFor(lookupCtx, db, storeID, userID, models.PermissionSettings)
+		lookupCancel()
+		if err != nil {
+			RespondWithError(w, r, err)
+			return
+		}
+
+		// Read and validate the file before touching the table
+		body, err := uploadedCSV(w, r)
+		if err != nil {
+			RespondWithError(w, r, ErrInvalidPayload.WithDetails(err.Error()))
+			return
+		}
+		defer body.Close()
+
+		now := time.Now()
+		rates, rowErrors, err := parseCourierRateCSV(body, models.CurrencyOf(store.Currency))
+		if err != nil {
+			RespondWithError(w, r, ErrInvalidPayload.WithDetails(err.Error()))
+			return
+		}
+		if len(rowErrors) > 0 {
+			RespondWithError(w, r, ErrValidationFailed.WithMessage("CSV contains invalid rows").WithDetails(rowErrors))
+			return
+		}
+		if len(rates) == 0 {
+			RespondWithError(w, r, ErrValidationFailed.WithMessage("CSV contains no rates"))
+			return
+		}
+
+		// Create database context
+		ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+		defer cancel()
+
+		tableFilter := bson.M{"store_id": storeID, "courier": courier, "service": service}
+		before, err := courierRateTables(ctx, db, tableFilter)
+		if err != nil {
+			RespondWithError(w, r, err)
+			return
+		}
+
+		// Insert the new rows before dropping the old ones, so quotes never
+		// see an empty table
+		documents := make([]interface{}, len(rates))
+		ids := make([]primitive.ObjectID, len(rates))
+		for i := range rates {
+			rates[i].ID = primitive.NewObjectID()
+			rates[i].StoreID = storeID
+			rates[i].Courier = courier
+			rates[i].Service = service
+			rates[i].CreatedAt = now
+			documents[i] = rates[i]
+			ids[i] = rates[i].ID
+		}
+		ratesColl := db.GetCollection(models.CourierRateCollection)
+		if _, err := ratesColl.InsertMany(ctx, documents); err != nil {
+			RespondWithError(w, r, ErrInternal.WithMessage("Failed to save courier rates"))
+			return
+		}
+		if _, err := ratesColl.DeleteMany(ctx, bson.M{"store_id": storeID, "courier": courier, "service": service, "_id": bson.M{"$nin": ids}}); err != nil {
+			RespondWithError(w, r, ErrInternal.WithMessage("Failed to replace courier rates"))
+			return
+		}
+
+		table := models.CourierRateTable{Courier: courier, Service: service, Rates: len(rates), UpdatedAt: now}
+
+		// Record mutation
+		var previous *models.CourierRateTable
+		if len(before) > 0 {
+			previous = &before[0]
+		}
+		recordAudit(ctx, db, r, models.AuditRatesUpload, "courier_rates", storeID, storeID, previous, &table)
+
+		// Send response
+		RespondWithJSON(w, http.StatusOK, table)
+	}
+}
+
+// DeleteCourierRates removes the rate table of one courier service
+func DeleteCourierRates(db *models.Database) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// Get user ID from context
+		userID, err := getUserIDFromContext(r)
+		if err != nil {
+			RespondWithError(w, r, ErrUnauthenticated)
+			return
+		}
+
+		// Get store ID and courier service from URL
+		vars := mux.Vars(r)
+		storeID, err := primitive.ObjectIDFromHex(vars["id"])
+		if err != nil {
+			RespondWithError(w, r, ErrInvalidStoreID)
+			return
+		}
+		courier := strings.TrimSpace(vars["courier"])
+		service := strings.TrimSpace(vars["service"])
+
+		// Create database context
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		// Check if store exists and the user's role allows this
+		if _, err := loadStoreFor(ctx, db, storeID, userID, models.PermissionSettings); err != nil {
+			RespondWithError(w, r, err)
+			return
+		}
+
+		tableFilter := bson.M{"store_id": storeID, "courier": courier, "service": service}
+		before, err := courierRateTables(ctx, db, tableFilter)
+		if err != nil {
+			RespondWithError(w, r, err)
+			return
+		}
+		if len(before) == 0 {
+			RespondWithError(w, r, ErrRatesNotFound)
+			return
+		}
+
+		// Delete table
+		if _, err := db.GetCollection(models.CourierRateCollection).DeleteMany(ctx, tableFilter); err != nil {
+			RespondWithError(w, r, ErrInternal.WithMessage("Failed to delete courier rates"))
+			return
+		}
+
+		// Record mutation
+		recordAudit(ctx, db, r, models.AuditRatesDelete, "courier_rates", storeID, storeID, &before[0], nil)
+
+		// Send response
+		RespondWithJSON(w, http.StatusOK, map[string]string{"message": "Courier rates deleted successfully"})
+	}
+}
+
+// QuoteShipping lists what each courier service of a store charges to ship
+// a cart to a destination region, from the uploaded rate tables
+func QuoteShipping(db *models.Database) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// Get store ID from URL
+		vars := mux.Vars(r)
+		storeID, err := primitive.ObjectIDFromHex(vars["storeId"])
+		if err != nil {
+			RespondWithError(w, r, ErrInvalidStoreID)
+			return
+		}
+
+		var req models.ShippingQuoteRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			RespondWithError(w, r, ErrInvalidPayload.WithDetails(err.Error()))
+			return
+		}
+		if errs := req.Validate(); len(errs) > 0 {
+			RespondWithError(w, r, ErrValidationFailed.WithDetails(errs))
+			return
+		}
+		productIDs, quantities, err := parseCartItems(req.Items)
+		if err != nil {
+			RespondWithError(w, r, err)
+			return
+		}
+
+		// Create database context
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		// Find active store
+		var store models.Store
+		err = db.GetCollection(models.StoreCollection).FindOne(ctx, notDeleted(bson.M{"_id": storeID, "active": true})).Decode(&store)
+		if err != nil {
+			if err == mongo.ErrNoDocuments {
+				RespondWithError(w, r, ErrStoreNotFound)
+			} else {
+				RespondWithError(w, r, ErrInternal.WithMessage("Failed to find store"))
+			}
+			return
+		}
+		quote, err := quoteShipping(ctx, db, store, productIDs, quantities, req.Destination)
+		if err != nil {
+			RespondWithError(w, r, err)
+			return
+		}
+
+		// Send response
+		RespondWithJSON(w, http.StatusOK, quote)
+	}
+}
+
+// quoteShipping weighs a cart and prices it with every courier service of
+// the store that ships from the store's origin, or from anywhere when it has
+// none, to the destination region. Options are sorted cheapest first.
+func quoteShipping(ctx context.Context, db *models.Database, store models.Store, productIDs []primitive.ObjectID, quantities map[primitive.ObjectID]int, destination string) (models.ShippingQuote, error) {
+	var quote models.ShippingQuote
+	currency := models.CurrencyOf(store.Currency)
+
+	// Weigh the cart
+	cursor, err := db.GetCollection(models.ProductCollection).Find(ctx, notDeleted(bson.M{"_id": bson.M{"$in": productIDs}, "store_id": store.ID, "active": true}))
+	if err != nil {
+		return quote, ErrInternal.WithMessage("Failed to find products")
+	}
+	var products []models.Product
+	if err = cursor.All(ctx, &products); err != nil {
+		return quote, ErrInternal.WithMessage("Failed to decode products")
+	}
+	byID := make(map[primitive.ObjectID]models.Product, len(products))
+	for _, product := range products {
+		byID[product.ID] = product
+	}
+
+	grams := 0
+	var unavailable, unweighed []models.FieldError
+	for _, productID := range productIDs {
+		product, ok := byID[productID]
+		switch {
+		case !ok:
+			unavailable = append(unavailable, models.FieldError{Field: productID.Hex(), Message: "is not available"})
+		case product.ShippingGrams() == 0:
+			unweighed = append(unweighed, models.FieldError{Field: productID.Hex(), Message: "has no weight or dimensions"})
+		default:
+			grams += product.ShippingGrams() * quantities[productID]
+		}
+	}
+	if len(unavailable) > 0 {
+		return quote, ErrProductsUnavailable.WithDetails(unavailable)
+	}
+	if len(unweighed) > 0 {
+		return quote, ErrWeightMissing.WithDetails(unweighed)
+	}
+
+	quote = models.ShippingQuote{
+		Origin:      store.ShippingOrigin,
+		Destination: strings.TrimSpace(destination),
+		WeightGrams: grams,
+		BilledKg:    models.ChargeableKilograms(grams),
+		Currency:    currency.Code,
+		Options:     []models.ShippingOption{},
+	}
+
+	// Rates from the store's origin, or from anywhere when it has none
+	filter := bson.M{"store_id": store.ID, "destination_key": models.RegionKey(destination)}
+	if store.ShippingOrigin != "" {
+		filter["origin_key"] = models.RegionKey(store.ShippingOrigin)
+	}
+	cursor, err = db.GetCollection(models.CourierRateCollection).Find(ctx, filter)
+	if err != nil {
+		return quote, ErrInternal.WithMessage("Failed to find courier rates")
+	}
+	var rates []models.CourierRate
+	if err = cursor.All(ctx, &rates); err != nil {
+		return quote, ErrInternal.WithMessage("Failed to decode courier rates")
+	}
+
+	// One option per courier service, the cheapest if a table repeats a route
+	cheapest := map[string]models.ShippingOption{}
+	for _, rate := range rates {
+		option := models.ShippingOption{
+			Courier:    rate.Courier,
+			Service:    rate.Service,
+			PriceMinor: rate.PricePerKgMinor * int64(quote.BilledKg),
+			MinDays:    rate.MinDays,
+			MaxDays:    rate.MaxDays,
+		}
+		key := rate.Courier + "\x00" + rate.Service
+		if existing, ok := cheapest[key]; !ok || option.PriceMinor < existing.PriceMinor {
+			cheapest[key] = option
+		}
+	}
+	for _, option := range cheapest {
+		option.FormattedPrice = currency.Format(option.PriceMinor)
+		quote.Options = append(quote.Options, option)
+	}
+	sort.Slice(quote.Options, func(i, j int) bool {
+		a, b := quote.Options[i], quote.Options[j]
+		if a.PriceMinor != b.PriceMinor {
+			return a.PriceMinor < b.PriceMinor
+		}
+		return a.MaxDays < b.MaxDays
+	})
+	return quote, nil
+}
+
+// courierRateTables summarizes the rate tables matching a filter
+func courierRateTables(ctx context.Context, db *models.Database, filter bson.M) ([]models.CourierRateTable, error) {
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: filter}},
+		{{Key: "$group", Value: bson.M{
+			"_id":        bson.M{"courier": "$courier", "service": "$service"},
+			"rates":      bson.M{"$sum": 1},
+			"updated_at": bson.M{"$max": "$created_at"},
+		}}},
+		{{Key: "$project", Value: bson.M{
+			"_id":        0,
+			"courier":    "$_id.courier",
+			"service":    "$_id.service",
+			"rates":      1,
+			"updated_at": 1,
+		}}},
+		{{Key: "$sort", Value: bson.D{{Key: "courier", Value: 1}, {Key: "service", Value: 1}}}},
+	}
+	cursor, err := db.GetCollection(models.CourierRateCollection).Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, ErrInternal.WithMessage("Failed to list courier rates")
+	}
+	tables := []models.CourierRateTable{}
+	if err = cursor.All(ctx, &tables); err != nil {
+		return nil, ErrInternal.WithMessage("Failed to decode courier rates")
+	}
+	return tables, nil
+}
+
+// parseCourierRateCSV parses and validates the rows of a rate table. Columns
+// are matched by header name, case-insensitively.
+func parseCourierRateCSV(body io.Reader, currency models.Currency) ([]models.CourierRate, []models.ImportRowError, error) {
+	reader := csv.NewReader(body)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read CSV header: %v", err)
+	}
+	columns := map[string]int{}
+	for i, name := range header {
+		name = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))
+		columns[name] = i
+	}
+	for _, required := range []string{"origin", "destination", "price_per_kg", "eta"} {
+		if _, ok := columns[required]; !ok {
+			return nil, nil, fmt.Errorf("CSV header must include a %q column", required)
+		}
+	}
+
+	rates := []models.CourierRate{}
+	rowErrors := []models.ImportRowError{}
+	line := 1
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		line++
+		if err != nil {
+			rowErrors = append(rowErrors, models.ImportRowError{Row: line, Message: err.Error()})
+			continue
+		}
+		if len(rates)+countRows(rowErrors) >= courierRatesMaxRows {
+			return nil, nil, fmt.Errorf("CSV must not have more than %d rows", courierRatesMaxRows)
+		}
+
+		value := func(column string) string {
+			if i := columns[column]; i < len(record) {
+				return strings.TrimSpace(record[i])
+			}
+			return ""
+		}
+		fail := func(field, message string) {
+			rowErrors = append(rowErrors, models.ImportRowError{Row: line, Field: field, Message: message})
+		}
+
+		rate := models.CourierRate{Origin: value("origin"), Destination: value("destination")}
+		rate.OriginKey = models.RegionKey(rate.Origin)
+		rate.DestinationKey = models.RegionKey(rate.Destination)
+		valid := true
+		if rate.OriginKey == "" {
+			fail("origin", "is required")
+			valid = false
+		}
+		if rate.DestinationKey == "" {
+			fail("destination", "is required")
+			valid = false
+		}
+		price, err := currency.ParseAmount(value("price_per_kg"))
+		if err != nil || price <= 0 {
+			fail("price_per_kg", "must be a positive number")
+			valid = false
+		}
+		rate.PricePerKgMinor = price
+		rate.MinDays, rate.MaxDays, err = parseETA(value("eta"))
+		if err != nil {
+			fail("eta", err.Error())
+			valid = false
+		}
+		if valid {
+			rates = append(rates, rate)
+		}
+	}
+	return rates, rowErrors, nil
+}
+
+// parseETA parses a delivery estimate in days, either "3" or a range such as
+// "2-3", optionally followed by a unit such as "days"
+func parseETA(v string) (minDays, maxDays int, err error) {
+	v = strings.TrimSpace(strings.TrimRight(strings.ToLower(v), "abcdefghijklmnopqrstuvwxyz "))
+	low, high, isRange := strings.Cut(v, "-")
+	minDays, err = strconv.Atoi(strings.TrimSpace(low))
+	if err != nil || minDays < 0 {
+		return 0, 0, fmt.Errorf("must be a number of days such as 3 or 2-3")
+	}
+	maxDays = minDays
+	if isRange {
+		maxDays, err = strconv.Atoi(strings.TrimSpace(high))
+		if err != nil || maxDays < minDays {
+			return 0, 0, fmt.Errorf("must be a number of days such as 3 or 2-3")
+		}
+	}
+	return minDays, maxDays, nil
+}
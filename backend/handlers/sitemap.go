@@ -0,0 +1,366 @@
+package handlers
+
+import (
+	"context"
+	"encoding/xml"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"wacatalogue/backend/models"
+)
+
+// Sitemap protocol limits, which apply to sitemaps and sitemap indexes alike
+const (
+	maxSitemapURLs  = 50000
+	maxSitemapBytes = 50 * 1024 * 1024
+)
+
+// Bytes an entry adds to a sitemap besides its path: the markup, the lastmod
+// timestamp and room for a public base URL of up to 256 characters
+const sitemapEntryOverhead = 128 + 256
+
+const sitemapXMLNS = "http://www.sitemaps.org/schemas/sitemap/0.9"
+
+// Crawlers re-read sitemaps regularly, so let caches hold them for a while
+const sitemapCacheControl = "public, max-age=3600"
+
+type sitemapIndex struct {
+	XMLName  xml.Name     `xml:"sitemapindex"`
+	XMLNS    string       `xml:"xmlns,attr"`
+	Sitemaps []sitemapRef `xml:"sitemap"`
+}
+
+type sitemapRef struct {
+	Loc     string `xml:"loc"`
+	LastMod string `xml:"lastmod"`
+}
+
+type sitemapURLSet struct {
+	XMLName xml.Name     `xml:"urlset"`
+	XMLNS   string       `xml:"xmlns,attr"`
+	URLs    []sitemapURL `xml:"url"`
+}
+
+type sitemapURL struct {
+	Loc     string `xml:"loc"`
+	LastMod string `xml:"lastmod"`
+}
+
+// GetRobotsTxt serves robots.txt, pointing crawlers at every sitemap index
+func GetRobotsTxt(db *models.Database) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// Create database context
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		parts, err := db.GetCollection(models.SitemapCollection).CountDocuments(ctx, bson.M{})
+		if err != nil {
+			log.Printf("Failed to count sitemaps for robots.txt: %v", err)
+			parts = 0
+		}
+
+		base := publicBaseURL(r)
+		var body strings.Builder
+		body.WriteString("User-agent: *\n")
+		body.WriteString("Allow: /\n")
+		body.WriteString("Allow: /api/stores/*/feeds/\n")
+		body.WriteString("Disallow: /api/\n")
+		body.WriteString("\n")
+		body.WriteString("Sitemap: " + base + "/sitemap.xml\n")
+		for page := int64(2); (page-1)*maxSitemapURLs < parts; page++ {
+			body.WriteString("Sitemap: " + sitemapIndexURL(base, page) + "\n")
+		}
+
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.Header().Set("Cache-Control", sitemapCacheControl)
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(body.String()))
+	}
+}
+
+// GetSitemapIndex serves a page of the sitemap index. /sitemap.xml is the
+// first page; further pages only exist once there are more store sitemaps
+// than a single index may list.
+func GetSitemapIndex(db *models.Database) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		page := int64(1)
+		if value, ok := mux.Vars(r)["page"]; ok {
+			parsed, err := strconv.ParseInt(value, 10, 64)
+			if err != nil || parsed < 1 {
+				RespondWithError(w, r, ErrSitemapNotFound)
+				return
+			}
+			page = parsed
+		}
+
+		// Create database context
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+
+		findOptions := options.Find()
+		findOptions.SetSort(bson.D{{Key: "store_id", Value: 1}, {Key: "part", Value: 1}})
+		findOptions.SetSkip((page - 1) * maxSitemapURLs)
+		findOptions.SetLimit(maxSitemapURLs)
+		findOptions.SetProjection(bson.M{"store_id": 1, "part": 1, "lastmod": 1})
+
+		cursor, err := db.GetCollection(models.SitemapCollection).Find(ctx, bson.M{}, findOptions)
+		if err != nil {
+			RespondWithError(w, r, ErrInternal.WithMessage("Failed to find sitemaps"))
+			return
+		}
+		var parts []models.SitemapPart
+		if err = cursor.All(ctx, &parts); err != nil {
+			RespondWithError(w, r, ErrInternal.WithMessage("Failed to decode sitemaps"))
+			return
+		}
+		if len(parts) == 0 && page > 1 {
+			RespondWithError(w, r, ErrSitemapNotFound)
+			return
+		}
+
+		base := publicBaseURL(r)
+		index := sitemapIndex{XMLNS: sitemapXMLNS, Sitemaps: []sitemapRef{}}
+		var latest time.Time
+		for _, part := range parts {
+			index.Sitemaps = append(index.Sitemaps, sitemapRef{
+				Loc:     storeSitemapURL(base, part.StoreID, part.Part),
+				LastMod: part.LastMod.UTC().Format(time.RFC3339),
+			})
+			if part.LastMod.After(latest) {
+				latest = part.LastMod
+			}
+		}
+
+		writeSitemap(w, index, latest)
+	}
+}
+
+// GetStoreSitemap serves one part of a store's sitemap
+func GetStoreSitemap(db *models.Database) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// Get store ID and part from URL
+		vars := mux.Vars(r)
+		storeID, err := primitive.ObjectIDFromHex(vars["storeId"])
+		if err != nil {
+			RespondWithError(w, r, ErrInvalidStoreID)
+			return
+		}
+		partNumber, err := strconv.Atoi(vars["part"])
+		if err != nil {
+			RespondWithError(w, r, ErrSitemapNotFound)
+			return
+		}
+
+		// Create database context
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+
+		var part models.SitemapPart
+		err = db.GetCollection(models.SitemapCollection).FindOne(ctx, bson.M{"store_id": storeID, "part": partNumber}).Decode(&part)
+		if err != nil {
+			if err == mongo.ErrNoDocuments {
+				RespondWithError(w, r, ErrSitemapNotFound)
+			} else {
+				RespondWithError(w, r, ErrInternal.WithMessage("Failed to find sitemap"))
+			}
+			return
+		}
+
+		base := publicBaseURL(r)
+		urlSet := sitemapURLSet{XMLNS: sitemapXMLNS, URLs: make([]sitemapURL, 0, len(part.URLs))}
+		for _, entry := range part.URLs {
+			urlSet.URLs = append(urlSet.URLs, sitemapURL{
+				Loc:     base + entry.Path,
+				LastMod: entry.LastMod.UTC().Format(time.RFC3339),
+			})
+		}
+
+		writeSitemap(w, urlSet, part.LastMod)
+	}
+}
+
+// writeSitemap sends a sitemap document with caching headers
+func writeSitemap(w http.ResponseWriter, payload interface{}, lastMod time.Time) {
+	if !lastMod.IsZero() {
+		w.Header().Set("Last-Modified", lastMod.UTC().Format(http.TimeFormat))
+	}
+	w.Header().Set("Cache-Control", sitemapCacheControl)
+	writeXML(w, payload)
+}
+
+// sitemapIndexURL returns the URL of a sitemap index page
+func sitemapIndexURL(base string, page int64) string {
+	if page == 1 {
+		return base + "/sitemap.xml"
+	}
+	return base + "/sitemaps/index-" + strconv.FormatInt(page, 10) + ".xml"
+}
+
+// storeSitemapURL returns the URL of one part of a store's sitemap
+func storeSitemapURL(base string, storeID primitive.ObjectID, part int) string {
+	return base + "/sitemaps/" + storeID.Hex() + "/" + strconv.Itoa(part) + ".xml"
+}
+
+// StartSitemapRefresh keeps the stored sitemaps up to date. The first pass
+// rebuilds every store; later passes only regenerate stores that changed, or
+// whose products changed, since the previous pass began.
+func StartSitemapRefresh(ctx context.Context, db *models.Database, interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		var since time.Time
+		for {
+			started := time.Now()
+			if err := refreshSitemaps(ctx, db, since); err != nil {
+				log.Printf("Sitemap refresh: %v", err)
+			} else {
+				since = started
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// refreshSitemaps regenerates the sitemaps of stores changed since the given
+// time. A zero time rebuilds everything and drops sitemaps of purged stores.
+func refreshSitemaps(ctx context.Context, db *models.Database, since time.Time) error {
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Minute)
+	defer cancel()
+
+	changed := bson.M{}
+	if !since.IsZero() {
+		changed = bson.M{"updated_at": bson.M{"$gte": since}}
+	}
+
+	// Deactivating, deleting or restoring a store or product bumps updated_at,
+	// so these two queries catch every change that affects a sitemap
+	storeIDs, err := db.GetCollection(models.StoreCollection).Distinct(ctx, "_id", changed)
+	if err != nil {
+		return err
+	}
+	productStoreIDs, err := db.GetCollection(models.ProductCollection).Distinct(ctx, "store_id", changed)
+	if err != nil {
+		return err
+	}
+
+	stale := map[primitive.ObjectID]bool{}
+	for _, id := range append(storeIDs, productStoreIDs...) {
+		if storeID, ok := id.(primitive.ObjectID); ok {
+			stale[storeID] = true
+		}
+	}
+
+	for storeID := range stale {
+		if err := regenerateStoreSitemap(ctx, db, storeID); err != nil {
+			log.Printf("Sitemap refresh: failed to regenerate store %s: %v", storeID.Hex(), err)
+		}
+	}
+
+	if since.IsZero() {
+		live := make([]primitive.ObjectID, 0, len(storeIDs))
+		for _, id := range storeIDs {
+			if storeID, ok := id.(primitive.ObjectID); ok {
+				live = append(live, storeID)
+			}
+		}
+		if _, err := db.GetCollection(models.SitemapCollection).DeleteMany(ctx, bson.M{"store_id": bson.M{"$nin": live}}); err != nil {
+			return err
+		}
+	}
+
+	if len(stale) > 0 {
+		log.Printf("Sitemap refresh: regenerated %d stores", len(stale))
+	}
+	return nil
+}
+
+// regenerateStoreSitemap rebuilds the sitemap parts of a single store, removing
+// them when the store is no longer public
+func regenerateStoreSitemap(ctx context.Context, db *models.Database, storeID primitive.ObjectID) error {
+	sitemapsColl := db.GetCollection(models.SitemapCollection)
+
+	store, products, err := loadPublicCatalog(ctx, db, storeID)
+	if err == ErrStoreNotFound {
+		_, err = sitemapsColl.DeleteMany(ctx, bson.M{"store_id": storeID})
+		return err
+	} else if err != nil {
+		return err
+	}
+
+	// The store page lists its products, so it changes whenever they do
+	modified, err := lastModified(ctx, db, store)
+	if err != nil {
+		return err
+	}
+	urls := []models.SitemapURL{{Path: storeURL("", store.ID), LastMod: modified}}
+	for _, product := range products {
+		urls = append(urls, models.SitemapURL{Path: productURL("", store.ID, product.ID), LastMod: product.UpdatedAt})
+	}
+
+	now := time.Now()
+	parts := splitSitemap(urls)
+	for i, partURLs := range parts {
+		part := models.SitemapPart{
+			StoreID:     storeID,
+			Part:        i + 1,
+			URLs:        partURLs,
+			GeneratedAt: now,
+		}
+		for _, entry := range partURLs {
+			if entry.LastMod.After(part.LastMod) {
+				part.LastMod = entry.LastMod
+			}
+		}
+
+		_, err := sitemapsColl.UpdateOne(
+			ctx,
+			bson.M{"store_id": storeID, "part": part.Part},
+			bson.M{"$set": part},
+			options.Update().SetUpsert(true),
+		)
+		if err != nil {
+			return err
+		}
+	}
+
+	// Drop parts left over from when the store had more products
+	_, err = sitemapsColl.DeleteMany(ctx, bson.M{"store_id": storeID, "part": bson.M{"$gt": len(parts)}})
+	return err
+}
+
+// splitSitemap splits URLs into parts that stay within the protocol limits
+func splitSitemap(urls []models.SitemapURL) [][]models.SitemapURL {
+	var parts [][]models.SitemapURL
+	var current []models.SitemapURL
+	size := len(xml.Header) + 128 // urlset element
+
+	for _, entry := range urls {
+		entrySize := len(entry.Path) + sitemapEntryOverhead
+		if len(current) == maxSitemapURLs || (len(current) > 0 && size+entrySize > maxSitemapBytes) {
+			parts = append(parts, current)
+			current = nil
+			size = len(xml.Header) + 128
+		}
+		current = append(current, entry)
+		size += entrySize
+	}
+	if len(current) > 0 {
+		parts = append(parts, current)
+	}
+	return parts
+}
@@ -0,0 +1,219 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+
+	"wacatalogue/backend/models"
+)
+
+// Products with at most this many units left are reported as low on stock
+const defaultLowStockThreshold = 5
+
+// GetMyStoreSummary returns the dashboard overview of the current user's
+// selected store, or the one named by the storeId query parameter. Orders and
+// revenue are only summarized for roles that may see orders or reports.
+// Everything is computed by MongoDB aggregations so only the figures, not the
+// catalog or order history, are loaded into memory.
+func GetMyStoreSummary(db *models.Database) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// Get user ID from context
+		userID, err := getUserIDFromContext(r)
+		if err != nil {
+			RespondWithError(w, r, ErrUnauthenticated)
+			return
+		}
+
+		threshold := defaultLowStockThreshold
+		if value := r.URL.Query().Get("lowStock"); value != "" {
+			threshold, err = strconv.Atoi(value)
+			if err != nil || threshold < 1 {
+				RespondWithError(w, r, ErrValidationFailed.WithDetails([]models.FieldError{{Field: "lowStock", Message: "must be a positive number"}}))
+				return
+			}
+		}
+
+		// Create database context
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		store, err := loadMyStore(ctx, db, r, userID)
+		if err != nil {
+			RespondWithError(w, r, err)
+			return
+		}
+
+		role, err := storeRole(ctx, db, store, userID)
+		if err != nil {
+			RespondWithError(w, r, err)
+			return
+		}
+
+		summary := models.DashboardSummary{
+			StoreID:           store.ID,
+			Currency:          models.CurrencyOf(store.Currency).Code,
+			LowStockThreshold: threshold,
+			LowStockItems:     []models.Product{},
+			OrdersByStatus:    map[string]int64{},
+			RecentOrders:      []models.Order{},
+			Revenue7Days:      models.RevenueWindow{Days: 7},
+			Revenue30Days:     models.RevenueWindow{Days: 30},
+			TopProducts:       []models.TopSellingProduct{},
+			OrdersVisible:     models.RoleAllows(role, models.PermissionOrders) || models.RoleAllows(role, models.PermissionReports),
+		}
+
+		if err := summarizeProducts(ctx, db, &summary); err != nil {
+			RespondWithError(w, r, err)
+			return
+		}
+		if summary.OrdersVisible {
+			if err := summarizeOrders(ctx, db, &summary); err != nil {
+				RespondWithError(w, r, err)
+				return
+			}
+		}
+
+		// Send response
+		summary.ApplyCurrency()
+		RespondWithJSON(w, http.StatusOK, summary)
+	}
+}
+
+// summarizeProducts fills in product counts and low-stock items
+func summarizeProducts(ctx context.Context, db *models.Database, summary *models.DashboardSummary) error {
+	countIf := func(condition interface{}) bson.M {
+		return bson.M{"$sum": bson.M{"$cond": bson.A{condition, 1, 0}}}
+	}
+	// Only products that track stock can run out
+	tracked := bson.M{"$eq": bson.A{"$track_stock", true}}
+	lowStock := bson.M{"$and": bson.A{
+		tracked,
+		bson.M{"$gt": bson.A{"$stock", 0}},
+		bson.M{"$lte": bson.A{"$stock", summary.LowStockThreshold}},
+	}}
+
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: notDeleted(bson.M{"store_id": summary.StoreID})}},
+		{{Key: "$facet", Value: bson.M{
+			"counts": bson.A{
+				bson.M{"$group": bson.M{
+					"_id":          nil,
+					"total":        bson.M{"$sum": 1},
+					"active":       countIf("$active"),
+					"featured":     countIf("$featured"),
+					"out_of_stock": countIf(bson.M{"$and": bson.A{tracked, bson.M{"$lte": bson.A{"$stock", 0}}}}),
+					"low_stock":    countIf(lowStock),
+				}},
+			},
+			"low_stock_items": bson.A{
+				bson.M{"$match": bson.M{"active": true, "track_stock": true, "stock": bson.M{"$gt": 0, "$lte": summary.LowStockThreshold}}},
+				bson.M{"$sort": bson.D{{Key: "stock", Value: 1}, {Key: "name", Value: 1}}},
+				bson.M{"$limit": 10},
+			},
+		}}},
+	}
+
+	cursor, err := db.GetCollection(models.ProductCollection).Aggregate(ctx, pipeline)
+	if err != nil {
+		return ErrInternal.WithMessage("Failed to aggregate products")
+	}
+	var results []struct {
+		Counts        []models.ProductCounts `bson:"counts"`
+		LowStockItems []models.Product       `bson:"low_stock_items"`
+	}
+	if err = cursor.All(ctx, &results); err != nil {
+		return ErrInternal.WithMessage("Failed to decode product summary")
+	}
+
+	if len(results) > 0 {
+		if len(results[0].Counts) > 0 {
+			summary.Products = results[0].Counts[0]
+		}
+		summary.LowStockItems = append(summary.LowStockItems, results[0].LowStockItems...)
+	}
+	return nil
+}
+
+// summarizeOrders fills in order counts, recent orders, revenue and best sellers
+func summarizeOrders(ctx context.Context, db *models.Database, summary *models.DashboardSummary) error {
+	now := time.Now()
+	since7 := now.AddDate(0, 0, -7)
+	since30 := now.AddDate(0, 0, -30)
+	revenueStatuses := bson.M{"$in": models.RevenueStatuses}
+
+	revenueSince := func(since time.Time) bson.A {
+		return bson.A{
+			bson.M{"$match": bson.M{"status": revenueStatuses, "created_at": bson.M{"$gte": since}}},
+			bson.M{"$group": bson.M{"_id": nil, "orders": bson.M{"$sum": 1}, "revenue_minor": bson.M{"$sum": "$total_minor"}}},
+		}
+	}
+
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{"store_id": summary.StoreID}}},
+		{{Key: "$facet", Value: bson.M{
+			"by_status": bson.A{
+				bson.M{"$group": bson.M{"_id": "$status", "count": bson.M{"$sum": 1}}},
+			},
+			"recent": bson.A{
+				bson.M{"$sort": bson.M{"created_at": -1}},
+				bson.M{"$limit": 5},
+			},
+			"revenue_7":  revenueSince(since7),
+			"revenue_30": revenueSince(since30),
+			"top_products": bson.A{
+				bson.M{"$match": bson.M{"status": revenueStatuses, "created_at": bson.M{"$gte": since30}}},
+				bson.M{"$unwind": "$items"},
+				bson.M{"$group": bson.M{
+					"_id":           "$items.product_id",
+					"name":          bson.M{"$last": "$items.name"},
+					"quantity":      bson.M{"$sum": "$items.quantity"},
+					"revenue_minor": bson.M{"$sum": "$items.subtotal_minor"},
+				}},
+				bson.M{"$sort": bson.D{{Key: "quantity", Value: -1}, {Key: "revenue_minor", Value: -1}}},
+				bson.M{"$limit": 5},
+			},
+		}}},
+	}
+
+	cursor, err := db.GetCollection(models.OrderCollection).Aggregate(ctx, pipeline)
+	if err != nil {
+		return ErrInternal.WithMessage("Failed to aggregate orders")
+	}
+	var results []struct {
+		ByStatus []struct {
+			Status string `bson:"_id"`
+			Count  int64  `bson:"count"`
+		} `bson:"by_status"`
+		Recent      []models.Order             `bson:"recent"`
+		Revenue7    []models.RevenueWindow     `bson:"revenue_7"`
+		Revenue30   []models.RevenueWindow     `bson:"revenue_30"`
+		TopProducts []models.TopSellingProduct `bson:"top_products"`
+	}
+	if err = cursor.All(ctx, &results); err != nil {
+		return ErrInternal.WithMessage("Failed to decode order summary")
+	}
+	if len(results) == 0 {
+		return nil
+	}
+
+	result := results[0]
+	for _, status := range result.ByStatus {
+		summary.OrdersByStatus[status.Status] = status.Count
+	}
+	summary.RecentOrders = append(summary.RecentOrders, result.Recent...)
+	if len(result.Revenue7) > 0 {
+		summary.Revenue7Days.Orders = result.Revenue7[0].Orders
+		summary.Revenue7Days.RevenueMinor = result.Revenue7[0].RevenueMinor
+	}
+	if len(result.Revenue30) > 0 {
+		summary.Revenue30Days.Orders = result.Revenue30[0].Orders
+		summary.Revenue30Days.RevenueMinor = result.Revenue30[0].RevenueMinor
+	}
+	summary.TopProducts = append(summary.TopProducts, result.TopProducts...)
+	return nil
+}
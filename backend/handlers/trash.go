@@ -0,0 +1,387 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"wacatalogue/backend/models"
+)
+
+// inTrash matches documents that have been soft-deleted
+var inTrash = bson.M{"$exists": true}
+
+// GetTrash lists the user's trashed stores, and the trashed products of the
+// stores they own or may manage the catalog of, as those users can restore them
+func GetTrash(db *models.Database) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// Get user ID from context
+		userID, err := getUserIDFromContext(r)
+		if err != nil {
+			RespondWithError(w, r, ErrUnauthenticated)
+			return
+		}
+
+		// Create database context
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		findOptions := options.Find()
+		findOptions.SetSort(bson.D{{Key: "deleted_at", Value: -1}})
+
+		// Find every store of the user, trashed or not, to scope the product query
+		storesColl := db.GetCollection(models.StoreCollection)
+		cursor, err := storesColl.Find(ctx, bson.M{"owner_id": userID}, findOptions)
+		if err != nil {
+			RespondWithError(w, r, ErrInternal.WithMessage("Failed to find stores"))
+			return
+		}
+		var stores []models.Store
+		if err = cursor.All(ctx, &stores); err != nil {
+			RespondWithError(w, r, ErrInternal.WithMessage("Failed to decode stores"))
+			return
+		}
+
+		response := models.TrashResponse{Stores: []models.Store{}, Products: []models.Product{}}
+		storeIDs := make([]primitive.ObjectID, 0, len(stores))
+		currencies := make(map[primitive.ObjectID]models.Currency, len(stores))
+		for _, store := range stores {
+			storeIDs = append(storeIDs, store.ID)
+			currencies[store.ID] = models.CurrencyOf(store.Currency)
+			if store.DeletedAt != nil {
+				response.Stores = append(response.Stores, store)
+			}
+		}
+
+		// Add the live stores whose catalog the user helps manage
+		members, err := storeMemberships(ctx, db, userID)
+		if err != nil {
+			RespondWithError(w, r, err)
+			return
+		}
+		memberStoreIDs := []primitive.ObjectID{}
+		for _, member := range members {
+			if models.RoleAllows(member.Role, models.PermissionCatalog) {
+				memberStoreIDs = append(memberStoreIDs, member.StoreID)
+			}
+		}
+		if len(memberStoreIDs) > 0 {
+			cursor, err = storesColl.Find(ctx, notDeleted(bson.M{"_id": bson.M{"$in": memberStoreIDs}}), options.Find().SetProjection(bson.M{"_id": 1, "currency": 1}))
+			if err != nil {
+				RespondWithError(w, r, ErrInternal.WithMessage("Failed to find stores"))
+				return
+			}
+			var memberStores []models.Store
+			if err = cursor.All(ctx, &memberStores); err != nil {
+				RespondWithError(w, r, ErrInternal.WithMessage("Failed to decode stores"))
+				return
+			}
+			for _, store := range memberStores {
+				storeIDs = append(storeIDs, store.ID)
+				currencies[store.ID] = models.CurrencyOf(store.Currency)
+			}
+		}
+
+		// Find trashed products
+		productsColl := db.GetCollection(models.ProductCollection)
+		cursor, err = productsColl.Find(ctx, bson.M{"store_id": bson.M{"$in": storeIDs}, "deleted_at": inTrash}, findOptions)
+		if err != nil {
+			RespondWithError(w, r, ErrInternal.WithMessage("Failed to find products"))
+			return
+		}
+		if err = cursor.All(ctx, &response.Products); err != nil {
+			RespondWithError(w, r, ErrInternal.WithMessage("Failed to decode products"))
+			return
+		}
+		now := time.Now()
+		for i := range response.Products {
+			product := &response.Products[i]
+			product.ApplyPricing(now, currencies[product.StoreID])
+		}
+
+		// Send response
+		RespondWithJSON(w, http.StatusOK, response)
+	}
+}
+
+// RestoreStore takes a store out of the trash along with the products deleted with it
+func RestoreStore(db *models.Database) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// Get user ID from context
+		userID, err := getUserIDFromContext(r)
+		if err != nil {
+			RespondWithError(w, r, ErrUnauthenticated)
+			return
+		}
+
+		// Get store ID from URL
+		vars := mux.Vars(r)
+		storeID, err := primitive.ObjectIDFromHex(vars["id"])
+		if err != nil {
+			RespondWithError(w, r, ErrInvalidStoreID)
+			return
+		}
+
+		// Create database context
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		// Find trashed store
+		storesColl := db.GetCollection(models.StoreCollection)
+		var store models.Store
+		err = storesColl.FindOne(ctx, bson.M{"_id": storeID, "deleted_at": inTrash}).Decode(&store)
+		if err != nil {
+			if err == mongo.ErrNoDocuments {
+				RespondWithError(w, r, ErrStoreNotFound.WithMessage("Store not found in trash"))
+			} else {
+				RespondWithError(w, r, ErrInternal.WithMessage("Failed to find store"))
+			}
+			return
+		}
+		if store.OwnerID != userID {
+			RespondWithError(w, r, ErrNotOwner)
+			return
+		}
+
+		// Restore store
+		now := time.Now()
+		_, err = storesColl.UpdateOne(
+			ctx,
+			bson.M{"_id": storeID},
+			bson.M{"$unset": bson.M{"deleted_at": ""}, "$set": bson.M{"updated_at": now}},
+		)
+		if err != nil {
+			RespondWithError(w, r, ErrInternal.WithMessage("Failed to restore store"))
+			return
+		}
+
+		// Restore products that were trashed together with the store
+		productsColl := db.GetCollection(models.ProductCollection)
+		_, err = productsColl.UpdateMany(
+			ctx,
+			bson.M{"store_id": storeID, "deleted_at": *store.DeletedAt},
+			bson.M{"$unset": bson.M{"deleted_at": ""}, "$set": bson.M{"updated_at": now}},
+		)
+		if err != nil {
+			log.Printf("Failed to restore products of store %s: %v", storeID.Hex(), err)
+		}
+
+		// Select the restored store for the dashboard
+		usersColl := db.GetCollection(models.UserCollection)
+		_, err = usersColl.UpdateOne(
+			ctx,
+			bson.M{"_id": userID},
+			bson.M{"$set": bson.M{"store_id": storeID, "updated_at": now}},
+		)
+		if err != nil {
+			log.Printf("Failed to link restored store %s to user %s: %v", storeID.Hex(), userID.Hex(), err)
+		}
+
+		// Get restored store
+		var restoredStore models.Store
+		if err = storesColl.FindOne(ctx, bson.M{"_id": storeID}).Decode(&restoredStore); err != nil {
+			RespondWithError(w, r, ErrInternal.WithMessage("Failed to retrieve restored store"))
+			return
+		}
+
+		// Record mutation
+		recordAudit(ctx, db, r, models.AuditStoreRestore, "store", storeID, storeID, &store, &restoredStore)
+
+		// Send response
+		RespondWithJSON(w, http.StatusOK, restoredStore)
+	}
+}
+
+// RestoreProduct takes a product out of the trash
+func RestoreProduct(db *models.Database) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// Get user ID from context
+		userID, err := getUserIDFromContext(r)
+		if err != nil {
+			RespondWithError(w, r, ErrUnauthenticated)
+			return
+		}
+
+		// Get product ID from URL
+		vars := mux.Vars(r)
+		productID, err := primitive.ObjectIDFromHex(vars["id"])
+		if err != nil {
+			RespondWithError(w, r, ErrInvalidProductID)
+			return
+		}
+
+		// Create database context
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		// Find trashed product
+		productsColl := db.GetCollection(models.ProductCollection)
+		var product models.Product
+		err = productsColl.FindOne(ctx, bson.M{"_id": productID, "deleted_at": inTrash}).Decode(&product)
+		if err != nil {
+			if err == mongo.ErrNoDocuments {
+				RespondWithError(w, r, ErrProductNotFound.WithMessage("Product not found in trash"))
+			} else {
+				RespondWithError(w, r, ErrInternal.WithMessage("Failed to find product"))
+			}
+			return
+		}
+
+		// The product's store must be live and owned by the user
+		store, err := loadStoreFor(ctx, db, product.StoreID, userID, models.PermissionCatalog)
+		if err != nil {
+			if err == ErrStoreNotFound {
+				err = ErrStoreInTrash
+			}
+			RespondWithError(w, r, err)
+			return
+		}
+
+		// Restore product
+		_, err = productsColl.UpdateOne(
+			ctx,
+			bson.M{"_id": productID},
+			bson.M{"$unset": bson.M{"deleted_at": ""}, "$set": bson.M{"updated_at": time.Now()}},
+		)
+		if err != nil {
+			if mongo.IsDuplicateKeyError(err) {
+				RespondWithError(w, r, ErrSKUExists.WithMessage("Another product of the store has taken this product's SKU"))
+			} else {
+				RespondWithError(w, r, ErrInternal.WithMessage("Failed to restore product"))
+			}
+			return
+		}
+
+		// Get restored product
+		var restoredProduct models.Product
+		if err = productsColl.FindOne(ctx, bson.M{"_id": productID}).Decode(&restoredProduct); err != nil {
+			RespondWithError(w, r, ErrInternal.WithMessage("Failed to retrieve restored product"))
+			return
+		}
+
+		// Record mutation
+		recordAudit(ctx, db, r, models.AuditProductRestore, "product", productID, product.StoreID, &product, &restoredProduct)
+
+		// Send response
+		restoredProduct.ApplyPricing(time.Now(), models.CurrencyOf(store.Currency))
+		RespondWithJSON(w, http.StatusOK, restoredProduct)
+	}
+}
+
+// StartTrashPurge permanently deletes stores and products that have been in the
+// trash for longer than retention, along with the purged stores' data. It
+// checks every interval until ctx is done.
+func StartTrashPurge(ctx context.Context, db *models.Database, retention, interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			purgeTrash(ctx, db, retention)
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// purgeTrash runs a single purge pass
+func purgeTrash(ctx context.Context, db *models.Database, retention time.Duration) {
+	ctx, cancel := context.WithTimeout(ctx, time.Minute)
+	defer cancel()
+
+	expired := bson.M{"deleted_at": bson.M{"$lt": time.Now().Add(-retention)}}
+
+	// Purge stores first, with everything that belonged to them
+	storesColl := db.GetCollection(models.StoreCollection)
+	cursor, err := storesColl.Find(ctx, expired, options.Find().SetProjection(bson.M{"_id": 1}))
+	if err != nil {
+		log.Printf("Trash purge: failed to find expired stores: %v", err)
+		return
+	}
+	var stores []struct {
+		ID primitive.ObjectID `bson:"_id"`
+	}
+	if err = cursor.All(ctx, &stores); err != nil {
+		log.Printf("Trash purge: failed to decode expired stores: %v", err)
+		return
+	}
+
+	for _, store := range stores {
+		// The store goes last, so a failed purge is retried on the next pass
+		if err := purgeStoreData(ctx, db, store.ID); err != nil {
+			log.Printf("Trash purge: failed to delete data of store %s: %v", store.ID.Hex(), err)
+			continue
+		}
+		if _, err := storesColl.DeleteOne(ctx, bson.M{"_id": store.ID}); err != nil {
+			log.Printf("Trash purge: failed to delete store %s: %v", store.ID.Hex(), err)
+		}
+	}
+
+	result, err := db.GetCollection(models.ProductCollection).DeleteMany(ctx, expired)
+	if err != nil {
+		log.Printf("Trash purge: failed to delete expired products: %v", err)
+		return
+	}
+
+	if len(stores) > 0 || result.DeletedCount > 0 {
+		log.Printf("Trash purge: removed %d stores and %d products", len(stores), result.DeletedCount)
+	}
+}
+
+// Collections holding a store's data by store_id, deleted with the store.
+// The audit log is kept as the record of what happened to the store.
+var storeDataCollections = []string{
+	models.ProductCollection,
+	models.OrderCollection,
+	models.ConversationMessageCollection,
+	models.CustomerCollection,
+	models.ReviewCollection,
+	models.DeliveryZoneCollection,
+	models.CourierRateCollection,
+	models.StoreMemberCollection,
+	models.StoreInviteCollection,
+	models.NotificationCollection,
+	models.ImportJobCollection,
+	models.AnalyticsDailyCollection,
+	models.SitemapCollection,
+}
+
+// purgeStoreData permanently deletes everything that belongs to a store
+func purgeStoreData(ctx context.Context, db *models.Database, storeID primitive.ObjectID) error {
+	// Coupon redemptions are kept by coupon
+	couponsColl := db.GetCollection(models.CouponCollection)
+	couponIDs, err := couponsColl.Distinct(ctx, "_id", bson.M{"store_id": storeID})
+	if err != nil {
+		return err
+	}
+	if len(couponIDs) > 0 {
+		if _, err := db.GetCollection(models.CouponRedemptionCollection).DeleteMany(ctx, bson.M{"coupon_id": bson.M{"$in": couponIDs}}); err != nil {
+			return err
+		}
+	}
+	if _, err := couponsColl.DeleteMany(ctx, bson.M{"store_id": storeID}); err != nil {
+		return err
+	}
+
+	for _, name := range storeDataCollections {
+		if _, err := db.GetCollection(name).DeleteMany(ctx, bson.M{"store_id": storeID}); err != nil {
+			return fmt.Errorf("%s: %v", name, err)
+		}
+	}
+
+	// Nobody's dashboard should open the store any more
+	_, err = db.GetCollection(models.UserCollection).UpdateMany(ctx, bson.M{"store_id": storeID}, bson.M{"$unset": bson.M{"store_id": ""}})
+	return err
+}
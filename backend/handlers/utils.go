@@ -2,8 +2,12 @@ package handlers
 
 import (
 	"encoding/json"
+	"log"
+	"net"
 	"net/http"
 	"os"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/dgrijalva/jwt-go"
@@ -23,13 +27,29 @@ func init() {
 
 // ErrorResponse represents an API error response
 type ErrorResponse struct {
-	Status  int    `json:"status"`
-	Message string `json:"message"`
+	Status    int         `json:"status"`
+	Code      string      `json:"code"`
+	Message   string      `json:"message"`
+	Details   interface{} `json:"details,omitempty"`
+	RequestID string      `json:"requestId,omitempty"`
 }
 
-// RespondWithError sends an error response
-func RespondWithError(w http.ResponseWriter, code int, message string) {
-	RespondWithJSON(w, code, ErrorResponse{Status: code, Message: message})
+// RespondWithError sends an error response. Errors that are not an *APIError
+// are reported as INTERNAL_ERROR so internal messages never leak to clients.
+func RespondWithError(w http.ResponseWriter, r *http.Request, err error) {
+	apiErr, ok := err.(*APIError)
+	if !ok {
+		log.Printf("Unhandled error on %s %s: %v", r.Method, r.URL.Path, err)
+		apiErr = ErrInternal
+	}
+
+	RespondWithJSON(w, apiErr.Status, ErrorResponse{
+		Status:    apiErr.Status,
+		Code:      apiErr.Code,
+		Message:   apiErr.Message,
+		Details:   apiErr.Details,
+		RequestID: getRequestIDFromContext(r),
+	})
 }
 
 // RespondWithJSON sends a JSON response
@@ -37,7 +57,7 @@ func RespondWithJSON(w http.ResponseWriter, code int, payload interface{}) {
 	response, err := json.Marshal(payload)
 	if err != nil {
 		w.WriteHeader(http.StatusInternalServerError)
-		w.Write([]byte(`{"status":500,"message":"Internal server error"}`))
+		w.Write([]byte(`{"status":500,"code":"INTERNAL_ERROR","message":"Internal server error"}`))
 		return
 	}
 
@@ -80,4 +100,79 @@ func GenerateJWT(userID primitive.ObjectID, username string) (string, error) {
 	}
 
 	return tokenString, nil
-}
\ No newline at end of file
+}
+
+// clientIP returns the caller's IP. Forwarding headers are only believed
+// when the request came through a proxy listed in TRUSTED_PROXIES; the
+// client is then the nearest address in X-Forwarded-For that is not one of
+// those proxies.
+func clientIP(r *http.Request) string {
+	remote := remoteIP(r)
+	if !trustedProxy(remote) {
+		return remote
+	}
+	if forwarded := r.Header.Get("X-Forwarded-For"); forwarded != "" {
+		hops := strings.Split(forwarded, ",")
+		for i := len(hops) - 1; i >= 0; i-- {
+			hop := strings.TrimSpace(hops[i])
+			if net.ParseIP(hop) == nil {
+				break
+			}
+			if !trustedProxy(hop) || i == 0 {
+				return hop
+			}
+		}
+	}
+	if realIP := strings.TrimSpace(r.Header.Get("X-Real-IP")); net.ParseIP(realIP) != nil {
+		return realIP
+	}
+	return remote
+}
+
+// remoteIP returns the address of the connection's other end
+func remoteIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// trustedProxy reports whether an IP is one of the reverse proxies listed in
+// TRUSTED_PROXIES, a comma-separated list of addresses and CIDR ranges
+func trustedProxy(ip string) bool {
+	addr := net.ParseIP(ip)
+	if addr == nil {
+		return false
+	}
+	for _, entry := range strings.Split(os.Getenv("TRUSTED_PROXIES"), ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		if _, network, err := net.ParseCIDR(entry); err == nil {
+			if network.Contains(addr) {
+				return true
+			}
+		} else if proxy := net.ParseIP(entry); proxy != nil && proxy.Equal(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// parsePagination reads the page and limit query parameters
+func parsePagination(r *http.Request, defaultLimit, maxLimit int64) (page, limit int64) {
+	page, _ = strconv.ParseInt(r.URL.Query().Get("page"), 10, 64)
+	if page < 1 {
+		page = 1
+	}
+	limit, _ = strconv.ParseInt(r.URL.Query().Get("limit"), 10, 64)
+	if limit < 1 {
+		limit = defaultLimit
+	}
+	if limit > maxLimit {
+		limit = maxLimit
+	}
+	return page, limit
+}
@@ -0,0 +1,73 @@
+package handlers
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+// TestClientIP checks that forwarding headers are only believed from
+// trusted proxies
+func TestClientIP(t *testing.T) {
+	t.Setenv("TRUSTED_PROXIES", "10.0.0.1, 172.16.0.0/12")
+
+	tests := []struct {
+		name      string
+		remote    string
+		forwarded string
+		realIP    string
+		want      string
+	}{
+		{name: "direct", remote: "203.0.113.7:5100", want: "203.0.113.7"},
+		{name: "direct with forged header", remote: "203.0.113.7:5100", forwarded: "198.51.100.1", want: "203.0.113.7"},
+		{name: "through proxy", remote: "10.0.0.1:443", forwarded: "198.51.100.1", want: "198.51.100.1"},
+		{name: "forged hop before proxy", remote: "10.0.0.1:443", forwarded: "192.0.2.9, 198.51.100.1", want: "198.51.100.1"},
+		{name: "through proxy chain", remote: "10.0.0.1:443", forwarded: "198.51.100.1, 172.16.4.2", want: "198.51.100.1"},
+		{name: "real IP through proxy", remote: "10.0.0.1:443", realIP: "198.51.100.1", want: "198.51.100.1"},
+		{name: "garbage through proxy", remote: "10.0.0.1:443", forwarded: "not-an-ip", want: "10.0.0.1"},
+	}
+
+	for _, tt := range tests {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.RemoteAddr = tt.remote
+		if tt.forwarded != "" {
+			req.Header.Set("X-Forwarded-For", tt.forwarded)
+		}
+		if tt.realIP != "" {
+			req.Header.Set("X-Real-IP", tt.realIP)
+		}
+		if got := clientIP(req); got != tt.want {
+			t.Errorf("%s: got %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+// TestPublicBaseURL checks that forwarded host headers only count from
+// trusted proxies
+func TestPublicBaseURL(t *testing.T) {
+	t.Setenv("TRUSTED_PROXIES", "10.0.0.1")
+	t.Setenv("PUBLIC_BASE_URL", "")
+
+	tests := []struct {
+		name   string
+		remote string
+		want   string
+	}{
+		{name: "direct with forged headers", remote: "203.0.113.7:5100", want: "http://shop.example"},
+		{name: "through proxy", remote: "10.0.0.1:443", want: "https://cdn.example"},
+	}
+
+	for _, tt := range tests {
+		req := httptest.NewRequest("GET", "http://shop.example/", nil)
+		req.RemoteAddr = tt.remote
+		req.Header.Set("X-Forwarded-Proto", "https")
+		req.Header.Set("X-Forwarded-Host", "cdn.example")
+		if got := publicBaseURL(req); got != tt.want {
+			t.Errorf("%s: got %q, want %q", tt.name, got, tt.want)
+		}
+	}
+
+	t.Setenv("PUBLIC_BASE_URL", "https://shop.example/")
+	if got := publicBaseURL(httptest.NewRequest("GET", "/", nil)); got != "https://shop.example" {
+		t.Errorf("got %q with PUBLIC_BASE_URL set, want https://shop.example", got)
+	}
+}
@@ -0,0 +1,407 @@
+package handlers
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"wacatalogue/backend/models"
+)
+
+// Webhook payloads larger than this are rejected
+const webhookMaxBytes = 1 << 20
+
+// Messages without an order reference are attached to the sender's most
+// recent conversation if it was active within this window
+const conversationFollowUpWindow = 30 * 24 * time.Hour
+
+// orderReferencePattern finds the "Order #REFERENCE" line of the message
+// generated at checkout, see orderMessage
+var orderReferencePattern = regexp.MustCompile(`(?i)order\s*#\s*([0-9a-f]{8})\b`)
+
+// inboundMessage is a customer message taken from a webhook payload
+type inboundMessage struct {
+	WhatsAppID    string
+	From          string // Phone number digits
+	ProfileName   string
+	BusinessPhone string // Digits of the number that received the message
+	Type          string
+	Text          string
+	SentAt        time.Time
+	References    []string // Order references quoted in the text
+}
+
+// VerifyWhatsAppWebhook answers the verification request the WhatsApp Cloud
+// API sends when the webhook is configured, echoing the challenge when the
+// verify token matches WHATSAPP_VERIFY_TOKEN
+func VerifyWhatsAppWebhook(db *models.Database) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		token := os.Getenv("WHATSAPP_VERIFY_TOKEN")
+		if token == "" || query.Get("hub.mode") != "subscribe" ||
+			subtle.ConstantTimeCompare([]byte(query.Get("hub.verify_token")), []byte(token)) != 1 {
+			RespondWithError(w, r, ErrVerifyTokenMismatch)
+			return
+		}
+
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(query.Get("hub.challenge")))
+	}
+}
+
+// ReceiveWhatsAppWebhook records the messages customers send to a store's
+// WhatsApp number and attaches them to the orders they are about. Payloads
+// must be signed with WHATSAPP_APP_SECRET. Messages the API delivers again
+// are ignored, so a failed delivery can safely be retried.
+func ReceiveWhatsAppWebhook(db *models.Database) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, webhookMaxBytes))
+		if err != nil {
+			RespondWithError(w, r, ErrInvalidPayload.WithDetails(err.Error()))
+			return
+		}
+
+		// Check signature before looking at the payload
+		if !validWebhookSignature(os.Getenv("WHATSAPP_APP_SECRET"), body, r.Header.Get("X-Hub-Signature-256")) {
+			RespondWithError(w, r, ErrInvalidSignature)
+			return
+		}
+
+		messages, err := parseWhatsAppWebhook(body)
+		if err != nil {
+			RespondWithError(w, r, ErrInvalidPayload.WithDetails(err.Error()))
+			return
+		}
+
+		// Create database context
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		response := models.WebhookResponse{Received: len(messages)}
+		now := time.Now()
+		for _, message := range messages {
+			attached, err := attachConversationMessage(ctx, db, message, now)
+			if err != nil {
+				log.Printf("Failed to attach WhatsApp message %s: %v", message.WhatsAppID, err)
+				RespondWithError(w, r, ErrInternal.WithMessage("Failed to record messages"))
+				return
+			}
+			if attached {
+				response.Attached++
+			}
+		}
+
+		// Send response
+		RespondWithJSON(w, http.StatusOK, response)
+	}
+}
+
+// GetOrderConversation lists the WhatsApp messages attached to one of the
+// user's orders, oldest first
+func GetOrderConversation(db *models.Database) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// Get user ID from context
+		userID, err := getUserIDFromContext(r)
+		if err != nil {
+			RespondWithError(w, r, ErrUnauthenticated)
+			return
+		}
+
+		// Get order ID from URL
+		vars := mux.Vars(r)
+		orderID, err := primitive.ObjectIDFromHex(vars["id"])
+		if err != nil {
+			RespondWithError(w, r, ErrInvalidOrderID)
+			return
+		}
+
+		// Create database context
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		if _, _, err := loadOrderFor(ctx, db, orderID, userID); err != nil {
+			RespondWithError(w, r, err)
+			return
+		}
+
+		findOptions := options.Find().SetSort(bson.D{{Key: "sent_at", Value: 1}})
+		cursor, err := db.GetCollection(models.ConversationMessageCollection).Find(ctx, bson.M{"order_id": orderID}, findOptions)
+		if err != nil {
+			RespondWithError(w, r, ErrInternal.WithMessage("Failed to find messages"))
+			return
+		}
+		messages := []models.ConversationMessage{}
+		if err = cursor.All(ctx, &messages); err != nil {
+			RespondWithError(w, r, ErrInternal.WithMessage("Failed to decode messages"))
+			return
+		}
+
+		// Send response
+		RespondWithJSON(w, http.StatusOK, messages)
+	}
+}
+
+// validWebhookSignature checks the X-Hub-Signature-256 header, an HMAC-SHA256
+// of the raw body keyed with the app secret. Without a secret nothing is valid.
+func validWebhookSignature(secret string, body []byte, header string) bool {
+	if secret == "" {
+		return false
+	}
+	signature, ok := strings.CutPrefix(header, "sha256=")
+	if !ok {
+		return false
+	}
+	got, err := hex.DecodeString(signature)
+	if err != nil {
+		return false
+	}
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return hmac.Equal(got, mac.Sum(nil))
+}
+
+// parseWhatsAppWebhook extracts the customer messages from a webhook payload.
+// Delivery statuses and other notifications are skipped.
+func parseWhatsAppWebhook(body []byte) ([]inboundMessage, error) {
+	var payload models.WhatsAppWebhook
+	if err := json.Unmarshal(body, &payload); err != nil {
+		return nil, err
+	}
+
+	messages := []inboundMessage{}
+	for _, entry := range payload.Entry {
+		for _, change := range entry.Changes {
+			if change.Field != "messages" {
+				continue
+			}
+			names := make(map[string]string, len(change.Value.Contacts))
+			for _, contact := range change.Value.Contacts {
+				names[contact.WaID] = contact.Profile.Name
+			}
+			for _, message := range change.Value.Messages {
+				if message.ID == "" || phoneDigits(message.From) == "" {
+					continue
+				}
+				inbound := inboundMessage{
+					WhatsAppID:    message.ID,
+					From:          phoneDigits(message.From),
+					ProfileName:   names[message.From],
+					BusinessPhone: phoneDigits(change.Value.Metadata.DisplayPhoneNumber),
+					Type:          message.Type,
+					Text:          message.Body(),
+				}
+				if seconds, err := strconv.ParseInt(message.Timestamp, 10, 64); err == nil {
+					inbound.SentAt = time.Unix(seconds, 0).UTC()
+				}
+				inbound.References = orderReferences(inbound.Text)
+				messages = append(messages, inbound)
+			}
+		}
+	}
+	return messages, nil
+}
+
+// orderReferences returns the order references quoted in a message, in order
+func orderReferences(text string) []string {
+	references := []string{}
+	seen := map[string]bool{}
+	for _, match := range orderReferencePattern.FindAllStringSubmatch(text, -1) {
+		reference := strings.ToUpper(match[1])
+		if !seen[reference] {
+			seen[reference] = true
+			references = append(references, reference)
+		}
+	}
+	return references
+}
+
+// attachConversationMessage saves a message against the order it is about and
+// updates the order's conversation summary. It reports false when no order
+// matches; such messages are not kept.
+func attachConversationMessage(ctx context.Context, db *models.Database, message inboundMessage, now time.Time) (bool, error) {
+	if message.SentAt.IsZero() {
+		message.SentAt = now
+	}
+
+	order, found, err := matchConversationOrder(ctx, db, message)
+	if err != nil || !found {
+		return false, err
+	}
+
+	record := models.ConversationMessage{
+		ID:            primitive.NewObjectID(),
+		WhatsAppID:    message.WhatsAppID,
+		StoreID:       order.StoreID,
+		OrderID:       order.ID,
+		From:          message.From,
+		ProfileName:   message.ProfileName,
+		BusinessPhone: message.BusinessPhone,
+		Type:          message.Type,
+		Text:          message.Text,
+		SentAt:        message.SentAt,
+		ReceivedAt:    now,
+	}
+	if _, err := db.GetCollection(models.ConversationMessageCollection).InsertOne(ctx, record); err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			// Delivered before; the order already counts it
+			return true, nil
+		}
+		return false, err
+	}
+
+	set := bson.M{"conversation.phone": message.From}
+	if message.ProfileName != "" {
+		set["conversation.profile_name"] = message.ProfileName
+	}
+	update := bson.M{
+		"$set": set,
+		"$inc": bson.M{"conversation.message_count": 1},
+		"$min": bson.M{"conversation.first_message_at": message.SentAt},
+		"$max": bson.M{"conversation.last_message_at": message.SentAt},
+	}
+	_, err = db.GetCollection(models.OrderCollection).UpdateOne(ctx, bson.M{"_id": order.ID}, update)
+	return true, err
+}
+
+// matchConversationOrder finds the order a message is about among the orders
+// of the stores that own the receiving number: the order whose reference it
+// quotes, or else the order the sender last wrote about. Messages to a number
+// no store uses are dropped, so one store never sees another's customers.
+func matchConversationOrder(ctx context.Context, db *models.Database, message inboundMessage) (models.Order, bool, error) {
+	if message.BusinessPhone == "" {
+		return models.Order{}, false, nil
+	}
+
+	// Find the stores behind the receiving number
+	findOptions := options.Find().SetProjection(bson.M{"whatsapp_number": 1})
+	filter := notDeleted(bson.M{"whatsapp_digits": message.BusinessPhone})
+	cursor, err := db.GetCollection(models.StoreCollection).Find(ctx, filter, findOptions)
+	if err != nil {
+		return models.Order{}, false, err
+	}
+	var stores []models.Store
+	if err := cursor.All(ctx, &stores); err != nil {
+		return models.Order{}, false, err
+	}
+	if len(stores) == 0 {
+		return models.Order{}, false, nil
+	}
+	storeIDs := make([]primitive.ObjectID, len(stores))
+	for i, store := range stores {
+		storeIDs[i] = store.ID
+	}
+
+	sort := bson.D{{Key: "created_at", Value: -1}}
+	filter = bson.M{"store_id": bson.M{"$in": storeIDs}}
+	if len(message.References) > 0 {
+		filter["reference"] = bson.M{"$in": message.References}
+	} else {
+		filter["conversation.phone"] = message.From
+		filter["conversation.last_message_at"] = bson.M{"$gte": message.SentAt.Add(-conversationFollowUpWindow)}
+		sort = bson.D{{Key: "conversation.last_message_at", Value: -1}}
+	}
+	cursor, err = db.GetCollection(models.OrderCollection).Find(ctx, filter, options.Find().SetSort(sort).SetLimit(20))
+	if err != nil {
+		return models.Order{}, false, err
+	}
+	var candidates []models.Order
+	if err := cursor.All(ctx, &candidates); err != nil {
+		return models.Order{}, false, err
+	}
+
+	order, found := chooseConversationOrder(message, stores, candidates)
+	return order, found, nil
+}
+
+// storeNumberDigits normalises a store's WhatsApp number to the international
+// digits webhook messages carry
+func storeNumberDigits(number string) string {
+	return internationalPhone(number, "")
+}
+
+// IndexStoreNumbers fills in the normalised WhatsApp number of stores saved
+// before it was stored. Only stores without one are touched, so it is safe to
+// run on every start.
+func IndexStoreNumbers(ctx context.Context, db *models.Database) error {
+	storesColl := db.GetCollection(models.StoreCollection)
+	findOptions := options.Find().SetProjection(bson.M{"whatsapp_number": 1})
+	cursor, err := storesColl.Find(ctx, bson.M{"whatsapp_digits": bson.M{"$exists": false}}, findOptions)
+	if err != nil {
+		return err
+	}
+	var stores []models.Store
+	if err := cursor.All(ctx, &stores); err != nil {
+		return err
+	}
+	for _, store := range stores {
+		if _, err := storesColl.UpdateOne(ctx, bson.M{"_id": store.ID}, bson.M{"$set": bson.M{"whatsapp_digits": storeNumberDigits(store.WhatsappNumber)}}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// chooseConversationOrder picks the order a message is about from candidates
+// sorted most relevant first. Only orders of the given stores qualify. A
+// follow-up goes to the sender's latest conversation; a quoted reference to
+// the order placed from the sender's phone, or to the only order with it when
+// that order has no phone. Anything else may be a guessed reference and
+// matches nothing.
+func chooseConversationOrder(message inboundMessage, stores []models.Store, candidates []models.Order) (models.Order, bool) {
+	allowed := make(map[primitive.ObjectID]models.Store, len(stores))
+	for _, store := range stores {
+		allowed[store.ID] = store
+	}
+	var orders []models.Order
+	for _, candidate := range candidates {
+		if _, ok := allowed[candidate.StoreID]; ok {
+			orders = append(orders, candidate)
+		}
+	}
+
+	if len(message.References) == 0 {
+		for _, order := range orders {
+			if order.Conversation != nil && order.Conversation.Phone == message.From {
+				return order, true
+			}
+		}
+		return models.Order{}, false
+	}
+
+	var quoted []models.Order
+	for _, order := range orders {
+		for _, reference := range message.References {
+			if order.Reference == reference {
+				quoted = append(quoted, order)
+				break
+			}
+		}
+	}
+	for _, order := range quoted {
+		if internationalPhone(order.CustomerPhone, allowed[order.StoreID].WhatsappNumber) == message.From {
+			return order, true
+		}
+	}
+	if len(quoted) == 1 && phoneDigits(quoted[0].CustomerPhone) == "" {
+		return quoted[0], true
+	}
+	return models.Order{}, false
+}
@@ -0,0 +1,213 @@
+package handlers
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"wacatalogue/backend/models"
+)
+
+// loadWebhookFixture reads a payload recorded from the WhatsApp Cloud API
+func loadWebhookFixture(t *testing.T, name string) []byte {
+	t.Helper()
+	body, err := os.ReadFile(filepath.Join("testdata", "whatsapp", name))
+	if err != nil {
+		t.Fatalf("failed to read fixture %s: %v", name, err)
+	}
+	return body
+}
+
+// signWebhook returns the X-Hub-Signature-256 header the API would send
+func signWebhook(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// TestParseWhatsAppWebhook checks the messages and order references taken
+// from recorded payloads
+func TestParseWhatsAppWebhook(t *testing.T) {
+	tests := []struct {
+		fixture    string
+		count      int
+		typ        string
+		references []string
+		sentAt     int64
+	}{
+		{fixture: "text_message.json", count: 1, typ: "text", references: []string{"5F3A9C21"}, sentAt: 1760860800},
+		{fixture: "image_caption.json", count: 1, typ: "image", references: []string{"5F3A9C21"}, sentAt: 1760861100},
+		{fixture: "follow_up.json", count: 1, typ: "text", references: []string{}, sentAt: 1760861400},
+		{fixture: "status_update.json", count: 0},
+	}
+
+	for _, tt := range tests {
+		messages, err := parseWhatsAppWebhook(loadWebhookFixture(t, tt.fixture))
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", tt.fixture, err)
+			continue
+		}
+		if len(messages) != tt.count {
+			t.Errorf("%s: got %d messages, want %d", tt.fixture, len(messages), tt.count)
+			continue
+		}
+		if tt.count == 0 {
+			continue
+		}
+
+		message := messages[0]
+		if message.From != "2348059876543" || message.ProfileName != "Ada Obi" || message.BusinessPhone != "2348031234567" {
+			t.Errorf("%s: got sender %q (%q) to %q", tt.fixture, message.From, message.ProfileName, message.BusinessPhone)
+		}
+		if message.Type != tt.typ {
+			t.Errorf("%s: got type %q, want %q", tt.fixture, message.Type, tt.typ)
+		}
+		if !reflect.DeepEqual(message.References, tt.references) {
+			t.Errorf("%s: got references %v, want %v", tt.fixture, message.References, tt.references)
+		}
+		if !message.SentAt.Equal(time.Unix(tt.sentAt, 0)) {
+			t.Errorf("%s: got sent at %v, want %v", tt.fixture, message.SentAt, time.Unix(tt.sentAt, 0))
+		}
+	}
+}
+
+// TestValidWebhookSignature checks signatures against the raw payload
+func TestValidWebhookSignature(t *testing.T) {
+	body := loadWebhookFixture(t, "text_message.json")
+	header := signWebhook("app-secret", body)
+
+	if !validWebhookSignature("app-secret", body, header) {
+		t.Error("valid signature was rejected")
+	}
+	if validWebhookSignature("other-secret", body, header) {
+		t.Error("signature with the wrong secret was accepted")
+	}
+	if validWebhookSignature("app-secret", append(body, ' '), header) {
+		t.Error("signature of a modified body was accepted")
+	}
+	if validWebhookSignature("", body, signWebhook("", body)) {
+		t.Error("signature was accepted without a configured secret")
+	}
+	if validWebhookSignature("app-secret", body, header[len("sha256="):]) {
+		t.Error("signature without the sha256= prefix was accepted")
+	}
+}
+
+// TestVerifyWhatsAppWebhook checks the verify token challenge
+func TestVerifyWhatsAppWebhook(t *testing.T) {
+	t.Setenv("WHATSAPP_VERIFY_TOKEN", "verify-me")
+	handler := VerifyWhatsAppWebhook(nil)
+
+	req := httptest.NewRequest("GET", "/api/webhooks/whatsapp?hub.mode=subscribe&hub.verify_token=verify-me&hub.challenge=1158201444", nil)
+	rec := httptest.NewRecorder()
+	handler(rec, req)
+	if rec.Code != http.StatusOK || rec.Body.String() != "1158201444" {
+		t.Errorf("got %d %q, want 200 with the challenge", rec.Code, rec.Body.String())
+	}
+
+	req = httptest.NewRequest("GET", "/api/webhooks/whatsapp?hub.mode=subscribe&hub.verify_token=wrong&hub.challenge=1158201444", nil)
+	rec = httptest.NewRecorder()
+	handler(rec, req)
+	if rec.Code != http.StatusForbidden {
+		t.Errorf("got %d for a wrong verify token, want 403", rec.Code)
+	}
+}
+
+// TestReceiveWhatsAppWebhookRejectsBadSignature checks that unsigned payloads
+// are refused before the database is used
+func TestReceiveWhatsAppWebhookRejectsBadSignature(t *testing.T) {
+	t.Setenv("WHATSAPP_APP_SECRET", "app-secret")
+	handler := ReceiveWhatsAppWebhook(nil)
+	body := loadWebhookFixture(t, "text_message.json")
+
+	for _, header := range []string{"", signWebhook("other-secret", body)} {
+		req := httptest.NewRequest("POST", "/api/webhooks/whatsapp", bytes.NewReader(body))
+		if header != "" {
+			req.Header.Set("X-Hub-Signature-256", header)
+		}
+		rec := httptest.NewRecorder()
+		handler(rec, req)
+		if rec.Code != http.StatusUnauthorized {
+			t.Errorf("signature %q: got %d, want 401", header, rec.Code)
+		}
+	}
+}
+
+// parseFixtureMessage returns the only message of a recorded payload
+func parseFixtureMessage(t *testing.T, name string) inboundMessage {
+	t.Helper()
+	messages, err := parseWhatsAppWebhook(loadWebhookFixture(t, name))
+	if err != nil || len(messages) != 1 {
+		t.Fatalf("%s: got %d messages, error %v", name, len(messages), err)
+	}
+	return messages[0]
+}
+
+// TestStoreNumberDigits checks that stored numbers match the number that
+// received a message, however they were typed
+func TestStoreNumberDigits(t *testing.T) {
+	message := parseFixtureMessage(t, "text_message.json")
+	for _, number := range []string{"+234 803 123 4567", "(+234) 803-123-4567", "00234 803 123 4567", "2348031234567"} {
+		if got := storeNumberDigits(number); got != message.BusinessPhone {
+			t.Errorf("storeNumberDigits(%q) = %q, want %q", number, got, message.BusinessPhone)
+		}
+	}
+	if got := storeNumberDigits("+234 803 123 4560"); got == message.BusinessPhone {
+		t.Errorf("another number matched %q", got)
+	}
+}
+
+// TestChooseConversationOrder checks which order recorded messages attach
+// to, and that orders of other stores are never chosen
+func TestChooseConversationOrder(t *testing.T) {
+	storeA, storeB := primitive.NewObjectID(), primitive.NewObjectID()
+	stores := []models.Store{{ID: storeA, WhatsappNumber: "+234 803 123 4567"}}
+	order := func(store primitive.ObjectID, phone string, conversation bool) models.Order {
+		o := models.Order{ID: primitive.NewObjectID(), StoreID: store, Reference: "5F3A9C21", CustomerPhone: phone}
+		if conversation {
+			o.Conversation = &models.OrderConversation{Phone: "2348059876543"}
+		}
+		return o
+	}
+	otherStore := order(storeB, "+2348059876543", true)
+	ownStore := order(storeA, "", true)
+	fromSender := order(storeA, "+234 805 987 6543", false)
+	fromSenderLocal := order(storeA, "0805 987 6543", false)
+	fromSomeoneElse := order(storeA, "+2348011111111", false)
+
+	tests := []struct {
+		name       string
+		fixture    string
+		candidates []models.Order
+		want       *models.Order
+	}{
+		{name: "reference only in another store", fixture: "text_message.json", candidates: []models.Order{otherStore}},
+		{name: "reference in both stores", fixture: "text_message.json", candidates: []models.Order{otherStore, ownStore}, want: &ownStore},
+		{name: "reference shared, sender's order wins", fixture: "image_caption.json", candidates: []models.Order{fromSomeoneElse, fromSender}, want: &fromSender},
+		{name: "reference shared, no sender match", fixture: "text_message.json", candidates: []models.Order{ownStore, fromSomeoneElse}},
+		{name: "reference shared, sender's local number wins", fixture: "image_caption.json", candidates: []models.Order{fromSomeoneElse, fromSenderLocal}, want: &fromSenderLocal},
+		{name: "reference of someone else's order", fixture: "image_caption.json", candidates: []models.Order{fromSomeoneElse}},
+		{name: "follow-up in another store", fixture: "follow_up.json", candidates: []models.Order{otherStore}},
+		{name: "follow-up", fixture: "follow_up.json", candidates: []models.Order{otherStore, fromSender, ownStore}, want: &ownStore},
+	}
+
+	for _, tt := range tests {
+		got, found := chooseConversationOrder(parseFixtureMessage(t, tt.fixture), stores, tt.candidates)
+		switch {
+		case tt.want == nil && found:
+			t.Errorf("%s: attached to order %s, want no match", tt.name, got.ID.Hex())
+		case tt.want != nil && (!found || got.ID != tt.want.ID):
+			t.Errorf("%s: got order %s (found %v), want %s", tt.name, got.ID.Hex(), found, tt.want.ID.Hex())
+		}
+	}
+}
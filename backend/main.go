@@ -1,11 +1,13 @@
 package main
 
 import (
+	"context"
 	"log"
 	"net/http"
 	"os"
+	"strconv"
+	"time"
 
-	"github.com/gorilla/mux"
 	"github.com/joho/godotenv"
 	"github.com/rs/cors"
 
@@ -32,52 +34,70 @@ func main() {
 	}
 	defer db.Close()
 
-	// Create router
-	router := mux.NewRouter()
+	// Create required indexes
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	if err := db.EnsureIndexes(ctx); err != nil {
+		// Queries still work without them; /readyz lists what is missing
+		log.Printf("Error: required indexes could not be built and need fixing:\n%v", err)
+	}
+	cancel()
 
-	// API routes
-	apiRouter := router.PathPrefix("/api").Subrouter()
+	// Convert amounts stored as floats to minor units
+	ctx, cancel = context.WithTimeout(context.Background(), 5*time.Minute)
+	if err := db.MigrateMoney(ctx); err != nil {
+		log.Fatalf("Failed to migrate amounts to minor units: %v", err)
+	}
+	cancel()
 
-	// Public routes
-	apiRouter.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {
-		handlers.RespondWithJSON(w, http.StatusOK, map[string]string{"status": "healthy"})
-	}).Methods("GET")
+	// Stores saved before numbers were normalised can't receive webhook messages
+	ctx, cancel = context.WithTimeout(context.Background(), time.Minute)
+	if err := handlers.IndexStoreNumbers(ctx, db); err != nil {
+		log.Printf("Warning: failed to normalise store WhatsApp numbers: %v", err)
+	}
+	cancel()
 
-	// Auth routes
-	apiRouter.HandleFunc("/auth/register", handlers.Register(db)).Methods("POST")
-	apiRouter.HandleFunc("/auth/login", handlers.Login(db)).Methods("POST")
+	// Jobs that were running when the server stopped will never finish
+	ctx, cancel = context.WithTimeout(context.Background(), 30*time.Second)
+	if err := handlers.FailInterruptedImports(ctx, db, 5*time.Minute); err != nil {
+		log.Printf("Warning: failed to mark interrupted imports: %v", err)
+	}
+	cancel()
+
+	// Purge the trash in the background
+	retentionDays := 30
+	if value := os.Getenv("TRASH_RETENTION_DAYS"); value != "" {
+		if days, err := strconv.Atoi(value); err == nil && days > 0 {
+			retentionDays = days
+		} else {
+			log.Printf("Warning: invalid TRASH_RETENTION_DAYS %q, using %d", value, retentionDays)
+		}
+	}
+	handlers.StartTrashPurge(context.Background(), db, time.Duration(retentionDays)*24*time.Hour, time.Hour)
 
-	// Store routes (public)
-	apiRouter.HandleFunc("/stores", handlers.GetAllStores(db)).Methods("GET")
-	apiRouter.HandleFunc("/stores/{id}", handlers.GetStore(db)).Methods("GET")
-	apiRouter.HandleFunc("/stores/{storeId}/products", handlers.GetStoreProducts(db)).Methods("GET")
-	apiRouter.HandleFunc("/products/{id}", handlers.GetProduct(db)).Methods("GET")
+	// Keep sitemaps up to date in the background
+	handlers.StartSitemapRefresh(context.Background(), db, 10*time.Minute)
 
-	// Protected routes
-	protectedRouter := apiRouter.PathPrefix("/").Subrouter()
-	protectedRouter.Use(handlers.AuthMiddleware)
+	// Feature products while they are on sale
+	handlers.StartSaleScheduler(context.Background(), db, time.Minute)
 
-	// Store routes (protected)
-	protectedRouter.HandleFunc("/my-store", handlers.GetMyStore(db)).Methods("GET")
-	protectedRouter.HandleFunc("/stores", handlers.CreateStore(db)).Methods("POST")
-	protectedRouter.HandleFunc("/stores/{id}", handlers.UpdateStore(db)).Methods("PUT")
-	protectedRouter.HandleFunc("/stores/{id}", handlers.DeleteStore(db)).Methods("DELETE")
+	// Send owner notifications in the background
+	notifiers := handlers.NotifiersFromEnv()
+	handlers.StartNotificationDelivery(context.Background(), db, notifiers, 30*time.Second)
 
-	// Product routes (protected)
-	protectedRouter.HandleFunc("/stores/{storeId}/products", handlers.CreateProduct(db)).Methods("POST")
-	protectedRouter.HandleFunc("/products/{id}", handlers.UpdateProduct(db)).Methods("PUT")
-	protectedRouter.HandleFunc("/products/{id}", handlers.DeleteProduct(db)).Methods("DELETE")
+	// Create router
+	router := newRouter(db, notifiers)
 
 	// CORS handler
 	c := cors.New(cors.Options{
 		AllowedOrigins:   []string{"*"},
 		AllowedMethods:   []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
-		AllowedHeaders:   []string{"Content-Type", "Authorization"},
+		AllowedHeaders:   []string{"Content-Type", "Authorization", "X-Request-ID"},
+		ExposedHeaders:   []string{"X-Request-ID"},
 		AllowCredentials: true,
 	})
 
 	// Create server
-	handler := c.Handler(router)
+	handler := c.Handler(handlers.RequestIDMiddleware(router))
 	srv := &http.Server{
 		Addr:    "0.0.0.0:" + port,
 		Handler: handler,
@@ -86,4 +106,4 @@ func main() {
 	// Start server
 	log.Printf("Server starting on port %s...\n", port)
 	log.Fatal(srv.ListenAndServe())
-}
\ No newline at end of file
+}
@@ -0,0 +1,98 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Analytics collections
+const (
+	// AnalyticsDailyCollection holds one counter document per store, product and day
+	AnalyticsDailyCollection = "analytics_daily"
+	// AnalyticsDedupeCollection remembers recent events per visitor so repeats
+	// within the deduplication window are not counted twice
+	AnalyticsDedupeCollection = "analytics_dedupe"
+)
+
+// AnalyticsDedupeSeconds is how long a repeated event from the same visitor is ignored
+const AnalyticsDedupeSeconds = 30 * 60
+
+// Storefront event types
+const (
+	EventStoreView     = "store_view"
+	EventProductView   = "product_view"
+	EventAddToCart     = "add_to_cart"
+	EventWhatsAppClick = "whatsapp_click"
+)
+
+// AnalyticsEventRequest is a storefront event reported by the browser
+type AnalyticsEventRequest struct {
+	Type      string `json:"type"`
+	ProductID string `json:"productId,omitempty"` // Required for product events
+}
+
+// AnalyticsCounts are the event totals of a rollup
+type AnalyticsCounts struct {
+	StoreViews     int64 `bson:"store_view" json:"storeViews"`
+	ProductViews   int64 `bson:"product_view" json:"productViews"`
+	AddToCart      int64 `bson:"add_to_cart" json:"addToCart"`
+	WhatsAppClicks int64 `bson:"whatsapp_click" json:"whatsappClicks"`
+}
+
+// Get returns the counter of an event type
+func (c AnalyticsCounts) Get(eventType string) int64 {
+	switch eventType {
+	case EventStoreView:
+		return c.StoreViews
+	case EventProductView:
+		return c.ProductViews
+	case EventAddToCart:
+		return c.AddToCart
+	case EventWhatsAppClick:
+		return c.WhatsAppClicks
+	}
+	return 0
+}
+
+// AnalyticsDaily is the rollup of a store's events on one UTC day. Product
+// events are counted on a document carrying the product ID; store-wide events
+// on one with the zero ID.
+type AnalyticsDaily struct {
+	ID        primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	StoreID   primitive.ObjectID `bson:"store_id" json:"storeId"`
+	ProductID primitive.ObjectID `bson:"product_id" json:"productId"`
+	Date      time.Time          `bson:"date" json:"date"`
+	Counts    AnalyticsCounts    `bson:"counts" json:"counts"`
+}
+
+// AnalyticsDay is a point of an analytics time series
+type AnalyticsDay struct {
+	Date   string          `json:"date"` // YYYY-MM-DD, UTC
+	Counts AnalyticsCounts `json:"counts"`
+}
+
+// AnalyticsTimeSeriesResponse lists daily totals for a date range
+type AnalyticsTimeSeriesResponse struct {
+	From      string          `json:"from"`
+	To        string          `json:"to"`
+	ProductID string          `json:"productId,omitempty"`
+	Days      []AnalyticsDay  `json:"days"`
+	Totals    AnalyticsCounts `json:"totals"`
+}
+
+// AnalyticsTopProduct is a product ranked by one of its counters
+type AnalyticsTopProduct struct {
+	ProductID primitive.ObjectID `json:"productId"`
+	Name      string             `json:"name"`
+	Value     int64              `json:"value"`
+	Counts    AnalyticsCounts    `json:"counts"`
+}
+
+// AnalyticsTopProductsResponse ranks a store's products for a date range
+type AnalyticsTopProductsResponse struct {
+	From     string                `json:"from"`
+	To       string                `json:"to"`
+	Metric   string                `json:"metric"`
+	Products []AnalyticsTopProduct `json:"products"`
+}
@@ -0,0 +1,70 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// AuditLogCollection is append-only: entries are inserted and never updated or deleted
+const AuditLogCollection = "audit_log"
+
+// Audit actions
+const (
+	AuditStoreCreate    = "store.create"
+	AuditStoreUpdate    = "store.update"
+	AuditStoreDelete    = "store.delete"
+	AuditStoreRestore   = "store.restore"
+	AuditProductCreate  = "product.create"
+	AuditProductUpdate  = "product.update"
+	AuditProductDelete  = "product.delete"
+	AuditProductRestore = "product.restore"
+	AuditProductImport  = "product.import"
+	AuditOrderStatus    = "order.status"
+	AuditCouponCreate   = "coupon.create"
+	AuditCouponUpdate   = "coupon.update"
+	AuditCouponDelete   = "coupon.delete"
+	AuditZoneCreate     = "delivery_zone.create"
+	AuditZoneUpdate     = "delivery_zone.update"
+	AuditZoneDelete     = "delivery_zone.delete"
+	AuditRatesUpload    = "courier_rates.upload"
+	AuditRatesDelete    = "courier_rates.delete"
+	AuditCustomerUpdate = "customer.update"
+	AuditInviteCreate   = "invite.create"
+	AuditInviteRevoke   = "invite.revoke"
+	AuditMemberJoin     = "member.join"
+	AuditMemberUpdate   = "member.update"
+	AuditMemberRemove   = "member.remove"
+	AuditReviewModerate = "review.moderate"
+	AuditReviewRemove   = "review.remove"
+)
+
+// FieldChange records the value of a field before and after a mutation
+type FieldChange struct {
+	Before interface{} `bson:"before" json:"before"`
+	After  interface{} `bson:"after" json:"after"`
+}
+
+// AuditEntry represents a single mutation in the audit log
+type AuditEntry struct {
+	ID            primitive.ObjectID     `bson:"_id" json:"id"`
+	ActorID       primitive.ObjectID     `bson:"actor_id" json:"actorId"`
+	ActorUsername string                 `bson:"actor_username" json:"actorUsername"`
+	Action        string                 `bson:"action" json:"action"`
+	TargetType    string                 `bson:"target_type" json:"targetType"` // e.g. "store", "product", "order", "coupon" or "customer"
+	TargetID      primitive.ObjectID     `bson:"target_id" json:"targetId"`
+	StoreID       primitive.ObjectID     `bson:"store_id" json:"storeId"`
+	Changes       map[string]FieldChange `bson:"changes" json:"changes"`
+	IP            string                 `bson:"ip" json:"ip"`
+	UserAgent     string                 `bson:"user_agent,omitempty" json:"userAgent,omitempty"`
+	RequestID     string                 `bson:"request_id,omitempty" json:"requestId,omitempty"`
+	CreatedAt     time.Time              `bson:"created_at" json:"createdAt"`
+}
+
+// AuditLogResponse represents a page of audit entries
+type AuditLogResponse struct {
+	Entries []AuditEntry `json:"entries"`
+	Total   int64        `json:"total"`
+	Page    int64        `json:"page"`
+	Limit   int64        `json:"limit"`
+}
@@ -0,0 +1,160 @@
+package models
+
+import (
+	"strconv"
+	"strings"
+)
+
+// Order charge types
+const (
+	ChargeServiceCharge = "service_charge"
+	ChargeTax           = "tax"
+)
+
+// TaxSettings configures the tax a store charges on orders, e.g. PPN or PB1
+type TaxSettings struct {
+	Enabled    bool     `bson:"enabled" json:"enabled"`
+	Name       string   `bson:"name,omitempty" json:"name,omitempty"`             // Shown on orders, defaults to "Tax"
+	Rate       float64  `bson:"rate" json:"rate"`                                 // Percent, e.g. 11
+	Inclusive  bool     `bson:"inclusive" json:"inclusive"`                       // Prices already include the tax
+	Categories []string `bson:"categories,omitempty" json:"categories,omitempty"` // Product categories taxed; empty taxes every product
+}
+
+// ServiceChargeSettings configures the service charge a store adds to orders
+type ServiceChargeSettings struct {
+	Enabled bool    `bson:"enabled" json:"enabled"`
+	Name    string  `bson:"name,omitempty" json:"name,omitempty"` // Shown on orders, defaults to "Service charge"
+	Rate    float64 `bson:"rate" json:"rate"`                     // Percent, e.g. 5
+	Taxable bool    `bson:"taxable" json:"taxable"`               // Tax is also charged on the service charge
+}
+
+// OrderCharge is a tax or service charge line of an order, worked out when
+// the order was placed
+type OrderCharge struct {
+	Type        string  `bson:"type" json:"type"` // "service_charge" or "tax"
+	Name        string  `bson:"name" json:"name"`
+	Rate        float64 `bson:"rate" json:"rate"`            // Percent
+	BaseMinor   int64   `bson:"base_minor" json:"baseMinor"` // Amount the rate was applied to
+	AmountMinor int64   `bson:"amount_minor" json:"amountMinor"`
+	Inclusive   bool    `bson:"inclusive,omitempty" json:"inclusive,omitempty"` // Already part of the prices, not added to the total
+
+	// Computed by Order.ApplyCurrency
+	FormattedAmount string `bson:"-" json:"formattedAmount"`
+}
+
+// Label names the charge the way orders show it, e.g. "PPN 11%"
+func (c OrderCharge) Label() string {
+	label := c.Name + " " + strconv.FormatFloat(c.Rate, 'f', -1, 64) + "%"
+	if c.Inclusive {
+		label += " (included)"
+	}
+	return label
+}
+
+// taxes reports whether the tax applies to products of category
+func (t TaxSettings) taxes(category string) bool {
+	if len(t.Categories) == 0 {
+		return true
+	}
+	for _, c := range t.Categories {
+		if strings.EqualFold(strings.TrimSpace(c), strings.TrimSpace(category)) {
+			return true
+		}
+	}
+	return false
+}
+
+// ApplyCharges works out the store's service charge and tax on the
+// discounted subtotal, records them as charge lines and sets the total,
+// delivery and shipping fees included. A discount is shared between taxed
+// and untaxed items in proportion to their subtotals. Fees are not taxed.
+//
+// With tax included in the prices, the service charge is worked out on the
+// prices without the tax, and tax on a taxable service charge is added as a
+// separate line, since the service charge itself never includes it.
+func (o *Order) ApplyCharges(store Store) {
+	o.Charges = nil
+	net := o.SubtotalMinor - o.DiscountMinor
+	o.TotalMinor = net
+	if o.Delivery != nil {
+		o.TotalMinor += o.Delivery.FeeMinor
+	}
+	if o.Shipping != nil {
+		o.TotalMinor += o.Shipping.FeeMinor
+	}
+
+	// The discounted subtotal of the taxed items, and the tax it already holds
+	t := store.Tax
+	taxing := t != nil && t.Enabled && t.Rate > 0 && net > 0
+	var taxed, included int64
+	if taxing {
+		for _, item := range o.Items {
+			if t.taxes(item.Category) {
+				taxed += item.SubtotalMinor
+			}
+		}
+		if o.SubtotalMinor > 0 && o.DiscountMinor > 0 {
+			taxed -= int64(float64(o.DiscountMinor)*float64(taxed)/float64(o.SubtotalMinor) + 0.5)
+		}
+		if t.Inclusive && taxed > 0 {
+			// taxed = without + without * rate / 100
+			included = taxed - int64(float64(taxed)*100/(100+t.Rate)+0.5)
+		}
+	}
+
+	var service int64
+	s := store.ServiceCharge
+	if s != nil && s.Enabled && s.Rate > 0 && net > 0 {
+		base := net - included
+		service = PercentOf(base, s.Rate)
+		o.Charges = append(o.Charges, OrderCharge{
+			Type:        ChargeServiceCharge,
+			Name:        chargeName(s.Name, "Service charge"),
+			Rate:        s.Rate,
+			BaseMinor:   base,
+			AmountMinor: service,
+		})
+		o.TotalMinor += service
+	}
+	if !taxing {
+		return
+	}
+
+	var added int64 // Amount the tax is added on
+	if t.Inclusive {
+		if included > 0 {
+			o.Charges = append(o.Charges, OrderCharge{
+				Type:        ChargeTax,
+				Name:        chargeName(t.Name, "Tax"),
+				Rate:        t.Rate,
+				BaseMinor:   taxed,
+				AmountMinor: included,
+				Inclusive:   true,
+			})
+		}
+	} else if taxed > 0 {
+		added = taxed
+	}
+	if service > 0 && s.Taxable {
+		added += service
+	}
+	if added > 0 {
+		charge := OrderCharge{
+			Type:        ChargeTax,
+			Name:        chargeName(t.Name, "Tax"),
+			Rate:        t.Rate,
+			BaseMinor:   added,
+			AmountMinor: PercentOf(added, t.Rate),
+		}
+		o.Charges = append(o.Charges, charge)
+		o.TotalMinor += charge.AmountMinor
+	}
+}
+
+// chargeName returns name, or fallback when the owner left it empty
+func chargeName(name, fallback string) string {
+	if name = strings.TrimSpace(name); name != "" {
+		return name
+	}
+	return fallback
+}
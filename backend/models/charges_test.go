@@ -0,0 +1,164 @@
+package models
+
+import (
+	"reflect"
+	"testing"
+)
+
+// TestApplyCharges checks service charge and tax lines and order totals
+func TestApplyCharges(t *testing.T) {
+	items := func(subtotals map[string]int64) []OrderItem {
+		var result []OrderItem
+		for _, category := range []string{"food", "drinks", "merch"} {
+			if subtotal, ok := subtotals[category]; ok {
+				result = append(result, OrderItem{Category: category, SubtotalMinor: subtotal})
+			}
+		}
+		return result
+	}
+	exclusive := &TaxSettings{Enabled: true, Name: "PPN", Rate: 10}
+	inclusive := &TaxSettings{Enabled: true, Name: "PPN", Rate: 10, Inclusive: true}
+	foodOnly := &TaxSettings{Enabled: true, Name: "PB1", Rate: 10, Categories: []string{" Food "}}
+	foodOnlyIncluded := &TaxSettings{Enabled: true, Name: "PB1", Rate: 10, Inclusive: true, Categories: []string{"food"}}
+	service := &ServiceChargeSettings{Enabled: true, Rate: 5}
+	taxableService := &ServiceChargeSettings{Enabled: true, Rate: 5, Taxable: true}
+
+	type line struct {
+		typ       string
+		base      int64
+		amount    int64
+		inclusive bool
+	}
+	tests := []struct {
+		name     string
+		store    Store
+		items    map[string]int64
+		discount int64
+		delivery int64
+		lines    []line
+		total    int64
+	}{
+		{
+			name:  "no charges",
+			items: map[string]int64{"food": 10000}, delivery: 2000,
+			total: 12000,
+		},
+		{
+			name:  "disabled tax",
+			store: Store{Tax: &TaxSettings{Rate: 10}},
+			items: map[string]int64{"food": 10000},
+			total: 10000,
+		},
+		{
+			name:  "exclusive tax, fees untaxed",
+			store: Store{Tax: exclusive},
+			items: map[string]int64{"food": 10000}, delivery: 2000,
+			lines: []line{{ChargeTax, 10000, 1000, false}},
+			total: 13000,
+		},
+		{
+			name:  "exclusive tax on a category",
+			store: Store{Tax: foodOnly},
+			items: map[string]int64{"food": 6000, "drinks": 4000},
+			lines: []line{{ChargeTax, 6000, 600, false}},
+			total: 10600,
+		},
+		{
+			name:  "discount shared with untaxed items",
+			store: Store{Tax: foodOnly},
+			items: map[string]int64{"food": 6000, "drinks": 4000}, discount: 1000,
+			lines: []line{{ChargeTax, 5400, 540, false}},
+			total: 9540,
+		},
+		{
+			name:  "only untaxed items",
+			store: Store{Tax: foodOnly},
+			items: map[string]int64{"drinks": 4000},
+			total: 4000,
+		},
+		{
+			name:  "inclusive tax",
+			store: Store{Tax: inclusive},
+			items: map[string]int64{"food": 11000},
+			lines: []line{{ChargeTax, 11000, 1000, true}},
+			total: 11000,
+		},
+		{
+			name:  "inclusive tax with a discount",
+			store: Store{Tax: inclusive},
+			items: map[string]int64{"food": 12000}, discount: 1000,
+			lines: []line{{ChargeTax, 11000, 1000, true}},
+			total: 11000,
+		},
+		{
+			name:  "exclusive tax with service charge",
+			store: Store{Tax: exclusive, ServiceCharge: service},
+			items: map[string]int64{"food": 10000},
+			lines: []line{{ChargeServiceCharge, 10000, 500, false}, {ChargeTax, 10000, 1000, false}},
+			total: 11500,
+		},
+		{
+			name:  "exclusive tax with taxable service charge",
+			store: Store{Tax: exclusive, ServiceCharge: taxableService},
+			items: map[string]int64{"food": 10000},
+			lines: []line{{ChargeServiceCharge, 10000, 500, false}, {ChargeTax, 10500, 1050, false}},
+			total: 11550,
+		},
+		{
+			name:  "inclusive tax with service charge on prices without tax",
+			store: Store{Tax: inclusive, ServiceCharge: service},
+			items: map[string]int64{"food": 11000},
+			lines: []line{{ChargeServiceCharge, 10000, 500, false}, {ChargeTax, 11000, 1000, true}},
+			total: 11500,
+		},
+		{
+			name:  "inclusive tax added on a taxable service charge",
+			store: Store{Tax: inclusive, ServiceCharge: taxableService},
+			items: map[string]int64{"food": 11000},
+			lines: []line{{ChargeServiceCharge, 10000, 500, false}, {ChargeTax, 11000, 1000, true}, {ChargeTax, 500, 50, false}},
+			total: 11550,
+		},
+		{
+			name:  "inclusive tax on a category with taxable service charge",
+			store: Store{Tax: foodOnlyIncluded, ServiceCharge: taxableService},
+			items: map[string]int64{"food": 11000, "drinks": 5000},
+			lines: []line{{ChargeServiceCharge, 15000, 750, false}, {ChargeTax, 11000, 1000, true}, {ChargeTax, 750, 75, false}},
+			total: 16825,
+		},
+		{
+			name:  "service charge without tax",
+			store: Store{ServiceCharge: taxableService},
+			items: map[string]int64{"food": 10000}, discount: 2000,
+			lines: []line{{ChargeServiceCharge, 8000, 400, false}},
+			total: 8400,
+		},
+		{
+			name:  "fully discounted",
+			store: Store{Tax: exclusive, ServiceCharge: taxableService},
+			items: map[string]int64{"food": 10000}, discount: 10000, delivery: 2000,
+			total: 2000,
+		},
+	}
+
+	for _, tt := range tests {
+		order := Order{Items: items(tt.items), DiscountMinor: tt.discount}
+		for _, item := range order.Items {
+			order.SubtotalMinor += item.SubtotalMinor
+		}
+		if tt.delivery > 0 {
+			order.Delivery = &OrderDelivery{FeeMinor: tt.delivery}
+		}
+		order.ApplyCharges(tt.store)
+
+		var lines []line
+		for _, charge := range order.Charges {
+			lines = append(lines, line{charge.Type, charge.BaseMinor, charge.AmountMinor, charge.Inclusive})
+		}
+		if !reflect.DeepEqual(lines, tt.lines) {
+			t.Errorf("%s: got charges %v, want %v", tt.name, lines, tt.lines)
+		}
+		if order.TotalMinor != tt.total {
+			t.Errorf("%s: got total %d, want %d", tt.name, order.TotalMinor, tt.total)
+		}
+	}
+}
@@ -0,0 +1,107 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Coupon collections
+const (
+	CouponCollection = "coupons"
+	// CouponRedemptionCollection counts redemptions per coupon and customer phone
+	CouponRedemptionCollection = "coupon_redemptions"
+)
+
+// Coupon discount types
+const (
+	CouponPercentage = "percentage"
+	CouponFixed      = "fixed"
+)
+
+// Coupon is a store's discount code. Amounts are in minor units of the
+// store's currency.
+type Coupon struct {
+	ID               primitive.ObjectID   `bson:"_id" json:"id"`
+	StoreID          primitive.ObjectID   `bson:"store_id" json:"storeId"`
+	Code             string               `bson:"code" json:"code"` // Stored upper-case; codes are case-insensitive
+	Description      string               `bson:"description,omitempty" json:"description,omitempty"`
+	Type             string               `bson:"type" json:"type"`
+	Percent          float64              `bson:"percent,omitempty" json:"percent,omitempty"`                     // Percent off for percentage coupons
+	AmountMinor      int64                `bson:"amount_minor,omitempty" json:"amountMinor,omitempty"`            // Amount off for fixed coupons
+	MaxDiscountMinor int64                `bson:"max_discount_minor,omitempty" json:"maxDiscountMinor,omitempty"` // Caps percentage discounts; 0 means no cap
+	MinOrderMinor    int64                `bson:"min_order_minor,omitempty" json:"minOrderMinor,omitempty"`       // Minimum order subtotal
+	StartsAt         *time.Time           `bson:"starts_at,omitempty" json:"startsAt,omitempty"`
+	EndsAt           *time.Time           `bson:"ends_at,omitempty" json:"endsAt,omitempty"`
+	UsageLimit       int                  `bson:"usage_limit" json:"usageLimit"`              // 0 means unlimited
+	PerCustomerLimit int                  `bson:"per_customer_limit" json:"perCustomerLimit"` // Per customer phone; 0 means unlimited
+	UsedCount        int                  `bson:"used_count" json:"usedCount"`
+	ProductIDs       []primitive.ObjectID `bson:"product_ids,omitempty" json:"productIds,omitempty"` // Limits the discount to these products
+	Categories       []string             `bson:"categories,omitempty" json:"categories,omitempty"`  // and/or to these categories
+	Active           bool                 `bson:"active" json:"active"`
+	CreatedAt        time.Time            `bson:"created_at" json:"createdAt"`
+	UpdatedAt        time.Time            `bson:"updated_at" json:"updatedAt"`
+
+	// Computed by ApplyCurrency
+	Currency string `bson:"-" json:"currency"`
+
+	// Deprecated: major-unit amounts for clients of the float API, kept for
+	// the compatibility period. Value is the percent or the fixed amount.
+	LegacyValue       float64 `bson:"-" json:"value" deprecated:"true"`
+	LegacyMaxDiscount float64 `bson:"-" json:"maxDiscount,omitempty" deprecated:"true"`
+	LegacyMinOrder    float64 `bson:"-" json:"minOrder,omitempty" deprecated:"true"`
+}
+
+// ApplyCurrency fills in the currency and the deprecated major-unit amounts
+func (c *Coupon) ApplyCurrency(currency Currency) {
+	c.Currency = currency.Code
+	c.LegacyValue = c.Percent
+	if c.Type == CouponFixed {
+		c.LegacyValue = currency.Major(c.AmountMinor)
+	}
+	c.LegacyMaxDiscount = currency.Major(c.MaxDiscountMinor)
+	c.LegacyMinOrder = currency.Major(c.MinOrderMinor)
+}
+
+// CouponRequest represents the request body for creating or replacing a
+// coupon. Amounts are in minor units of the store's currency.
+type CouponRequest struct {
+	Code             string     `json:"code"`
+	Description      string     `json:"description,omitempty"`
+	Type             string     `json:"type"`
+	Percent          float64    `json:"percent,omitempty"`     // For percentage coupons
+	AmountMinor      int64      `json:"amountMinor,omitempty"` // For fixed coupons
+	MaxDiscountMinor int64      `json:"maxDiscountMinor,omitempty"`
+	MinOrderMinor    int64      `json:"minOrderMinor,omitempty"`
+	StartsAt         *time.Time `json:"startsAt,omitempty"`
+	EndsAt           *time.Time `json:"endsAt,omitempty"`
+	UsageLimit       int        `json:"usageLimit,omitempty"`
+	PerCustomerLimit int        `json:"perCustomerLimit,omitempty"`
+	ProductIDs       []string   `json:"productIds,omitempty"`
+	Categories       []string   `json:"categories,omitempty"`
+	Active           *bool      `json:"active,omitempty"`
+
+	// Deprecated: value is the percent or the major-unit fixed amount; the
+	// others are major-unit amounts. Used when the new fields are zero.
+	Value       *float64 `json:"value,omitempty" deprecated:"true"`
+	MaxDiscount *float64 `json:"maxDiscount,omitempty" deprecated:"true"`
+	MinOrder    *float64 `json:"minOrder,omitempty" deprecated:"true"`
+}
+
+// ResolveAmounts converts deprecated major-unit amounts to minor units
+func (r *CouponRequest) ResolveAmounts(currency Currency) {
+	if r.Value != nil {
+		switch {
+		case r.Type == CouponPercentage && r.Percent == 0:
+			r.Percent = *r.Value
+		case r.Type == CouponFixed && r.AmountMinor == 0:
+			r.AmountMinor = currency.FromMajor(*r.Value)
+		}
+	}
+	if r.MaxDiscount != nil && r.MaxDiscountMinor == 0 {
+		r.MaxDiscountMinor = currency.FromMajor(*r.MaxDiscount)
+	}
+	if r.MinOrder != nil && r.MinOrderMinor == 0 {
+		r.MinOrderMinor = currency.FromMajor(*r.MinOrder)
+	}
+}
@@ -0,0 +1,72 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// CustomerCollection holds the customers of each store, created at checkout
+const CustomerCollection = "customers"
+
+// Customers keep at most this many saved addresses, most recently used first
+const MaxCustomerAddresses = 10
+
+// Customer is a store's record of someone who ordered from it, keyed by the
+// digits of their phone number
+type Customer struct {
+	ID          primitive.ObjectID `bson:"_id" json:"id"`
+	StoreID     primitive.ObjectID `bson:"store_id" json:"storeId"`
+	Phone       string             `bson:"phone" json:"phone"` // Digits with the country code, as in wa.me links
+	Name        string             `bson:"name,omitempty" json:"name,omitempty"`
+	Addresses   []CustomerAddress  `bson:"addresses,omitempty" json:"addresses,omitempty"`
+	Notes       string             `bson:"notes,omitempty" json:"notes,omitempty"` // Written by the owner
+	Tags        []string           `bson:"tags,omitempty" json:"tags,omitempty"`   // Written by the owner
+	OrderCount  int                `bson:"order_count" json:"orderCount"`
+	LastOrderAt time.Time          `bson:"last_order_at" json:"lastOrderAt"`
+	CreatedAt   time.Time          `bson:"created_at" json:"createdAt"`
+	UpdatedAt   time.Time          `bson:"updated_at" json:"updatedAt"`
+
+	// Computed from the customer's confirmed and completed orders
+	LifetimeValueMinor     int64  `bson:"-" json:"lifetimeValueMinor"`
+	FormattedLifetimeValue string `bson:"-" json:"formattedLifetimeValue"`
+}
+
+// CustomerAddress is an address a customer had an order delivered or shipped to
+type CustomerAddress struct {
+	Address     string    `bson:"address" json:"address"`
+	Location    *GeoPoint `bson:"location,omitempty" json:"location,omitempty"`       // Delivery orders
+	Destination string    `bson:"destination,omitempty" json:"destination,omitempty"` // Shipping orders
+	LastUsedAt  time.Time `bson:"last_used_at" json:"lastUsedAt"`
+}
+
+// SameAs reports whether two saved addresses are the same place
+func (a CustomerAddress) SameAs(other CustomerAddress) bool {
+	return a.Address == other.Address && a.Destination == other.Destination
+}
+
+// ApplyCurrency fills in the formatted lifetime value
+func (c *Customer) ApplyCurrency(currency Currency) {
+	c.FormattedLifetimeValue = currency.Format(c.LifetimeValueMinor)
+}
+
+// UpdateCustomerRequest represents the request body for annotating a customer
+type UpdateCustomerRequest struct {
+	Name  *string   `json:"name,omitempty"`
+	Notes *string   `json:"notes,omitempty"`
+	Tags  *[]string `json:"tags,omitempty"`
+}
+
+// CustomerListResponse is a page of a store's customers
+type CustomerListResponse struct {
+	Customers []Customer `json:"customers"`
+	Total     int64      `json:"total"`
+	Page      int64      `json:"page"`
+	Limit     int64      `json:"limit"`
+}
+
+// CustomerDetail is a customer with their order history, newest first
+type CustomerDetail struct {
+	Customer Customer `json:"customer"`
+	Orders   []Order  `json:"orders"`
+}
@@ -66,4 +66,9 @@ func (d *Database) Close() error {
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 	return d.client.Disconnect(ctx)
-}
\ No newline at end of file
+}
+
+// Ping checks that the primary is reachable
+func (d *Database) Ping(ctx context.Context) error {
+	return d.client.Ping(ctx, readpref.Primary())
+}
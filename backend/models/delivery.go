@@ -0,0 +1,130 @@
+package models
+
+import (
+	"strconv"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// DeliveryZoneCollection holds the areas stores deliver to
+const DeliveryZoneCollection = "delivery_zones"
+
+// Delivery zone types
+const (
+	ZoneRadius  = "radius"  // A distance band around the store's coordinates
+	ZonePolygon = "polygon" // An area drawn on the map
+)
+
+// GeoPoint is a GeoJSON point. Coordinates are longitude then latitude.
+type GeoPoint struct {
+	Type        string     `bson:"type" json:"type"` // Always "Point"
+	Coordinates [2]float64 `bson:"coordinates" json:"coordinates"`
+}
+
+// NewGeoPoint returns the GeoJSON point at a latitude and longitude
+func NewGeoPoint(latitude, longitude float64) GeoPoint {
+	return GeoPoint{Type: "Point", Coordinates: [2]float64{longitude, latitude}}
+}
+
+// GeoPolygon is a GeoJSON polygon: closed rings of longitude, latitude
+// positions, the outer boundary first and any holes after it
+type GeoPolygon struct {
+	Type        string         `bson:"type" json:"type"` // Always "Polygon"
+	Coordinates [][][2]float64 `bson:"coordinates" json:"coordinates"`
+}
+
+// DeliveryZone is an area a store delivers to, with its fee. Radius zones
+// are bands of distance from the store, so 0-3 km and 3-7 km zones can
+// charge different fees. Amounts are in minor units of the store's currency.
+type DeliveryZone struct {
+	ID                primitive.ObjectID `bson:"_id" json:"id"`
+	StoreID           primitive.ObjectID `bson:"store_id" json:"storeId"`
+	Name              string             `bson:"name" json:"name"`
+	Type              string             `bson:"type" json:"type"`
+	MinDistanceMeters float64            `bson:"min_distance_m,omitempty" json:"minDistanceMeters,omitempty"` // Inner edge of a radius band
+	MaxDistanceMeters float64            `bson:"max_distance_m,omitempty" json:"maxDistanceMeters,omitempty"` // Outer edge of a radius band
+	Area              *GeoPolygon        `bson:"area,omitempty" json:"area,omitempty"`                        // Polygon zones only
+	FeeMinor          int64              `bson:"fee_minor" json:"feeMinor"`
+	MinOrderMinor     int64              `bson:"min_order_minor,omitempty" json:"minOrderMinor,omitempty"` // Minimum discounted subtotal
+	Active            bool               `bson:"active" json:"active"`
+	CreatedAt         time.Time          `bson:"created_at" json:"createdAt"`
+	UpdatedAt         time.Time          `bson:"updated_at" json:"updatedAt"`
+
+	// Computed by ApplyCurrency
+	Currency          string `bson:"-" json:"currency"`
+	FormattedFee      string `bson:"-" json:"formattedFee"`
+	FormattedMinOrder string `bson:"-" json:"formattedMinOrder,omitempty"`
+}
+
+// ApplyCurrency fills in the currency and formatted amounts of the zone
+func (z *DeliveryZone) ApplyCurrency(currency Currency) {
+	z.Currency = currency.Code
+	z.FormattedFee = currency.Format(z.FeeMinor)
+	z.FormattedMinOrder = ""
+	if z.MinOrderMinor > 0 {
+		z.FormattedMinOrder = currency.Format(z.MinOrderMinor)
+	}
+}
+
+// DeliveryZoneRequest represents the request body for creating or replacing
+// a delivery zone
+type DeliveryZoneRequest struct {
+	Name              string      `json:"name"`
+	Type              string      `json:"type"`
+	MinDistanceMeters float64     `json:"minDistanceMeters,omitempty"` // For radius zones
+	MaxDistanceMeters float64     `json:"maxDistanceMeters,omitempty"` // For radius zones
+	Area              *GeoPolygon `json:"area,omitempty"`              // For polygon zones
+	FeeMinor          int64       `json:"feeMinor"`
+	MinOrderMinor     int64       `json:"minOrderMinor,omitempty"`
+	Active            *bool       `json:"active,omitempty"` // Defaults to true
+}
+
+// DeliveryLocation is where a customer wants an order delivered
+type DeliveryLocation struct {
+	Latitude  float64 `json:"latitude"`
+	Longitude float64 `json:"longitude"`
+	Address   string  `json:"address,omitempty"` // Free text for the courier
+}
+
+// DeliveryQuoteRequest represents the request body for a delivery quote
+type DeliveryQuoteRequest struct {
+	Latitude      float64 `json:"latitude"`
+	Longitude     float64 `json:"longitude"`
+	SubtotalMinor *int64  `json:"subtotalMinor,omitempty"` // Checked against minimum orders when given
+}
+
+// DeliveryQuote says whether a store delivers to a location and for how much
+type DeliveryQuote struct {
+	Available      bool               `json:"available"`
+	Reason         string             `json:"reason,omitempty"` // Why delivery is not available
+	ZoneID         primitive.ObjectID `json:"zoneId,omitempty"`
+	ZoneName       string             `json:"zoneName,omitempty"`
+	DistanceMeters float64            `json:"distanceMeters,omitempty"` // From the store, when it has coordinates
+	FeeMinor       int64              `json:"feeMinor"`
+	MinOrderMinor  int64              `json:"minOrderMinor,omitempty"`
+	Currency       string             `json:"currency"`
+
+	FormattedFee      string `json:"formattedFee,omitempty"`
+	FormattedMinOrder string `json:"formattedMinOrder,omitempty"`
+}
+
+// OrderDelivery records where an order is delivered and what it cost
+type OrderDelivery struct {
+	ZoneID         primitive.ObjectID `bson:"zone_id" json:"zoneId"`
+	ZoneName       string             `bson:"zone_name" json:"zoneName"`
+	Location       GeoPoint           `bson:"location" json:"location"`
+	Address        string             `bson:"address,omitempty" json:"address,omitempty"`
+	DistanceMeters float64            `bson:"distance_m,omitempty" json:"distanceMeters,omitempty"`
+	FeeMinor       int64              `bson:"fee_minor" json:"feeMinor"`
+
+	// Computed by Order.ApplyCurrency
+	FormattedFee string `bson:"-" json:"formattedFee"`
+}
+
+// MapsURL links to the delivery location on Google Maps
+func (d OrderDelivery) MapsURL() string {
+	return "https://maps.google.com/?q=" +
+		strconv.FormatFloat(d.Location.Coordinates[1], 'f', 6, 64) + "," +
+		strconv.FormatFloat(d.Location.Coordinates[0], 'f', 6, 64)
+}
@@ -0,0 +1,19 @@
+package models
+
+import "go.mongodb.org/mongo-driver/bson/primitive"
+
+// FeedIssue describes why a product was left out of a product feed
+type FeedIssue struct {
+	ProductID   primitive.ObjectID `json:"productId"`
+	ProductName string             `json:"productName"`
+	Field       string             `json:"field"`
+	Message     string             `json:"message"`
+}
+
+// FeedValidationReport summarizes which products a feed can include
+type FeedValidationReport struct {
+	Feed          string      `json:"feed"`
+	TotalProducts int         `json:"totalProducts"`
+	ValidProducts int         `json:"validProducts"`
+	Issues        []FeedIssue `json:"issues"`
+}
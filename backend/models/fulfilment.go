@@ -0,0 +1,213 @@
+package models
+
+import (
+	"strconv"
+	"strings"
+	"time"
+	_ "time/tzdata" // Pickup slots are checked in the store's time zone
+)
+
+// Fulfilment methods customers choose from at checkout
+const (
+	FulfilmentPickup   = "pickup"
+	FulfilmentDelivery = "delivery" // Local delivery within the store's delivery zones
+	FulfilmentShipping = "shipping" // Courier shipping priced from the store's rate tables
+	FulfilmentDineIn   = "dine_in"
+)
+
+// DefaultTimezone is the time zone of stores that have not chosen one
+const DefaultTimezone = "Asia/Jakarta"
+
+// Pickup slots can be booked this many days ahead unless the store says otherwise
+const defaultPickupDaysAhead = 7
+
+// FulfilmentSettings are the ways a store hands orders over
+type FulfilmentSettings struct {
+	Pickup   PickupSettings `bson:"pickup" json:"pickup"`
+	Delivery bool           `bson:"delivery" json:"delivery"`
+	Shipping bool           `bson:"shipping" json:"shipping"`
+	DineIn   bool           `bson:"dine_in" json:"dineIn"`
+}
+
+// PickupSettings configure where and when customers collect their orders
+type PickupSettings struct {
+	Enabled   bool             `bson:"enabled" json:"enabled"`
+	Locations []PickupLocation `bson:"locations,omitempty" json:"locations,omitempty"`  // Empty means the store's own location
+	Slots     []PickupSlot     `bson:"slots,omitempty" json:"slots,omitempty"`          // Empty means pickup is arranged over WhatsApp
+	DaysAhead int              `bson:"days_ahead,omitempty" json:"daysAhead,omitempty"` // How far ahead slots can be booked, defaults to 7
+	Timezone  string           `bson:"timezone,omitempty" json:"timezone,omitempty"`    // IANA zone of the slots, defaults to Asia/Jakarta
+}
+
+// PickupLocation is an address customers can collect orders from
+type PickupLocation struct {
+	ID      string `bson:"id" json:"id"` // Assigned when the settings are saved
+	Name    string `bson:"name" json:"name"`
+	Address string `bson:"address" json:"address"`
+}
+
+// PickupSlot is a window of the day in which orders can be collected
+type PickupSlot struct {
+	Days  []int  `bson:"days,omitempty" json:"days,omitempty"` // Weekdays, 0 is Sunday; empty means every day
+	Start string `bson:"start" json:"start"`                   // "HH:MM"
+	End   string `bson:"end" json:"end"`                       // "HH:MM"
+}
+
+// Label names the slot the way customers choose it, e.g. "09:00-12:00"
+func (s PickupSlot) Label() string {
+	return s.Start + "-" + s.End
+}
+
+// opensOn reports whether the slot is offered on a weekday
+func (s PickupSlot) opensOn(day time.Weekday) bool {
+	if len(s.Days) == 0 {
+		return true
+	}
+	for _, d := range s.Days {
+		if time.Weekday(d) == day {
+			return true
+		}
+	}
+	return false
+}
+
+// Location returns the time zone of the pickup slots
+func (p PickupSettings) Location() *time.Location {
+	name := p.Timezone
+	if name == "" {
+		name = DefaultTimezone
+	}
+	if location, err := time.LoadLocation(name); err == nil {
+		return location
+	}
+	return time.UTC
+}
+
+// FulfilmentSettings returns the store's settings, or the defaults of stores
+// that have not configured any: pickup at the store and local delivery
+func (s Store) FulfilmentSettings() FulfilmentSettings {
+	if s.Fulfilment != nil {
+		return *s.Fulfilment
+	}
+	return FulfilmentSettings{Pickup: PickupSettings{Enabled: true}, Delivery: true}
+}
+
+// Methods lists the fulfilment methods the settings allow
+func (f FulfilmentSettings) Methods() []string {
+	var methods []string
+	if f.Pickup.Enabled {
+		methods = append(methods, FulfilmentPickup)
+	}
+	if f.Delivery {
+		methods = append(methods, FulfilmentDelivery)
+	}
+	if f.Shipping {
+		methods = append(methods, FulfilmentShipping)
+	}
+	if f.DineIn {
+		methods = append(methods, FulfilmentDineIn)
+	}
+	return methods
+}
+
+// Allows reports whether customers may choose a method
+func (f FulfilmentSettings) Allows(method string) bool {
+	for _, m := range f.Methods() {
+		if m == method {
+			return true
+		}
+	}
+	return false
+}
+
+// FulfilmentChoice is how a customer wants their order handed over
+type FulfilmentChoice struct {
+	Method           string `json:"method"`                     // pickup, delivery, shipping or dine_in
+	PickupLocationID string `json:"pickupLocationId,omitempty"` // Required when the store has several locations
+	PickupDate       string `json:"pickupDate,omitempty"`       // YYYY-MM-DD, required when the store has slots
+	PickupSlot       string `json:"pickupSlot,omitempty"`       // Slot label such as "09:00-12:00"
+	TableNumber      string `json:"tableNumber,omitempty"`      // Dine-in only
+}
+
+// OrderFulfilment records how an order is handed over. Delivery and shipping
+// details are kept in Order.Delivery and Order.Shipping.
+type OrderFulfilment struct {
+	Method         string          `bson:"method" json:"method"`
+	PickupLocation *PickupLocation `bson:"pickup_location,omitempty" json:"pickupLocation,omitempty"`
+	PickupDate     string          `bson:"pickup_date,omitempty" json:"pickupDate,omitempty"`
+	PickupSlot     string          `bson:"pickup_slot,omitempty" json:"pickupSlot,omitempty"`
+	TableNumber    string          `bson:"table_number,omitempty" json:"tableNumber,omitempty"`
+}
+
+// ResolvePickup checks a pickup choice against the settings at now and
+// returns the fulfilment to record
+func (p PickupSettings) ResolvePickup(choice FulfilmentChoice, store Store, now time.Time) (OrderFulfilment, []FieldError) {
+	fulfilment := OrderFulfilment{Method: FulfilmentPickup}
+
+	// Location
+	switch {
+	case len(p.Locations) == 0:
+		fulfilment.PickupLocation = &PickupLocation{Name: store.Name, Address: store.Location}
+	case len(p.Locations) == 1 && choice.PickupLocationID == "":
+		fulfilment.PickupLocation = &p.Locations[0]
+	default:
+		for i := range p.Locations {
+			if p.Locations[i].ID == choice.PickupLocationID {
+				fulfilment.PickupLocation = &p.Locations[i]
+			}
+		}
+		if fulfilment.PickupLocation == nil {
+			return fulfilment, []FieldError{{Field: "fulfilment.pickupLocationId", Message: "must be one of the store's pickup locations"}}
+		}
+	}
+
+	// Time slot
+	if len(p.Slots) == 0 {
+		return fulfilment, nil
+	}
+	location := p.Location()
+	date, err := time.ParseInLocation("2006-01-02", choice.PickupDate, location)
+	if err != nil {
+		return fulfilment, []FieldError{{Field: "fulfilment.pickupDate", Message: "must be a date such as 2024-01-31"}}
+	}
+	daysAhead := p.DaysAhead
+	if daysAhead <= 0 {
+		daysAhead = defaultPickupDaysAhead
+	}
+	local := now.In(location)
+	today := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, location)
+	if date.Before(today) || date.After(today.AddDate(0, 0, daysAhead)) {
+		return fulfilment, []FieldError{{Field: "fulfilment.pickupDate", Message: "must be within the next " + strconv.Itoa(daysAhead) + " days"}}
+	}
+
+	// Several slots may share a label on different days, so look for one
+	// that opens on the chosen day before rejecting the choice
+	labelled := false
+	for _, slot := range p.Slots {
+		if slot.Label() != choice.PickupSlot {
+			continue
+		}
+		labelled = true
+		if !slot.opensOn(date.Weekday()) {
+			continue
+		}
+		end, _ := time.ParseInLocation("2006-01-02 15:04", choice.PickupDate+" "+slot.End, location)
+		if !end.After(now) {
+			return fulfilment, []FieldError{{Field: "fulfilment.pickupSlot", Message: "has already passed"}}
+		}
+		fulfilment.PickupDate = choice.PickupDate
+		fulfilment.PickupSlot = slot.Label()
+		return fulfilment, nil
+	}
+	if labelled {
+		return fulfilment, []FieldError{{Field: "fulfilment.pickupSlot", Message: "is not offered on " + date.Weekday().String()}}
+	}
+	var labels []string
+	seen := map[string]bool{}
+	for _, slot := range p.Slots {
+		if !seen[slot.Label()] {
+			seen[slot.Label()] = true
+			labels = append(labels, slot.Label())
+		}
+	}
+	return fulfilment, []FieldError{{Field: "fulfilment.pickupSlot", Message: "must be one of " + strings.Join(labels, ", ")}}
+}
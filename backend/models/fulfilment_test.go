@@ -0,0 +1,54 @@
+package models
+
+import (
+	"testing"
+	"time"
+)
+
+// TestResolvePickupSlot checks pickup slots against the chosen day and time
+func TestResolvePickupSlot(t *testing.T) {
+	settings := PickupSettings{
+		Enabled: true,
+		Slots: []PickupSlot{
+			{Days: []int{1, 2, 3, 4, 5}, Start: "09:00", End: "12:00"},
+			{Days: []int{6}, Start: "09:00", End: "12:00"},
+			{Days: []int{6}, Start: "13:00", End: "17:00"},
+		},
+	}
+	jakarta := settings.Location()
+	// Friday 10 October 2025, 10:00 in Jakarta
+	now := time.Date(2025, 10, 10, 10, 0, 0, 0, jakarta)
+
+	tests := []struct {
+		date, slot string
+		wantErr    string
+	}{
+		{date: "2025-10-10", slot: "09:00-12:00"},
+		{date: "2025-10-11", slot: "09:00-12:00"}, // Saturday, offered by the second slot
+		{date: "2025-10-11", slot: "13:00-17:00"},
+		{date: "2025-10-13", slot: "09:00-12:00"},
+		{date: "2025-10-12", slot: "09:00-12:00", wantErr: "is not offered on Sunday"},
+		{date: "2025-10-10", slot: "13:00-17:00", wantErr: "is not offered on Friday"},
+		{date: "2025-10-10", slot: "18:00-20:00", wantErr: "must be one of 09:00-12:00, 13:00-17:00"},
+		{date: "2025-10-09", slot: "09:00-12:00", wantErr: "must be within the next 7 days"},
+		{date: "10/11/2025", slot: "09:00-12:00", wantErr: "must be a date such as 2024-01-31"},
+	}
+
+	for _, tt := range tests {
+		fulfilment, errs := settings.ResolvePickup(FulfilmentChoice{Method: FulfilmentPickup, PickupDate: tt.date, PickupSlot: tt.slot}, Store{Name: "Kopi"}, now)
+		switch {
+		case tt.wantErr == "" && len(errs) > 0:
+			t.Errorf("%s %s: unexpected errors %v", tt.date, tt.slot, errs)
+		case tt.wantErr == "" && (fulfilment.PickupDate != tt.date || fulfilment.PickupSlot != tt.slot):
+			t.Errorf("%s %s: got pickup %s %s", tt.date, tt.slot, fulfilment.PickupDate, fulfilment.PickupSlot)
+		case tt.wantErr != "" && (len(errs) != 1 || errs[0].Message != tt.wantErr):
+			t.Errorf("%s %s: got errors %v, want %q", tt.date, tt.slot, errs, tt.wantErr)
+		}
+	}
+
+	// A slot that ended earlier today has passed
+	late := time.Date(2025, 10, 10, 12, 30, 0, 0, jakarta)
+	if _, errs := settings.ResolvePickup(FulfilmentChoice{PickupDate: "2025-10-10", PickupSlot: "09:00-12:00"}, Store{}, late); len(errs) != 1 || errs[0].Message != "has already passed" {
+		t.Errorf("got errors %v for a slot that has passed", errs)
+	}
+}
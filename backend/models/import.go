@@ -0,0 +1,50 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// ImportJobCollection holds the progress of background product imports
+const ImportJobCollection = "import_jobs"
+
+// Import job statuses
+const (
+	ImportJobPending   = "pending"
+	ImportJobRunning   = "running"
+	ImportJobCompleted = "completed"
+	ImportJobFailed    = "failed"
+)
+
+// ImportRowError describes why a CSV row was rejected
+type ImportRowError struct {
+	Row     int    `bson:"row" json:"row"` // 1-based line number in the file, header included
+	Field   string `bson:"field,omitempty" json:"field,omitempty"`
+	Message string `bson:"message" json:"message"`
+}
+
+// ImportReport summarizes a product CSV import
+type ImportReport struct {
+	DryRun    bool             `bson:"dry_run" json:"dryRun"`
+	TotalRows int              `bson:"total_rows" json:"totalRows"`
+	ValidRows int              `bson:"valid_rows" json:"validRows"`
+	Created   int              `bson:"created" json:"created"`
+	Updated   int              `bson:"updated" json:"updated"`
+	Errors    []ImportRowError `bson:"errors" json:"errors"`
+}
+
+// ImportJob tracks a product import that runs in the background
+type ImportJob struct {
+	ID            primitive.ObjectID `bson:"_id" json:"id"`
+	StoreID       primitive.ObjectID `bson:"store_id" json:"storeId"`
+	UserID        primitive.ObjectID `bson:"user_id" json:"userId"`
+	Status        string             `bson:"status" json:"status"`
+	TotalRows     int                `bson:"total_rows" json:"totalRows"`
+	ProcessedRows int                `bson:"processed_rows" json:"processedRows"`
+	Report        *ImportReport      `bson:"report,omitempty" json:"report,omitempty"`
+	Error         string             `bson:"error,omitempty" json:"error,omitempty"`
+	CreatedAt     time.Time          `bson:"created_at" json:"createdAt"`
+	UpdatedAt     time.Time          `bson:"updated_at" json:"updatedAt"`
+	FinishedAt    *time.Time         `bson:"finished_at,omitempty" json:"finishedAt,omitempty"`
+}
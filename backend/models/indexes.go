@@ -0,0 +1,277 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// RequiredIndexes lists the indexes the API relies on, keyed by collection name
+var RequiredIndexes = map[string][]mongo.IndexModel{
+	UserCollection: {
+		{
+			Keys:    bson.D{{Key: "username", Value: 1}},
+			Options: options.Index().SetName("username_unique").SetUnique(true),
+		},
+	},
+	StoreCollection: {
+		{
+			Keys:    bson.D{{Key: "owner_id", Value: 1}},
+			Options: options.Index().SetName("owner_id"),
+		},
+		{
+			Keys:    bson.D{{Key: "active", Value: 1}, {Key: "created_at", Value: -1}},
+			Options: options.Index().SetName("active_created_at"),
+		},
+		{
+			Keys:    bson.D{{Key: "deleted_at", Value: 1}},
+			Options: options.Index().SetName("deleted_at").SetSparse(true),
+		},
+		{
+			Keys:    bson.D{{Key: "updated_at", Value: 1}},
+			Options: options.Index().SetName("updated_at"),
+		},
+		{
+			Keys:    bson.D{{Key: "coordinates", Value: "2dsphere"}},
+			Options: options.Index().SetName("coordinates_2dsphere"),
+		},
+		{
+			Keys:    bson.D{{Key: "whatsapp_digits", Value: 1}},
+			Options: options.Index().SetName("whatsapp_digits"),
+		},
+	},
+	ProductCollection: {
+		{
+			Keys:    bson.D{{Key: "store_id", Value: 1}, {Key: "created_at", Value: -1}},
+			Options: options.Index().SetName("store_id_created_at"),
+		},
+		{
+			Keys:    bson.D{{Key: "deleted_at", Value: 1}},
+			Options: options.Index().SetName("deleted_at").SetSparse(true),
+		},
+		{
+			// A SKU names one product of a store. Trashed products keep theirs
+			// under their deletion time, so the SKU can be reused meanwhile.
+			Keys: bson.D{{Key: "store_id", Value: 1}, {Key: "sku", Value: 1}, {Key: "deleted_at", Value: 1}},
+			Options: options.Index().SetName("store_id_sku_unique").SetUnique(true).
+				SetPartialFilterExpression(bson.M{"sku": bson.M{"$gt": ""}}),
+		},
+		{
+			Keys:    bson.D{{Key: "updated_at", Value: 1}},
+			Options: options.Index().SetName("updated_at"),
+		},
+		{
+			Keys:    bson.D{{Key: "sale_price_minor", Value: 1}},
+			Options: options.Index().SetName("sale_price_minor").SetSparse(true),
+		},
+	},
+	AuditLogCollection: {
+		{
+			Keys:    bson.D{{Key: "store_id", Value: 1}, {Key: "created_at", Value: -1}},
+			Options: options.Index().SetName("store_id_created_at"),
+		},
+		{
+			Keys:    bson.D{{Key: "actor_id", Value: 1}, {Key: "created_at", Value: -1}},
+			Options: options.Index().SetName("actor_id_created_at"),
+		},
+		{
+			Keys:    bson.D{{Key: "created_at", Value: -1}},
+			Options: options.Index().SetName("created_at"),
+		},
+	},
+	OrderCollection: {
+		{
+			Keys:    bson.D{{Key: "store_id", Value: 1}, {Key: "created_at", Value: -1}},
+			Options: options.Index().SetName("store_id_created_at"),
+		},
+		{
+			Keys:    bson.D{{Key: "store_id", Value: 1}, {Key: "status", Value: 1}, {Key: "created_at", Value: -1}},
+			Options: options.Index().SetName("store_id_status_created_at"),
+		},
+		{
+			Keys:    bson.D{{Key: "customer_id", Value: 1}, {Key: "created_at", Value: -1}},
+			Options: options.Index().SetName("customer_id_created_at").SetSparse(true),
+		},
+		{
+			Keys:    bson.D{{Key: "store_id", Value: 1}, {Key: "reference", Value: 1}},
+			Options: options.Index().SetName("store_id_reference"),
+		},
+		{
+			Keys:    bson.D{{Key: "reference", Value: 1}},
+			Options: options.Index().SetName("reference"),
+		},
+		{
+			Keys:    bson.D{{Key: "conversation.phone", Value: 1}, {Key: "conversation.last_message_at", Value: -1}},
+			Options: options.Index().SetName("conversation_phone_last_message_at").SetSparse(true),
+		},
+	},
+	ReviewCollection: {
+		{
+			Keys:    bson.D{{Key: "order_id", Value: 1}, {Key: "product_id", Value: 1}},
+			Options: options.Index().SetName("order_id_product_id_unique").SetUnique(true),
+		},
+		{
+			Keys:    bson.D{{Key: "product_id", Value: 1}, {Key: "status", Value: 1}, {Key: "created_at", Value: -1}},
+			Options: options.Index().SetName("product_id_status_created_at"),
+		},
+		{
+			Keys:    bson.D{{Key: "store_id", Value: 1}, {Key: "status", Value: 1}, {Key: "created_at", Value: -1}},
+			Options: options.Index().SetName("store_id_status_created_at"),
+		},
+	},
+	ConversationMessageCollection: {
+		{
+			Keys:    bson.D{{Key: "whatsapp_id", Value: 1}},
+			Options: options.Index().SetName("whatsapp_id_unique").SetUnique(true),
+		},
+		{
+			Keys:    bson.D{{Key: "order_id", Value: 1}, {Key: "sent_at", Value: 1}},
+			Options: options.Index().SetName("order_id_sent_at"),
+		},
+	},
+	NotificationCollection: {
+		{
+			Keys:    bson.D{{Key: "status", Value: 1}, {Key: "next_attempt_at", Value: 1}},
+			Options: options.Index().SetName("status_next_attempt_at"),
+		},
+		{
+			Keys:    bson.D{{Key: "store_id", Value: 1}, {Key: "created_at", Value: -1}},
+			Options: options.Index().SetName("store_id_created_at"),
+		},
+	},
+	CustomerCollection: {
+		{
+			Keys:    bson.D{{Key: "store_id", Value: 1}, {Key: "phone", Value: 1}},
+			Options: options.Index().SetName("store_id_phone_unique").SetUnique(true),
+		},
+		{
+			Keys:    bson.D{{Key: "store_id", Value: 1}, {Key: "last_order_at", Value: -1}},
+			Options: options.Index().SetName("store_id_last_order_at"),
+		},
+	},
+	StoreMemberCollection: {
+		{
+			Keys:    bson.D{{Key: "store_id", Value: 1}, {Key: "user_id", Value: 1}},
+			Options: options.Index().SetName("store_id_user_id_unique").SetUnique(true),
+		},
+		{
+			Keys:    bson.D{{Key: "user_id", Value: 1}},
+			Options: options.Index().SetName("user_id"),
+		},
+	},
+	StoreInviteCollection: {
+		{
+			Keys:    bson.D{{Key: "token_hash", Value: 1}},
+			Options: options.Index().SetName("token_hash_unique").SetUnique(true),
+		},
+		{
+			Keys:    bson.D{{Key: "store_id", Value: 1}, {Key: "created_at", Value: -1}},
+			Options: options.Index().SetName("store_id_created_at"),
+		},
+	},
+	CouponCollection: {
+		{
+			Keys:    bson.D{{Key: "store_id", Value: 1}, {Key: "code", Value: 1}},
+			Options: options.Index().SetName("store_id_code_unique").SetUnique(true),
+		},
+	},
+	DeliveryZoneCollection: {
+		{
+			Keys:    bson.D{{Key: "store_id", Value: 1}, {Key: "active", Value: 1}},
+			Options: options.Index().SetName("store_id_active"),
+		},
+		{
+			Keys:    bson.D{{Key: "area", Value: "2dsphere"}},
+			Options: options.Index().SetName("area_2dsphere"),
+		},
+	},
+	CourierRateCollection: {
+		{
+			Keys:    bson.D{{Key: "store_id", Value: 1}, {Key: "destination_key", Value: 1}, {Key: "origin_key", Value: 1}},
+			Options: options.Index().SetName("store_id_destination_key_origin_key"),
+		},
+		{
+			Keys:    bson.D{{Key: "store_id", Value: 1}, {Key: "courier", Value: 1}, {Key: "service", Value: 1}},
+			Options: options.Index().SetName("store_id_courier_service"),
+		},
+	},
+	CouponRedemptionCollection: {
+		{
+			Keys:    bson.D{{Key: "coupon_id", Value: 1}, {Key: "phone", Value: 1}},
+			Options: options.Index().SetName("coupon_id_phone_unique").SetUnique(true),
+		},
+	},
+	AnalyticsDailyCollection: {
+		{
+			Keys:    bson.D{{Key: "store_id", Value: 1}, {Key: "product_id", Value: 1}, {Key: "date", Value: 1}},
+			Options: options.Index().SetName("store_id_product_id_date").SetUnique(true),
+		},
+		{
+			Keys:    bson.D{{Key: "store_id", Value: 1}, {Key: "date", Value: 1}},
+			Options: options.Index().SetName("store_id_date"),
+		},
+	},
+	AnalyticsDedupeCollection: {
+		{
+			Keys:    bson.D{{Key: "created_at", Value: 1}},
+			Options: options.Index().SetName("created_at_ttl").SetExpireAfterSeconds(AnalyticsDedupeSeconds),
+		},
+	},
+	SitemapCollection: {
+		{
+			Keys:    bson.D{{Key: "store_id", Value: 1}, {Key: "part", Value: 1}},
+			Options: options.Index().SetName("store_id_part").SetUnique(true),
+		},
+	},
+}
+
+// EnsureIndexes creates any required index that does not exist yet
+func (d *Database) EnsureIndexes(ctx context.Context) error {
+	var errs []error
+	for name, indexes := range RequiredIndexes {
+		// One index failing, e.g. a unique index over duplicate data, doesn't
+		// stop the others from being built
+		for _, index := range indexes {
+			if _, err := d.GetCollection(name).Indexes().CreateOne(ctx, index); err != nil {
+				errs = append(errs, fmt.Errorf("failed to create index %s.%s: %v", name, *index.Options.Name, err))
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// MissingIndexes returns the required indexes that are not present, as "collection.index" names
+func (d *Database) MissingIndexes(ctx context.Context) ([]string, error) {
+	missing := []string{}
+	for name, indexes := range RequiredIndexes {
+		cursor, err := d.GetCollection(name).Indexes().List(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list indexes on %s: %v", name, err)
+		}
+
+		var existing []struct {
+			Name string `bson:"name"`
+		}
+		if err := cursor.All(ctx, &existing); err != nil {
+			return nil, fmt.Errorf("failed to decode indexes on %s: %v", name, err)
+		}
+
+		present := make(map[string]bool, len(existing))
+		for _, index := range existing {
+			present[index.Name] = true
+		}
+
+		for _, index := range indexes {
+			if !present[*index.Options.Name] {
+				missing = append(missing, name+"."+*index.Options.Name)
+			}
+		}
+	}
+	sort.Strings(missing)
+	return missing, nil
+}
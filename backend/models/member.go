@@ -0,0 +1,122 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// StoreMemberCollection holds the users who help run a store besides its owner
+const StoreMemberCollection = "store_members"
+
+// StoreInviteCollection holds pending invitations to join a store
+const StoreInviteCollection = "store_invites"
+
+// Invitations can be accepted for this long after they are created
+const InviteTTL = 7 * 24 * time.Hour
+
+// Store roles. The user who created a store is always its owner; other
+// users get a role when they accept an invitation.
+const (
+	RoleOwner        = "owner"
+	RoleManager      = "manager"
+	RoleCatalogStaff = "staff-catalog"
+	RoleOrderStaff   = "staff-orders"
+)
+
+// Permissions checked by the store management endpoints
+const (
+	PermissionView     = "view"     // Open the store in the dashboard
+	PermissionSettings = "settings" // Store settings, coupons, delivery zones and courier rates
+	PermissionCatalog  = "catalog"  // Products, imports and feeds
+	PermissionOrders   = "orders"   // Orders and customers
+	PermissionReports  = "reports"  // Analytics and the audit log
+	PermissionMembers  = "members"  // Invitations and roles
+	PermissionDelete   = "delete"   // Moving the store to the trash
+)
+
+// rolePermissions lists what each role may do
+var rolePermissions = map[string][]string{
+	RoleOwner:        {PermissionView, PermissionSettings, PermissionCatalog, PermissionOrders, PermissionReports, PermissionMembers, PermissionDelete},
+	RoleManager:      {PermissionView, PermissionSettings, PermissionCatalog, PermissionOrders, PermissionReports},
+	RoleCatalogStaff: {PermissionView, PermissionCatalog},
+	RoleOrderStaff:   {PermissionView, PermissionOrders},
+}
+
+// ValidRole reports whether a role exists
+func ValidRole(role string) bool {
+	_, ok := rolePermissions[role]
+	return ok
+}
+
+// RoleAllows reports whether a role grants a permission
+func RoleAllows(role, permission string) bool {
+	for _, p := range rolePermissions[role] {
+		if p == permission {
+			return true
+		}
+	}
+	return false
+}
+
+// StoreMember gives a user a role in someone else's store
+type StoreMember struct {
+	ID        primitive.ObjectID `bson:"_id" json:"id"`
+	StoreID   primitive.ObjectID `bson:"store_id" json:"storeId"`
+	UserID    primitive.ObjectID `bson:"user_id" json:"userId"`
+	Username  string             `bson:"username" json:"username"`
+	Role      string             `bson:"role" json:"role"`
+	InvitedBy primitive.ObjectID `bson:"invited_by" json:"invitedBy"`
+	CreatedAt time.Time          `bson:"created_at" json:"createdAt"`
+	UpdatedAt time.Time          `bson:"updated_at" json:"updatedAt"`
+}
+
+// StoreInvite is an invitation to join a store with a role. Invitations
+// with an email can only be accepted by a user with that email; the others
+// by anyone with the link. Account emails are not verified, so the email
+// only narrows who may accept: the link is what proves the invitation was
+// meant for them, and should only be shared with the person invited.
+type StoreInvite struct {
+	ID         primitive.ObjectID `bson:"_id" json:"id"`
+	StoreID    primitive.ObjectID `bson:"store_id" json:"storeId"`
+	StoreName  string             `bson:"store_name" json:"storeName"`
+	Role       string             `bson:"role" json:"role"`
+	Email      string             `bson:"email,omitempty" json:"email,omitempty"`
+	TokenHash  string             `bson:"token_hash" json:"-"` // SHA-256 of the token in the link
+	InvitedBy  primitive.ObjectID `bson:"invited_by" json:"invitedBy"`
+	ExpiresAt  time.Time          `bson:"expires_at" json:"expiresAt"`
+	AcceptedAt *time.Time         `bson:"accepted_at,omitempty" json:"acceptedAt,omitempty"`
+	AcceptedBy primitive.ObjectID `bson:"accepted_by,omitempty" json:"acceptedBy,omitempty"`
+	CreatedAt  time.Time          `bson:"created_at" json:"createdAt"`
+}
+
+// CreateInviteRequest represents the request body for inviting a user to a store
+type CreateInviteRequest struct {
+	Role  string `json:"role"`
+	Email string `json:"email,omitempty"` // Emailed the link when email is configured; leave empty for a link anyone can accept
+}
+
+// CreateInviteResponse is a new invitation with its token, which is shown only once
+type CreateInviteResponse struct {
+	Invite  StoreInvite `json:"invite"`
+	Token   string      `json:"token"`
+	URL     string      `json:"url"`     // Dashboard link that accepts the invitation
+	Emailed bool        `json:"emailed"` // The link was emailed to the invitation's address
+}
+
+// AcceptInviteRequest represents the request body for accepting an invitation
+type AcceptInviteRequest struct {
+	Token string `json:"token"`
+}
+
+// UpdateMemberRequest represents the request body for changing a member's role
+type UpdateMemberRequest struct {
+	Role string `json:"role"`
+}
+
+// StoreTeamResponse lists who can manage a store
+type StoreTeamResponse struct {
+	OwnerID primitive.ObjectID `json:"ownerId"`
+	Members []StoreMember      `json:"members"`
+	Invites []StoreInvite      `json:"invites"` // Pending invitations
+}
@@ -13,53 +13,126 @@ const (
 	ProductCollection = "products"
 )
 
+// DefaultCurrency is the ISO 4217 currency of stores that have not chosen one
+const DefaultCurrency = "IDR"
+
 // User represents a user document in MongoDB
 type User struct {
 	ID           primitive.ObjectID `bson:"_id" json:"id"`
 	Username     string             `bson:"username" json:"username"`
 	PasswordHash string             `bson:"password_hash" json:"-"` // Not included in JSON responses
 	Email        string             `bson:"email" json:"email"`
-	Role         string             `bson:"role" json:"role"` // "admin", "owner", etc.
-	StoreID      primitive.ObjectID `bson:"store_id,omitempty" json:"storeId,omitempty"`
+	Role         string             `bson:"role" json:"role"`                            // "admin", "owner", etc.
+	StoreID      primitive.ObjectID `bson:"store_id,omitempty" json:"storeId,omitempty"` // Store the dashboard opens by default
 	CreatedAt    time.Time          `bson:"created_at" json:"createdAt"`
 	UpdatedAt    time.Time          `bson:"updated_at" json:"updatedAt"`
 }
 
+// MyStoresResponse lists the stores a user owns or helps run and the one the
+// dashboard opens by default
+type MyStoresResponse struct {
+	Stores          []Store            `json:"stores"`
+	Roles           map[string]string  `json:"roles"` // The user's role, keyed by store ID
+	SelectedStoreID primitive.ObjectID `json:"selectedStoreId,omitempty"`
+}
+
 // Store represents a store document in MongoDB
 type Store struct {
-	ID             primitive.ObjectID  `bson:"_id" json:"id"`
-	OwnerID        primitive.ObjectID  `bson:"owner_id" json:"ownerId"`
-	Name           string              `bson:"name" json:"name"`
-	Description    string              `bson:"description" json:"description"`
-	Logo           string              `bson:"logo" json:"logo"`
-	Location       string              `bson:"location" json:"location"`
-	WhatsappNumber string              `bson:"whatsapp_number" json:"whatsappNumber"`
-	BusinessHours  string              `bson:"business_hours" json:"businessHours"`
-	Tags           []string            `bson:"tags,omitempty" json:"tags,omitempty"`
-	Active         bool                `bson:"active" json:"active"`
-	FeaturedProduct primitive.ObjectID `bson:"featured_product,omitempty" json:"featuredProduct,omitempty"`
-	CreatedAt      time.Time           `bson:"created_at" json:"createdAt"`
-	UpdatedAt      time.Time           `bson:"updated_at" json:"updatedAt"`
+	ID              primitive.ObjectID     `bson:"_id" json:"id"`
+	OwnerID         primitive.ObjectID     `bson:"owner_id" json:"ownerId"`
+	Name            string                 `bson:"name" json:"name"`
+	Description     string                 `bson:"description" json:"description"`
+	Logo            string                 `bson:"logo" json:"logo"`
+	Location        string                 `bson:"location" json:"location"`
+	Coordinates     *GeoPoint              `bson:"coordinates,omitempty" json:"coordinates,omitempty"`        // Where radius delivery zones are measured from
+	ShippingOrigin  string                 `bson:"shipping_origin,omitempty" json:"shippingOrigin,omitempty"` // Region matched against the origin of courier rates
+	WhatsappNumber  string                 `bson:"whatsapp_number" json:"whatsappNumber"`
+	WhatsappDigits  string                 `bson:"whatsapp_digits,omitempty" json:"-"` // WhatsappNumber in international digits, matched against webhook messages
+	BusinessHours   string                 `bson:"business_hours" json:"businessHours"`
+	Tags            []string               `bson:"tags,omitempty" json:"tags,omitempty"`
+	Active          bool                   `bson:"active" json:"active"`
+	FeaturedProduct primitive.ObjectID     `bson:"featured_product,omitempty" json:"featuredProduct,omitempty"`
+	Currency        string                 `bson:"currency,omitempty" json:"currency"` // ISO 4217; amounts of the store are in its minor units
+	Tax             *TaxSettings           `bson:"tax,omitempty" json:"tax,omitempty"`
+	ServiceCharge   *ServiceChargeSettings `bson:"service_charge,omitempty" json:"serviceCharge,omitempty"`
+	Fulfilment      *FulfilmentSettings    `bson:"fulfilment,omitempty" json:"fulfilment,omitempty"`       // Unset means pickup and delivery, see FulfilmentSettings
+	Notifications   *NotificationSettings  `bson:"notifications,omitempty" json:"notifications,omitempty"` // Unset means new orders go to the WhatsApp number
+	Rating          *RatingSummary         `bson:"rating,omitempty" json:"rating,omitempty"`               // Maintained from published product reviews
+	CreatedAt       time.Time              `bson:"created_at" json:"createdAt"`
+	UpdatedAt       time.Time              `bson:"updated_at" json:"updatedAt"`
+	DeletedAt       *time.Time             `bson:"deleted_at,omitempty" json:"deletedAt,omitempty"` // Set while the store is in the trash
 }
 
-// Product represents a product document in MongoDB
+// Product represents a product document in MongoDB. Amounts are in minor
+// units of the store's currency.
 type Product struct {
 	ID          primitive.ObjectID `bson:"_id" json:"id"`
 	StoreID     primitive.ObjectID `bson:"store_id" json:"storeId"`
+	SKU         string             `bson:"sku,omitempty" json:"sku,omitempty"`
 	Name        string             `bson:"name" json:"name"`
 	Description string             `bson:"description" json:"description"`
-	Price       float64            `bson:"price" json:"price"`
+	PriceMinor  int64              `bson:"price_minor" json:"priceMinor"`
 	Image       string             `bson:"image" json:"image"`
 	Category    string             `bson:"category" json:"category"`
 	Stock       int                `bson:"stock" json:"stock"`
+	TrackStock  bool               `bson:"track_stock,omitempty" json:"trackStock"` // Checkout only enforces Stock, and confirming orders only takes it, when set
 	Featured    bool               `bson:"featured" json:"featured"`
 	Active      bool               `bson:"active" json:"active"`
 	CreatedAt   time.Time          `bson:"created_at" json:"createdAt"`
 	UpdatedAt   time.Time          `bson:"updated_at" json:"updatedAt"`
+	DeletedAt   *time.Time         `bson:"deleted_at,omitempty" json:"deletedAt,omitempty"` // Set while the product is in the trash
+
+	// Sale pricing. SalePriceMinor replaces PriceMinor between SaleStartsAt
+	// and SaleEndsAt; either bound may be left open. CompareAtPriceMinor is
+	// the struck-through reference price and defaults to PriceMinor.
+	SalePriceMinor      int64      `bson:"sale_price_minor,omitempty" json:"salePriceMinor,omitempty"`
+	SaleStartsAt        *time.Time `bson:"sale_starts_at,omitempty" json:"saleStartsAt,omitempty"`
+	SaleEndsAt          *time.Time `bson:"sale_ends_at,omitempty" json:"saleEndsAt,omitempty"`
+	CompareAtPriceMinor int64      `bson:"compare_at_price_minor,omitempty" json:"compareAtPriceMinor,omitempty"`
+
+	// Shipping. Weight and dimensions of one unit, packed; see ShippingGrams
+	WeightGrams int     `bson:"weight_g,omitempty" json:"weightGrams,omitempty"`
+	LengthCm    float64 `bson:"length_cm,omitempty" json:"lengthCm,omitempty"`
+	WidthCm     float64 `bson:"width_cm,omitempty" json:"widthCm,omitempty"`
+	HeightCm    float64 `bson:"height_cm,omitempty" json:"heightCm,omitempty"`
+
+	// Maintained from published reviews
+	Rating *RatingSummary `bson:"rating,omitempty" json:"rating,omitempty"`
+
+	// Maintained by the sale scheduler
+	SaleStarted  bool `bson:"sale_started,omitempty" json:"-"`  // The scheduler has seen the sale start
+	SaleFeatured bool `bson:"sale_featured,omitempty" json:"-"` // Featured by the scheduler, to be unfeatured when the sale ends
+
+	// Computed at request time by ApplyPricing
+	Currency                string `bson:"-" json:"currency"`
+	EffectivePriceMinor     int64  `bson:"-" json:"effectivePriceMinor"`
+	OnSale                  bool   `bson:"-" json:"onSale"`
+	DiscountPercent         int    `bson:"-" json:"discountPercent,omitempty"`
+	FormattedPrice          string `bson:"-" json:"formattedPrice"`                    // Effective price, e.g. "Rp 15.000"
+	FormattedCompareAtPrice string `bson:"-" json:"formattedCompareAtPrice,omitempty"` // Set while discounted
+
+	// Deprecated: major-unit amounts for clients of the float API, kept for
+	// the compatibility period. Computed by ApplyPricing.
+	LegacyPrice          float64 `bson:"-" json:"price" deprecated:"true"`
+	LegacySalePrice      float64 `bson:"-" json:"salePrice,omitempty" deprecated:"true"`
+	LegacyCompareAtPrice float64 `bson:"-" json:"compareAtPrice,omitempty" deprecated:"true"`
+	LegacyEffectivePrice float64 `bson:"-" json:"effectivePrice" deprecated:"true"`
+}
+
+// InStock reports whether the product can be ordered; products that don't
+// track stock always can
+func (p Product) InStock() bool {
+	return !p.TrackStock || p.Stock > 0
 }
 
 // API Request/Response Models
 
+// TrashResponse lists a user's soft-deleted stores and products
+type TrashResponse struct {
+	Stores   []Store   `json:"stores"`
+	Products []Product `json:"products"`
+}
+
 // RegisterRequest represents the request body for user registration
 type RegisterRequest struct {
 	Username string `json:"username"`
@@ -88,40 +161,111 @@ type CreateStoreRequest struct {
 	WhatsappNumber string   `json:"whatsappNumber"`
 	BusinessHours  string   `json:"businessHours"`
 	Tags           []string `json:"tags,omitempty"`
+	Currency       string   `json:"currency,omitempty"` // ISO 4217, defaults to IDR
 }
 
 // UpdateStoreRequest represents the request body for store updates
 type UpdateStoreRequest struct {
-	Name           string   `json:"name,omitempty"`
-	Description    string   `json:"description,omitempty"`
-	Logo           string   `json:"logo,omitempty"`
-	Location       string   `json:"location,omitempty"`
-	WhatsappNumber string   `json:"whatsappNumber,omitempty"`
-	BusinessHours  string   `json:"businessHours,omitempty"`
-	Tags           []string `json:"tags,omitempty"`
-	Active         *bool    `json:"active,omitempty"`
+	Name           string    `json:"name,omitempty"`
+	Description    string    `json:"description,omitempty"`
+	Logo           string    `json:"logo,omitempty"`
+	Location       string    `json:"location,omitempty"`
+	WhatsappNumber string    `json:"whatsappNumber,omitempty"`
+	BusinessHours  string    `json:"businessHours,omitempty"`
+	Tags           []string  `json:"tags,omitempty"`
+	Currency       string    `json:"currency,omitempty"`
+	Active         *bool     `json:"active,omitempty"`
+	Coordinates    *GeoPoint `json:"coordinates,omitempty"`
+	ShippingOrigin string    `json:"shippingOrigin,omitempty"`
+
+	// Replace the store's settings when present; send enabled false to turn off
+	Tax           *TaxSettings           `json:"tax,omitempty"`
+	ServiceCharge *ServiceChargeSettings `json:"serviceCharge,omitempty"`
+	Fulfilment    *FulfilmentSettings    `json:"fulfilment,omitempty"`
+	Notifications *NotificationSettings  `json:"notifications,omitempty"`
 }
 
-// CreateProductRequest represents the request body for product creation
+// CreateProductRequest represents the request body for product creation.
+// Amounts are in minor units of the store's currency.
 type CreateProductRequest struct {
-	Name        string   `json:"name"`
-	Description string   `json:"description"`
-	Price       float64  `json:"price"`
-	Image       string   `json:"image"`
-	Category    string   `json:"category"`
-	Stock       int      `json:"stock"`
-	Featured    bool     `json:"featured"`
-	Active      *bool    `json:"active,omitempty"`
+	SKU         string `json:"sku,omitempty"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	PriceMinor  int64  `json:"priceMinor"`
+	Image       string `json:"image"`
+	Category    string `json:"category"`
+	Stock       int    `json:"stock"`
+	TrackStock  bool   `json:"trackStock,omitempty"`
+	Featured    bool   `json:"featured"`
+	Active      *bool  `json:"active,omitempty"`
+
+	SalePriceMinor      int64      `json:"salePriceMinor,omitempty"`
+	SaleStartsAt        *time.Time `json:"saleStartsAt,omitempty"`
+	SaleEndsAt          *time.Time `json:"saleEndsAt,omitempty"`
+	CompareAtPriceMinor int64      `json:"compareAtPriceMinor,omitempty"`
+
+	WeightGrams int     `json:"weightGrams,omitempty"`
+	LengthCm    float64 `json:"lengthCm,omitempty"`
+	WidthCm     float64 `json:"widthCm,omitempty"`
+	HeightCm    float64 `json:"heightCm,omitempty"`
+
+	// Deprecated: major-unit amounts, used when the minor-unit field is zero
+	Price          *float64 `json:"price,omitempty" deprecated:"true"`
+	SalePrice      *float64 `json:"salePrice,omitempty" deprecated:"true"`
+	CompareAtPrice *float64 `json:"compareAtPrice,omitempty" deprecated:"true"`
+}
+
+// ResolveAmounts converts deprecated major-unit amounts to minor units
+func (r *CreateProductRequest) ResolveAmounts(currency Currency) {
+	resolve := func(minor *int64, major *float64) {
+		if *minor == 0 && major != nil {
+			*minor = currency.FromMajor(*major)
+		}
+	}
+	resolve(&r.PriceMinor, r.Price)
+	resolve(&r.SalePriceMinor, r.SalePrice)
+	resolve(&r.CompareAtPriceMinor, r.CompareAtPrice)
 }
 
-// UpdateProductRequest represents the request body for product updates
+// UpdateProductRequest represents the request body for product updates.
+// Amounts are in minor units of the store's currency.
 type UpdateProductRequest struct {
-	Name        string  `json:"name,omitempty"`
-	Description string  `json:"description,omitempty"`
-	Price       *float64 `json:"price,omitempty"`
-	Image       string  `json:"image,omitempty"`
-	Category    string  `json:"category,omitempty"`
-	Stock       *int    `json:"stock,omitempty"`
-	Featured    *bool   `json:"featured,omitempty"`
-	Active      *bool   `json:"active,omitempty"`
-}
\ No newline at end of file
+	SKU         string `json:"sku,omitempty"`
+	Name        string `json:"name,omitempty"`
+	Description string `json:"description,omitempty"`
+	PriceMinor  *int64 `json:"priceMinor,omitempty"`
+	Image       string `json:"image,omitempty"`
+	Category    string `json:"category,omitempty"`
+	Stock       *int   `json:"stock,omitempty"`
+	TrackStock  *bool  `json:"trackStock,omitempty"`
+	Featured    *bool  `json:"featured,omitempty"`
+	Active      *bool  `json:"active,omitempty"`
+
+	SalePriceMinor      *int64     `json:"salePriceMinor,omitempty"` // 0 ends the sale and clears its window
+	SaleStartsAt        *time.Time `json:"saleStartsAt,omitempty"`
+	SaleEndsAt          *time.Time `json:"saleEndsAt,omitempty"`
+	CompareAtPriceMinor *int64     `json:"compareAtPriceMinor,omitempty"` // 0 falls back to the price
+
+	WeightGrams *int     `json:"weightGrams,omitempty"`
+	LengthCm    *float64 `json:"lengthCm,omitempty"`
+	WidthCm     *float64 `json:"widthCm,omitempty"`
+	HeightCm    *float64 `json:"heightCm,omitempty"`
+
+	// Deprecated: major-unit amounts, used when the minor-unit field is absent
+	Price          *float64 `json:"price,omitempty" deprecated:"true"`
+	SalePrice      *float64 `json:"salePrice,omitempty" deprecated:"true"`
+	CompareAtPrice *float64 `json:"compareAtPrice,omitempty" deprecated:"true"`
+}
+
+// ResolveAmounts converts deprecated major-unit amounts to minor units
+func (r *UpdateProductRequest) ResolveAmounts(currency Currency) {
+	resolve := func(minor **int64, major *float64) {
+		if *minor == nil && major != nil {
+			amount := currency.FromMajor(*major)
+			*minor = &amount
+		}
+	}
+	resolve(&r.PriceMinor, r.Price)
+	resolve(&r.SalePriceMinor, r.SalePrice)
+	resolve(&r.CompareAtPriceMinor, r.CompareAtPrice)
+}
@@ -0,0 +1,183 @@
+package models
+
+import (
+	"errors"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Currency describes how amounts of an ISO 4217 currency are stored and shown.
+// Amounts are stored as int64 counts of the currency's minor unit, e.g. cents.
+type Currency struct {
+	Code      string // ISO 4217 code
+	Exponent  int    // ISO 4217 minor unit digits; 100 minor units make a USD
+	Symbol    string // Written before the amount, with any spacing it needs
+	Thousands string // Digit group separator
+	Decimal   string // Decimal separator
+	Fraction  int    // Fraction digits shown when formatting, at most Exponent
+}
+
+// currencies are the currencies stores can price in
+var currencies = map[string]Currency{
+	// Rupiah has two minor digits in ISO 4217 but sen are not in use
+	"IDR": {Code: "IDR", Exponent: 2, Symbol: "Rp ", Thousands: ".", Decimal: ",", Fraction: 0},
+	"MYR": {Code: "MYR", Exponent: 2, Symbol: "RM ", Thousands: ",", Decimal: ".", Fraction: 2},
+	"SGD": {Code: "SGD", Exponent: 2, Symbol: "S$", Thousands: ",", Decimal: ".", Fraction: 2},
+	"PHP": {Code: "PHP", Exponent: 2, Symbol: "₱", Thousands: ",", Decimal: ".", Fraction: 2},
+	"THB": {Code: "THB", Exponent: 2, Symbol: "฿", Thousands: ",", Decimal: ".", Fraction: 2},
+	"VND": {Code: "VND", Exponent: 0, Symbol: "₫", Thousands: ".", Decimal: ",", Fraction: 0},
+	"INR": {Code: "INR", Exponent: 2, Symbol: "₹", Thousands: ",", Decimal: ".", Fraction: 2},
+	"AUD": {Code: "AUD", Exponent: 2, Symbol: "A$", Thousands: ",", Decimal: ".", Fraction: 2},
+	"USD": {Code: "USD", Exponent: 2, Symbol: "$", Thousands: ",", Decimal: ".", Fraction: 2},
+	"EUR": {Code: "EUR", Exponent: 2, Symbol: "€", Thousands: ".", Decimal: ",", Fraction: 2},
+	"GBP": {Code: "GBP", Exponent: 2, Symbol: "£", Thousands: ",", Decimal: ".", Fraction: 2},
+	"JPY": {Code: "JPY", Exponent: 0, Symbol: "¥", Thousands: ",", Decimal: ".", Fraction: 0},
+}
+
+// LookupCurrency returns a supported currency by code
+func LookupCurrency(code string) (Currency, bool) {
+	currency, ok := currencies[strings.ToUpper(code)]
+	return currency, ok
+}
+
+// CurrencyOf returns a supported currency, falling back to DefaultCurrency
+// for empty or unknown codes
+func CurrencyOf(code string) Currency {
+	if currency, ok := LookupCurrency(code); ok {
+		return currency
+	}
+	return currencies[DefaultCurrency]
+}
+
+// CurrencyCodes lists the supported currency codes in alphabetical order
+func CurrencyCodes() []string {
+	codes := make([]string, 0, len(currencies))
+	for code := range currencies {
+		codes = append(codes, code)
+	}
+	sort.Strings(codes)
+	return codes
+}
+
+// errInvalidAmount is returned for amounts that are not plain decimal numbers
+var errInvalidAmount = errors.New("must be a number")
+
+// ParseAmount converts a decimal string in major units, e.g. "15000.50", to
+// minor units. Extra fraction digits are rounded half away from zero without
+// going through floating point.
+func (c Currency) ParseAmount(s string) (int64, error) {
+	s = strings.TrimSpace(s)
+	negative := strings.HasPrefix(s, "-")
+	s = strings.TrimPrefix(strings.TrimPrefix(s, "-"), "+")
+
+	whole, fraction := s, ""
+	if i := strings.IndexByte(s, '.'); i >= 0 {
+		whole, fraction = s[:i], s[i+1:]
+	}
+	if whole == "" && fraction == "" {
+		return 0, errInvalidAmount
+	}
+	for _, part := range []string{whole, fraction} {
+		for _, r := range part {
+			if r < '0' || r > '9' {
+				return 0, errInvalidAmount
+			}
+		}
+	}
+
+	// Pad or cut the fraction to the minor unit, remembering the first cut digit
+	roundUp := false
+	if len(fraction) > c.Exponent {
+		roundUp = fraction[c.Exponent] >= '5'
+		fraction = fraction[:c.Exponent]
+	}
+	fraction += strings.Repeat("0", c.Exponent-len(fraction))
+
+	digits := strings.TrimLeft(whole+fraction, "0")
+	if digits == "" {
+		digits = "0"
+	}
+	minor, err := strconv.ParseInt(digits, 10, 64)
+	if err != nil {
+		return 0, errors.New("is too large")
+	}
+	if roundUp {
+		minor++
+	}
+	if negative {
+		minor = -minor
+	}
+	return minor, nil
+}
+
+// FromMajor converts an amount in major units to minor units, rounding half
+// away from zero on its shortest decimal representation
+func (c Currency) FromMajor(amount float64) int64 {
+	if math.IsNaN(amount) || math.IsInf(amount, 0) {
+		return 0
+	}
+	minor, err := c.ParseAmount(strconv.FormatFloat(amount, 'f', -1, 64))
+	if err != nil {
+		return 0
+	}
+	return minor
+}
+
+// Major converts minor units to major units. Only for display and the
+// deprecated float fields of the API; never compute with the result.
+func (c Currency) Major(minor int64) float64 {
+	return float64(minor) / math.Pow10(c.Exponent)
+}
+
+// DecimalString formats minor units as a plain decimal in major units with every
+// minor digit, e.g. "15000.00", the way catalog feeds and JSON-LD expect
+func (c Currency) DecimalString(minor int64) string {
+	if minor < 0 {
+		return "-" + c.decimal(-minor, c.Exponent, "", ".")
+	}
+	return c.decimal(minor, c.Exponent, "", ".")
+}
+
+// Format formats minor units for people, e.g. "Rp 15.000" or "$12.50"
+func (c Currency) Format(minor int64) string {
+	if minor < 0 {
+		return "-" + c.Symbol + c.decimal(-minor, c.Fraction, c.Thousands, c.Decimal)
+	}
+	return c.Symbol + c.decimal(minor, c.Fraction, c.Thousands, c.Decimal)
+}
+
+// decimal renders a non-negative amount of minor units with the given
+// fraction digits, rounding half up when digits are dropped
+func (c Currency) decimal(minor int64, fraction int, thousands, decimal string) string {
+	drop := c.Exponent - fraction
+	if drop > 0 {
+		unit := int64(math.Pow10(drop))
+		minor = (minor + unit/2) / unit
+	}
+
+	digits := strconv.FormatInt(minor, 10)
+	if len(digits) <= fraction {
+		digits = strings.Repeat("0", fraction-len(digits)+1) + digits
+	}
+	whole, frac := digits[:len(digits)-fraction], digits[len(digits)-fraction:]
+
+	var grouped strings.Builder
+	for i, r := range whole {
+		if i > 0 && thousands != "" && (len(whole)-i)%3 == 0 {
+			grouped.WriteString(thousands)
+		}
+		grouped.WriteRune(r)
+	}
+	if frac != "" {
+		grouped.WriteString(decimal + frac)
+	}
+	return grouped.String()
+}
+
+// PercentOf returns percent of an amount in minor units, rounded half away
+// from zero
+func PercentOf(minor int64, percent float64) int64 {
+	return int64(math.Round(float64(minor) * percent / 100))
+}
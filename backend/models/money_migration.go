@@ -0,0 +1,155 @@
+package models
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// MigrateMoney converts amounts stored as major-unit floats, from before
+// amounts were kept in minor units, and gives stores without a currency the
+// default one. Only documents that still have float fields are touched, so it
+// is safe to run on every start.
+func (d *Database) MigrateMoney(ctx context.Context) error {
+	storesColl := d.GetCollection(StoreCollection)
+	if _, err := storesColl.UpdateMany(ctx,
+		bson.M{"currency": bson.M{"$exists": false}},
+		bson.M{"$set": bson.M{"currency": DefaultCurrency}},
+	); err != nil {
+		return fmt.Errorf("failed to set store currencies: %v", err)
+	}
+
+	// Products and coupons are in the currency of their store, trashed stores included
+	cursor, err := storesColl.Find(ctx, bson.M{}, options.Find().SetProjection(bson.M{"_id": 1, "currency": 1}))
+	if err != nil {
+		return fmt.Errorf("failed to find stores: %v", err)
+	}
+	var stores []Store
+	if err = cursor.All(ctx, &stores); err != nil {
+		return fmt.Errorf("failed to decode stores: %v", err)
+	}
+
+	var products, coupons, orders int64
+	productsColl := d.GetCollection(ProductCollection)
+	couponsColl := d.GetCollection(CouponCollection)
+	for _, store := range stores {
+		currency := CurrencyOf(store.Currency)
+		filter := bson.M{"store_id": store.ID}
+
+		n, err := migrateAmounts(ctx, productsColl, filter, currency, map[string]string{
+			"price":            "price_minor",
+			"sale_price":       "sale_price_minor",
+			"compare_at_price": "compare_at_price_minor",
+		})
+		if err != nil {
+			return err
+		}
+		products += n
+
+		// A coupon's value was the percent off or, for fixed coupons, the amount off
+		result, err := couponsColl.UpdateMany(ctx,
+			bson.M{"store_id": store.ID, "type": CouponPercentage, "value": bson.M{"$exists": true}},
+			mongo.Pipeline{
+				{{Key: "$set", Value: bson.M{"percent": "$value"}}},
+				{{Key: "$unset", Value: "value"}},
+			},
+		)
+		if err != nil {
+			return fmt.Errorf("failed to migrate percentage coupons: %v", err)
+		}
+		coupons += result.ModifiedCount
+
+		n, err = migrateAmounts(ctx, couponsColl, bson.M{"store_id": store.ID, "type": CouponFixed}, currency, map[string]string{
+			"value": "amount_minor",
+		})
+		if err != nil {
+			return err
+		}
+		coupons += n
+
+		n, err = migrateAmounts(ctx, couponsColl, filter, currency, map[string]string{
+			"max_discount": "max_discount_minor",
+			"min_order":    "min_order_minor",
+		})
+		if err != nil {
+			return err
+		}
+		coupons += n
+	}
+
+	// Orders carry their own currency
+	ordersColl := d.GetCollection(OrderCollection)
+	codes, err := ordersColl.Distinct(ctx, "currency", bson.M{"total": bson.M{"$exists": true}})
+	if err != nil {
+		return fmt.Errorf("failed to list order currencies: %v", err)
+	}
+	for _, code := range codes {
+		code, _ := code.(string)
+		currency := CurrencyOf(code)
+		result, err := ordersColl.UpdateMany(ctx,
+			bson.M{"currency": code, "total": bson.M{"$exists": true}},
+			mongo.Pipeline{
+				{{Key: "$set", Value: bson.M{
+					"subtotal_minor": minorExpr("$subtotal", currency),
+					"discount_minor": minorExpr("$discount", currency),
+					"total_minor":    minorExpr("$total", currency),
+					"items": bson.M{"$map": bson.M{
+						"input": "$items",
+						"in": bson.M{"$mergeObjects": bson.A{"$$this", bson.M{
+							"price_minor":    minorExpr("$$this.price", currency),
+							"subtotal_minor": minorExpr("$$this.subtotal", currency),
+						}}},
+					}},
+				}}},
+				{{Key: "$unset", Value: bson.A{"subtotal", "discount", "total", "items.price", "items.subtotal"}}},
+			},
+		)
+		if err != nil {
+			return fmt.Errorf("failed to migrate %s orders: %v", code, err)
+		}
+		orders += result.ModifiedCount
+	}
+
+	if products > 0 || coupons > 0 || orders > 0 {
+		log.Printf("Money migration: converted %d products, %d coupon fields and %d orders to minor units", products, coupons, orders)
+	}
+	return nil
+}
+
+// migrateAmounts moves float fields to their minor-unit replacements
+func migrateAmounts(ctx context.Context, coll *mongo.Collection, filter bson.M, currency Currency, fields map[string]string) (int64, error) {
+	var modified int64
+	for from, to := range fields {
+		match := bson.M{from: bson.M{"$exists": true}}
+		for key, value := range filter {
+			match[key] = value
+		}
+
+		result, err := coll.UpdateMany(ctx, match, mongo.Pipeline{
+			{{Key: "$set", Value: bson.M{to: minorExpr("$"+from, currency)}}},
+			{{Key: "$unset", Value: from}},
+		})
+		if err != nil {
+			return modified, fmt.Errorf("failed to migrate %s.%s: %v", coll.Name(), from, err)
+		}
+		modified += result.ModifiedCount
+	}
+	return modified, nil
+}
+
+// minorExpr converts a major-unit amount to minor units in an update
+// pipeline, rounding half up since stored amounts are never negative. The
+// float is converted to a decimal first, which keeps its 15 significant
+// digits, so 0.285 scales to exactly 28.5 rather than 28.499999999999996.
+func minorExpr(amount string, currency Currency) bson.M {
+	scale, _ := primitive.ParseDecimal128("1" + strings.Repeat("0", currency.Exponent))
+	half, _ := primitive.ParseDecimal128("0.5")
+	scaled := bson.M{"$multiply": bson.A{bson.M{"$toDecimal": amount}, scale}}
+	return bson.M{"$toLong": bson.M{"$floor": bson.M{"$add": bson.A{scaled, half}}}}
+}
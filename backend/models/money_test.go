@@ -0,0 +1,171 @@
+package models
+
+import (
+	"math"
+	"strconv"
+	"testing"
+)
+
+// TestParseAmount checks parsing of major-unit decimals into minor units
+func TestParseAmount(t *testing.T) {
+	usd, idr, jpy := CurrencyOf("USD"), CurrencyOf("IDR"), CurrencyOf("JPY")
+
+	tests := []struct {
+		currency Currency
+		input    string
+		want     int64
+		wantErr  bool
+	}{
+		{currency: usd, input: "12.50", want: 1250},
+		{currency: usd, input: " 12.5 ", want: 1250},
+		{currency: usd, input: "12", want: 1200},
+		{currency: usd, input: ".5", want: 50},
+		{currency: usd, input: "5.", want: 500},
+		{currency: usd, input: "+3.10", want: 310},
+		{currency: usd, input: "0.285", want: 29},
+		{currency: usd, input: "1.005", want: 101},
+		{currency: usd, input: "1.004", want: 100},
+		{currency: usd, input: "0.999", want: 100},
+		{currency: usd, input: "-1.005", want: -101},
+		{currency: usd, input: "-0.50", want: -50},
+		{currency: usd, input: "007.00", want: 700},
+		{currency: idr, input: "15000", want: 1500000},
+		{currency: idr, input: "15000.50", want: 1500050},
+		{currency: jpy, input: "1500", want: 1500},
+		{currency: jpy, input: "1500.5", want: 1501},
+		{currency: jpy, input: "1500.49", want: 1500},
+		{currency: jpy, input: "-2.5", want: -3},
+		{currency: usd, input: "92233720368547758.07", want: math.MaxInt64},
+		{currency: usd, input: "92233720368547758.08", wantErr: true},
+		{currency: jpy, input: "99999999999999999999", wantErr: true},
+		{currency: usd, input: "", wantErr: true},
+		{currency: usd, input: ".", wantErr: true},
+		{currency: usd, input: "-", wantErr: true},
+		{currency: usd, input: "1,000", wantErr: true},
+		{currency: usd, input: "1e3", wantErr: true},
+		{currency: usd, input: "12.5.0", wantErr: true},
+		{currency: usd, input: "--1", wantErr: true},
+		{currency: usd, input: "$5", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := tt.currency.ParseAmount(tt.input)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("%s ParseAmount(%q) = %d, want an error", tt.currency.Code, tt.input, got)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("%s ParseAmount(%q) = %d, %v, want %d", tt.currency.Code, tt.input, got, err, tt.want)
+		}
+	}
+}
+
+// TestFromMajor checks that floats round on their decimal representation
+func TestFromMajor(t *testing.T) {
+	usd, jpy := CurrencyOf("USD"), CurrencyOf("JPY")
+
+	tests := []struct {
+		currency Currency
+		amount   float64
+		want     int64
+	}{
+		{currency: usd, amount: 0.285, want: 29},
+		{currency: usd, amount: 1.005, want: 101},
+		{currency: usd, amount: 19.99, want: 1999},
+		{currency: usd, amount: -1.005, want: -101},
+		{currency: usd, amount: 0, want: 0},
+		{currency: jpy, amount: 2.5, want: 3},
+		{currency: usd, amount: math.NaN(), want: 0},
+		{currency: usd, amount: math.Inf(1), want: 0},
+		{currency: usd, amount: 1e300, want: 0},
+	}
+
+	for _, tt := range tests {
+		if got := tt.currency.FromMajor(tt.amount); got != tt.want {
+			t.Errorf("%s FromMajor(%v) = %d, want %d", tt.currency.Code, tt.amount, got, tt.want)
+		}
+	}
+}
+
+// TestDecimalString checks plain decimals with every minor digit
+func TestDecimalString(t *testing.T) {
+	tests := []struct {
+		code  string
+		minor int64
+		want  string
+	}{
+		{code: "USD", minor: 1250, want: "12.50"},
+		{code: "USD", minor: 5, want: "0.05"},
+		{code: "USD", minor: 0, want: "0.00"},
+		{code: "USD", minor: -5, want: "-0.05"},
+		{code: "USD", minor: 123456789, want: "1234567.89"},
+		{code: "IDR", minor: 1500000, want: "15000.00"},
+		{code: "JPY", minor: 1500, want: "1500"},
+		{code: "JPY", minor: -7, want: "-7"},
+		{code: "USD", minor: math.MaxInt64, want: "92233720368547758.07"},
+	}
+
+	for _, tt := range tests {
+		if got := CurrencyOf(tt.code).DecimalString(tt.minor); got != tt.want {
+			t.Errorf("%s DecimalString(%d) = %q, want %q", tt.code, tt.minor, got, tt.want)
+		}
+	}
+}
+
+// TestFormat checks amounts formatted for people, with grouping and the
+// currency's shown fraction digits
+func TestFormat(t *testing.T) {
+	tests := []struct {
+		code  string
+		minor int64
+		want  string
+	}{
+		{code: "USD", minor: 1250, want: "$12.50"},
+		{code: "USD", minor: 123456789, want: "$1,234,567.89"},
+		{code: "USD", minor: 100000, want: "$1,000.00"},
+		{code: "USD", minor: 99999, want: "$999.99"},
+		{code: "USD", minor: 0, want: "$0.00"},
+		{code: "USD", minor: -1250, want: "-$12.50"},
+		{code: "EUR", minor: 123456, want: "€1.234,56"},
+		{code: "IDR", minor: 1500000, want: "Rp 15.000"},
+		{code: "IDR", minor: 1500050, want: "Rp 15.001"},
+		{code: "IDR", minor: 1500049, want: "Rp 15.000"},
+		{code: "IDR", minor: 49, want: "Rp 0"},
+		{code: "IDR", minor: -150000000, want: "-Rp 1.500.000"},
+		{code: "JPY", minor: 1234567, want: "¥1,234,567"},
+		{code: "VND", minor: 25000, want: "₫25.000"},
+		{code: "XXX", minor: 1500000, want: "Rp 15.000"},
+	}
+
+	for _, tt := range tests {
+		if got := CurrencyOf(tt.code).Format(tt.minor); got != tt.want {
+			t.Errorf("%s Format(%d) = %q, want %q", tt.code, tt.minor, got, tt.want)
+		}
+	}
+}
+
+// TestPercentOf checks percentages of minor amounts round half away from zero
+func TestPercentOf(t *testing.T) {
+	tests := []struct {
+		minor   int64
+		percent float64
+		want    int64
+	}{
+		{minor: 10000, percent: 10, want: 1000},
+		{minor: 1250, percent: 11, want: 138},
+		{minor: 50, percent: 1, want: 1},
+		{minor: 49, percent: 1, want: 0},
+		{minor: 333, percent: 12.5, want: 42},
+		{minor: -50, percent: 1, want: -1},
+		{minor: 10000, percent: 0, want: 0},
+		{minor: 10000, percent: 100, want: 10000},
+	}
+
+	for _, tt := range tests {
+		if got := PercentOf(tt.minor, tt.percent); got != tt.want {
+			t.Errorf("PercentOf(%d, %s) = %d, want %d", tt.minor, strconv.FormatFloat(tt.percent, 'f', -1, 64), got, tt.want)
+		}
+	}
+}
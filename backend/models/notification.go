@@ -0,0 +1,85 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// NotificationCollection holds owner notifications waiting to be sent or
+// already delivered
+const NotificationCollection = "notifications"
+
+// Notification channels, each sent through its own provider
+const (
+	NotifyWhatsApp = "whatsapp"
+	NotifyEmail    = "email"
+)
+
+// Notification events
+const (
+	NotificationOrderCreated = "order.created"
+	NotificationStoreInvite  = "store.invite" // Sent right away and not stored, as it holds the invitation link
+)
+
+// Notification statuses. Pending notifications are retried with backoff
+// until they are sent or run out of attempts.
+const (
+	NotificationPending = "pending"
+	NotificationSent    = "sent"
+	NotificationFailed  = "failed"
+)
+
+// A notification is given up after this many delivery attempts
+const NotificationMaxAttempts = 6
+
+// NotificationSettings choose how a store's owner hears about new orders
+type NotificationSettings struct {
+	NewOrders bool   `bson:"new_orders" json:"newOrders"`
+	WhatsApp  string `bson:"whatsapp,omitempty" json:"whatsapp,omitempty"` // Number to message; empty turns WhatsApp off
+	Email     string `bson:"email,omitempty" json:"email,omitempty"`       // Address to email; empty turns email off
+}
+
+// NotificationSettings returns the store's settings, or the defaults of
+// stores that never set them: new orders go to the store's WhatsApp number
+func (s Store) NotificationSettings() NotificationSettings {
+	if s.Notifications != nil {
+		return *s.Notifications
+	}
+	return NotificationSettings{NewOrders: true, WhatsApp: s.WhatsappNumber}
+}
+
+// Public returns the store as anyone may see it, without the owner's
+// private notification settings
+func (s Store) Public() Store {
+	s.Notifications = nil
+	return s
+}
+
+// Notification is a message to a store owner about an event in their store
+type Notification struct {
+	ID             primitive.ObjectID `bson:"_id" json:"id"`
+	StoreID        primitive.ObjectID `bson:"store_id" json:"storeId"`
+	OrderID        primitive.ObjectID `bson:"order_id,omitempty" json:"orderId,omitempty"`
+	Event          string             `bson:"event" json:"event"`
+	Channel        string             `bson:"channel" json:"channel"`
+	Recipient      string             `bson:"recipient" json:"recipient"` // Phone number or email address
+	Subject        string             `bson:"subject" json:"subject"`
+	Body           string             `bson:"body" json:"body"`
+	TemplateParams []string           `bson:"template_params,omitempty" json:"templateParams,omitempty"` // Filled into the WhatsApp template
+	Status         string             `bson:"status" json:"status"`
+	Attempts       int                `bson:"attempts" json:"attempts"`
+	LastError      string             `bson:"last_error,omitempty" json:"lastError,omitempty"`
+	NextAttemptAt  time.Time          `bson:"next_attempt_at" json:"nextAttemptAt"` // Also pushed back while an attempt is in progress
+	SentAt         *time.Time         `bson:"sent_at,omitempty" json:"sentAt,omitempty"`
+	CreatedAt      time.Time          `bson:"created_at" json:"createdAt"`
+	UpdatedAt      time.Time          `bson:"updated_at" json:"updatedAt"`
+}
+
+// NotificationListResponse is a page of a store's notifications
+type NotificationListResponse struct {
+	Notifications []Notification `json:"notifications"`
+	Total         int64          `json:"total"`
+	Page          int64          `json:"page"`
+	Limit         int64          `json:"limit"`
+}
@@ -0,0 +1,165 @@
+package models
+
+import (
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// OrderCollection holds the orders customers send to stores over WhatsApp
+const OrderCollection = "orders"
+
+// Order statuses. Orders start pending when the customer is handed over to
+// WhatsApp; the owner confirms, completes or cancels them from the dashboard.
+// Tracked stock is taken when an order is confirmed or completed.
+const (
+	OrderPending   = "pending"
+	OrderConfirmed = "confirmed"
+	OrderCompleted = "completed"
+	OrderCancelled = "cancelled"
+)
+
+// RevenueStatuses are the order statuses that count towards revenue
+var RevenueStatuses = []string{OrderConfirmed, OrderCompleted}
+
+// OrderItem is a product line of an order, priced when the order was placed
+type OrderItem struct {
+	ProductID     primitive.ObjectID `bson:"product_id" json:"productId"`
+	SKU           string             `bson:"sku,omitempty" json:"sku,omitempty"`
+	Name          string             `bson:"name" json:"name"`
+	Category      string             `bson:"category,omitempty" json:"category,omitempty"`
+	PriceMinor    int64              `bson:"price_minor" json:"priceMinor"`
+	Quantity      int                `bson:"quantity" json:"quantity"`
+	SubtotalMinor int64              `bson:"subtotal_minor" json:"subtotalMinor"`
+	TrackStock    bool               `bson:"track_stock,omitempty" json:"-"`    // The product tracked stock when the order was placed
+	StockReserved bool               `bson:"stock_reserved,omitempty" json:"-"` // Taken from the product's tracked stock, returned if a confirmed order is cancelled
+
+	// Computed by Order.ApplyCurrency
+	FormattedPrice    string `bson:"-" json:"formattedPrice"`
+	FormattedSubtotal string `bson:"-" json:"formattedSubtotal"`
+
+	// Deprecated: major-unit amounts, kept for the compatibility period
+	LegacyPrice    float64 `bson:"-" json:"price" deprecated:"true"`
+	LegacySubtotal float64 `bson:"-" json:"subtotal" deprecated:"true"`
+}
+
+// Order represents an order document in MongoDB. Amounts are in minor units
+// of Currency, the store's currency when the order was placed.
+type Order struct {
+	ID            primitive.ObjectID `bson:"_id" json:"id"`
+	StoreID       primitive.ObjectID `bson:"store_id" json:"storeId"`
+	Reference     string             `bson:"reference" json:"reference"` // Short code quoted in the WhatsApp message
+	Items         []OrderItem        `bson:"items" json:"items"`
+	SubtotalMinor int64              `bson:"subtotal_minor" json:"subtotalMinor"`
+	CouponID      primitive.ObjectID `bson:"coupon_id,omitempty" json:"couponId,omitempty"`
+	CouponCode    string             `bson:"coupon_code,omitempty" json:"couponCode,omitempty"`
+	CouponPhone   string             `bson:"coupon_phone,omitempty" json:"-"`  // Phone the coupon's per-customer limit was counted under
+	CouponVoided  bool               `bson:"coupon_voided,omitempty" json:"-"` // The coupon use was given back when the order was cancelled
+	DiscountMinor int64              `bson:"discount_minor" json:"discountMinor"`
+	Charges       []OrderCharge      `bson:"charges,omitempty" json:"charges,omitempty"` // Service charge and tax, see ApplyCharges
+	Fulfilment    *OrderFulfilment   `bson:"fulfilment,omitempty" json:"fulfilment,omitempty"`
+	Delivery      *OrderDelivery     `bson:"delivery,omitempty" json:"delivery,omitempty"`
+	Shipping      *OrderShipping     `bson:"shipping,omitempty" json:"shipping,omitempty"`
+	TotalMinor    int64              `bson:"total_minor" json:"totalMinor"` // Subtotal less discount plus charges not already in the prices, delivery and shipping
+	Currency      string             `bson:"currency" json:"currency"`
+	CustomerID    primitive.ObjectID `bson:"customer_id,omitempty" json:"customerId,omitempty"` // Set when the customer gave a phone number
+	CustomerName  string             `bson:"customer_name,omitempty" json:"customerName,omitempty"`
+	CustomerPhone string             `bson:"customer_phone,omitempty" json:"customerPhone,omitempty"`
+	Note          string             `bson:"note,omitempty" json:"note,omitempty"`
+	Conversation  *OrderConversation `bson:"conversation,omitempty" json:"conversation,omitempty"` // WhatsApp messages received about the order
+	Status        string             `bson:"status" json:"status"`
+	CreatedAt     time.Time          `bson:"created_at" json:"createdAt"`
+	UpdatedAt     time.Time          `bson:"updated_at" json:"updatedAt"`
+
+	// Computed by ApplyCurrency
+	FormattedSubtotal string `bson:"-" json:"formattedSubtotal"`
+	FormattedDiscount string `bson:"-" json:"formattedDiscount,omitempty"`
+	FormattedTotal    string `bson:"-" json:"formattedTotal"`
+
+	// Deprecated: major-unit amounts, kept for the compatibility period
+	LegacySubtotal float64 `bson:"-" json:"subtotal" deprecated:"true"`
+	LegacyDiscount float64 `bson:"-" json:"discount" deprecated:"true"`
+	LegacyTotal    float64 `bson:"-" json:"total" deprecated:"true"`
+}
+
+// ApplyCurrency fills in the formatted and deprecated major-unit amounts of
+// the order and its items
+func (o *Order) ApplyCurrency() {
+	currency := CurrencyOf(o.Currency)
+	for i := range o.Items {
+		item := &o.Items[i]
+		item.FormattedPrice = currency.Format(item.PriceMinor)
+		item.FormattedSubtotal = currency.Format(item.SubtotalMinor)
+		item.LegacyPrice = currency.Major(item.PriceMinor)
+		item.LegacySubtotal = currency.Major(item.SubtotalMinor)
+	}
+	for i := range o.Charges {
+		o.Charges[i].FormattedAmount = currency.Format(o.Charges[i].AmountMinor)
+	}
+	if o.Delivery != nil {
+		o.Delivery.FormattedFee = currency.Format(o.Delivery.FeeMinor)
+	}
+	if o.Shipping != nil {
+		o.Shipping.FormattedFee = currency.Format(o.Shipping.FeeMinor)
+	}
+	o.FormattedSubtotal = currency.Format(o.SubtotalMinor)
+	o.FormattedDiscount = ""
+	if o.DiscountMinor > 0 {
+		o.FormattedDiscount = currency.Format(o.DiscountMinor)
+	}
+	o.FormattedTotal = currency.Format(o.TotalMinor)
+	o.LegacySubtotal = currency.Major(o.SubtotalMinor)
+	o.LegacyDiscount = currency.Major(o.DiscountMinor)
+	o.LegacyTotal = currency.Major(o.TotalMinor)
+}
+
+// ApplyCurrency fills in the formatted amounts of orders
+func ApplyCurrency(orders []Order) {
+	for i := range orders {
+		orders[i].ApplyCurrency()
+	}
+}
+
+// OrderReference derives the short reference of an order from its ID
+func OrderReference(id primitive.ObjectID) string {
+	return strings.ToUpper(id.Hex()[16:])
+}
+
+// CheckoutItem is a product and quantity in a checkout request
+type CheckoutItem struct {
+	ProductID string `json:"productId"`
+	Quantity  int    `json:"quantity"`
+}
+
+// CheckoutRequest represents the request body for placing an order
+type CheckoutRequest struct {
+	Items         []CheckoutItem    `json:"items"`
+	CouponCode    string            `json:"couponCode,omitempty"`
+	Fulfilment    FulfilmentChoice  `json:"fulfilment"`         // Required at checkout, optional for quotes
+	Delivery      *DeliveryLocation `json:"delivery,omitempty"` // For delivery
+	Shipping      *ShippingChoice   `json:"shipping,omitempty"` // For shipping
+	CustomerName  string            `json:"customerName,omitempty"`
+	CustomerPhone string            `json:"customerPhone,omitempty"`
+	Note          string            `json:"note,omitempty"`
+}
+
+// CheckoutResponse is a placed order with the WhatsApp link that sends it to the store
+type CheckoutResponse struct {
+	Order       Order  `json:"order"`
+	Message     string `json:"message"`
+	WhatsAppURL string `json:"whatsappUrl"`
+}
+
+// UpdateOrderStatusRequest represents the request body for changing an order's status
+type UpdateOrderStatusRequest struct {
+	Status string `json:"status"`
+}
+
+// OrderListResponse is a page of a store's orders
+type OrderListResponse struct {
+	Orders []Order `json:"orders"`
+	Total  int64   `json:"total"`
+	Page   int64   `json:"page"`
+	Limit  int64   `json:"limit"`
+}
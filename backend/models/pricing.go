@@ -0,0 +1,55 @@
+package models
+
+import (
+	"math"
+	"time"
+)
+
+// SaleActive reports whether the product's sale price applies at t
+func (p Product) SaleActive(t time.Time) bool {
+	if p.SalePriceMinor <= 0 || p.SalePriceMinor >= p.PriceMinor {
+		return false
+	}
+	if p.SaleStartsAt != nil && t.Before(*p.SaleStartsAt) {
+		return false
+	}
+	if p.SaleEndsAt != nil && !t.Before(*p.SaleEndsAt) {
+		return false
+	}
+	return true
+}
+
+// ApplyPricing fills in the effective price and discount as of t, formatted
+// in the store's currency
+func (p *Product) ApplyPricing(t time.Time, currency Currency) {
+	p.Currency = currency.Code
+	p.EffectivePriceMinor = p.PriceMinor
+	p.OnSale = p.SaleActive(t)
+	if p.OnSale {
+		p.EffectivePriceMinor = p.SalePriceMinor
+	}
+
+	reference := p.PriceMinor
+	if p.CompareAtPriceMinor > 0 {
+		reference = p.CompareAtPriceMinor
+	}
+	p.DiscountPercent = 0
+	p.FormattedCompareAtPrice = ""
+	if reference > p.EffectivePriceMinor {
+		p.DiscountPercent = int(math.Round(float64(reference-p.EffectivePriceMinor) / float64(reference) * 100))
+		p.FormattedCompareAtPrice = currency.Format(reference)
+	}
+	p.FormattedPrice = currency.Format(p.EffectivePriceMinor)
+
+	p.LegacyPrice = currency.Major(p.PriceMinor)
+	p.LegacySalePrice = currency.Major(p.SalePriceMinor)
+	p.LegacyCompareAtPrice = currency.Major(p.CompareAtPriceMinor)
+	p.LegacyEffectivePrice = currency.Major(p.EffectivePriceMinor)
+}
+
+// ApplyPricing fills in the effective prices of products as of t
+func ApplyPricing(products []Product, t time.Time, currency Currency) {
+	for i := range products {
+		products[i].ApplyPricing(t, currency)
+	}
+}
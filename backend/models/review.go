@@ -0,0 +1,95 @@
+package models
+
+import (
+	"math"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// ReviewCollection holds the reviews customers leave on products they ordered
+const ReviewCollection = "reviews"
+
+// Review statuses. Reviews are published when submitted; owners can hide
+// them from the storefront and admins can remove abusive ones for good.
+const (
+	ReviewPublished = "published"
+	ReviewHidden    = "hidden"
+	ReviewRemoved   = "removed"
+)
+
+// Review is a customer's rating of a product, verified against an order
+// that contained it
+type Review struct {
+	ID         primitive.ObjectID `bson:"_id" json:"id"`
+	StoreID    primitive.ObjectID `bson:"store_id" json:"storeId"`
+	ProductID  primitive.ObjectID `bson:"product_id" json:"productId"`
+	OrderID    primitive.ObjectID `bson:"order_id" json:"-"`
+	CustomerID primitive.ObjectID `bson:"customer_id,omitempty" json:"-"`
+	AuthorName string             `bson:"author_name" json:"authorName"`
+	Rating     int                `bson:"rating" json:"rating"` // 1 to 5
+	Title      string             `bson:"title,omitempty" json:"title,omitempty"`
+	Body       string             `bson:"body,omitempty" json:"body,omitempty"`
+	Reply      *ReviewReply       `bson:"reply,omitempty" json:"reply,omitempty"`
+	Status     string             `bson:"status" json:"status"`
+	Removal    *ReviewRemoval     `bson:"removal,omitempty" json:"removal,omitempty"`
+	CreatedAt  time.Time          `bson:"created_at" json:"createdAt"`
+	UpdatedAt  time.Time          `bson:"updated_at" json:"updatedAt"`
+}
+
+// ReviewReply is the store's public answer to a review
+type ReviewReply struct {
+	Body      string             `bson:"body" json:"body"`
+	AuthorID  primitive.ObjectID `bson:"author_id" json:"-"`
+	CreatedAt time.Time          `bson:"created_at" json:"createdAt"`
+}
+
+// ReviewRemoval records why an admin removed a review
+type ReviewRemoval struct {
+	Reason    string             `bson:"reason" json:"reason"`
+	RemovedBy primitive.ObjectID `bson:"removed_by" json:"removedBy"`
+	RemovedAt time.Time          `bson:"removed_at" json:"removedAt"`
+}
+
+// RatingSummary is the average of the published reviews of a product or store
+type RatingSummary struct {
+	Average float64 `bson:"average" json:"average"` // Rounded to one decimal
+	Count   int     `bson:"count" json:"count"`
+}
+
+// RoundRating rounds an average rating to one decimal, the way it is shown
+func RoundRating(average float64) float64 {
+	return math.Round(average*10) / 10
+}
+
+// CreateReviewRequest represents the request body for reviewing a product.
+// The order reference and phone number prove the customer bought it.
+type CreateReviewRequest struct {
+	OrderReference string `json:"orderReference"`
+	Phone          string `json:"phone"`
+	Rating         int    `json:"rating"`
+	Title          string `json:"title,omitempty"`
+	Body           string `json:"body,omitempty"`
+	Name           string `json:"name,omitempty"` // Defaults to the name on the order
+}
+
+// ModerateReviewRequest represents the request body for an owner's reply to
+// or hiding of a review
+type ModerateReviewRequest struct {
+	Reply  *string `json:"reply,omitempty"` // Empty removes the reply
+	Hidden *bool   `json:"hidden,omitempty"`
+}
+
+// RemoveReviewRequest represents the request body for an admin removing a review
+type RemoveReviewRequest struct {
+	Reason string `json:"reason"`
+}
+
+// ReviewListResponse is a page of reviews
+type ReviewListResponse struct {
+	Reviews []Review       `json:"reviews"`
+	Rating  *RatingSummary `json:"rating,omitempty"`
+	Total   int64          `json:"total"`
+	Page    int64          `json:"page"`
+	Limit   int64          `json:"limit"`
+}
@@ -0,0 +1,118 @@
+package models
+
+import (
+	"math"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// CourierRateCollection holds the courier rate tables owners upload
+const CourierRateCollection = "courier_rates"
+
+// VolumetricDivisor converts cubic centimeters to volumetric kilograms, the
+// divisor most couriers use for parcels
+const VolumetricDivisor = 6000
+
+// CourierRate is a row of a courier service's rate table: the price per
+// kilogram from an origin region to a destination region. Prices are in
+// minor units of the store's currency.
+type CourierRate struct {
+	ID              primitive.ObjectID `bson:"_id" json:"id"`
+	StoreID         primitive.ObjectID `bson:"store_id" json:"storeId"`
+	Courier         string             `bson:"courier" json:"courier"` // e.g. "JNE"
+	Service         string             `bson:"service" json:"service"` // e.g. "REG"
+	Origin          string             `bson:"origin" json:"origin"`
+	Destination     string             `bson:"destination" json:"destination"`
+	OriginKey       string             `bson:"origin_key" json:"-"`      // RegionKey of Origin
+	DestinationKey  string             `bson:"destination_key" json:"-"` // RegionKey of Destination
+	PricePerKgMinor int64              `bson:"price_per_kg_minor" json:"pricePerKgMinor"`
+	MinDays         int                `bson:"min_days" json:"minDays"`
+	MaxDays         int                `bson:"max_days" json:"maxDays"`
+	CreatedAt       time.Time          `bson:"created_at" json:"createdAt"`
+}
+
+// CourierRateTable summarizes an uploaded rate table of one courier service
+type CourierRateTable struct {
+	Courier   string    `bson:"courier" json:"courier"`
+	Service   string    `bson:"service" json:"service"`
+	Rates     int       `bson:"rates" json:"rates"`
+	UpdatedAt time.Time `bson:"updated_at" json:"updatedAt"`
+}
+
+// RegionKey normalizes a region name for matching, so "Jakarta Selatan" and
+// " jakarta  selatan" are the same region
+func RegionKey(region string) string {
+	return strings.ToLower(strings.Join(strings.Fields(region), " "))
+}
+
+// ShippingGrams is the weight a courier charges for one unit of the product:
+// its actual weight or its volumetric weight, whichever is greater
+func (p Product) ShippingGrams() int {
+	volumetric := int(math.Ceil(p.LengthCm * p.WidthCm * p.HeightCm * 1000 / VolumetricDivisor))
+	if volumetric > p.WeightGrams {
+		return volumetric
+	}
+	return p.WeightGrams
+}
+
+// ChargeableKilograms rounds a parcel weight up to whole kilograms, at least
+// one, the way couriers bill
+func ChargeableKilograms(grams int) int {
+	kg := (grams + 999) / 1000
+	if kg < 1 {
+		return 1
+	}
+	return kg
+}
+
+// ShippingQuoteRequest represents the request body for a shipping quote
+type ShippingQuoteRequest struct {
+	Items       []CheckoutItem `json:"items"`
+	Destination string         `json:"destination"` // Region, matched against rate table destinations
+}
+
+// ShippingOption is what one courier service charges to ship a cart
+type ShippingOption struct {
+	Courier        string `json:"courier"`
+	Service        string `json:"service"`
+	PriceMinor     int64  `json:"priceMinor"`
+	FormattedPrice string `json:"formattedPrice"`
+	MinDays        int    `json:"minDays"`
+	MaxDays        int    `json:"maxDays"`
+}
+
+// ShippingQuote lists the shipping options for a cart, cheapest first
+type ShippingQuote struct {
+	Origin      string           `json:"origin,omitempty"`
+	Destination string           `json:"destination"`
+	WeightGrams int              `json:"weightGrams"` // Chargeable weight, volumetric weight included
+	BilledKg    int              `json:"billedKg"`
+	Currency    string           `json:"currency"`
+	Options     []ShippingOption `json:"options"`
+}
+
+// ShippingChoice is the courier service and destination a customer picked
+// from a shipping quote
+type ShippingChoice struct {
+	Courier     string `json:"courier"`
+	Service     string `json:"service"`
+	Destination string `json:"destination"`       // Region
+	Address     string `json:"address,omitempty"` // Full address for the parcel
+}
+
+// OrderShipping records how an order is shipped and what it cost
+type OrderShipping struct {
+	Courier     string `bson:"courier" json:"courier"`
+	Service     string `bson:"service" json:"service"`
+	Destination string `bson:"destination" json:"destination"`
+	Address     string `bson:"address,omitempty" json:"address,omitempty"`
+	BilledKg    int    `bson:"billed_kg" json:"billedKg"`
+	FeeMinor    int64  `bson:"fee_minor" json:"feeMinor"`
+	MinDays     int    `bson:"min_days" json:"minDays"`
+	MaxDays     int    `bson:"max_days" json:"maxDays"`
+
+	// Computed by Order.ApplyCurrency
+	FormattedFee string `bson:"-" json:"formattedFee"`
+}
@@ -0,0 +1,28 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// SitemapCollection holds the generated sitemap of every active store, split
+// into parts that each fit the sitemap protocol limits
+const SitemapCollection = "sitemaps"
+
+// SitemapURL is a page listed in a sitemap. Path is relative to the public
+// base URL, which is only known when the sitemap is served.
+type SitemapURL struct {
+	Path    string    `bson:"path" json:"path"`
+	LastMod time.Time `bson:"lastmod" json:"lastmod"`
+}
+
+// SitemapPart is one sitemap file of a store
+type SitemapPart struct {
+	ID          primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	StoreID     primitive.ObjectID `bson:"store_id" json:"storeId"`
+	Part        int                `bson:"part" json:"part"` // 1-based
+	URLs        []SitemapURL       `bson:"urls" json:"urls"`
+	LastMod     time.Time          `bson:"lastmod" json:"lastmod"`
+	GeneratedAt time.Time          `bson:"generated_at" json:"generatedAt"`
+}
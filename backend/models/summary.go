@@ -0,0 +1,71 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// ProductCounts breaks down a store's products
+type ProductCounts struct {
+	Total      int64 `bson:"total" json:"total"`
+	Active     int64 `bson:"active" json:"active"`
+	Featured   int64 `bson:"featured" json:"featured"`
+	OutOfStock int64 `bson:"out_of_stock" json:"outOfStock"`
+	LowStock   int64 `bson:"low_stock" json:"lowStock"`
+}
+
+// RevenueWindow totals the revenue-counting orders of a period
+type RevenueWindow struct {
+	Days             int    `bson:"-" json:"days"`
+	Orders           int64  `bson:"orders" json:"orders"`
+	RevenueMinor     int64  `bson:"revenue_minor" json:"revenueMinor"`
+	FormattedRevenue string `bson:"-" json:"formattedRevenue"`
+
+	// Deprecated: major-unit revenue, kept for the compatibility period
+	LegacyRevenue float64 `bson:"-" json:"revenue" deprecated:"true"`
+}
+
+// TopSellingProduct is a product ranked by quantity sold
+type TopSellingProduct struct {
+	ProductID        primitive.ObjectID `bson:"_id" json:"productId"`
+	Name             string             `bson:"name" json:"name"`
+	Quantity         int64              `bson:"quantity" json:"quantity"`
+	RevenueMinor     int64              `bson:"revenue_minor" json:"revenueMinor"`
+	FormattedRevenue string             `bson:"-" json:"formattedRevenue"`
+
+	// Deprecated: major-unit revenue, kept for the compatibility period
+	LegacyRevenue float64 `bson:"-" json:"revenue" deprecated:"true"`
+}
+
+// DashboardSummary is the overview of a store shown on the owner dashboard
+type DashboardSummary struct {
+	StoreID           primitive.ObjectID  `json:"storeId"`
+	Currency          string              `json:"currency"`
+	Products          ProductCounts       `json:"products"`
+	LowStockThreshold int                 `json:"lowStockThreshold"`
+	LowStockItems     []Product           `json:"lowStockItems"`
+	OrdersByStatus    map[string]int64    `json:"ordersByStatus"`
+	RecentOrders      []Order             `json:"recentOrders"`
+	Revenue7Days      RevenueWindow       `json:"revenue7Days"`
+	Revenue30Days     RevenueWindow       `json:"revenue30Days"`
+	TopProducts       []TopSellingProduct `json:"topProducts"`   // Best sellers of the last 30 days
+	OrdersVisible     bool                `json:"ordersVisible"` // The user may see orders and revenue; those sections are empty otherwise
+}
+
+// ApplyCurrency fills in the formatted and deprecated major-unit amounts of
+// the summary
+func (s *DashboardSummary) ApplyCurrency() {
+	currency := CurrencyOf(s.Currency)
+	ApplyPricing(s.LowStockItems, time.Now(), currency)
+	ApplyCurrency(s.RecentOrders)
+	for _, window := range []*RevenueWindow{&s.Revenue7Days, &s.Revenue30Days} {
+		window.FormattedRevenue = currency.Format(window.RevenueMinor)
+		window.LegacyRevenue = currency.Major(window.RevenueMinor)
+	}
+	for i := range s.TopProducts {
+		product := &s.TopProducts[i]
+		product.FormattedRevenue = currency.Format(product.RevenueMinor)
+		product.LegacyRevenue = currency.Major(product.RevenueMinor)
+	}
+}
@@ -0,0 +1,513 @@
+package models
+
+import (
+	"net/mail"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+var couponCodePattern = regexp.MustCompile(`^[A-Za-z0-9_-]{3,32}$`)
+
+// FieldError describes why a single request field is invalid
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// Validate checks a registration request
+func (r RegisterRequest) Validate() []FieldError {
+	var errs []FieldError
+	if strings.TrimSpace(r.Username) == "" {
+		errs = append(errs, FieldError{Field: "username", Message: "is required"})
+	}
+	return errs
+}
+
+// Validate checks a store creation request
+func (r CreateStoreRequest) Validate() []FieldError {
+	var errs []FieldError
+	if strings.TrimSpace(r.Name) == "" {
+		errs = append(errs, FieldError{Field: "name", Message: "is required"})
+	}
+	if strings.TrimSpace(r.WhatsappNumber) == "" {
+		errs = append(errs, FieldError{Field: "whatsappNumber", Message: "is required"})
+	}
+	errs = append(errs, validateCurrency(r.Currency)...)
+	return errs
+}
+
+// Validate checks a store update request
+func (r UpdateStoreRequest) Validate() []FieldError {
+	errs := validateCurrency(r.Currency)
+	if r.Tax != nil {
+		errs = append(errs, validateRate("tax.rate", r.Tax.Enabled, r.Tax.Rate)...)
+		for i, category := range r.Tax.Categories {
+			if strings.TrimSpace(category) == "" {
+				errs = append(errs, FieldError{Field: "tax.categories[" + strconv.Itoa(i) + "]", Message: "must not be empty"})
+			}
+		}
+	}
+	if r.ServiceCharge != nil {
+		errs = append(errs, validateRate("serviceCharge.rate", r.ServiceCharge.Enabled, r.ServiceCharge.Rate)...)
+	}
+	if r.Coordinates != nil {
+		errs = append(errs, validatePoint("coordinates", r.Coordinates)...)
+	}
+	if r.Fulfilment != nil {
+		errs = append(errs, validateFulfilment(*r.Fulfilment)...)
+	}
+	if r.Notifications != nil {
+		errs = append(errs, validateNotifications(*r.Notifications)...)
+	}
+	return errs
+}
+
+// validateNotifications checks a store's notification settings
+func validateNotifications(n NotificationSettings) []FieldError {
+	var errs []FieldError
+	if n.WhatsApp != "" {
+		digits := 0
+		for _, c := range n.WhatsApp {
+			if c >= '0' && c <= '9' {
+				digits++
+			}
+		}
+		if digits < 8 || digits > 15 {
+			errs = append(errs, FieldError{Field: "notifications.whatsapp", Message: "must be a phone number with country code"})
+		}
+	}
+	if n.Email != "" {
+		if _, err := mail.ParseAddress(n.Email); err != nil {
+			errs = append(errs, FieldError{Field: "notifications.email", Message: "must be a valid email address"})
+		}
+	}
+	return errs
+}
+
+// validateFulfilment checks a store's fulfilment settings
+func validateFulfilment(f FulfilmentSettings) []FieldError {
+	var errs []FieldError
+	if len(f.Methods()) == 0 {
+		errs = append(errs, FieldError{Field: "fulfilment", Message: "must enable at least one method"})
+	}
+	for i, location := range f.Pickup.Locations {
+		if strings.TrimSpace(location.Name) == "" {
+			errs = append(errs, FieldError{Field: "fulfilment.pickup.locations[" + strconv.Itoa(i) + "].name", Message: "is required"})
+		}
+	}
+	labels := map[string]bool{}
+	for i, slot := range f.Pickup.Slots {
+		field := "fulfilment.pickup.slots[" + strconv.Itoa(i) + "]"
+		start, startErr := time.Parse("15:04", slot.Start)
+		end, endErr := time.Parse("15:04", slot.End)
+		switch {
+		case startErr != nil || endErr != nil:
+			errs = append(errs, FieldError{Field: field, Message: "must have start and end times such as 09:00"})
+		case !end.After(start):
+			errs = append(errs, FieldError{Field: field + ".end", Message: "must be after start"})
+		case labels[slot.Label()]:
+			errs = append(errs, FieldError{Field: field, Message: "repeats another slot"})
+		}
+		labels[slot.Label()] = true
+		for _, day := range slot.Days {
+			if day < 0 || day > 6 {
+				errs = append(errs, FieldError{Field: field + ".days", Message: "must be weekdays from 0 (Sunday) to 6"})
+				break
+			}
+		}
+	}
+	if f.Pickup.DaysAhead < 0 {
+		errs = append(errs, FieldError{Field: "fulfilment.pickup.daysAhead", Message: "must not be negative"})
+	}
+	if f.Pickup.Timezone != "" {
+		if _, err := time.LoadLocation(f.Pickup.Timezone); err != nil {
+			errs = append(errs, FieldError{Field: "fulfilment.pickup.timezone", Message: "must be an IANA time zone such as Asia/Jakarta"})
+		}
+	}
+	return errs
+}
+
+// validateRate checks a tax or service charge percentage
+func validateRate(field string, enabled bool, rate float64) []FieldError {
+	switch {
+	case rate < 0 || rate > 100:
+		return []FieldError{{Field: field, Message: "must be between 0 and 100"}}
+	case enabled && rate == 0:
+		return []FieldError{{Field: field, Message: "is required when enabled"}}
+	}
+	return nil
+}
+
+// validateCurrency checks an optional ISO 4217 currency code
+func validateCurrency(code string) []FieldError {
+	if code == "" {
+		return nil
+	}
+	if _, ok := LookupCurrency(code); !ok {
+		return []FieldError{{Field: "currency", Message: "must be one of " + strings.Join(CurrencyCodes(), ", ")}}
+	}
+	return nil
+}
+
+// Validate checks a product creation request. Deprecated major-unit amounts
+// must have been resolved first.
+func (r CreateProductRequest) Validate() []FieldError {
+	var errs []FieldError
+	if strings.TrimSpace(r.Name) == "" {
+		errs = append(errs, FieldError{Field: "name", Message: "is required"})
+	}
+	if r.Stock < 0 {
+		errs = append(errs, FieldError{Field: "stock", Message: "must not be negative"})
+	}
+	errs = append(errs, validateAmounts(&r.PriceMinor, &r.SalePriceMinor, &r.CompareAtPriceMinor, r.SaleStartsAt, r.SaleEndsAt)...)
+	errs = append(errs, validateParcel(&r.WeightGrams, &r.LengthCm, &r.WidthCm, &r.HeightCm)...)
+	return errs
+}
+
+// Validate checks a product update request. Deprecated major-unit amounts
+// must have been resolved first.
+func (r UpdateProductRequest) Validate() []FieldError {
+	var errs []FieldError
+	if r.Stock != nil && *r.Stock < 0 {
+		errs = append(errs, FieldError{Field: "stock", Message: "must not be negative"})
+	}
+	errs = append(errs, validateAmounts(r.PriceMinor, r.SalePriceMinor, r.CompareAtPriceMinor, r.SaleStartsAt, r.SaleEndsAt)...)
+	errs = append(errs, validateParcel(r.WeightGrams, r.LengthCm, r.WidthCm, r.HeightCm)...)
+	return errs
+}
+
+// validateParcel checks the optional shipping weight and dimensions of a product
+func validateParcel(weight *int, length, width, height *float64) []FieldError {
+	var errs []FieldError
+	if weight != nil && *weight < 0 {
+		errs = append(errs, FieldError{Field: "weightGrams", Message: "must not be negative"})
+	}
+	for field, value := range map[string]*float64{"lengthCm": length, "widthCm": width, "heightCm": height} {
+		if value != nil && *value < 0 {
+			errs = append(errs, FieldError{Field: field, Message: "must not be negative"})
+		}
+	}
+	return errs
+}
+
+// validateAmounts checks product pricing fields. Nil values are left
+// unchanged by the request and are not checked.
+func validateAmounts(price, salePrice, compareAtPrice *int64, startsAt, endsAt *time.Time) []FieldError {
+	var errs []FieldError
+	if price != nil && *price < 0 {
+		errs = append(errs, FieldError{Field: "priceMinor", Message: "must not be negative"})
+	}
+	if salePrice != nil && *salePrice < 0 {
+		errs = append(errs, FieldError{Field: "salePriceMinor", Message: "must not be negative"})
+	}
+	if price != nil && salePrice != nil && *salePrice > 0 && *salePrice >= *price {
+		errs = append(errs, FieldError{Field: "salePriceMinor", Message: "must be lower than the price"})
+	}
+	if compareAtPrice != nil && *compareAtPrice < 0 {
+		errs = append(errs, FieldError{Field: "compareAtPriceMinor", Message: "must not be negative"})
+	}
+	if startsAt != nil && endsAt != nil && !endsAt.After(*startsAt) {
+		errs = append(errs, FieldError{Field: "saleEndsAt", Message: "must be after saleStartsAt"})
+	}
+	return errs
+}
+
+// Validate checks a storefront analytics event
+func (r AnalyticsEventRequest) Validate() []FieldError {
+	var errs []FieldError
+	switch r.Type {
+	case EventStoreView, EventWhatsAppClick:
+	case EventProductView, EventAddToCart:
+		if r.ProductID == "" {
+			errs = append(errs, FieldError{Field: "productId", Message: "is required for " + r.Type + " events"})
+		}
+	default:
+		errs = append(errs, FieldError{Field: "type", Message: "must be one of store_view, product_view, add_to_cart, whatsapp_click"})
+	}
+	return errs
+}
+
+// Validate checks a checkout request
+func (r CheckoutRequest) Validate() []FieldError {
+	var errs []FieldError
+	if len(r.Items) == 0 {
+		errs = append(errs, FieldError{Field: "items", Message: "must contain at least one product"})
+	}
+	if len(r.Items) > 100 {
+		errs = append(errs, FieldError{Field: "items", Message: "must not contain more than 100 products"})
+	}
+	for i, item := range r.Items {
+		field := "items[" + strconv.Itoa(i) + "]"
+		if item.ProductID == "" {
+			errs = append(errs, FieldError{Field: field + ".productId", Message: "is required"})
+		}
+		if item.Quantity < 1 {
+			errs = append(errs, FieldError{Field: field + ".quantity", Message: "must be at least 1"})
+		}
+	}
+	if r.Delivery != nil {
+		errs = append(errs, validateLatLng("delivery.", r.Delivery.Latitude, r.Delivery.Longitude)...)
+	}
+	switch r.Fulfilment.Method {
+	case "":
+	case FulfilmentPickup:
+	case FulfilmentDelivery:
+		if r.Delivery == nil {
+			errs = append(errs, FieldError{Field: "delivery", Message: "is required for delivery"})
+		}
+	case FulfilmentShipping:
+		if r.Shipping == nil || r.Shipping.Courier == "" || r.Shipping.Service == "" || strings.TrimSpace(r.Shipping.Destination) == "" {
+			errs = append(errs, FieldError{Field: "shipping", Message: "must name a courier, service and destination for shipping"})
+		}
+	case FulfilmentDineIn:
+		if strings.TrimSpace(r.Fulfilment.TableNumber) == "" {
+			errs = append(errs, FieldError{Field: "fulfilment.tableNumber", Message: "is required for dine-in"})
+		}
+	default:
+		errs = append(errs, FieldError{Field: "fulfilment.method", Message: "must be one of pickup, delivery, shipping, dine_in"})
+	}
+	return errs
+}
+
+// Validate checks a shipping quote request
+func (r ShippingQuoteRequest) Validate() []FieldError {
+	errs := CheckoutRequest{Items: r.Items}.Validate()
+	if strings.TrimSpace(r.Destination) == "" {
+		errs = append(errs, FieldError{Field: "destination", Message: "is required"})
+	}
+	return errs
+}
+
+// Validate checks an order status change
+func (r UpdateOrderStatusRequest) Validate() []FieldError {
+	switch r.Status {
+	case OrderPending, OrderConfirmed, OrderCompleted, OrderCancelled:
+		return nil
+	}
+	return []FieldError{{Field: "status", Message: "must be one of pending, confirmed, completed, cancelled"}}
+}
+
+// Validate checks a customer annotation
+func (r UpdateCustomerRequest) Validate() []FieldError {
+	var errs []FieldError
+	if r.Name != nil && len(*r.Name) > 100 {
+		errs = append(errs, FieldError{Field: "name", Message: "must not be longer than 100 characters"})
+	}
+	if r.Notes != nil && len(*r.Notes) > 2000 {
+		errs = append(errs, FieldError{Field: "notes", Message: "must not be longer than 2000 characters"})
+	}
+	if r.Tags != nil {
+		if len(*r.Tags) > 20 {
+			errs = append(errs, FieldError{Field: "tags", Message: "must not contain more than 20 tags"})
+		}
+		for i, tag := range *r.Tags {
+			if tag = strings.TrimSpace(tag); tag == "" || len(tag) > 32 {
+				errs = append(errs, FieldError{Field: "tags[" + strconv.Itoa(i) + "]", Message: "must be 1 to 32 characters"})
+			}
+		}
+	}
+	return errs
+}
+
+// Validate checks an invitation request. Owners can't be invited; every store
+// has exactly one.
+func (r CreateInviteRequest) Validate() []FieldError {
+	var errs []FieldError
+	if r.Role == RoleOwner || !ValidRole(r.Role) {
+		errs = append(errs, FieldError{Field: "role", Message: "must be one of manager, staff-catalog, staff-orders"})
+	}
+	if r.Email != "" {
+		if _, err := mail.ParseAddress(r.Email); err != nil {
+			errs = append(errs, FieldError{Field: "email", Message: "must be an email address"})
+		}
+	}
+	return errs
+}
+
+// Validate checks a member role change
+func (r UpdateMemberRequest) Validate() []FieldError {
+	if r.Role == RoleOwner || !ValidRole(r.Role) {
+		return []FieldError{{Field: "role", Message: "must be one of manager, staff-catalog, staff-orders"}}
+	}
+	return nil
+}
+
+// Validate checks a review
+func (r CreateReviewRequest) Validate() []FieldError {
+	var errs []FieldError
+	if strings.TrimSpace(r.OrderReference) == "" {
+		errs = append(errs, FieldError{Field: "orderReference", Message: "is required"})
+	}
+	if strings.TrimSpace(r.Phone) == "" {
+		errs = append(errs, FieldError{Field: "phone", Message: "is required"})
+	}
+	if r.Rating < 1 || r.Rating > 5 {
+		errs = append(errs, FieldError{Field: "rating", Message: "must be between 1 and 5"})
+	}
+	if len(r.Title) > 100 {
+		errs = append(errs, FieldError{Field: "title", Message: "must not be longer than 100 characters"})
+	}
+	if len(r.Body) > 2000 {
+		errs = append(errs, FieldError{Field: "body", Message: "must not be longer than 2000 characters"})
+	}
+	if len(r.Name) > 60 {
+		errs = append(errs, FieldError{Field: "name", Message: "must not be longer than 60 characters"})
+	}
+	return errs
+}
+
+// Validate checks an owner's moderation of a review
+func (r ModerateReviewRequest) Validate() []FieldError {
+	var errs []FieldError
+	if r.Reply == nil && r.Hidden == nil {
+		errs = append(errs, FieldError{Field: "reply", Message: "or hidden is required"})
+	}
+	if r.Reply != nil && len(*r.Reply) > 2000 {
+		errs = append(errs, FieldError{Field: "reply", Message: "must not be longer than 2000 characters"})
+	}
+	return errs
+}
+
+// Validate checks an admin's removal of a review
+func (r RemoveReviewRequest) Validate() []FieldError {
+	if strings.TrimSpace(r.Reason) == "" {
+		return []FieldError{{Field: "reason", Message: "is required"}}
+	}
+	return nil
+}
+
+// Validate checks a coupon request. Deprecated major-unit amounts must have
+// been resolved first.
+func (r CouponRequest) Validate() []FieldError {
+	var errs []FieldError
+	if !couponCodePattern.MatchString(r.Code) {
+		errs = append(errs, FieldError{Field: "code", Message: "must be 3 to 32 letters, digits, dashes or underscores"})
+	}
+	switch r.Type {
+	case CouponPercentage:
+		if r.Percent <= 0 || r.Percent > 100 {
+			errs = append(errs, FieldError{Field: "percent", Message: "must be between 0 and 100 for percentage coupons"})
+		}
+	case CouponFixed:
+		if r.AmountMinor <= 0 {
+			errs = append(errs, FieldError{Field: "amountMinor", Message: "must be positive for fixed coupons"})
+		}
+	default:
+		errs = append(errs, FieldError{Field: "type", Message: "must be percentage or fixed"})
+	}
+	if r.MaxDiscountMinor < 0 {
+		errs = append(errs, FieldError{Field: "maxDiscountMinor", Message: "must not be negative"})
+	}
+	if r.MinOrderMinor < 0 {
+		errs = append(errs, FieldError{Field: "minOrderMinor", Message: "must not be negative"})
+	}
+	if r.StartsAt != nil && r.EndsAt != nil && !r.EndsAt.After(*r.StartsAt) {
+		errs = append(errs, FieldError{Field: "endsAt", Message: "must be after startsAt"})
+	}
+	if r.UsageLimit < 0 {
+		errs = append(errs, FieldError{Field: "usageLimit", Message: "must not be negative"})
+	}
+	if r.PerCustomerLimit < 0 {
+		errs = append(errs, FieldError{Field: "perCustomerLimit", Message: "must not be negative"})
+	}
+	for i, id := range r.ProductIDs {
+		if !primitive.IsValidObjectID(id) {
+			errs = append(errs, FieldError{Field: "productIds[" + strconv.Itoa(i) + "]", Message: "must be a valid ID"})
+		}
+	}
+	return errs
+}
+
+// ValidateSale checks the sale pricing of a product as it will be stored
+func ValidateSale(p Product) []FieldError {
+	return validateAmounts(&p.PriceMinor, &p.SalePriceMinor, &p.CompareAtPriceMinor, p.SaleStartsAt, p.SaleEndsAt)
+}
+
+// Validate checks a delivery zone request
+func (r DeliveryZoneRequest) Validate() []FieldError {
+	var errs []FieldError
+	if strings.TrimSpace(r.Name) == "" {
+		errs = append(errs, FieldError{Field: "name", Message: "is required"})
+	}
+	switch r.Type {
+	case ZoneRadius:
+		if r.MinDistanceMeters < 0 {
+			errs = append(errs, FieldError{Field: "minDistanceMeters", Message: "must not be negative"})
+		}
+		if r.MaxDistanceMeters <= r.MinDistanceMeters {
+			errs = append(errs, FieldError{Field: "maxDistanceMeters", Message: "must be greater than minDistanceMeters"})
+		}
+	case ZonePolygon:
+		errs = append(errs, validatePolygon("area", r.Area)...)
+	default:
+		errs = append(errs, FieldError{Field: "type", Message: "must be radius or polygon"})
+	}
+	if r.FeeMinor < 0 {
+		errs = append(errs, FieldError{Field: "feeMinor", Message: "must not be negative"})
+	}
+	if r.MinOrderMinor < 0 {
+		errs = append(errs, FieldError{Field: "minOrderMinor", Message: "must not be negative"})
+	}
+	return errs
+}
+
+// Validate checks a delivery quote request
+func (r DeliveryQuoteRequest) Validate() []FieldError {
+	return validateLatLng("", r.Latitude, r.Longitude)
+}
+
+// validateLatLng checks a latitude and longitude in degrees
+func validateLatLng(prefix string, latitude, longitude float64) []FieldError {
+	var errs []FieldError
+	if latitude < -90 || latitude > 90 {
+		errs = append(errs, FieldError{Field: prefix + "latitude", Message: "must be between -90 and 90"})
+	}
+	if longitude < -180 || longitude > 180 {
+		errs = append(errs, FieldError{Field: prefix + "longitude", Message: "must be between -180 and 180"})
+	}
+	return errs
+}
+
+// validatePoint checks a GeoJSON point
+func validatePoint(field string, p *GeoPoint) []FieldError {
+	if p.Type != "Point" {
+		return []FieldError{{Field: field + ".type", Message: "must be Point"}}
+	}
+	return validateLatLng(field+".coordinates.", p.Coordinates[1], p.Coordinates[0])
+}
+
+// validatePolygon checks that a GeoJSON polygon has closed rings of valid
+// positions. Self-intersections are left to MongoDB, which rejects them on insert.
+func validatePolygon(field string, p *GeoPolygon) []FieldError {
+	if p == nil {
+		return []FieldError{{Field: field, Message: "is required for polygon zones"}}
+	}
+	if p.Type != "Polygon" {
+		return []FieldError{{Field: field + ".type", Message: "must be Polygon"}}
+	}
+	if len(p.Coordinates) == 0 {
+		return []FieldError{{Field: field + ".coordinates", Message: "must contain a ring"}}
+	}
+	var errs []FieldError
+	for i, ring := range p.Coordinates {
+		ringField := field + ".coordinates[" + strconv.Itoa(i) + "]"
+		if len(ring) < 4 {
+			errs = append(errs, FieldError{Field: ringField, Message: "must have at least 4 positions"})
+			continue
+		}
+		if ring[0] != ring[len(ring)-1] {
+			errs = append(errs, FieldError{Field: ringField, Message: "must end where it starts"})
+		}
+		for _, position := range ring {
+			if position[0] < -180 || position[0] > 180 || position[1] < -90 || position[1] > 90 {
+				errs = append(errs, FieldError{Field: ringField, Message: "must hold longitude, latitude positions"})
+				break
+			}
+		}
+	}
+	return errs
+}
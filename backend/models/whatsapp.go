@@ -0,0 +1,124 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// ConversationMessageCollection holds the WhatsApp messages customers sent
+// about their orders
+const ConversationMessageCollection = "conversation_messages"
+
+// WhatsAppWebhook is the payload the WhatsApp Cloud API posts to the webhook.
+// Only the parts used to attach messages to orders are decoded.
+type WhatsAppWebhook struct {
+	Object string          `json:"object"` // "whatsapp_business_account"
+	Entry  []WhatsAppEntry `json:"entry"`
+}
+
+// WhatsAppEntry is the change set of one WhatsApp Business Account
+type WhatsAppEntry struct {
+	ID      string           `json:"id"`
+	Changes []WhatsAppChange `json:"changes"`
+}
+
+// WhatsAppChange is one notification; message notifications have the field "messages"
+type WhatsAppChange struct {
+	Field string        `json:"field"`
+	Value WhatsAppValue `json:"value"`
+}
+
+// WhatsAppValue carries the messages received by one business phone number.
+// Delivery statuses of sent messages arrive in the same shape and are ignored.
+type WhatsAppValue struct {
+	MessagingProduct string            `json:"messaging_product"`
+	Metadata         WhatsAppMetadata  `json:"metadata"`
+	Contacts         []WhatsAppContact `json:"contacts,omitempty"`
+	Messages         []WhatsAppMessage `json:"messages,omitempty"`
+}
+
+// WhatsAppMetadata identifies the business phone number that received the messages
+type WhatsAppMetadata struct {
+	DisplayPhoneNumber string `json:"display_phone_number"`
+	PhoneNumberID      string `json:"phone_number_id"`
+}
+
+// WhatsAppContact is the profile of a customer who sent a message
+type WhatsAppContact struct {
+	WaID    string `json:"wa_id"`
+	Profile struct {
+		Name string `json:"name"`
+	} `json:"profile"`
+}
+
+// WhatsAppMessage is a message a customer sent. Text is in Text for text
+// messages, in the caption for media and in Button for quick replies.
+type WhatsAppMessage struct {
+	ID        string `json:"id"`
+	From      string `json:"from"`
+	Timestamp string `json:"timestamp"` // Unix seconds
+	Type      string `json:"type"`
+	Text      *struct {
+		Body string `json:"body"`
+	} `json:"text,omitempty"`
+	Image    *WhatsAppMedia `json:"image,omitempty"`
+	Video    *WhatsAppMedia `json:"video,omitempty"`
+	Document *WhatsAppMedia `json:"document,omitempty"`
+	Button   *struct {
+		Text string `json:"text"`
+	} `json:"button,omitempty"`
+}
+
+// WhatsAppMedia is an attachment; only its caption is kept
+type WhatsAppMedia struct {
+	ID      string `json:"id"`
+	Caption string `json:"caption,omitempty"`
+}
+
+// Body returns the text of the message, or "" when it has none
+func (m WhatsAppMessage) Body() string {
+	switch {
+	case m.Text != nil:
+		return m.Text.Body
+	case m.Image != nil:
+		return m.Image.Caption
+	case m.Video != nil:
+		return m.Video.Caption
+	case m.Document != nil:
+		return m.Document.Caption
+	case m.Button != nil:
+		return m.Button.Text
+	}
+	return ""
+}
+
+// ConversationMessage is a WhatsApp message attached to an order
+type ConversationMessage struct {
+	ID            primitive.ObjectID `bson:"_id" json:"id"`
+	WhatsAppID    string             `bson:"whatsapp_id" json:"whatsappId"` // Message ID from the Cloud API, so redeliveries are ignored
+	StoreID       primitive.ObjectID `bson:"store_id" json:"storeId"`
+	OrderID       primitive.ObjectID `bson:"order_id" json:"orderId"`
+	From          string             `bson:"from" json:"from"` // Phone number digits
+	ProfileName   string             `bson:"profile_name,omitempty" json:"profileName,omitempty"`
+	BusinessPhone string             `bson:"business_phone,omitempty" json:"businessPhone,omitempty"` // Number that received it
+	Type          string             `bson:"type" json:"type"`
+	Text          string             `bson:"text,omitempty" json:"text,omitempty"`
+	SentAt        time.Time          `bson:"sent_at" json:"sentAt"`
+	ReceivedAt    time.Time          `bson:"received_at" json:"receivedAt"`
+}
+
+// OrderConversation summarises the WhatsApp messages attached to an order
+type OrderConversation struct {
+	Phone          string    `bson:"phone" json:"phone"` // Digits of the customer's WhatsApp number
+	ProfileName    string    `bson:"profile_name,omitempty" json:"profileName,omitempty"`
+	MessageCount   int       `bson:"message_count" json:"messageCount"`
+	FirstMessageAt time.Time `bson:"first_message_at" json:"firstMessageAt"`
+	LastMessageAt  time.Time `bson:"last_message_at" json:"lastMessageAt"`
+}
+
+// WebhookResponse acknowledges a webhook delivery
+type WebhookResponse struct {
+	Received int `json:"received"` // Messages in the payload
+	Attached int `json:"attached"` // Messages attached to an order
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"github.com/gorilla/mux"
+
+	"wacatalogue/backend/handlers"
+	"wacatalogue/backend/models"
+)
+
+// newRouter registers every route served by the backend
+func newRouter(db *models.Database, notifiers map[string]handlers.Notifier) *mux.Router {
+	router := mux.NewRouter()
+	router.NotFoundHandler = handlers.NotFoundHandler()
+	router.MethodNotAllowedHandler = handlers.MethodNotAllowedHandler()
+
+	// Liveness and readiness probes
+	router.HandleFunc("/livez", handlers.Livez()).Methods("GET")
+	router.HandleFunc("/readyz", handlers.Readyz(db)).Methods("GET")
+
+	// Server-rendered storefront pages
+	router.HandleFunc("/store/{storeId}", handlers.StorePage(db)).Methods("GET")
+	router.HandleFunc("/store/{storeId}/products/{id}", handlers.ProductPage(db)).Methods("GET")
+
+	// Crawler discovery
+	router.HandleFunc("/robots.txt", handlers.GetRobotsTxt(db)).Methods("GET")
+	router.HandleFunc("/sitemap.xml", handlers.GetSitemapIndex(db)).Methods("GET")
+	router.HandleFunc("/sitemaps/index-{page}.xml", handlers.GetSitemapIndex(db)).Methods("GET")
+	router.HandleFunc("/sitemaps/{storeId}/{part}.xml", handlers.GetStoreSitemap(db)).Methods("GET")
+
+	// API routes
+	apiRouter := router.PathPrefix("/api").Subrouter()
+
+	// Public routes
+	apiRouter.HandleFunc("/health", handlers.Livez()).Methods("GET")
+
+	// API documentation
+	apiRouter.HandleFunc("/openapi.json", handlers.GetOpenAPISpec()).Methods("GET")
+	apiRouter.HandleFunc("/docs", handlers.GetAPIDocs()).Methods("GET")
+
+	// Auth routes
+	apiRouter.HandleFunc("/auth/register", handlers.Register(db)).Methods("POST")
+	apiRouter.HandleFunc("/auth/login", handlers.Login(db)).Methods("POST")
+
+	// Store routes (public)
+	apiRouter.HandleFunc("/stores", handlers.GetAllStores(db)).Methods("GET")
+	apiRouter.HandleFunc("/stores/{id}", handlers.GetStore(db)).Methods("GET")
+	apiRouter.HandleFunc("/stores/{storeId}/products", handlers.GetStoreProducts(db)).Methods("GET")
+	apiRouter.HandleFunc("/products/{id}", handlers.GetProduct(db)).Methods("GET")
+
+	// Review routes (public)
+	apiRouter.HandleFunc("/products/{id}/reviews", handlers.GetProductReviews(db)).Methods("GET")
+	apiRouter.HandleFunc("/products/{id}/reviews", handlers.CreateReview(db)).Methods("POST")
+
+	// Catalog feeds (public, fetched by Meta and Google on a schedule)
+	apiRouter.HandleFunc("/stores/{storeId}/feeds/meta.csv", handlers.GetMetaCatalogCSV(db)).Methods("GET")
+	apiRouter.HandleFunc("/stores/{storeId}/feeds/meta.xml", handlers.GetMetaCatalogXML(db)).Methods("GET")
+	apiRouter.HandleFunc("/stores/{storeId}/feeds/google.xml", handlers.GetGoogleMerchantRSS(db)).Methods("GET")
+	apiRouter.HandleFunc("/stores/{storeId}/feeds/google.atom", handlers.GetGoogleMerchantAtom(db)).Methods("GET")
+
+	// Storefront analytics events (public, sent by the browser)
+	apiRouter.HandleFunc("/stores/{storeId}/events", handlers.RecordStorefrontEvent(db)).Methods("POST")
+
+	// Checkout (public, the customer is handed over to WhatsApp afterwards)
+	apiRouter.HandleFunc("/stores/{storeId}/quote", handlers.QuoteCart(db)).Methods("POST")
+	apiRouter.HandleFunc("/stores/{storeId}/delivery-quote", handlers.QuoteDelivery(db)).Methods("POST")
+	apiRouter.HandleFunc("/stores/{storeId}/shipping-quote", handlers.QuoteShipping(db)).Methods("POST")
+	apiRouter.HandleFunc("/stores/{storeId}/checkout", handlers.Checkout(db)).Methods("POST")
+
+	// WhatsApp Cloud API webhook
+	apiRouter.HandleFunc("/webhooks/whatsapp", handlers.VerifyWhatsAppWebhook(db)).Methods("GET")
+	apiRouter.HandleFunc("/webhooks/whatsapp", handlers.ReceiveWhatsAppWebhook(db)).Methods("POST")
+
+	// Protected routes
+	protectedRouter := apiRouter.PathPrefix("/").Subrouter()
+	protectedRouter.Use(handlers.AuthMiddleware)
+
+	// Store routes (protected)
+	protectedRouter.HandleFunc("/my-store", handlers.GetMyStore(db)).Methods("GET")
+	protectedRouter.HandleFunc("/my-store/summary", handlers.GetMyStoreSummary(db)).Methods("GET")
+	protectedRouter.HandleFunc("/my-stores", handlers.GetMyStores(db)).Methods("GET")
+	protectedRouter.HandleFunc("/my-stores/{id}/select", handlers.SelectMyStore(db)).Methods("POST")
+	protectedRouter.HandleFunc("/stores", handlers.CreateStore(db)).Methods("POST")
+	protectedRouter.HandleFunc("/stores/{id}", handlers.UpdateStore(db)).Methods("PUT")
+	protectedRouter.HandleFunc("/stores/{id}", handlers.DeleteStore(db)).Methods("DELETE")
+	protectedRouter.HandleFunc("/stores/{id}/audit-log", handlers.GetStoreAuditLog(db)).Methods("GET")
+	protectedRouter.HandleFunc("/stores/{id}/restore", handlers.RestoreStore(db)).Methods("POST")
+	protectedRouter.HandleFunc("/stores/{id}/analytics", handlers.GetStoreAnalytics(db)).Methods("GET")
+	protectedRouter.HandleFunc("/stores/{id}/analytics/top-products", handlers.GetTopProducts(db)).Methods("GET")
+	protectedRouter.HandleFunc("/stores/{id}/notifications", handlers.GetStoreNotifications(db)).Methods("GET")
+
+	// Product routes (protected)
+	protectedRouter.HandleFunc("/stores/{storeId}/products", handlers.CreateProduct(db)).Methods("POST")
+	protectedRouter.HandleFunc("/stores/{storeId}/products/export", handlers.ExportProductsCSV(db)).Methods("GET")
+	protectedRouter.HandleFunc("/stores/{storeId}/products/import", handlers.ImportProductsCSV(db)).Methods("POST")
+	protectedRouter.HandleFunc("/import-jobs/{id}", handlers.GetImportJob(db)).Methods("GET")
+	protectedRouter.HandleFunc("/stores/{storeId}/feeds/google/issues", handlers.GetGoogleFeedIssues(db)).Methods("GET")
+	protectedRouter.HandleFunc("/products/{id}", handlers.UpdateProduct(db)).Methods("PUT")
+	protectedRouter.HandleFunc("/products/{id}", handlers.DeleteProduct(db)).Methods("DELETE")
+	protectedRouter.HandleFunc("/products/{id}/restore", handlers.RestoreProduct(db)).Methods("POST")
+
+	// Order routes (protected)
+	protectedRouter.HandleFunc("/stores/{id}/orders", handlers.GetStoreOrders(db)).Methods("GET")
+	protectedRouter.HandleFunc("/orders/{id}", handlers.GetOrder(db)).Methods("GET")
+	protectedRouter.HandleFunc("/orders/{id}/status", handlers.UpdateOrderStatus(db)).Methods("PUT")
+	protectedRouter.HandleFunc("/orders/{id}/conversation", handlers.GetOrderConversation(db)).Methods("GET")
+
+	// Team routes (protected)
+	protectedRouter.HandleFunc("/stores/{id}/members", handlers.GetStoreTeam(db)).Methods("GET")
+	protectedRouter.HandleFunc("/stores/{id}/invites", handlers.CreateStoreInvite(db, notifiers[models.NotifyEmail])).Methods("POST")
+	protectedRouter.HandleFunc("/invites/accept", handlers.AcceptStoreInvite(db)).Methods("POST")
+	protectedRouter.HandleFunc("/invites/{id}", handlers.RevokeStoreInvite(db)).Methods("DELETE")
+	protectedRouter.HandleFunc("/members/{id}", handlers.UpdateStoreMember(db)).Methods("PUT")
+	protectedRouter.HandleFunc("/members/{id}", handlers.RemoveStoreMember(db)).Methods("DELETE")
+
+	// Customer routes (protected)
+	protectedRouter.HandleFunc("/stores/{id}/customers", handlers.GetStoreCustomers(db)).Methods("GET")
+	protectedRouter.HandleFunc("/customers/{id}", handlers.GetCustomer(db)).Methods("GET")
+	protectedRouter.HandleFunc("/customers/{id}", handlers.UpdateCustomer(db)).Methods("PUT")
+
+	// Review routes (protected)
+	protectedRouter.HandleFunc("/stores/{id}/reviews", handlers.GetStoreReviews(db)).Methods("GET")
+	protectedRouter.HandleFunc("/reviews/{id}", handlers.ModerateReview(db)).Methods("PUT")
+
+	// Coupon routes (protected)
+	protectedRouter.HandleFunc("/stores/{id}/coupons", handlers.GetStoreCoupons(db)).Methods("GET")
+	protectedRouter.HandleFunc("/stores/{id}/coupons", handlers.CreateCoupon(db)).Methods("POST")
+	protectedRouter.HandleFunc("/coupons/{id}", handlers.UpdateCoupon(db)).Methods("PUT")
+	protectedRouter.HandleFunc("/coupons/{id}", handlers.DeleteCoupon(db)).Methods("DELETE")
+
+	// Delivery zone routes (protected)
+	protectedRouter.HandleFunc("/stores/{id}/delivery-zones", handlers.GetStoreDeliveryZones(db)).Methods("GET")
+	protectedRouter.HandleFunc("/stores/{id}/delivery-zones", handlers.CreateDeliveryZone(db)).Methods("POST")
+	protectedRouter.HandleFunc("/delivery-zones/{id}", handlers.UpdateDeliveryZone(db)).Methods("PUT")
+	protectedRouter.HandleFunc("/delivery-zones/{id}", handlers.DeleteDeliveryZone(db)).Methods("DELETE")
+
+	// Courier rate routes (protected)
+	protectedRouter.HandleFunc("/stores/{id}/courier-rates", handlers.GetCourierRates(db)).Methods("GET")
+	protectedRouter.HandleFunc("/stores/{id}/courier-rates/{courier}/{service}", handlers.UploadCourierRates(db)).Methods("PUT")
+	protectedRouter.HandleFunc("/stores/{id}/courier-rates/{courier}/{service}", handlers.DeleteCourierRates(db)).Methods("DELETE")
+
+	// Trash routes (protected)
+	protectedRouter.HandleFunc("/trash", handlers.GetTrash(db)).Methods("GET")
+
+	// Admin routes
+	adminRouter := protectedRouter.PathPrefix("/admin").Subrouter()
+	adminRouter.Use(handlers.AdminMiddleware(db))
+	adminRouter.HandleFunc("/audit-log", handlers.GetAuditLog(db)).Methods("GET")
+	adminRouter.HandleFunc("/reviews/{id}/remove", handlers.RemoveReview(db)).Methods("POST")
+
+	return router
+}
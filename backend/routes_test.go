@@ -0,0 +1,55 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+
+	"wacatalogue/backend/handlers"
+)
+
+// TestOpenAPICoversRoutes fails when a registered route is missing from the
+// OpenAPI document, or when the document describes a route that does not exist
+func TestOpenAPICoversRoutes(t *testing.T) {
+	router := newRouter(nil, nil)
+	paths := handlers.OpenAPISpec()["paths"].(map[string]interface{})
+
+	registered := map[string]bool{}
+	err := router.Walk(func(route *mux.Route, _ *mux.Router, _ []*mux.Route) error {
+		path, err := route.GetPathTemplate()
+		if err != nil {
+			return nil
+		}
+		methods, err := route.GetMethods()
+		if err != nil {
+			return nil // subrouter prefix, not an endpoint
+		}
+
+		for _, method := range methods {
+			method = strings.ToLower(method)
+			registered[method+" "+path] = true
+
+			item, ok := paths[path].(map[string]interface{})
+			if !ok {
+				t.Errorf("route %s %s is missing from the OpenAPI spec", strings.ToUpper(method), path)
+				continue
+			}
+			if _, ok := item[method]; !ok {
+				t.Errorf("route %s %s is missing from the OpenAPI spec", strings.ToUpper(method), path)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("failed to walk routes: %v", err)
+	}
+
+	for path, item := range paths {
+		for method := range item.(map[string]interface{}) {
+			if !registered[method+" "+path] {
+				t.Errorf("OpenAPI spec documents %s %s but no such route is registered", strings.ToUpper(method), path)
+			}
+		}
+	}
+}
@@ -2,4 +2,4 @@
 
 # Start the backend application
 echo "Starting backend application..."
-cd backend && go run main.go
\ No newline at end of file
+cd backend && go run .
\ No newline at end of file
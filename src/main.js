@@ -2,5 +2,25 @@ import { mount } from "svelte";
 
 import App from './App.svelte';
 
+const target = document.getElementById("app");
 
-mount(App, { target: document.getElementById("app") });
\ No newline at end of file
+// Pages served by the backend embed the data they were rendered with
+const initialState = document.getElementById("initial-state");
+if (initialState) {
+  try {
+    window.__INITIAL_STATE__ = JSON.parse(initialState.textContent);
+  } catch (err) {
+    console.error('Failed to read initial state:', err);
+  }
+
+  // The app routes on the hash, so point it at the server-rendered route
+  const state = window.__INITIAL_STATE__;
+  if (state && state.route && !window.location.hash) {
+    history.replaceState(null, "", window.location.pathname + window.location.search + "#" + state.route);
+  }
+
+  // Replace the server-rendered markup with the app
+  target.textContent = "";
+}
+
+mount(App, { target });
\ No newline at end of file